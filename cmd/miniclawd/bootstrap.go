@@ -3,11 +3,12 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/yifanes/miniclawd/internal/app"
 	"github.com/yifanes/miniclawd/internal/config"
 	"github.com/yifanes/miniclawd/internal/storage"
 )
 
-// bootstrap loads config and opens the database.
+// bootstrap loads config, opens the database and resolves secret references.
 func bootstrap() (*config.Config, *storage.Database, error) {
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -23,5 +24,10 @@ func bootstrap() (*config.Config, *storage.Database, error) {
 		return nil, nil, fmt.Errorf("opening database: %w", err)
 	}
 
+	if _, err := app.ResolveSecrets(cfg, db); err != nil {
+		db.Close()
+		return nil, nil, fmt.Errorf("resolving secrets: %w", err)
+	}
+
 	return cfg, db, nil
 }
@@ -1,15 +1,21 @@
 package cmd
 
 import (
+	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/yifanes/miniclawd/internal/app"
 	"github.com/yifanes/miniclawd/internal/config"
 	"github.com/yifanes/miniclawd/internal/gateway"
 	"github.com/yifanes/miniclawd/internal/hooks"
 	"github.com/yifanes/miniclawd/internal/logging"
+	"github.com/yifanes/miniclawd/internal/storage"
+	"golang.org/x/term"
 )
 
 var version = "dev"
@@ -31,6 +37,14 @@ func Run() int {
 		return runGateway()
 	case "hooks":
 		return runHooks()
+	case "secrets":
+		return runSecrets()
+	case "audit":
+		return runAudit()
+	case "mcp-serve":
+		return runMCPServe()
+	case "memory":
+		return runMemory()
 	case "version":
 		fmt.Printf("miniclawd %s\n", version)
 		return 0
@@ -56,6 +70,10 @@ Commands:
   doctor    Run preflight diagnostics
   gateway   Manage background gateway service
   hooks     Manage hooks (list, enable, disable)
+  secrets   Manage encrypted secrets (set, list, rm)
+  audit     Show the audit log (--kind, --tool, --status, --chat, --since, --until, --limit)
+  mcp-serve Serve memory, scheduling and messaging tools over MCP (--http, --addr, --api-key)
+  memory    Inspect and curate memories (list, search, show, edit, archive, delete, export, import)
   version   Print version
   help      Show this help`)
 }
@@ -109,6 +127,16 @@ func runDoctor() int {
 		return 1
 	}
 
+	// MCP servers may need ${secret:NAME} env values to start.
+	if cfg.HasSecretRefs() {
+		if db, err := storage.Open(cfg.DBPath()); err == nil {
+			if _, err := app.ResolveSecrets(cfg, db); err != nil {
+				fmt.Fprintf(os.Stderr, "secrets: %v\n", err)
+			}
+			db.Close()
+		}
+	}
+
 	checks := app.RunDoctor(cfg)
 	fmt.Print(app.FormatChecks(checks))
 
@@ -170,3 +198,209 @@ func runHooks() int {
 	}
 	return 0
 }
+
+func runSecrets() int {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config error: %v\n", err)
+		return 1
+	}
+	// Open the database directly: bootstrap would fail on references to
+	// secrets that have not been set yet.
+	db, err := storage.Open(cfg.DBPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "database error: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	sub := "list"
+	if len(os.Args) >= 3 {
+		sub = os.Args[2]
+	}
+	store, err := app.OpenSecretStore(cfg, db, sub == "set")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "secrets error: %v\n", err)
+		return 1
+	}
+	if store == nil {
+		fmt.Printf("No secrets key yet; 'miniclawd secrets set' creates %s (or set %s).\n",
+			cfg.SecretsKeyPath(), storage.SecretsKeyEnv)
+		return 0
+	}
+
+	switch sub {
+	case "list":
+		infos, err := store.List()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "secrets error: %v\n", err)
+			return 1
+		}
+		if len(infos) == 0 {
+			fmt.Println("No secrets stored.")
+			return 0
+		}
+		for _, s := range infos {
+			fmt.Printf("  %s (updated %s)\n", s.Name, s.UpdatedAt)
+		}
+	case "set":
+		if len(os.Args) != 4 {
+			fmt.Fprintf(os.Stderr, "usage: miniclawd secrets set <name>  (the value is prompted for, or read from stdin)\n")
+			return 1
+		}
+		name := os.Args[3]
+		value, err := readSecretValue(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "reading value: %v\n", err)
+			return 1
+		}
+		if value == "" {
+			fmt.Fprintf(os.Stderr, "secret value must not be empty\n")
+			return 1
+		}
+		if err := store.Set(name, value); err != nil {
+			fmt.Fprintf(os.Stderr, "secrets error: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Secret %q saved. Reference it as ${secret:%s}.\n", name, name)
+	case "rm":
+		if len(os.Args) < 4 {
+			fmt.Fprintf(os.Stderr, "usage: miniclawd secrets rm <name>\n")
+			return 1
+		}
+		ok, err := store.Delete(os.Args[3])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "secrets error: %v\n", err)
+			return 1
+		}
+		if !ok {
+			fmt.Fprintf(os.Stderr, "secret %q not found\n", os.Args[3])
+			return 1
+		}
+		fmt.Printf("Secret %q removed.\n", os.Args[3])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown secrets subcommand: %s\n", sub)
+		return 1
+	}
+	return 0
+}
+
+// readSecretValue prompts for a secret without echoing it, or reads the
+// first line of stdin when it is not a terminal. Values are never taken from
+// the command line, where they would land in shell history and ps output.
+func readSecretValue(name string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprintf(os.Stderr, "Value for %s: ", name)
+		b, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(b), err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func runAudit() int {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	kind := fs.String("kind", "", "only this kind (tool, auth, path_policy, ...)")
+	tool := fs.String("tool", "", "only this tool (or other action)")
+	status := fs.String("status", "", "only this status (ok, error, denied, ...)")
+	chat := fs.Int64("chat", 0, "only this chat ID")
+	since := fs.String("since", "24h", "start time: RFC 3339, YYYY-MM-DD, or a duration ago like 2h or 7d")
+	until := fs.String("until", "", "end time, same formats as --since")
+	limit := fs.Int("limit", 100, "maximum entries")
+	asJSON := fs.Bool("json", false, "print one JSON object per line")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		return 1
+	}
+
+	f := storage.AuditFilter{Kind: *kind, Action: *tool, Status: *status, Limit: *limit}
+	if *chat != 0 {
+		f.ChatID = chat
+	}
+	var err error
+	if f.Since, err = storage.ParseTimeBound(*since); err != nil {
+		fmt.Fprintf(os.Stderr, "--since: %v\n", err)
+		return 1
+	}
+	if f.Until, err = storage.ParseTimeBound(*until); err != nil {
+		fmt.Fprintf(os.Stderr, "--until: %v\n", err)
+		return 1
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config error: %v\n", err)
+		return 1
+	}
+	db, err := storage.Open(cfg.DBPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "database error: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	logs, err := db.QueryAuditLogs(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audit error: %v\n", err)
+		return 1
+	}
+	if len(logs) == 0 {
+		fmt.Println("No audit entries.")
+		return 0
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, l := range logs {
+		if *asJSON {
+			enc.Encode(l)
+			continue
+		}
+		line := fmt.Sprintf("%s  %-11s %-7s %-22s %s", l.CreatedAt, l.Kind, l.Status, l.Actor, l.Action)
+		if l.DurationMs != nil {
+			line += fmt.Sprintf(" (%dms)", *l.DurationMs)
+		}
+		if l.ErrorType != nil {
+			line += " [" + *l.ErrorType + "]"
+		}
+		if l.Target != nil {
+			line += " " + *l.Target
+		}
+		if l.Detail != nil {
+			line += "  " + *l.Detail
+		}
+		fmt.Println(line)
+	}
+	return 0
+}
+
+func runMCPServe() int {
+	fs := flag.NewFlagSet("mcp-serve", flag.ContinueOnError)
+	useHTTP := fs.Bool("http", false, "serve Streamable HTTP at /mcp instead of stdio")
+	addr := fs.String("addr", "", "HTTP listen address (default mcp_serve.host:port)")
+	apiKey := fs.String("api-key", os.Getenv("MINICLAWD_API_KEY"), "API key for stdio clients (default $MINICLAWD_API_KEY)")
+	if err := fs.Parse(os.Args[2:]); err != nil {
+		return 1
+	}
+
+	cfg, db, err := bootstrap()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "startup error: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	err = app.ServeMCP(cfg, db, app.MCPServeOptions{
+		HTTP:    *useHTTP,
+		Addr:    *addr,
+		APIKey:  *apiKey,
+		Version: version,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mcp-serve error: %v\n", err)
+		return 1
+	}
+	return 0
+}
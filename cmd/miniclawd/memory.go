@@ -0,0 +1,511 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/yifanes/miniclawd/internal/app"
+	"github.com/yifanes/miniclawd/internal/config"
+	"github.com/yifanes/miniclawd/internal/storage"
+)
+
+const memoryUsage = `usage: miniclawd memory <subcommand> [flags]
+
+  list                 List memories (--chat, --global, --category, --archived, --limit, --json)
+  search <query>       Search memories (--chat, --archived, --limit, --json)
+  show <id>            Show a memory with its supersede history
+  edit <id>            Edit a memory (--content, --category, --confidence; opens $EDITOR without --content)
+  archive <id>...      Archive memories
+  delete <id>...       Delete memories
+  export               Export memories (--format jsonl|md, --out, --chat, --global, --archived)
+  import [FILE]        Import an export (--format, --chat, --global), or a notes folder with --notes DIR`
+
+func runMemory() int {
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, memoryUsage)
+		return 1
+	}
+	sub, args := os.Args[2], os.Args[3:]
+	var run func(*storage.Database, []string) int
+	switch sub {
+	case "list":
+		run = runMemoryList
+	case "search":
+		run = runMemorySearch
+	case "show":
+		run = runMemoryShow
+	case "edit":
+		run = runMemoryEdit
+	case "archive", "delete":
+		run = func(db *storage.Database, args []string) int { return runMemoryRemove(db, sub, args) }
+	case "export":
+		run = runMemoryExport
+	case "import":
+		run = runMemoryImport
+	case "help", "-h", "--help":
+		fmt.Println(memoryUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown memory subcommand: %s\n", sub)
+		fmt.Fprintln(os.Stderr, memoryUsage)
+		return 1
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config error: %v\n", err)
+		return 1
+	}
+	db, err := storage.Open(cfg.DBPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "database error: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+	return run(db, args)
+}
+
+// memoryScope adds the --chat and --global flags shared by several
+// subcommands.
+type memoryScope struct {
+	chat   *int64
+	global *bool
+}
+
+func addMemoryScope(fs *flag.FlagSet) memoryScope {
+	return memoryScope{
+		chat:   fs.Int64("chat", 0, "only this chat ID"),
+		global: fs.Bool("global", false, "only global memories"),
+	}
+}
+
+// chatID returns the --chat value, or nil if unset.
+func (s memoryScope) chatID() *int64 {
+	if *s.chat == 0 {
+		return nil
+	}
+	return s.chat
+}
+
+func (s memoryScope) validate() bool {
+	if *s.chat != 0 && *s.global {
+		fmt.Fprintln(os.Stderr, "--chat and --global are mutually exclusive")
+		return false
+	}
+	return true
+}
+
+func runMemoryList(db *storage.Database, args []string) int {
+	fs := flag.NewFlagSet("memory list", flag.ContinueOnError)
+	scope := addMemoryScope(fs)
+	category := fs.String("category", "", "only this category (PROFILE, KNOWLEDGE, EVENT)")
+	archived := fs.Bool("archived", false, "include archived memories")
+	limit := fs.Int("limit", 100, "maximum memories (0 for all)")
+	asJSON := fs.Bool("json", false, "print one JSON object per line")
+	if err := fs.Parse(args); err != nil || !scope.validate() {
+		return 1
+	}
+
+	mems, err := db.ListMemories(storage.MemoryFilter{
+		ChatID:          scope.chatID(),
+		Global:          *scope.global,
+		Category:        strings.ToUpper(*category),
+		IncludeArchived: *archived,
+		Limit:           *limit,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "memory error: %v\n", err)
+		return 1
+	}
+	printMemories(mems, *asJSON)
+	return 0
+}
+
+func runMemorySearch(db *storage.Database, args []string) int {
+	fs := flag.NewFlagSet("memory search", flag.ContinueOnError)
+	chat := fs.Int64("chat", 0, "search this chat's and global memories (default: all chats)")
+	archived := fs.Bool("archived", false, "include archived memories")
+	limit := fs.Int("limit", 20, "maximum results")
+	asJSON := fs.Bool("json", false, "print one JSON object per line")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	query := strings.Join(fs.Args(), " ")
+	if strings.TrimSpace(query) == "" {
+		fmt.Fprintln(os.Stderr, "usage: miniclawd memory search [flags] <query>")
+		return 1
+	}
+
+	mems, err := db.SearchMemoriesWithOptions(*chat, query, *limit, *archived, *chat == 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "memory error: %v\n", err)
+		return 1
+	}
+	printMemories(mems, *asJSON)
+	return 0
+}
+
+func printMemories(mems []storage.Memory, asJSON bool) {
+	if len(mems) == 0 {
+		if !asJSON {
+			fmt.Println("No memories.")
+		}
+		return
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, m := range mems {
+		if asJSON {
+			enc.Encode(m)
+			continue
+		}
+		scope := "global"
+		if m.ChatID != nil {
+			scope = fmt.Sprintf("chat %d", *m.ChatID)
+		}
+		flags := ""
+		if m.IsArchived {
+			flags = " [archived]"
+		}
+		content := strings.Join(strings.Fields(m.Content), " ")
+		if r := []rune(content); len(r) > 100 {
+			content = string(r[:100]) + "…"
+		}
+		fmt.Printf("%6d  %-9s %-12s %.2f%s  %s\n", m.ID, m.Category, scope, m.Confidence, flags, content)
+	}
+}
+
+// memoryID parses the single ID argument of show and edit.
+func memoryID(fs *flag.FlagSet) (int64, bool) {
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "usage: miniclawd %s [flags] <id>\n", fs.Name())
+		return 0, false
+	}
+	id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid memory ID %q\n", fs.Arg(0))
+		return 0, false
+	}
+	return id, true
+}
+
+func runMemoryShow(db *storage.Database, args []string) int {
+	fs := flag.NewFlagSet("memory show", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	id, ok := memoryID(fs)
+	if !ok {
+		return 1
+	}
+	m, err := db.GetMemoryByID(id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "memory error: %v\n", err)
+		return 1
+	}
+	if m == nil {
+		fmt.Fprintf(os.Stderr, "memory %d not found\n", id)
+		return 1
+	}
+
+	fmt.Printf("ID:          %d\n", m.ID)
+	if m.ChatID != nil {
+		fmt.Printf("Scope:       chat %d\n", *m.ChatID)
+		if ch, ext, err := db.GetChatExternalID(*m.ChatID); err == nil && ch != "" {
+			fmt.Printf("Chat:        %s %s\n", ch, ext)
+		}
+	} else {
+		fmt.Printf("Scope:       global\n")
+	}
+	fmt.Printf("Category:    %s\n", m.Category)
+	fmt.Printf("Confidence:  %.2f\n", m.Confidence)
+	fmt.Printf("Source:      %s\n", m.Source)
+	fmt.Printf("Created:     %s\n", m.CreatedAt)
+	fmt.Printf("Updated:     %s\n", m.UpdatedAt)
+	fmt.Printf("Last seen:   %s\n", m.LastSeenAt)
+	if m.IsArchived {
+		archivedAt := ""
+		if m.ArchivedAt != nil {
+			archivedAt = *m.ArchivedAt
+		}
+		fmt.Printf("Archived:    %s\n", archivedAt)
+	}
+	if m.EmbeddingModel != nil {
+		fmt.Printf("Embedding:   %s\n", *m.EmbeddingModel)
+	}
+
+	edges, err := db.GetSupersedeEdges(id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "memory error: %v\n", err)
+		return 1
+	}
+	for _, e := range edges {
+		reason := ""
+		if e.Reason != nil {
+			reason = " (" + *e.Reason + ")"
+		}
+		if e.ToMemoryID == id {
+			fmt.Printf("Supersedes:  %d at %s%s\n", e.FromMemoryID, e.CreatedAt, reason)
+		} else {
+			fmt.Printf("Superseded:  by %d at %s%s\n", e.ToMemoryID, e.CreatedAt, reason)
+		}
+	}
+	fmt.Printf("\n%s\n", m.Content)
+	return 0
+}
+
+func runMemoryEdit(db *storage.Database, args []string) int {
+	fs := flag.NewFlagSet("memory edit", flag.ContinueOnError)
+	content := fs.String("content", "", "new content (default: edit in $EDITOR)")
+	category := fs.String("category", "", "new category (PROFILE, KNOWLEDGE, EVENT)")
+	confidence := fs.Float64("confidence", 0, "new confidence, 0 to 1")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	id, ok := memoryID(fs)
+	if !ok {
+		return 1
+	}
+	if *confidence < 0 || *confidence > 1 {
+		fmt.Fprintln(os.Stderr, "--confidence must be between 0 and 1")
+		return 1
+	}
+	if *category != "" && !validMemoryCategory(*category) {
+		fmt.Fprintf(os.Stderr, "--category must be one of %s\n", strings.Join(memoryCategories, ", "))
+		return 1
+	}
+	m, err := db.GetMemoryByID(id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "memory error: %v\n", err)
+		return 1
+	}
+	if m == nil {
+		fmt.Fprintf(os.Stderr, "memory %d not found\n", id)
+		return 1
+	}
+
+	newContent := *content
+	if newContent == "" && *category == "" && *confidence == 0 {
+		newContent, err = editInEditor(m.Content)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "edit error: %v\n", err)
+			return 1
+		}
+		if newContent == "" {
+			fmt.Fprintln(os.Stderr, "Empty content; memory left unchanged.")
+			return 1
+		}
+	}
+	if newContent == "" {
+		newContent = m.Content
+	}
+	newCategory := m.Category
+	if *category != "" {
+		newCategory = strings.ToUpper(*category)
+	}
+	newConfidence := m.Confidence
+	if *confidence > 0 {
+		newConfidence = *confidence
+	}
+	if newContent == m.Content && newCategory == m.Category && newConfidence == m.Confidence {
+		fmt.Println("No changes.")
+		return 0
+	}
+
+	if err := db.EditMemory(id, newContent, newCategory, newConfidence); err != nil {
+		fmt.Fprintf(os.Stderr, "memory error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Memory %d updated.\n", id)
+	return 0
+}
+
+// memoryCategories are the categories memories are filed under.
+var memoryCategories = []string{"PROFILE", "KNOWLEDGE", "EVENT"}
+
+func validMemoryCategory(category string) bool {
+	return slices.Contains(memoryCategories, strings.ToUpper(category))
+}
+
+// editInEditor opens text in $EDITOR (vi by default) and returns the
+// edited, trimmed text.
+func editInEditor(text string) (string, error) {
+	f, err := os.CreateTemp("", "miniclawd-memory-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(text + "\n"); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	// EDITOR may carry arguments, as in "code --wait".
+	parts := strings.Fields(editor)
+	cmd := exec.Command(parts[0], append(parts[1:], f.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w", editor, err)
+	}
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func runMemoryRemove(db *storage.Database, sub string, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: miniclawd memory %s <id>...\n", sub)
+		return 1
+	}
+	status := 0
+	for _, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid memory ID %q\n", arg)
+			status = 1
+			continue
+		}
+		m, err := db.GetMemoryByID(id)
+		if err == nil && m == nil {
+			fmt.Fprintf(os.Stderr, "memory %d not found\n", id)
+			status = 1
+			continue
+		}
+		if err == nil {
+			if sub == "archive" {
+				err = db.ArchiveMemory(id)
+			} else {
+				err = db.DeleteMemory(id)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "memory %d: %v\n", id, err)
+			status = 1
+			continue
+		}
+		if sub == "archive" {
+			fmt.Printf("Memory %d archived.\n", id)
+		} else {
+			fmt.Printf("Memory %d deleted.\n", id)
+		}
+	}
+	return status
+}
+
+func runMemoryExport(db *storage.Database, args []string) int {
+	fs := flag.NewFlagSet("memory export", flag.ContinueOnError)
+	scope := addMemoryScope(fs)
+	format := fs.String("format", "", "jsonl or md (default: from --out, else jsonl)")
+	out := fs.String("out", "", "output file (default: stdout)")
+	archived := fs.Bool("archived", false, "include archived memories")
+	if err := fs.Parse(args); err != nil || !scope.validate() {
+		return 1
+	}
+	if *format == "" {
+		*format = app.MemoryFormatFor(*out)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "export error: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	n, err := app.ExportMemories(db, storage.MemoryFilter{
+		ChatID:          scope.chatID(),
+		Global:          *scope.global,
+		IncludeArchived: *archived,
+	}, *format, w)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export error: %v\n", err)
+		return 1
+	}
+	if *out != "" {
+		fmt.Printf("Exported %d memories to %s.\n", n, *out)
+	}
+	return 0
+}
+
+func runMemoryImport(db *storage.Database, args []string) int {
+	fs := flag.NewFlagSet("memory import", flag.ContinueOnError)
+	chat := fs.Int64("chat", 0, "put every memory in this chat")
+	global := fs.Bool("global", false, "make every memory global")
+	format := fs.String("format", "", "jsonl or md (default: from the file name)")
+	notes := fs.String("notes", "", "import a folder of Markdown and text notes instead of an export")
+	category := fs.String("category", "KNOWLEDGE", "category for --notes")
+	confidence := fs.Float64("confidence", 0.8, "confidence for --notes")
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+	if *chat != 0 && *global {
+		fmt.Fprintln(os.Stderr, "--chat and --global are mutually exclusive")
+		return 1
+	}
+	opts := app.MemoryImportOptions{Global: *global}
+	if *chat != 0 {
+		opts.ChatID = chat
+	}
+
+	var records []app.MemoryRecord
+	var err error
+	switch {
+	case *notes != "" && fs.NArg() == 0:
+		if *confidence <= 0 || *confidence > 1 {
+			fmt.Fprintln(os.Stderr, "--confidence must be between 0 and 1")
+			return 1
+		}
+		if !validMemoryCategory(*category) {
+			fmt.Fprintf(os.Stderr, "--category must be one of %s\n", strings.Join(memoryCategories, ", "))
+			return 1
+		}
+		records, err = app.ReadNotes(*notes, strings.ToUpper(*category), *confidence)
+	case *notes == "" && fs.NArg() == 1:
+		path := fs.Arg(0)
+		if *format == "" {
+			*format = app.MemoryFormatFor(path)
+		}
+		var f *os.File
+		if f, err = os.Open(path); err == nil {
+			records, err = app.ReadMemoryRecords(f, *format)
+			f.Close()
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "usage: miniclawd memory import [flags] FILE, or --notes DIR")
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import error: %v\n", err)
+		return 1
+	}
+
+	res, err := app.ImportMemories(db, records, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Imported %d memories (%d duplicates, %d supersede edges, %d skipped).\n",
+		res.Imported, res.Duplicates, res.Edges, len(res.Skipped))
+	for _, s := range res.Skipped {
+		fmt.Printf("  skipped %s\n", s)
+	}
+	return 0
+}
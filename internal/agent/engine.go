@@ -9,7 +9,9 @@ import (
 
 	"github.com/yifanes/miniclawd/internal/config"
 	"github.com/yifanes/miniclawd/internal/core"
+	"github.com/yifanes/miniclawd/internal/embedding"
 	"github.com/yifanes/miniclawd/internal/llm"
+	"github.com/yifanes/miniclawd/internal/mcp"
 	"github.com/yifanes/miniclawd/internal/storage"
 	"github.com/yifanes/miniclawd/internal/tools"
 )
@@ -19,6 +21,7 @@ type AgentRequestContext struct {
 	CallerChannel string
 	ChatID        int64
 	ChatType      string
+	Profile       string // agent profile for tool policies; empty means "default"
 }
 
 // AgentDeps holds all dependencies needed by the agent engine.
@@ -28,6 +31,9 @@ type AgentDeps struct {
 	LLM       llm.LLMProvider
 	Tools     *tools.ToolRegistry
 	Skills    string // skills catalog for system prompt
+	Processes *tools.ProcessManager
+	MCP       *mcp.McpManager             // nil when no MCP servers are configured
+	Embedding embedding.EmbeddingProvider // nil when embeddings are disabled
 }
 
 // ProcessWithAgent runs the agentic loop for a user message.
@@ -42,7 +48,19 @@ func ProcessWithEvents(ctx context.Context, deps *AgentDeps, reqCtx AgentRequest
 
 	cfg := deps.Config
 
-	// Check for explicit memory command (/remember: fast path).
+	// Build auth context for tools.
+	auth := &tools.ToolAuthContext{
+		CallerChannel:  reqCtx.CallerChannel,
+		CallerChatID:   reqCtx.ChatID,
+		CallerChatType: reqCtx.ChatType,
+		Profile:        reqCtx.Profile,
+		ControlChatIDs: cfg.ControlChatIDs,
+	}
+
+	// Check for explicit memory command (/remember: fast path) and MCP prompt
+	// commands.
+	var mcpPrompt *mcpPromptCommand
+	var mcpPromptSource string
 	if overridePrompt == nil {
 		msgs, err := deps.DB.GetRecentMessages(reqCtx.ChatID, 1)
 		if err == nil && len(msgs) > 0 {
@@ -55,6 +73,14 @@ func ProcessWithEvents(ctx context.Context, deps *AgentDeps, reqCtx AgentRequest
 					return "Remembered.", nil
 				}
 			}
+			if !lastMsg.IsFromBot && deps.MCP != nil {
+				if cmd := expandMcpPrompt(ctx, deps.MCP, deps.Tools, auth, lastMsg.Content); cmd != nil {
+					if cmd.reply != "" {
+						return cmd.reply, nil
+					}
+					mcpPrompt, mcpPromptSource = cmd, lastMsg.Content
+				}
+			}
 		}
 	}
 
@@ -103,6 +129,10 @@ func ProcessWithEvents(ctx context.Context, deps *AgentDeps, reqCtx AgentRequest
 		}
 	}
 
+	if mcpPrompt != nil {
+		applyMcpPrompt(messages, mcpPromptSource, mcpPrompt)
+	}
+
 	// Add image if provided.
 	if imageData != nil && len(messages) > 0 {
 		lastIdx := len(messages) - 1
@@ -137,15 +167,13 @@ func ProcessWithEvents(ctx context.Context, deps *AgentDeps, reqCtx AgentRequest
 		}
 	}
 
-	memoryContext := BuildDBMemoryContext(deps.DB, reqCtx.ChatID, query, cfg.MemoryTokenBudget)
-	systemPrompt := BuildSystemPrompt(cfg.BotUsername, reqCtx.CallerChannel, memoryContext, reqCtx.ChatID, deps.Skills, soulContent)
-
-	// Build auth context for tools.
-	auth := &tools.ToolAuthContext{
-		CallerChannel:  reqCtx.CallerChannel,
-		CallerChatID:   reqCtx.ChatID,
-		ControlChatIDs: cfg.ControlChatIDs,
+	// AGENTS.md files and structured memories share the memory budget.
+	fileContext, fileTokens := BuildFileMemoryContext(cfg, reqCtx.ChatID, cfg.MemoryTokenBudget)
+	memoryContext := BuildDBMemoryContext(ctx, deps.DB, deps.Embedding, reqCtx.ChatID, query, cfg.MemoryTokenBudget-fileTokens)
+	if fileContext != "" {
+		memoryContext = strings.TrimSpace(fileContext + "\n" + memoryContext)
 	}
+	systemPrompt := BuildSystemPrompt(cfg.BotUsername, reqCtx.CallerChannel, memoryContext, reqCtx.ChatID, deps.Skills, soulContent)
 
 	// Log the user query being sent.
 	lastUserText := query
@@ -155,7 +183,7 @@ func ProcessWithEvents(ctx context.Context, deps *AgentDeps, reqCtx AgentRequest
 	log.Printf("[agent] chat %d: processing, %d messages, user query: %q", reqCtx.ChatID, len(messages), lastUserText)
 
 	// Agentic loop.
-	toolDefs := deps.Tools.Definitions()
+	toolDefs, toolsVersion := deps.Tools.VersionedDefinitions(auth)
 	emptyVisibleRetried := false
 
 	for iteration := 0; iteration < cfg.MaxToolIterations; iteration++ {
@@ -163,6 +191,12 @@ func ProcessWithEvents(ctx context.Context, deps *AgentDeps, reqCtx AgentRequest
 			return "", ctx.Err()
 		}
 
+		// Pick up tools registered or removed since the last iteration.
+		if v := deps.Tools.Version(); v != toolsVersion {
+			toolDefs, toolsVersion = deps.Tools.VersionedDefinitions(auth)
+			log.Printf("[agent] chat %d: tool set changed, now %d tools", reqCtx.ChatID, len(toolDefs))
+		}
+
 		if eventCh != nil {
 			eventCh <- IterationEvent(iteration)
 		}
@@ -259,7 +293,7 @@ func ProcessWithEvents(ctx context.Context, deps *AgentDeps, reqCtx AgentRequest
 			})
 
 			// Execute each tool.
-			var resultBlocks []core.ContentBlock
+			var resultBlocks, extraBlocks []core.ContentBlock
 			for _, tu := range toolUses {
 				if eventCh != nil {
 					eventCh <- ToolStartEvent(tu.Name)
@@ -283,10 +317,17 @@ func ProcessWithEvents(ctx context.Context, deps *AgentDeps, reqCtx AgentRequest
 				if eventCh != nil {
 					eventCh <- ToolResultEvent(tu.Name, result.IsError, resultPreview,
 						derefDuration(result.DurationMs), result.StatusCode, result.Bytes, result.ErrorType)
+					if result.Diff != nil {
+						eventCh <- FileDiffEvent(tu.Name, result.Diff.Path, result.Diff.Diff,
+							result.Diff.Added, result.Diff.Removed)
+					}
 				}
 
 				resultBlocks = append(resultBlocks, core.ToolResultBlock(tu.ID, result.Content, result.IsError))
+				extraBlocks = append(extraBlocks, result.Blocks...)
 			}
+			// Tool results must come first in the message.
+			resultBlocks = append(resultBlocks, extraBlocks...)
 
 			messages = append(messages, core.Message{
 				Role:    "user",
@@ -2,7 +2,7 @@ package agent
 
 // AgentEvent represents events emitted during agent processing (for SSE streaming).
 type AgentEvent struct {
-	Type       string // "iteration", "tool_start", "tool_result", "text_delta", "final_response"
+	Type       string // "iteration", "tool_start", "tool_result", "file_diff", "text_delta", "final_response"
 	Iteration  int
 	Name       string
 	IsError    bool
@@ -13,6 +13,10 @@ type AgentEvent struct {
 	ErrorType  *string
 	Delta      string
 	Text       string
+	Path       string
+	Diff       string
+	Added      int
+	Removed    int
 }
 
 func IterationEvent(iteration int) AgentEvent {
@@ -30,6 +34,10 @@ func ToolResultEvent(name string, isError bool, preview string, durationMs int64
 	}
 }
 
+func FileDiffEvent(name, path, diff string, added, removed int) AgentEvent {
+	return AgentEvent{Type: "file_diff", Name: name, Path: path, Diff: diff, Added: added, Removed: removed}
+}
+
 func TextDeltaEvent(delta string) AgentEvent {
 	return AgentEvent{Type: "text_delta", Delta: delta}
 }
@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yifanes/miniclawd/internal/config"
+	"github.com/yifanes/miniclawd/internal/tools"
+)
+
+// fileMemoryShare is the part of the memory token budget AGENTS.md files
+// may use; the rest is kept for structured memories.
+const fileMemoryShare = 2.0 / 3
+
+// agentsFile is one AGENTS.md file to inject.
+type agentsFile struct {
+	scope   string // global, chat or workspace
+	path    string // shown for workspace files
+	readVia string // tool that reads the whole file, if the model may
+	content string
+	tokens  int
+}
+
+// BuildFileMemoryContext formats the global, per-chat and chat workspace
+// AGENTS.md files for the system prompt and returns the tokens it used. Files share
+// at most fileMemoryShare of tokenBudget: small files are included whole,
+// and the rest split what is left evenly, keeping the start of each
+// truncated file.
+func BuildFileMemoryContext(cfg *config.Config, chatID int64, tokenBudget int) (string, int) {
+	files := loadAgentsFiles(cfg, chatID)
+	if len(files) == 0 || tokenBudget <= 0 {
+		return "", 0
+	}
+
+	// Fill from the smallest file up so each gets an even share of what
+	// the smaller ones left over.
+	order := make([]int, len(files))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return files[order[a]].tokens < files[order[b]].tokens })
+	allowed := make([]int, len(files))
+	remaining := int(float64(tokenBudget) * fileMemoryShare)
+	for k, i := range order {
+		share := remaining / (len(order) - k)
+		allowed[i] = min(files[i].tokens, share)
+		remaining -= allowed[i]
+	}
+
+	var sb strings.Builder
+	used := 0
+	sb.WriteString("<file_memories>\n")
+	for i, f := range files {
+		if allowed[i] <= 0 {
+			continue
+		}
+		content := f.content
+		if allowed[i] < f.tokens {
+			content = truncateAtLine(content, allowed[i]*4) +
+				fmt.Sprintf("\n[... truncated: showing about %d of %d tokens", allowed[i], f.tokens)
+			if f.readVia != "" {
+				content += "; read the whole file with " + f.readVia
+			}
+			content += "]"
+		}
+		if f.path != "" {
+			fmt.Fprintf(&sb, "<agents_md scope=%q path=%q>\n%s\n</agents_md>\n", f.scope, f.path, content)
+		} else {
+			fmt.Fprintf(&sb, "<agents_md scope=%q>\n%s\n</agents_md>\n", f.scope, content)
+		}
+		used += allowed[i]
+	}
+	if used == 0 {
+		return "", 0
+	}
+	sb.WriteString("</file_memories>")
+	return sb.String(), used
+}
+
+// loadAgentsFiles reads the non-empty AGENTS.md files for a chat: the
+// chat's own, the global one, then, with per-chat workspaces, the one in
+// the chat's working directory.
+func loadAgentsFiles(cfg *config.Config, chatID int64) []agentsFile {
+	var files []agentsFile
+	add := func(scope, path, shownPath, readVia string) {
+		content := readFileIfExists(path)
+		if content == nil {
+			return
+		}
+		files = append(files, agentsFile{
+			scope:   scope,
+			path:    shownPath,
+			readVia: readVia,
+			content: *content,
+			tokens:  max(len(*content)/4, 1),
+		})
+	}
+
+	add("chat", filepath.Join(cfg.GroupDir(chatID), "AGENTS.md"), "", `read_memory (scope "chat")`)
+	add("global", filepath.Join(cfg.RuntimeDir(), "groups", "AGENTS.md"), "", `read_memory (scope "global")`)
+
+	// Any chat can write the workspace root (bash and process_start only
+	// set the starting directory), so only the chat's own working directory
+	// is trusted, and only when it is not shared with other chats.
+	// Instructions for every chat belong in the global file above, which
+	// lives in the runtime directory that chat tools cannot reach.
+	if cfg.WorkingDirIsolation == config.IsolationShared {
+		return files
+	}
+	dir := tools.NewWorkspace(cfg.WorkingDir, string(cfg.WorkingDirIsolation)).ChatDir(chatID)
+	add("workspace", filepath.Join(dir, "AGENTS.md"), filepath.Join(filepath.Base(dir), "AGENTS.md"), "read_file")
+	return files
+}
+
+// truncateAtLine cuts s to at most maxBytes, at a line break when one is
+// reasonably close, and never inside a UTF-8 sequence.
+func truncateAtLine(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	if nl := strings.LastIndexByte(s[:cut], '\n'); nl >= cut/2 {
+		cut = nl
+	}
+	return strings.TrimRight(s[:cut], " \t\n")
+}
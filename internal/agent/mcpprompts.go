@@ -0,0 +1,143 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/yifanes/miniclawd/internal/core"
+	"github.com/yifanes/miniclawd/internal/mcp"
+	"github.com/yifanes/miniclawd/internal/tools"
+)
+
+// mcpPromptCommand is the outcome of an MCP prompt slash command: either a
+// direct reply, or a rendered prompt to send in place of the command.
+type mcpPromptCommand struct {
+	reply  string
+	text   string
+	blocks []core.ContentBlock
+}
+
+// expandMcpPrompt handles "/prompts" and "/<server>:<prompt> [key=value ...]
+// [text]". Free text fills the first argument not set by key=value. The
+// commands are gated like the mcp_list_prompts and mcp_get_prompt tools.
+// It returns nil if text is not an MCP prompt command.
+func expandMcpPrompt(ctx context.Context, m *mcp.McpManager, reg *tools.ToolRegistry, auth *tools.ToolAuthContext, text string) *mcpPromptCommand {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return nil
+	}
+	cmd := strings.TrimPrefix(fields[0], "/")
+	if i := strings.Index(cmd, "@"); i >= 0 {
+		cmd = cmd[:i] // Telegram appends @botname in groups
+	}
+
+	if cmd == "prompts" {
+		if err := reg.Authorize(auth, "mcp_list_prompts", json.RawMessage(`{}`)); err != nil {
+			return &mcpPromptCommand{reply: err.Error()}
+		}
+		prompts := m.Prompts()
+		if len(prompts) == 0 {
+			return &mcpPromptCommand{reply: "No MCP prompts available."}
+		}
+		return &mcpPromptCommand{reply: "MCP prompts:\n" + tools.FormatMcpPrompts(prompts)}
+	}
+
+	server, name, ok := strings.Cut(cmd, ":")
+	if !ok {
+		return nil
+	}
+	prompt, ok := m.Prompt(server, name)
+	if !ok {
+		return nil
+	}
+
+	args := map[string]string{}
+	var free []string
+	for _, f := range fields[1:] {
+		if k, v, ok := strings.Cut(f, "="); ok && hasPromptArg(prompt, k) {
+			args[k] = v
+			continue
+		}
+		free = append(free, f)
+	}
+	if len(free) > 0 {
+		for _, a := range prompt.Arguments {
+			if _, set := args[a.Name]; !set {
+				args[a.Name] = strings.Join(free, " ")
+				break
+			}
+		}
+	}
+	var missing []string
+	for _, a := range prompt.Arguments {
+		if _, set := args[a.Name]; a.Required && !set {
+			missing = append(missing, a.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return &mcpPromptCommand{reply: fmt.Sprintf("Missing argument(s) %s. Usage: %s",
+			strings.Join(missing, ", "), tools.FormatMcpPrompts([]mcp.Prompt{prompt}))}
+	}
+
+	input, _ := json.Marshal(map[string]any{"server": server, "name": name, "arguments": args})
+	if err := reg.Authorize(auth, "mcp_get_prompt", input); err != nil {
+		return &mcpPromptCommand{reply: err.Error()}
+	}
+	msgs, err := m.GetPrompt(ctx, server, name, args)
+	if err != nil {
+		return &mcpPromptCommand{reply: fmt.Sprintf("MCP prompt /%s:%s failed: %v", server, name, err)}
+	}
+	mixed := false
+	for _, msg := range msgs {
+		if msg.Role != "user" {
+			mixed = true
+		}
+	}
+	out := &mcpPromptCommand{}
+	var parts []string
+	for _, msg := range msgs {
+		if mixed {
+			parts = append(parts, fmt.Sprintf("[%s]\n%s", msg.Role, msg.Text))
+		} else {
+			parts = append(parts, msg.Text)
+		}
+		out.blocks = append(out.blocks, msg.Blocks...)
+	}
+	out.text = core.RedactSecrets(strings.Join(parts, "\n\n"))
+	out.blocks = core.RedactBlocks(out.blocks)
+	return out
+}
+
+func hasPromptArg(p mcp.Prompt, name string) bool {
+	for _, a := range p.Arguments {
+		if a.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// applyMcpPrompt replaces the command in the last user message with the
+// rendered prompt.
+func applyMcpPrompt(messages []core.Message, command string, p *mcpPromptCommand) {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != "user" {
+			continue
+		}
+		text := messages[i].Content.Text
+		if idx := strings.LastIndex(text, command); idx >= 0 && !messages[i].Content.IsBlocks() {
+			text = text[:idx] + p.text + text[idx+len(command):]
+		} else {
+			text = p.text
+		}
+		if len(p.blocks) > 0 {
+			blocks := append([]core.ContentBlock{}, p.blocks...)
+			messages[i].Content = core.BlocksContent(append(blocks, core.TextBlock(text)))
+		} else {
+			messages[i].Content = core.TextContent(text)
+		}
+		return
+	}
+}
@@ -1,62 +1,125 @@
 package agent
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/yifanes/miniclawd/internal/embedding"
 	"github.com/yifanes/miniclawd/internal/storage"
 )
 
+// Weights for hybrid retrieval: relevance blends vector similarity with
+// keyword overlap, and confidence adds a smaller boost.
+const (
+	vectorWeight      = 0.7
+	keywordWeight     = 0.3
+	confidenceWeight  = 0.2
+	queryEmbedTimeout = 5 * time.Second
+)
+
+// Candidate pools merged before ranking: the most recent memories, the best
+// full-text matches, and the nearest embeddings.
+const (
+	recencyCandidates = 100
+	keywordCandidates = 50
+	vectorCandidates  = 50
+)
+
 // BuildDBMemoryContext retrieves memories from the database and formats them
-// for injection into the system prompt, respecting the token budget.
-func BuildDBMemoryContext(db *storage.Database, chatID int64, query string, tokenBudget int) string {
+// for injection into the system prompt, respecting the token budget. With an
+// embedding provider, memories are ranked by vector similarity to the query
+// combined with keyword overlap; without one, or if the query cannot be
+// embedded, by keyword overlap alone. Candidates are the most recent
+// memories plus the best keyword and vector matches among all of the chat's
+// and global memories.
+func BuildDBMemoryContext(ctx context.Context, db *storage.Database, embedder embedding.EmbeddingProvider, chatID int64, query string, tokenBudget int) string {
 	if db == nil || tokenBudget <= 0 {
 		return ""
 	}
 
 	// Fetch candidate memories.
-	memories, err := db.GetMemoriesForContext(chatID, 100)
-	if err != nil || len(memories) == 0 {
+	memories, err := db.GetMemoriesForContext(chatID, recencyCandidates)
+	if err != nil {
+		return ""
+	}
+	seen := make(map[int64]bool, len(memories))
+	for _, m := range memories {
+		seen[m.ID] = true
+	}
+	add := func(ms []storage.Memory) {
+		for _, m := range ms {
+			if !seen[m.ID] && !m.IsArchived && m.Confidence >= storage.ContextMinConfidence {
+				seen[m.ID] = true
+				memories = append(memories, m)
+			}
+		}
+	}
+	if strings.TrimSpace(query) != "" {
+		if ms, err := db.SearchMemories(chatID, query, keywordCandidates); err == nil {
+			add(ms)
+		}
+	}
+
+	method := "keyword"
+	vectors, queryVec := memoryVectors(ctx, db, embedder, chatID, query)
+	if queryVec != nil && len(vectors) > 0 {
+		method = "hybrid"
+		if ms, err := db.GetMemoriesByIDs(nearestMemories(vectors, queryVec, seen, vectorCandidates)); err == nil {
+			add(ms)
+		} else {
+			log.Printf("[memory] loading vector matches: %v", err)
+		}
+	}
+	if len(memories) == 0 {
 		return ""
 	}
+	// Ties in score keep the most recently updated first.
+	sort.SliceStable(memories, func(i, j int) bool { return memories[i].UpdatedAt > memories[j].UpdatedAt })
 
-	// Score and rank memories by keyword relevance.
+	// Score and rank memories.
 	type scored struct {
 		mem   storage.Memory
 		score float64
 	}
 	var candidates []scored
 
-	queryLower := strings.ToLower(query)
-	queryWords := strings.Fields(queryLower)
+	var queryWords []string
+	for _, w := range strings.Fields(strings.ToLower(query)) {
+		if len(w) >= 3 {
+			queryWords = append(queryWords, w)
+		}
+	}
 
 	for _, m := range memories {
-		score := m.Confidence
-		contentLower := strings.ToLower(m.Content)
-
-		// Keyword scoring: boost memories that match query terms.
-		for _, w := range queryWords {
-			if len(w) >= 3 && strings.Contains(contentLower, w) {
-				score += 0.3
+		keyword := 0.0
+		if len(queryWords) > 0 {
+			contentLower := strings.ToLower(m.Content)
+			for _, w := range queryWords {
+				if strings.Contains(contentLower, w) {
+					keyword++
+				}
 			}
+			keyword /= float64(len(queryWords))
 		}
 
-		// Boost higher-confidence memories.
-		if m.Confidence >= 0.8 {
-			score += 0.2
+		relevance := keyword
+		if method == "hybrid" {
+			// Memories not yet embedded compete on keywords alone.
+			relevance = keywordWeight * keyword
+			if v, ok := vectors[m.ID]; ok {
+				relevance += vectorWeight * max(embedding.Cosine(queryVec, v), 0)
+			}
 		}
 
-		candidates = append(candidates, scored{mem: m, score: score})
+		candidates = append(candidates, scored{mem: m, score: relevance + confidenceWeight*m.Confidence})
 	}
 
-	// Sort by score descending.
-	for i := 0; i < len(candidates)-1; i++ {
-		for j := i + 1; j < len(candidates); j++ {
-			if candidates[j].score > candidates[i].score {
-				candidates[i], candidates[j] = candidates[j], candidates[i]
-			}
-		}
-	}
+	// Sort by score descending; ties keep the most recently updated first.
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
 
 	// Select memories within token budget (estimate ~4 chars per token).
 	var selected []storage.Memory
@@ -80,7 +143,7 @@ func BuildDBMemoryContext(db *storage.Database, chatID int64, query string, toke
 	}
 
 	// Log injection for observability.
-	db.LogMemoryInjection(chatID, "keyword", len(candidates), selectedCount, omittedCount, tokensUsed)
+	db.LogMemoryInjection(chatID, method, len(candidates), selectedCount, omittedCount, tokensUsed)
 
 	// Format as XML.
 	var sb strings.Builder
@@ -96,3 +159,49 @@ func BuildDBMemoryContext(db *storage.Database, chatID int64, query string, toke
 
 	return sb.String()
 }
+
+// memoryVectors embeds the query and loads the stored embeddings of every
+// memory eligible for chatID's context. It returns a nil query vector when
+// vector retrieval is not possible.
+func memoryVectors(ctx context.Context, db *storage.Database, embedder embedding.EmbeddingProvider, chatID int64, query string) (map[int64][]float32, []float32) {
+	if embedder == nil || strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+	vectors, err := db.GetContextMemoryEmbeddings(chatID, embedder.ModelName())
+	if err != nil {
+		log.Printf("[memory] loading embeddings: %v", err)
+		return nil, nil
+	}
+	if len(vectors) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, queryEmbedTimeout)
+	defer cancel()
+	out, err := embedder.Embed(ctx, []string{query})
+	if err != nil || len(out) != 1 {
+		log.Printf("[memory] embedding query, falling back to keywords: %v", err)
+		return nil, nil
+	}
+	return vectors, out[0]
+}
+
+// nearestMemories returns the ids of the k vectors most similar to queryVec,
+// leaving out those already in skip.
+func nearestMemories(vectors map[int64][]float32, queryVec []float32, skip map[int64]bool, k int) []int64 {
+	type hit struct {
+		id  int64
+		sim float64
+	}
+	var hits []hit
+	for id, v := range vectors {
+		if !skip[id] {
+			hits = append(hits, hit{id, embedding.Cosine(queryVec, v)})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].sim > hits[j].sim })
+	var ids []int64
+	for _, h := range hits[:min(k, len(hits))] {
+		ids = append(ids, h.id)
+	}
+	return ids
+}
@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yifanes/miniclawd/internal/storage"
+)
+
+// stubEmbedder maps known texts to fixed vectors; anything else is
+// orthogonal to them.
+type stubEmbedder map[string][]float32
+
+func (s stubEmbedder) ModelName() string { return "stub" }
+func (s stubEmbedder) Dimension() int    { return 2 }
+func (s stubEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		if v, ok := s[t]; ok {
+			out[i] = v
+		} else {
+			out[i] = []float32{0, 1}
+		}
+	}
+	return out, nil
+}
+
+func TestBuildDBMemoryContextHybrid(t *testing.T) {
+	db, err := storage.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	const (
+		query    = "where does the user live"
+		semantic = "User resides in Paris"
+		keyword  = "The live demo is on Friday"
+	)
+	embedder := stubEmbedder{query: {1, 0}, semantic: {1, 0}, keyword: {0, 1}}
+	for _, content := range []string{semantic, keyword} {
+		id, err := db.InsertMemoryWithMetadata(nil, content, "KNOWLEDGE", "tool", 0.8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vec, _ := embedder.Embed(context.Background(), []string{content})
+		if err := db.SaveMemoryEmbedding(id, content, embedder.ModelName(), vec[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Keywords alone prefer the memory sharing a word with the query ...
+	out := BuildDBMemoryContext(context.Background(), db, nil, 1, query, 1000)
+	if strings.Index(out, keyword) > strings.Index(out, semantic) {
+		t.Errorf("keyword ranking put the semantic match first:\n%s", out)
+	}
+	// ... while hybrid ranking lets the vector match win.
+	out = BuildDBMemoryContext(context.Background(), db, embedder, 1, query, 1000)
+	if i := strings.Index(out, semantic); i < 0 || i > strings.Index(out, keyword) {
+		t.Errorf("hybrid ranking did not put the semantic match first:\n%s", out)
+	}
+}
+
+func TestBuildDBMemoryContextLooksPastRecent(t *testing.T) {
+	db, err := storage.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	const (
+		query    = "where does the user live"
+		semantic = "Resides in Paris"
+		keyword  = "The live demo is on Friday"
+	)
+	embedder := stubEmbedder{query: {1, 0}, semantic: {1, 0}}
+	id, err := db.InsertMemoryWithMetadata(nil, semantic, "PROFILE", "tool", 0.8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveMemoryEmbedding(id, semantic, embedder.ModelName(), []float32{1, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.InsertMemoryWithMetadata(nil, keyword, "KNOWLEDGE", "tool", 0.8); err != nil {
+		t.Fatal(err)
+	}
+
+	// Push both out of the most recent memories.
+	time.Sleep(1100 * time.Millisecond)
+	for i := range recencyCandidates {
+		if _, err := db.InsertMemoryWithMetadata(nil, fmt.Sprintf("filler %d", i), "KNOWLEDGE", "tool", 0.8); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := BuildDBMemoryContext(context.Background(), db, nil, 1, query, 10000)
+	if !strings.Contains(out, keyword) || strings.Contains(out, semantic) {
+		t.Errorf("keyword retrieval missed the older match:\n%s", out)
+	}
+	out = BuildDBMemoryContext(context.Background(), db, embedder, 1, query, 10000)
+	if !strings.Contains(out, semantic) {
+		t.Errorf("vector retrieval missed the older match:\n%s", out)
+	}
+}
@@ -56,6 +56,9 @@ func RunDoctor(cfg *config.Config) []Check {
 	checks = append(checks, checkBinary("git"))
 	checks = append(checks, checkBinary("agent-browser"))
 
+	// Start each MCP server once.
+	checks = append(checks, checkMCPServers(cfg)...)
+
 	return checks
 }
 
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/yifanes/miniclawd/internal/config"
+	"github.com/yifanes/miniclawd/internal/core"
+	"github.com/yifanes/miniclawd/internal/mcp"
+	"github.com/yifanes/miniclawd/internal/tools"
+)
+
+// StartMCP starts the configured MCP servers and registers each of their
+// tools as mcp_<server>_<tool>, plus generic resource and prompt tools when
+// any server offers them. Servers stay supervised until ctx ends, and their
+// tools are re-synced as they change. It returns nil when no servers are
+// enabled.
+func StartMCP(ctx context.Context, cfg *config.Config, reg *tools.ToolRegistry) *mcp.McpManager {
+	servers := mcpServerConfigs(cfg)
+	if len(servers) == 0 {
+		return nil
+	}
+	mgr := mcp.NewMcpManager(servers)
+	mgr.Initialize(ctx)
+
+	bridges := &mcpBridges{reg: reg, mgr: mgr, names: make(map[string][]string)}
+	for _, name := range mgr.ServerNames() {
+		bridges.sync(name, mgr.Server(name).Tools())
+	}
+	mgr.OnToolsChanged(bridges.sync)
+	mgr.Supervise(ctx)
+	if mgr.HasResources() {
+		reg.Register(tools.NewMcpListResourcesTool(mgr))
+		reg.Register(tools.NewMcpReadResourceTool(mgr))
+	}
+	if len(mgr.Prompts()) > 0 {
+		reg.Register(tools.NewMcpListPromptsTool(mgr))
+		reg.Register(tools.NewMcpGetPromptTool(mgr))
+	}
+	return mgr
+}
+
+// mcpBridges keeps the registry's bridge tools in step with each server's
+// tool list as servers restart or report list changes.
+type mcpBridges struct {
+	reg *tools.ToolRegistry
+	mgr *mcp.McpManager
+
+	mu    sync.Mutex
+	names map[string][]string // server -> registered tool names
+}
+
+func (b *mcpBridges) sync(server string, defs []core.ToolDefinition) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	owned := make(map[string]bool)
+	for _, name := range b.names[server] {
+		owned[name] = true
+	}
+	var names []string
+	keep := make(map[string]bool)
+	for _, def := range defs {
+		t := tools.NewMcpBridgeTool(server, def.Name, def, b.mgr)
+		name := t.Name()
+		existing, ok := b.reg.Get(name)
+		if ok && !owned[name] {
+			log.Printf("[mcp] server %q: tool %q clashes with existing tool %s, skipped", server, def.Name, name)
+			continue
+		}
+		names = append(names, name)
+		keep[name] = true
+		if ok && sameDefinition(existing.Definition(), t.Definition()) {
+			continue
+		}
+		b.reg.Register(t)
+	}
+	for name := range owned {
+		if !keep[name] {
+			b.reg.Unregister(name)
+		}
+	}
+	b.names[server] = names
+}
+
+func sameDefinition(a, b core.ToolDefinition) bool {
+	return a.Name == b.Name && a.Description == b.Description && bytes.Equal(a.InputSchema, b.InputSchema)
+}
+
+// mcpServerConfigs maps the enabled mcp_servers onto the manager's config.
+func mcpServerConfigs(cfg *config.Config) []mcp.McpServerConfig {
+	var out []mcp.McpServerConfig
+	for _, name := range cfg.MCPServerNames() {
+		s := cfg.MCPServers[name]
+		out = append(out, mcp.McpServerConfig{
+			Name:        name,
+			Transport:   s.Transport,
+			Command:     s.Command,
+			Args:        s.Args,
+			Env:         s.Env,
+			Cwd:         s.Cwd,
+			URL:         s.URL,
+			Headers:     s.Headers,
+			BearerToken: s.BearerToken,
+			TimeoutSecs: s.TimeoutSecs,
+		})
+	}
+	return out
+}
+
+// checkMCPServers starts each MCP server once and reports whether it came
+// up and how many tools it offers.
+func checkMCPServers(cfg *config.Config) []Check {
+	servers := mcpServerConfigs(cfg)
+	if len(servers) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 45*time.Second)
+	defer cancel()
+	mgr := mcp.NewMcpManager(servers)
+	mgr.Initialize(ctx)
+	defer mgr.Close()
+
+	var checks []Check
+	for _, st := range mgr.Status() {
+		c := Check{Name: "mcp:" + st.Name}
+		if st.State == mcp.StateRunning {
+			c.Status = "ok"
+			c.Detail = fmt.Sprintf("%s, %d tool(s)", st.Transport, st.Tools)
+		} else {
+			c.Status = "fail"
+			c.Detail = fmt.Sprintf("%s: %s", st.Transport, st.Error)
+		}
+		checks = append(checks, c)
+	}
+	return checks
+}
@@ -0,0 +1,389 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/yifanes/miniclawd/internal/config"
+	"github.com/yifanes/miniclawd/internal/core"
+	"github.com/yifanes/miniclawd/internal/mcp"
+	"github.com/yifanes/miniclawd/internal/storage"
+	"github.com/yifanes/miniclawd/internal/tools"
+)
+
+// API key scopes understood by mcp-serve. A key may also carry chat:<id>
+// scopes, which limit it to those chats; without any it may act for every
+// chat and for global memories.
+const (
+	ScopeMemoryRead   = "memory:read"
+	ScopeMemoryWrite  = "memory:write"
+	ScopeChatsRead    = "chats:read"
+	ScopeSchedule     = "schedule:write"
+	ScopeMessagesSend = "messages:send"
+	ScopeAdmin        = "admin" // every scope
+
+	chatScopePrefix = "chat:"
+)
+
+// mcpToolScopes is the scope each exposable tool needs. Other tools listed
+// in mcp_serve.tools need admin.
+var mcpToolScopes = map[string]string{
+	"structured_memory_search": ScopeMemoryRead,
+	"structured_memory_update": ScopeMemoryWrite,
+	"structured_memory_delete": ScopeMemoryWrite,
+	"read_memory":              ScopeMemoryRead,
+	"write_memory":             ScopeMemoryWrite,
+	"export_chat":              ScopeChatsRead,
+	"search_history":           ScopeChatsRead,
+	"schedule_task":            ScopeSchedule,
+	"list_scheduled_tasks":     ScopeSchedule,
+	"pause_scheduled_task":     ScopeSchedule,
+	"resume_scheduled_task":    ScopeSchedule,
+	"cancel_scheduled_task":    ScopeSchedule,
+	"send_message":             ScopeMessagesSend,
+}
+
+const (
+	chatResourcePrefix       = "miniclawd://chats/"
+	memoryResourcePrefix     = "miniclawd://memories/chats/"
+	globalMemoryResourceURI  = "miniclawd://memories/global"
+	chatResourceMessageLimit = 200
+)
+
+// MCPServeOptions selects how ServeMCP listens.
+type MCPServeOptions struct {
+	HTTP    bool   // Streamable HTTP instead of stdio
+	Addr    string // host:port for HTTP; default from mcp_serve
+	APIKey  string // for stdio; HTTP clients send their own
+	Version string
+}
+
+// ServeMCP runs miniclawd as an MCP server until stdin closes (stdio) or
+// the process is interrupted (HTTP).
+func ServeMCP(cfg *config.Config, db *storage.Database, opts MCPServeOptions) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	registry, _, _ := buildChannels(cfg)
+	processes := newProcessManager(cfg)
+	defer processes.Shutdown()
+	reg := buildToolRegistry(cfg, db, &registrySender{registry: registry, db: db}, processes)
+
+	var exposed []string
+	for _, name := range cfg.MCPServe.Tools {
+		if !reg.Has(name) {
+			log.Printf("[mcp-serve] unknown tool %q in mcp_serve.tools, skipped", name)
+			continue
+		}
+		exposed = append(exposed, name)
+	}
+	log.Printf("[mcp-serve] exposing %d tool(s): %s", len(exposed), strings.Join(exposed, ", "))
+
+	transport := "stdio"
+	if opts.HTTP {
+		transport = "http"
+	}
+	serve := &mcpServe{db: db, reg: reg, tools: exposed, transport: transport}
+	srv := mcp.NewServer("miniclawd", opts.Version, serve.authenticate)
+
+	if !opts.HTTP {
+		if opts.APIKey == "" {
+			return fmt.Errorf("an API key is required: pass --api-key or set MINICLAWD_API_KEY")
+		}
+		errCh := make(chan error, 1)
+		go func() { errCh <- srv.ServeStdio(ctx, opts.APIKey, os.Stdin, os.Stdout) }()
+		select {
+		case err := <-errCh:
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	addr := opts.Addr
+	if addr == "" {
+		addr = net.JoinHostPort(cfg.MCPServe.Host, strconv.Itoa(int(cfg.MCPServe.Port)))
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", srv)
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	log.Printf("[mcp-serve] listening on http://%s/mcp", addr)
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// mcpServe authenticates MCP clients against the api_keys table.
+type mcpServe struct {
+	db        *storage.Database
+	reg       *tools.ToolRegistry
+	tools     []string
+	transport string
+}
+
+func (s *mcpServe) authenticate(key string) (mcp.ServerHandler, error) {
+	_, scopes, ok, err := s.db.ValidateAPIKeyHash(storage.HashAPIKey(key))
+	if err != nil {
+		return nil, fmt.Errorf("checking API key: %w", err)
+	}
+	if !ok {
+		detail := "invalid, expired or revoked API key"
+		if err := s.db.LogAuditEvent("auth", "mcp:"+s.transport, "api_key", nil, "failed", &detail); err != nil {
+			log.Printf("[mcp-serve] audit log write failed: %v", err)
+		}
+		return nil, errors.New(detail)
+	}
+	c := &mcpClient{serve: s, scopes: make(map[string]bool)}
+	for _, scope := range scopes {
+		if rest, ok := strings.CutPrefix(scope, chatScopePrefix); ok {
+			if chatID, err := strconv.ParseInt(rest, 10, 64); err == nil {
+				c.chats = append(c.chats, chatID)
+			}
+			continue
+		}
+		c.scopes[scope] = true
+	}
+	return c, nil
+}
+
+// mcpClient is the view of miniclawd one API key gets.
+type mcpClient struct {
+	serve  *mcpServe
+	scopes map[string]bool
+	chats  []int64 // nil: every chat
+}
+
+func (c *mcpClient) has(scope string) bool {
+	return c.scopes[ScopeAdmin] || c.scopes[scope]
+}
+
+func (c *mcpClient) canUseTool(name string) bool {
+	scope, ok := mcpToolScopes[name]
+	if !ok {
+		scope = ScopeAdmin
+	}
+	return c.has(scope)
+}
+
+func (c *mcpClient) canAccessChat(chatID int64) bool {
+	return c.chats == nil || slices.Contains(c.chats, chatID)
+}
+
+func (c *mcpClient) ListTools() []core.ToolDefinition {
+	var defs []core.ToolDefinition
+	for _, name := range c.serve.tools {
+		t, ok := c.serve.reg.Get(name)
+		if !ok || !c.canUseTool(name) {
+			continue
+		}
+		defs = append(defs, withChatParam(t.Definition()))
+	}
+	return defs
+}
+
+// withChatParam adds an optional chat_id to tools that act for the calling
+// chat, so MCP clients can say which chat they mean.
+func withChatParam(def core.ToolDefinition) core.ToolDefinition {
+	var schema map[string]any
+	if err := json.Unmarshal(def.InputSchema, &schema); err != nil {
+		return def
+	}
+	props, _ := schema["properties"].(map[string]any)
+	if props == nil {
+		props = map[string]any{}
+		schema["properties"] = props
+	}
+	if _, ok := props["chat_id"]; ok {
+		return def
+	}
+	props["chat_id"] = tools.IntProp("Chat to act for (default: global scope, or the API key's only chat)")
+	def.InputSchema, _ = json.Marshal(schema)
+	return def
+}
+
+func (c *mcpClient) CallTool(ctx context.Context, name string, args json.RawMessage) (string, bool) {
+	if !slices.Contains(c.serve.tools, name) {
+		return "unknown tool: " + name, true
+	}
+	if !c.canUseTool(name) {
+		scope := mcpToolScopes[name]
+		if scope == "" {
+			scope = ScopeAdmin
+		}
+		return fmt.Sprintf("API key lacks the %s scope needed for %s", scope, name), true
+	}
+
+	var p struct {
+		ChatID *int64 `json:"chat_id"`
+	}
+	json.Unmarshal(args, &p)
+	chatID := int64(0)
+	switch {
+	case p.ChatID != nil:
+		chatID = *p.ChatID
+	case len(c.chats) == 1:
+		chatID = c.chats[0]
+	}
+	if c.chats != nil && !slices.Contains(c.chats, chatID) {
+		if chatID == 0 {
+			return "chat_id is required for this API key", true
+		}
+		return fmt.Sprintf("API key has no access to chat %d", chatID), true
+	}
+
+	auth := &tools.ToolAuthContext{
+		CallerChannel:  "mcp",
+		CallerChatID:   chatID,
+		CallerChatType: "mcp",
+		Profile:        "mcp",
+	}
+	if c.chats == nil && c.scopes[ScopeAdmin] {
+		// Only admin keys act like a control chat; other unrestricted keys
+		// run as an ordinary chat.
+		auth.ControlChatIDs = []int64{chatID}
+	}
+	result := c.serve.reg.ExecuteWithAuth(ctx, name, args, auth)
+	return result.Content, result.IsError
+}
+
+func (c *mcpClient) ListResources(_ context.Context) ([]mcp.Resource, []mcp.ResourceTemplate, error) {
+	var resources []mcp.Resource
+	var templates []mcp.ResourceTemplate
+	if c.has(ScopeChatsRead) {
+		chats, err := c.serve.db.GetRecentChats(100)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, chat := range chats {
+			if !c.canAccessChat(chat.ChatID) {
+				continue
+			}
+			name := fmt.Sprintf("chat %d", chat.ChatID)
+			if chat.ChatTitle != nil && *chat.ChatTitle != "" {
+				name = *chat.ChatTitle
+			}
+			resources = append(resources, mcp.Resource{
+				URI:         chatResourcePrefix + strconv.FormatInt(chat.ChatID, 10),
+				Name:        name,
+				Description: fmt.Sprintf("Recent messages (%s, last active %s)", chat.ChatType, chat.LastMessageTime),
+				MimeType:    "text/markdown",
+			})
+		}
+		templates = append(templates, mcp.ResourceTemplate{
+			URITemplate: chatResourcePrefix + "{chat_id}",
+			Name:        "Chat messages",
+			Description: "Recent messages of a chat",
+			MimeType:    "text/markdown",
+		})
+	}
+	if c.has(ScopeMemoryRead) {
+		if c.chats == nil {
+			resources = append(resources, mcp.Resource{
+				URI:         globalMemoryResourceURI,
+				Name:        "Global memories",
+				Description: "Memories shared by all chats",
+				MimeType:    "text/markdown",
+			})
+		}
+		templates = append(templates, mcp.ResourceTemplate{
+			URITemplate: memoryResourcePrefix + "{chat_id}",
+			Name:        "Chat memories",
+			Description: "Active memories of a chat",
+			MimeType:    "text/markdown",
+		})
+	}
+	return resources, templates, nil
+}
+
+func (c *mcpClient) ReadResource(_ context.Context, uri string) (mcp.ResourceText, error) {
+	switch {
+	case uri == globalMemoryResourceURI:
+		if !c.has(ScopeMemoryRead) || c.chats != nil {
+			return mcp.ResourceText{}, fmt.Errorf("API key cannot read global memories")
+		}
+		return c.memories(uri, nil)
+
+	case strings.HasPrefix(uri, memoryResourcePrefix):
+		chatID, err := c.resourceChat(uri, memoryResourcePrefix, ScopeMemoryRead)
+		if err != nil {
+			return mcp.ResourceText{}, err
+		}
+		return c.memories(uri, &chatID)
+
+	case strings.HasPrefix(uri, chatResourcePrefix):
+		chatID, err := c.resourceChat(uri, chatResourcePrefix, ScopeChatsRead)
+		if err != nil {
+			return mcp.ResourceText{}, err
+		}
+		msgs, err := c.serve.db.GetRecentMessages(chatID, chatResourceMessageLimit)
+		if err != nil {
+			return mcp.ResourceText{}, err
+		}
+		var sb strings.Builder
+		for _, msg := range msgs {
+			fmt.Fprintf(&sb, "**%s** (%s)\n\n%s\n\n---\n\n", msg.SenderName, msg.Timestamp, msg.Content)
+		}
+		if len(msgs) == 0 {
+			sb.WriteString("No messages.")
+		}
+		return mcp.ResourceText{URI: uri, MimeType: "text/markdown", Text: sb.String()}, nil
+	}
+	return mcp.ResourceText{}, fmt.Errorf("unknown resource: %s", uri)
+}
+
+func (c *mcpClient) resourceChat(uri, prefix, scope string) (int64, error) {
+	chatID, err := strconv.ParseInt(strings.TrimPrefix(uri, prefix), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid chat ID in %s", uri)
+	}
+	if !c.has(scope) {
+		return 0, fmt.Errorf("API key lacks the %s scope", scope)
+	}
+	if !c.canAccessChat(chatID) {
+		return 0, fmt.Errorf("API key has no access to chat %d", chatID)
+	}
+	return chatID, nil
+}
+
+// memories renders the active memories of one chat, or the global ones
+// when chatID is nil.
+func (c *mcpClient) memories(uri string, chatID *int64) (mcp.ResourceText, error) {
+	var id int64
+	if chatID != nil {
+		id = *chatID
+	}
+	all, err := c.serve.db.GetAllMemoriesForChat(id)
+	if err != nil {
+		return mcp.ResourceText{}, err
+	}
+	var sb strings.Builder
+	for _, m := range all {
+		if m.IsArchived || (chatID == nil) != (m.ChatID == nil) {
+			continue
+		}
+		fmt.Fprintf(&sb, "- [id=%d] [%s] %s (confidence %.2f, %s)\n", m.ID, m.Category, m.Content, m.Confidence, m.Source)
+	}
+	if sb.Len() == 0 {
+		sb.WriteString("No memories.")
+	}
+	return mcp.ResourceText{URI: uri, MimeType: "text/markdown", Text: sb.String()}, nil
+}
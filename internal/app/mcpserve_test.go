@@ -0,0 +1,54 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/yifanes/miniclawd/internal/core"
+	"github.com/yifanes/miniclawd/internal/tools"
+)
+
+// authEchoTool reports whether it ran with control-chat rights.
+type authEchoTool struct{}
+
+func (authEchoTool) Name() string { return "read_memory" }
+
+func (authEchoTool) Definition() core.ToolDefinition {
+	return core.ToolDefinition{Name: "read_memory", InputSchema: json.RawMessage(`{"type":"object"}`)}
+}
+
+func (authEchoTool) Execute(_ context.Context, input json.RawMessage) tools.ToolResult {
+	auth := tools.ExtractAuthContext(input)
+	return tools.Success(strconv.FormatBool(auth != nil && auth.IsControlChat()))
+}
+
+func TestMCPClientControlChatNeedsAdmin(t *testing.T) {
+	reg := tools.NewToolRegistry()
+	reg.Register(authEchoTool{})
+	serve := &mcpServe{reg: reg, tools: []string{"read_memory"}}
+
+	tests := []struct {
+		name   string
+		scopes []string
+		chats  []int64
+		want   string
+	}{
+		{"admin", []string{ScopeAdmin}, nil, "true"},
+		{"unrestricted without admin", []string{ScopeMemoryRead}, nil, "false"},
+		{"chat restricted admin", []string{ScopeAdmin}, []int64{7}, "false"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &mcpClient{serve: serve, scopes: map[string]bool{}, chats: tc.chats}
+			for _, s := range tc.scopes {
+				c.scopes[s] = true
+			}
+			out, isErr := c.CallTool(context.Background(), "read_memory", json.RawMessage(`{"chat_id":7}`))
+			if isErr || out != tc.want {
+				t.Fatalf("CallTool = %q (error %v), want %q", out, isErr, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,606 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/yifanes/miniclawd/internal/storage"
+)
+
+// Memory export formats.
+const (
+	MemoryFormatJSONL    = "jsonl"
+	MemoryFormatMarkdown = "md"
+)
+
+// MemoryRecord is the portable form of a memory used by export and import.
+// Chat memories carry the chat's channel and external ID so they can be
+// matched to the same chat in another deployment.
+type MemoryRecord struct {
+	ID             int64            `json:"id"`
+	Content        string           `json:"content"`
+	Category       string           `json:"category"`
+	Scope          string           `json:"scope"` // "global" or "chat"
+	ChatID         *int64           `json:"chat_id,omitempty"`
+	ChatChannel    string           `json:"chat_channel,omitempty"`
+	ExternalChatID string           `json:"external_chat_id,omitempty"`
+	Confidence     float64          `json:"confidence"`
+	Source         string           `json:"source"`
+	CreatedAt      string           `json:"created_at,omitempty"`
+	UpdatedAt      string           `json:"updated_at,omitempty"`
+	LastSeenAt     string           `json:"last_seen_at,omitempty"`
+	Archived       bool             `json:"archived,omitempty"`
+	ArchivedAt     string           `json:"archived_at,omitempty"`
+	Supersedes     []SupersedeEntry `json:"supersedes,omitempty"`
+}
+
+// SupersedeEntry says a record replaced the memory with the given export ID.
+type SupersedeEntry struct {
+	ID        int64  `json:"id"`
+	Reason    string `json:"reason,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// MemoryFormatFor returns the format for a file name: Markdown for .md and
+// .markdown, JSON Lines otherwise.
+func MemoryFormatFor(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return MemoryFormatMarkdown
+	}
+	return MemoryFormatJSONL
+}
+
+// ExportMemories writes the memories matching f, with their supersede
+// edges, in format. It returns how many it wrote.
+func ExportMemories(db *storage.Database, f storage.MemoryFilter, format string, w io.Writer) (int, error) {
+	mems, err := db.ListMemories(f)
+	if err != nil {
+		return 0, err
+	}
+	edges, err := db.GetSupersedeEdges(0)
+	if err != nil {
+		return 0, err
+	}
+	supersedes := map[int64][]SupersedeEntry{}
+	for _, e := range edges {
+		entry := SupersedeEntry{ID: e.FromMemoryID, CreatedAt: e.CreatedAt}
+		if e.Reason != nil {
+			entry.Reason = *e.Reason
+		}
+		supersedes[e.ToMemoryID] = append(supersedes[e.ToMemoryID], entry)
+	}
+
+	// Oldest first, so importing preserves the original order of IDs.
+	records := make([]MemoryRecord, 0, len(mems))
+	chats := map[int64][2]string{}
+	for i := len(mems) - 1; i >= 0; i-- {
+		r := recordFromMemory(mems[i])
+		if r.ChatID != nil && r.ChatChannel == "" {
+			ids, ok := chats[*r.ChatID]
+			if !ok {
+				ch, ext, err := db.GetChatExternalID(*r.ChatID)
+				if err != nil {
+					return 0, err
+				}
+				ids = [2]string{ch, ext}
+				chats[*r.ChatID] = ids
+			}
+			r.ChatChannel, r.ExternalChatID = ids[0], ids[1]
+		}
+		r.Supersedes = supersedes[r.ID]
+		records = append(records, r)
+	}
+
+	bw := bufio.NewWriter(w)
+	switch format {
+	case MemoryFormatJSONL:
+		enc := json.NewEncoder(bw)
+		enc.SetEscapeHTML(false)
+		for _, r := range records {
+			if err := enc.Encode(r); err != nil {
+				return 0, err
+			}
+		}
+	case MemoryFormatMarkdown:
+		writeMemoriesMarkdown(bw, records)
+	default:
+		return 0, fmt.Errorf("unknown format %q (use jsonl or md)", format)
+	}
+	return len(records), bw.Flush()
+}
+
+func recordFromMemory(m storage.Memory) MemoryRecord {
+	r := MemoryRecord{
+		ID:         m.ID,
+		Content:    m.Content,
+		Category:   m.Category,
+		Scope:      "global",
+		ChatID:     m.ChatID,
+		Confidence: m.Confidence,
+		Source:     m.Source,
+		CreatedAt:  m.CreatedAt,
+		UpdatedAt:  m.UpdatedAt,
+		LastSeenAt: m.LastSeenAt,
+		Archived:   m.IsArchived,
+	}
+	if m.ChatID != nil {
+		r.Scope = "chat"
+	}
+	if m.ChatChannel != nil {
+		r.ChatChannel = *m.ChatChannel
+	}
+	if m.ExternalChatID != nil {
+		r.ExternalChatID = *m.ExternalChatID
+	}
+	if m.ArchivedAt != nil {
+		r.ArchivedAt = *m.ArchivedAt
+	}
+	return r
+}
+
+// ReadMemoryRecords parses an export in format.
+func ReadMemoryRecords(r io.Reader, format string) ([]MemoryRecord, error) {
+	switch format {
+	case MemoryFormatJSONL:
+		var records []MemoryRecord
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for line := 1; sc.Scan(); line++ {
+			text := strings.TrimSpace(sc.Text())
+			if text == "" {
+				continue
+			}
+			var rec MemoryRecord
+			if err := json.Unmarshal([]byte(text), &rec); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			records = append(records, rec)
+		}
+		return records, sc.Err()
+	case MemoryFormatMarkdown:
+		return readMemoriesMarkdown(r)
+	}
+	return nil, fmt.Errorf("unknown format %q (use jsonl or md)", format)
+}
+
+// MemoryImportOptions controls where imported memories go. With neither
+// set, each record keeps its own scope.
+type MemoryImportOptions struct {
+	ChatID *int64 // put every memory in this chat
+	Global bool   // make every memory global
+}
+
+// MemoryImportResult summarizes an import.
+type MemoryImportResult struct {
+	Imported   int
+	Duplicates int      // already present with the same content, scope and state
+	Skipped    []string // records that could not be placed, with the reason
+	Edges      int      // supersede edges added; existing ones are not repeated
+}
+
+// ImportMemories adds records to the database. Chat memories are matched
+// to chats by channel and external ID, or by chat ID when a record has no
+// channel; records whose chat is unknown are skipped. Memories already
+// present with identical content, scope and archived state are not
+// duplicated, so importing the same file twice is harmless. Supersede
+// edges between imported records are restored unless already present.
+func ImportMemories(db *storage.Database, records []MemoryRecord, opts MemoryImportOptions) (MemoryImportResult, error) {
+	var res MemoryImportResult
+	newIDs := map[int64]int64{}
+	chats := map[string]int64{}
+
+	for i, r := range records {
+		label := fmt.Sprintf("record %d", i+1)
+		if r.ID != 0 {
+			label = fmt.Sprintf("memory %d", r.ID)
+		}
+		if strings.TrimSpace(r.Content) == "" {
+			res.Skipped = append(res.Skipped, label+": empty content")
+			continue
+		}
+
+		var chatID *int64
+		switch {
+		case opts.ChatID != nil:
+			chatID = opts.ChatID
+		case opts.Global || r.Scope == "global" || (r.Scope == "" && r.ChatID == nil && r.ChatChannel == ""):
+		default:
+			id, reason, err := resolveImportChat(db, r, chats)
+			if err != nil {
+				return res, err
+			}
+			if id == 0 {
+				res.Skipped = append(res.Skipped, label+": "+reason)
+				continue
+			}
+			chatID = &id
+		}
+
+		existing, err := db.FindMemoryByContent(chatID, r.Content, r.Archived)
+		if err != nil {
+			return res, err
+		}
+		if existing != nil {
+			if r.ID != 0 {
+				newIDs[r.ID] = existing.ID
+			}
+			res.Duplicates++
+			continue
+		}
+
+		m := storage.Memory{
+			ChatID:     chatID,
+			Content:    r.Content,
+			Category:   r.Category,
+			Confidence: r.Confidence,
+			Source:     r.Source,
+			CreatedAt:  r.CreatedAt,
+			UpdatedAt:  r.UpdatedAt,
+			LastSeenAt: r.LastSeenAt,
+			IsArchived: r.Archived,
+		}
+		if m.Category == "" {
+			m.Category = "KNOWLEDGE"
+		}
+		if m.Confidence <= 0 || m.Confidence > 1 {
+			m.Confidence = 0.80
+		}
+		if m.Source == "" {
+			m.Source = "import"
+		}
+		if r.Archived && r.ArchivedAt != "" {
+			m.ArchivedAt = &r.ArchivedAt
+		}
+		if chatID != nil && r.ChatChannel != "" && opts.ChatID == nil {
+			m.ChatChannel, m.ExternalChatID = &r.ChatChannel, &r.ExternalChatID
+		}
+		id, err := db.RestoreMemory(m)
+		if err != nil {
+			return res, err
+		}
+		if r.ID != 0 {
+			newIDs[r.ID] = id
+		}
+		res.Imported++
+	}
+
+	for _, r := range records {
+		to, ok := newIDs[r.ID]
+		if !ok || r.ID == 0 {
+			continue
+		}
+		for _, s := range r.Supersedes {
+			from, ok := newIDs[s.ID]
+			if !ok {
+				continue
+			}
+			var reason *string
+			if s.Reason != "" {
+				reason = &s.Reason
+			}
+			added, err := db.AddSupersedeEdge(from, to, reason, s.CreatedAt)
+			if err != nil {
+				return res, err
+			}
+			if added {
+				res.Edges++
+			}
+		}
+	}
+	return res, nil
+}
+
+// resolveImportChat finds the chat a record belongs to. It returns 0 and a
+// reason if there is no such chat here.
+func resolveImportChat(db *storage.Database, r MemoryRecord, cache map[string]int64) (int64, string, error) {
+	if r.ChatChannel != "" {
+		key := r.ChatChannel + ":" + r.ExternalChatID
+		if id, ok := cache[key]; ok {
+			return id, "no chat " + key, nil
+		}
+		id, err := db.FindChatByExternalID(r.ChatChannel, r.ExternalChatID)
+		if err != nil {
+			return 0, "", err
+		}
+		cache[key] = id
+		return id, "no chat " + key, nil
+	}
+	if r.ChatID == nil {
+		return 0, "chat scope without a chat", nil
+	}
+	chatType, err := db.GetChatType(*r.ChatID)
+	if err != nil {
+		return 0, "", err
+	}
+	if chatType == "" {
+		return 0, fmt.Sprintf("no chat %d", *r.ChatID), nil
+	}
+	return *r.ChatID, "", nil
+}
+
+// --- Markdown ---
+
+// memoryHeading starts each memory in the Markdown format. Its metadata
+// follows as "- key: value" lines, then a blank line and the content.
+// Content lines that start with "## " are escaped with a backslash.
+const memoryHeading = "## Memory "
+
+func writeMemoriesMarkdown(w io.Writer, records []MemoryRecord) {
+	fmt.Fprintln(w, "# miniclawd memories")
+	for _, r := range records {
+		fmt.Fprintf(w, "\n%s%d\n\n", memoryHeading, r.ID)
+		field := func(key, value string) {
+			if value != "" {
+				fmt.Fprintf(w, "- %s: %s\n", key, value)
+			}
+		}
+		field("category", r.Category)
+		field("scope", r.Scope)
+		if r.ChatID != nil {
+			field("chat_id", strconv.FormatInt(*r.ChatID, 10))
+		}
+		field("chat_channel", r.ChatChannel)
+		field("external_chat_id", r.ExternalChatID)
+		field("confidence", strconv.FormatFloat(r.Confidence, 'f', -1, 64))
+		field("source", r.Source)
+		field("created_at", r.CreatedAt)
+		field("updated_at", r.UpdatedAt)
+		field("last_seen_at", r.LastSeenAt)
+		if r.Archived {
+			field("archived", "true")
+			field("archived_at", r.ArchivedAt)
+		}
+		for _, s := range r.Supersedes {
+			field("supersedes", strings.TrimRight(fmt.Sprintf("%d | %s | %s", s.ID, s.CreatedAt, s.Reason), " |"))
+		}
+		fmt.Fprintln(w)
+		for _, line := range strings.Split(r.Content, "\n") {
+			if strings.HasPrefix(line, "## ") || strings.HasPrefix(line, `\## `) {
+				line = `\` + line
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+func readMemoriesMarkdown(r io.Reader) ([]MemoryRecord, error) {
+	var records []MemoryRecord
+	var cur *MemoryRecord
+	var content []string
+	inMeta, sawMeta := false, false
+
+	flush := func() {
+		if cur != nil {
+			cur.Content = strings.TrimSpace(strings.Join(content, "\n"))
+			records = append(records, *cur)
+		}
+		cur, content = nil, nil
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+		if rest, ok := strings.CutPrefix(text, memoryHeading); ok {
+			flush()
+			cur = &MemoryRecord{}
+			if id, err := strconv.ParseInt(strings.TrimSpace(rest), 10, 64); err == nil {
+				cur.ID = id
+			}
+			inMeta, sawMeta = true, false
+			continue
+		}
+		if cur == nil {
+			continue // title and preamble
+		}
+		if inMeta {
+			if strings.TrimSpace(text) == "" {
+				inMeta = !sawMeta
+				continue
+			}
+			if item, ok := strings.CutPrefix(text, "- "); ok {
+				key, value, _ := strings.Cut(item, ":")
+				if ok, err := setMarkdownField(cur, strings.TrimSpace(key), strings.TrimSpace(value)); err != nil {
+					return nil, fmt.Errorf("line %d: %w", line, err)
+				} else if ok {
+					sawMeta = true
+					continue
+				}
+			}
+			inMeta = false
+		}
+		if strings.HasPrefix(text, `\## `) || strings.HasPrefix(text, `\\## `) {
+			text = text[1:]
+		}
+		content = append(content, text)
+	}
+	flush()
+	return records, sc.Err()
+}
+
+// setMarkdownField sets a metadata field, reporting false if key is not
+// one.
+func setMarkdownField(r *MemoryRecord, key, value string) (bool, error) {
+	switch key {
+	case "category":
+		r.Category = value
+	case "scope":
+		r.Scope = value
+	case "chat_id":
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return true, fmt.Errorf("chat_id: %w", err)
+		}
+		r.ChatID = &id
+	case "chat_channel":
+		r.ChatChannel = value
+	case "external_chat_id":
+		r.ExternalChatID = value
+	case "confidence":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return true, fmt.Errorf("confidence: %w", err)
+		}
+		r.Confidence = f
+	case "source":
+		r.Source = value
+	case "created_at":
+		r.CreatedAt = value
+	case "updated_at":
+		r.UpdatedAt = value
+	case "last_seen_at":
+		r.LastSeenAt = value
+	case "archived":
+		r.Archived = value == "true"
+	case "archived_at":
+		r.ArchivedAt = value
+	case "supersedes":
+		parts := strings.SplitN(value, "|", 3)
+		id, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+		if err != nil {
+			return true, fmt.Errorf("supersedes: %w", err)
+		}
+		s := SupersedeEntry{ID: id}
+		if len(parts) > 1 {
+			s.CreatedAt = strings.TrimSpace(parts[1])
+		}
+		if len(parts) > 2 {
+			s.Reason = strings.TrimSpace(parts[2])
+		}
+		r.Supersedes = append(r.Supersedes, s)
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// --- Notes folders ---
+
+// ReadNotes turns a folder of Markdown and text notes into memories: every
+// list item and every paragraph becomes one memory, prefixed with the
+// heading it sits under, and fenced code blocks stay whole. The source of
+// each memory records the file it came from.
+func ReadNotes(dir, category string, confidence float64) ([]MemoryRecord, error) {
+	var records []MemoryRecord
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".md", ".markdown", ".txt":
+		default:
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			rel = filepath.Base(path)
+		}
+		for _, note := range splitNotes(string(data)) {
+			records = append(records, MemoryRecord{
+				Content:    note,
+				Category:   category,
+				Scope:      "global",
+				Confidence: confidence,
+				Source:     "notes:" + filepath.ToSlash(rel),
+			})
+		}
+		return nil
+	})
+	return records, err
+}
+
+// splitNotes splits a note file into list items and paragraphs, skipping
+// YAML front matter.
+func splitNotes(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		if end := strings.Index(rest, "\n---\n"); end >= 0 {
+			text = rest[end+5:]
+		}
+	}
+
+	var notes []string
+	var heading string
+	var cur []string
+	inFence := false
+
+	flush := func() {
+		note := strings.TrimSpace(strings.Join(cur, "\n"))
+		cur = nil
+		if len([]rune(note)) < 3 || !strings.ContainsFunc(note, func(r rune) bool {
+			return unicode.IsLetter(r) || unicode.IsDigit(r)
+		}) {
+			return
+		}
+		if heading != "" {
+			note = heading + ": " + note
+		}
+		notes = append(notes, note)
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			cur = append(cur, line)
+			continue
+		}
+		if inFence {
+			cur = append(cur, line)
+			continue
+		}
+		switch {
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "#"):
+			flush()
+			heading = strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+		case isListItem(trimmed) && line == strings.TrimLeft(line, " \t"):
+			flush()
+			cur = append(cur, listItemText(trimmed))
+		default:
+			cur = append(cur, line)
+		}
+	}
+	flush()
+	return notes
+}
+
+func isListItem(s string) bool {
+	return listItemText(s) != s
+}
+
+// listItemText strips a list marker ("- ", "* ", "+ ", "1. ", "1) ").
+func listItemText(s string) string {
+	for _, m := range []string{"- ", "* ", "+ "} {
+		if rest, ok := strings.CutPrefix(s, m); ok {
+			return strings.TrimPrefix(strings.TrimPrefix(rest, "[ ] "), "[x] ")
+		}
+	}
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i > 0 && i+1 < len(s) && (s[i] == '.' || s[i] == ')') && s[i+1] == ' ' {
+		return s[i+2:]
+	}
+	return s
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yifanes/miniclawd/internal/storage"
+)
+
+func openMemoryTestDB(t *testing.T, name string) *storage.Database {
+	t.Helper()
+	db, err := storage.Open(filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMemoryExportImportRoundTrip(t *testing.T) {
+	for _, format := range []string{MemoryFormatJSONL, MemoryFormatMarkdown} {
+		t.Run(format, func(t *testing.T) {
+			src := openMemoryTestDB(t, "src.db")
+			chat, err := src.ResolveOrCreateChatID("telegram", "42", nil, "private")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := src.InsertMemoryWithMetadata(nil, "Team standup is at 10:00", "KNOWLEDGE", "tool", 0.9); err != nil {
+				t.Fatal(err)
+			}
+			oldID, _ := src.InsertMemoryWithMetadata(&chat, "User lives in Berlin", "PROFILE", "reflector", 0.7)
+			newID, _ := src.InsertMemoryWithMetadata(&chat, "User lives in Paris\n## not a heading", "PROFILE", "reflector", 0.8)
+			if err := src.SupersedeMemory(oldID, newID, "moved"); err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			n, err := ExportMemories(src, storage.MemoryFilter{IncludeArchived: true}, format, &buf)
+			if err != nil || n != 3 {
+				t.Fatalf("export: n=%d err=%v", n, err)
+			}
+			records, err := ReadMemoryRecords(bytes.NewReader(buf.Bytes()), format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The destination has the chat under a different ID.
+			dst := openMemoryTestDB(t, "dst.db")
+			dst.ResolveOrCreateChatID("discord", "7", nil, "group")
+			dstChat, _ := dst.ResolveOrCreateChatID("telegram", "42", nil, "private")
+			res, err := ImportMemories(dst, records, MemoryImportOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Imported != 3 || res.Edges != 1 || len(res.Skipped) != 0 {
+				t.Fatalf("import result = %+v", res)
+			}
+
+			mems, _ := dst.ListMemories(storage.MemoryFilter{ChatID: &dstChat, IncludeArchived: true})
+			if len(mems) != 2 {
+				t.Fatalf("chat memories = %d, want 2", len(mems))
+			}
+			var paris *storage.Memory
+			for i, m := range mems {
+				if strings.HasPrefix(m.Content, "User lives in Paris") {
+					paris = &mems[i]
+				} else if !m.IsArchived {
+					t.Errorf("superseded memory %q should stay archived", m.Content)
+				}
+			}
+			if paris == nil || paris.Content != "User lives in Paris\n## not a heading" ||
+				paris.Category != "PROFILE" || paris.Confidence != 0.8 || paris.Source != "reflector" {
+				t.Fatalf("restored memory = %+v", paris)
+			}
+			edges, _ := dst.GetSupersedeEdges(paris.ID)
+			if len(edges) != 1 || edges[0].Reason == nil || *edges[0].Reason != "moved" {
+				t.Fatalf("edges = %+v", edges)
+			}
+
+			res, err = ImportMemories(dst, records, MemoryImportOptions{})
+			if err != nil || res.Imported != 0 || res.Duplicates != 3 || res.Edges != 0 {
+				t.Fatalf("re-import result = %+v, err = %v", res, err)
+			}
+			if all, _ := dst.GetSupersedeEdges(0); len(all) != 1 {
+				t.Fatalf("edges after re-import = %d, want 1", len(all))
+			}
+		})
+	}
+}
+
+func TestImportSkipsUnknownChat(t *testing.T) {
+	db := openMemoryTestDB(t, "mem.db")
+	records := []MemoryRecord{{Content: "x fact", Scope: "chat", ChatChannel: "slack", ExternalChatID: "C1"}}
+	res, err := ImportMemories(db, records, MemoryImportOptions{})
+	if err != nil || res.Imported != 0 || len(res.Skipped) != 1 {
+		t.Fatalf("result = %+v, err = %v", res, err)
+	}
+	res, _ = ImportMemories(db, records, MemoryImportOptions{Global: true})
+	if res.Imported != 1 {
+		t.Fatalf("forced global result = %+v", res)
+	}
+}
+
+func TestSplitNotes(t *testing.T) {
+	text := "---\ntitle: x\n---\n# Deploys\n\nWe deploy on Tuesdays.\nNever on Fridays.\n\n- Use the staging cluster first\n  - then production\n- Rollbacks go through CI\n\n```sh\nmake deploy\n\nmake verify\n```\n\n---\n"
+	want := []string{
+		"Deploys: We deploy on Tuesdays.\nNever on Fridays.",
+		"Deploys: Use the staging cluster first\n  - then production",
+		"Deploys: Rollbacks go through CI",
+		"Deploys: ```sh\nmake deploy\n\nmake verify\n```",
+	}
+	got := splitNotes(text)
+	if len(got) != len(want) {
+		t.Fatalf("got %d notes: %q", len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("note %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/yifanes/miniclawd/internal/agent"
 	"github.com/yifanes/miniclawd/internal/channels"
@@ -14,6 +16,7 @@ import (
 	"github.com/yifanes/miniclawd/internal/embedding"
 	"github.com/yifanes/miniclawd/internal/hooks"
 	"github.com/yifanes/miniclawd/internal/llm"
+	"github.com/yifanes/miniclawd/internal/netguard"
 	"github.com/yifanes/miniclawd/internal/scheduler"
 	"github.com/yifanes/miniclawd/internal/skills"
 	"github.com/yifanes/miniclawd/internal/storage"
@@ -59,27 +62,8 @@ func Run(cfg *config.Config, db *storage.Database) error {
 	log.Printf("[app] LLM provider: %s (model: %s)", provider.ProviderName(), provider.ModelName())
 
 	// Create embedding provider (optional).
-	var embeddingProvider embedding.EmbeddingProvider
-	if cfg.EmbeddingProvider != nil && *cfg.EmbeddingProvider != "" {
-		apiKey := ""
-		if cfg.EmbeddingAPIKey != nil {
-			apiKey = *cfg.EmbeddingAPIKey
-		} else if cfg.OpenAIAPIKey != nil {
-			apiKey = *cfg.OpenAIAPIKey
-		}
-		baseURL := ""
-		if cfg.EmbeddingBaseURL != nil {
-			baseURL = *cfg.EmbeddingBaseURL
-		}
-		model := ""
-		if cfg.EmbeddingModel != nil {
-			model = *cfg.EmbeddingModel
-		}
-		dim := 0
-		if cfg.EmbeddingDim != nil {
-			dim = *cfg.EmbeddingDim
-		}
-		embeddingProvider = embedding.NewOpenAIEmbeddingProvider(apiKey, baseURL, model, dim)
+	embeddingProvider := newEmbeddingProvider(cfg)
+	if embeddingProvider != nil {
 		log.Printf("[app] embedding provider: %s (model: %s)", *cfg.EmbeddingProvider, embeddingProvider.ModelName())
 	}
 
@@ -89,68 +73,44 @@ func Run(cfg *config.Config, db *storage.Database) error {
 
 	// Create HookManager.
 	hooksMgr := hooks.NewHookManager(cfg.DataDir)
+	secretStore, err := OpenSecretStore(cfg, db, false)
+	if err != nil {
+		log.Printf("[app] secrets store unavailable: %v", err)
+	}
+	hooksMgr.SetSecretLookup(secretStore.Lookup)
 	log.Printf("[app] hooks: %d discovered", len(hooksMgr.ListHooks()))
 
 	// Build ChannelRegistry.
-	registry := channels.NewChannelRegistry()
+	registry, telegramAdapter, discordAdapter := buildChannels(cfg)
 
-	// Register Web adapter.
-	if cfg.WebEnabled {
-		registry.Register(channels.NewWebAdapter())
-	}
+	// Background process manager, killed on shutdown.
+	processes := newProcessManager(cfg)
+	defer processes.Shutdown()
 
-	// Register Telegram adapter.
-	var telegramAdapter *channels.TelegramAdapter
-	if cfg.TelegramBotToken != "" {
-		var err error
-		telegramAdapter, err = channels.NewTelegramAdapter(cfg.TelegramBotToken, cfg.BotUsername, cfg.AllowedGroups)
-		if err != nil {
-			log.Printf("[app] telegram adapter error: %v", err)
-		} else {
-			registry.Register(telegramAdapter)
-			log.Printf("[app] telegram: @%s", cfg.BotUsername)
-		}
-	}
+	// Build ToolRegistry.
+	toolRegistry := buildToolRegistry(cfg, db, &registrySender{registry: registry, db: db}, processes)
+	log.Printf("[app] tools: %d registered", len(toolRegistry.ToolNames()))
 
-	// Register Discord adapter.
-	var discordAdapter *channels.DiscordAdapter
-	if cfg.DiscordBotToken != nil && *cfg.DiscordBotToken != "" {
-		var err error
-		discordAdapter, err = channels.NewDiscordAdapter(*cfg.DiscordBotToken, cfg.DiscordAllowedChannels, cfg.DiscordNoMention)
-		if err != nil {
-			log.Printf("[app] discord adapter error: %v", err)
-		} else {
-			registry.Register(discordAdapter)
-			log.Printf("[app] discord: adapter registered")
-		}
+	// MCP servers.
+	mcpMgr := StartMCP(ctx, cfg, toolRegistry)
+	if mcpMgr != nil {
+		defer mcpMgr.Close()
+		log.Printf("[app] mcp: %d server(s), %d tools registered", len(mcpMgr.ServerNames()), len(mcpMgr.GetAllTools()))
 	}
-
-	// Build working directory.
-	workingDir := cfg.WorkingDir
-	os.MkdirAll(workingDir, 0o755)
-
-	// Build ToolRegistry.
-	sender := &registrySender{registry: registry, db: db}
-	toolRegistry := tools.BuildStandardRegistry(tools.RegistryConfig{
-		WorkingDir:      workingDir,
-		DataDir:         cfg.DataDir,
-		SkillsDir:       cfg.SkillsDir(),
-		Timezone:        cfg.Timezone,
-		DB:              db,
-		Sender:          sender,
-		ClawHubEnabled:  cfg.ClawHubAgentToolsEnabled,
-		ClawHubRegistry: cfg.ClawHubRegistry,
-		ClawHubToken:    cfg.ClawHubToken,
+	toolRegistry.OnChange(func(c tools.RegistryChange) {
+		log.Printf("[app] tools changed (v%d): added %v, removed %v", c.Version, c.Added, c.Removed)
 	})
-	log.Printf("[app] tools: %d registered", len(toolRegistry.ToolNames()))
 
 	// Build AgentDeps.
 	deps := &agent.AgentDeps{
-		Config: cfg,
-		DB:     db,
-		LLM:    provider,
-		Tools:  toolRegistry,
-		Skills: skillsMgr.BuildCatalog(),
+		Config:    cfg,
+		DB:        db,
+		LLM:       provider,
+		Tools:     toolRegistry,
+		Skills:    skillsMgr.BuildCatalog(),
+		Processes: processes,
+		MCP:       mcpMgr,
+		Embedding: embeddingProvider,
 	}
 
 	// Build AppState.
@@ -171,9 +131,15 @@ func Run(cfg *config.Config, db *storage.Database) error {
 	scheduler.SpawnScheduler(ctx, db, deps, registry)
 	log.Println("[app] scheduler started")
 
+	// Spawn embedding indexer.
+	if embeddingProvider != nil {
+		scheduler.SpawnEmbeddingIndexer(ctx, db, embeddingProvider)
+		log.Println("[app] embedding indexer started")
+	}
+
 	// Spawn reflector.
 	if cfg.ReflectorEnabled {
-		scheduler.SpawnReflector(ctx, db, provider, int(cfg.ReflectorIntervalMins))
+		scheduler.SpawnReflector(ctx, db, provider, embeddingProvider, int(cfg.ReflectorIntervalMins))
 		log.Printf("[app] reflector started (interval: %dm)", cfg.ReflectorIntervalMins)
 	}
 
@@ -215,3 +181,189 @@ func Run(cfg *config.Config, db *storage.Database) error {
 
 	return nil
 }
+
+// newEmbeddingProvider creates the configured embedding provider, or nil
+// if none is configured. "local" needs no network; any other value selects
+// an OpenAI-compatible API.
+func newEmbeddingProvider(cfg *config.Config) embedding.EmbeddingProvider {
+	if cfg.EmbeddingProvider == nil || *cfg.EmbeddingProvider == "" {
+		return nil
+	}
+	dim := 0
+	if cfg.EmbeddingDim != nil {
+		dim = *cfg.EmbeddingDim
+	}
+	if strings.EqualFold(*cfg.EmbeddingProvider, "local") {
+		return embedding.NewLocalEmbeddingProvider(dim)
+	}
+	apiKey := ""
+	if cfg.EmbeddingAPIKey != nil {
+		apiKey = *cfg.EmbeddingAPIKey
+	} else if cfg.OpenAIAPIKey != nil {
+		apiKey = *cfg.OpenAIAPIKey
+	}
+	baseURL := ""
+	if cfg.EmbeddingBaseURL != nil {
+		baseURL = *cfg.EmbeddingBaseURL
+	}
+	model := ""
+	if cfg.EmbeddingModel != nil {
+		model = *cfg.EmbeddingModel
+	}
+	return embedding.NewOpenAIEmbeddingProvider(apiKey, baseURL, model, dim)
+}
+
+// httpRequestConfig maps http_request config onto the tool's settings.
+func httpRequestConfig(c config.HTTPRequestConfig) tools.HTTPRequestConfig {
+	out := tools.HTTPRequestConfig{
+		AllowHosts:  c.AllowHosts,
+		Credentials: make(map[string]tools.HTTPCredential, len(c.Credentials)),
+	}
+	for alias, cred := range c.Credentials {
+		out.Credentials[alias] = tools.HTTPCredential{
+			Hosts:      cred.Hosts,
+			Header:     cred.Header,
+			Scheme:     cred.Scheme,
+			QueryParam: cred.QueryParam,
+			Value:      cred.Value,
+			AllowHTTP:  cred.AllowHTTP,
+		}
+	}
+	return out
+}
+
+// toolRiskPolicy builds the risk policy, falling back to the defaults for
+// unrecognized levels.
+func toolRiskPolicy(c config.ToolRiskConfig) *tools.RiskPolicy {
+	maxRisk, ok := tools.ParseToolRisk(c.MaxRisk)
+	if !ok {
+		log.Printf("[app] tool_risk.max_risk %q is not low, medium or high; using medium", c.MaxRisk)
+		maxRisk = tools.RiskMedium
+	}
+	controlMax, ok := tools.ParseToolRisk(c.ControlChatMaxRisk)
+	if !ok {
+		log.Printf("[app] tool_risk.control_chat_max_risk %q is not low, medium or high; using high", c.ControlChatMaxRisk)
+		controlMax = tools.RiskHigh
+	}
+	return tools.NewRiskPolicy(maxRisk, controlMax)
+}
+
+// toolPolicy maps tool_policies config onto the registry's policy.
+func toolPolicy(rules []config.ToolPolicyConfig) *tools.ToolPolicy {
+	if len(rules) == 0 {
+		return nil
+	}
+	out := make([]tools.ToolPolicyRule, len(rules))
+	for i, r := range rules {
+		out[i] = tools.ToolPolicyRule{
+			Channels:  r.Channels,
+			ChatTypes: r.ChatTypes,
+			ChatIDs:   r.ChatIDs,
+			Profiles:  r.Profiles,
+			Allow:     r.Allow,
+			Deny:      r.Deny,
+		}
+	}
+	return tools.NewToolPolicy(out)
+}
+
+// buildChannels registers an adapter for every configured channel.
+func buildChannels(cfg *config.Config) (registry *channels.ChannelRegistry, telegram *channels.TelegramAdapter, discord *channels.DiscordAdapter) {
+	registry = channels.NewChannelRegistry()
+
+	// Register Web adapter.
+	if cfg.WebEnabled {
+		registry.Register(channels.NewWebAdapter())
+	}
+
+	// Register Telegram adapter.
+	if cfg.TelegramBotToken != "" {
+		var err error
+		telegram, err = channels.NewTelegramAdapter(cfg.TelegramBotToken, cfg.BotUsername, cfg.AllowedGroups)
+		if err != nil {
+			log.Printf("[app] telegram adapter error: %v", err)
+		} else {
+			registry.Register(telegram)
+			log.Printf("[app] telegram: @%s", cfg.BotUsername)
+		}
+	}
+
+	// Register Discord adapter.
+	if cfg.DiscordBotToken != nil && *cfg.DiscordBotToken != "" {
+		var err error
+		discord, err = channels.NewDiscordAdapter(*cfg.DiscordBotToken, cfg.DiscordAllowedChannels, cfg.DiscordNoMention)
+		if err != nil {
+			log.Printf("[app] discord adapter error: %v", err)
+		} else {
+			registry.Register(discord)
+			log.Printf("[app] discord: adapter registered")
+		}
+	}
+	return registry, telegram, discord
+}
+
+// newProcessManager creates the background process manager, scoped per chat.
+// Callers must Shutdown it.
+func newProcessManager(cfg *config.Config) *tools.ProcessManager {
+	os.MkdirAll(cfg.WorkingDir, 0o755)
+
+	memoryLimit := ""
+	if cfg.Sandbox.MemoryLimit != nil {
+		memoryLimit = *cfg.Sandbox.MemoryLimit
+	}
+	pidsLimit := 0
+	if cfg.Sandbox.PidsLimit != nil {
+		pidsLimit = int(*cfg.Sandbox.PidsLimit)
+	}
+	return tools.NewProcessManager(
+		tools.NewWorkspace(cfg.WorkingDir, string(cfg.WorkingDirIsolation)),
+		tools.ProcessLimits{
+			MaxPerChat:  cfg.Sandbox.MaxProcesses,
+			MaxRuntime:  time.Duration(cfg.Sandbox.ProcessMaxRuntimeSecs) * time.Second,
+			MemoryLimit: memoryLimit,
+			PidsLimit:   pidsLimit,
+		})
+}
+
+// buildToolRegistry builds the standard tool set from config.
+func buildToolRegistry(cfg *config.Config, db *storage.Database, sender tools.ChannelSender, processes *tools.ProcessManager) *tools.ToolRegistry {
+	return tools.BuildStandardRegistry(tools.RegistryConfig{
+		WorkingDir:      cfg.WorkingDir,
+		DataDir:         cfg.DataDir,
+		SkillsDir:       cfg.SkillsDir(),
+		Timezone:        cfg.Timezone,
+		DB:              db,
+		Sender:          sender,
+		ClawHubEnabled:  cfg.ClawHubAgentToolsEnabled,
+		ClawHubRegistry: cfg.ClawHubRegistry,
+		ClawHubToken:    cfg.ClawHubToken,
+
+		WorkingDirIsolation: string(cfg.WorkingDirIsolation),
+		PathPolicy: tools.NewPathPolicy(cfg.PathPolicy.AllowedRoots,
+			cfg.PathPolicy.ReadOnlyRoots, cfg.PathPolicy.DenyGlobs, db),
+		Processes:  processes,
+		RiskPolicy: toolRiskPolicy(cfg.ToolRisk),
+		ToolPolicy: toolPolicy(cfg.ToolPolicies),
+		Outbound: netguard.New(netguard.Config{
+			AllowPrivate: cfg.Outbound.AllowPrivate,
+			AllowHosts:   cfg.Outbound.AllowHosts,
+			AllowCIDRs:   cfg.Outbound.AllowCIDRs,
+			DenyHosts:    cfg.Outbound.DenyHosts,
+			DenyCIDRs:    cfg.Outbound.DenyCIDRs,
+		}),
+		WebSearch: tools.SearchConfig{
+			Backend:      cfg.WebSearch.Backend,
+			URL:          cfg.WebSearch.URL,
+			APIKey:       cfg.WebSearch.APIKey,
+			CacheTTL:     time.Duration(cfg.WebSearch.CacheTTLSecs) * time.Second,
+			QueryParam:   cfg.WebSearch.QueryParam,
+			CountParam:   cfg.WebSearch.CountParam,
+			ResultsPath:  cfg.WebSearch.ResultsPath,
+			TitleField:   cfg.WebSearch.TitleField,
+			URLField:     cfg.WebSearch.URLField,
+			SnippetField: cfg.WebSearch.SnippetField,
+			Headers:      cfg.WebSearch.Headers,
+		},
+		HTTPRequest: httpRequestConfig(cfg.HTTPRequest),
+	})
+}
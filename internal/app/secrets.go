@@ -0,0 +1,50 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/yifanes/miniclawd/internal/config"
+	"github.com/yifanes/miniclawd/internal/core"
+	"github.com/yifanes/miniclawd/internal/storage"
+)
+
+// OpenSecretStore opens the encrypted secrets store. Without create, it
+// returns a nil store (and no error) when no key has been set up yet.
+func OpenSecretStore(cfg *config.Config, db *storage.Database, create bool) (*storage.SecretStore, error) {
+	key, err := storage.LoadSecretsKey(cfg.SecretsKeyPath(), create)
+	if errors.Is(err, storage.ErrNoSecretsKey) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewSecretStore(db, key)
+}
+
+// ResolveSecrets expands ${secret:NAME} references in cfg and registers every
+// stored secret for redaction from tool output and logs.
+func ResolveSecrets(cfg *config.Config, db *storage.Database) (*storage.SecretStore, error) {
+	store, err := OpenSecretStore(cfg, db, false)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.HasSecretRefs() {
+		if store == nil {
+			return nil, fmt.Errorf("config references secrets but %v", storage.ErrNoSecretsKey)
+		}
+		if err := cfg.ExpandSecrets(store.Lookup); err != nil {
+			return nil, err
+		}
+	}
+	if store != nil {
+		values, err := store.Values()
+		if err != nil {
+			return nil, fmt.Errorf("loading secrets: %w", err)
+		}
+		core.RegisterSecret(values...)
+		log.SetOutput(core.RedactingWriter(log.Writer()))
+	}
+	return store, nil
+}
@@ -146,6 +146,7 @@ func handleDiscordMessage(ctx context.Context, adapter *DiscordAdapter, db *stor
 			switch parts[0] {
 			case "/reset":
 				db.ClearChatContext(chatID)
+				deps.Processes.KillChat(chatID)
 				s.ChannelMessageSend(msg.ChannelID, "Context cleared.")
 				return
 			case "/usage":
@@ -162,6 +163,7 @@ func handleDiscordMessage(ctx context.Context, adapter *DiscordAdapter, db *stor
 				if len(msgs) > 0 {
 					agent.ArchiveConversation(deps.Config.DataDir, "discord", chatID, msgs)
 					db.ClearChatContext(chatID)
+					deps.Processes.KillChat(chatID)
 					s.ChannelMessageSend(msg.ChannelID, "Conversation archived and context cleared.")
 				} else {
 					s.ChannelMessageSend(msg.ChannelID, "No active session to archive.")
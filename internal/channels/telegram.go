@@ -146,6 +146,7 @@ func handleTelegramMessage(ctx context.Context, adapter *TelegramAdapter, db *st
 		switch msg.Command() {
 		case "reset":
 			db.ClearChatContext(chatID)
+			deps.Processes.KillChat(chatID)
 			reply := tgbotapi.NewMessage(msg.Chat.ID, "Context cleared.")
 			adapter.bot.Send(reply)
 			return
@@ -165,6 +166,7 @@ func handleTelegramMessage(ctx context.Context, adapter *TelegramAdapter, db *st
 			if messages != nil && len(messages) > 0 {
 				agent.ArchiveConversation(deps.Config.DataDir, "telegram", chatID, messages)
 				db.ClearChatContext(chatID)
+				deps.Processes.KillChat(chatID)
 				reply := tgbotapi.NewMessage(msg.Chat.ID, "Conversation archived and context cleared.")
 				adapter.bot.Send(reply)
 			} else {
@@ -9,6 +9,8 @@ import (
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/yifanes/miniclawd/internal/netguard"
 )
 
 // ClawHubGateway is an HTTP client for the ClawHub registry API.
@@ -18,12 +20,13 @@ type ClawHubGateway struct {
 	client      *http.Client
 }
 
-// NewClawHubGateway creates a new ClawHub client.
-func NewClawHubGateway(registryURL, token string) *ClawHubGateway {
+// NewClawHubGateway creates a new ClawHub client. Requests go through guard's
+// SSRF checks; a nil guard applies the default policy.
+func NewClawHubGateway(registryURL, token string, guard *netguard.Policy) *ClawHubGateway {
 	return &ClawHubGateway{
 		registryURL: strings.TrimRight(registryURL, "/"),
 		token:       token,
-		client:      &http.Client{Timeout: 30 * time.Second},
+		client:      guard.Client(30 * time.Second),
 	}
 }
 
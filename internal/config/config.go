@@ -31,8 +31,20 @@ type Config struct {
 	WorkingDir           string              `yaml:"working_dir"`
 	WorkingDirIsolation  WorkingDirIsolation `yaml:"working_dir_isolation"`
 	Sandbox              SandboxConfig       `yaml:"sandbox"`
+	PathPolicy           PathPolicyConfig    `yaml:"path_policy"`
+	ToolRisk             ToolRiskConfig      `yaml:"tool_risk"`
+	ToolPolicies         []ToolPolicyConfig  `yaml:"tool_policies"`
+	Outbound             OutboundConfig      `yaml:"outbound"`
+	WebSearch            WebSearchConfig     `yaml:"web_search"`
+	HTTPRequest          HTTPRequestConfig   `yaml:"http_request"`
 	Timezone             string              `yaml:"timezone"`
 	ControlChatIDs       []int64             `yaml:"control_chat_ids"`
+	SecretsKeyFile       string              `yaml:"secrets_key_file"` // default <data_dir>/secrets.key
+
+	// MCP servers, keyed by name; more may come from mcp_config_file
+	MCPServers    map[string]MCPServerConfig `yaml:"mcp_servers"`
+	MCPConfigFile string                     `yaml:"mcp_config_file"` // default <data_dir>/mcp.json
+	MCPServe      MCPServeConfig             `yaml:"mcp_serve"`       // miniclawd mcp-serve
 
 	// Discord
 	DiscordBotToken        *string  `yaml:"discord_bot_token"`
@@ -93,15 +105,90 @@ const (
 
 // SandboxConfig controls sandboxed command execution.
 type SandboxConfig struct {
-	Mode            string  `yaml:"mode"`             // "off" or "all"
+	Mode            string  `yaml:"mode"`             // only "off"; commands run on the host
 	Backend         string  `yaml:"backend"`          // "auto" or "docker"
 	Image           string  `yaml:"image"`            // default "ubuntu:25.10"
 	ContainerPrefix string  `yaml:"container_prefix"` // default "miniclawd-sandbox"
-	NoNetwork       bool    `yaml:"no_network"`       // default true
+	NoNetwork       bool    `yaml:"no_network"`       // not supported without a container
 	RequireRuntime  bool    `yaml:"require_runtime"`
 	MemoryLimit     *string `yaml:"memory_limit"`
-	CPUQuota        *float64 `yaml:"cpu_quota"`
-	PidsLimit       *uint32 `yaml:"pids_limit"`
+	CPUQuota        *float64 `yaml:"cpu_quota"`       // not supported without a container
+	PidsLimit       *uint32 `yaml:"pids_limit"`      // background processes, via ulimit -u
+
+	// Background processes (process_start)
+	MaxProcesses          int    `yaml:"max_processes"`            // running per chat, default 4
+	ProcessMaxRuntimeSecs uint64 `yaml:"process_max_runtime_secs"` // default 3600
+}
+
+// PathPolicyConfig confines file tools to a set of directories.
+type PathPolicyConfig struct {
+	AllowedRoots  []string `yaml:"allowed_roots"`   // read/write; default working_dir and data_dir/exports
+	ReadOnlyRoots []string `yaml:"read_only_roots"` // read only
+	DenyGlobs     []string `yaml:"deny_globs"`      // e.g. "*.pem", "**/secrets/**"
+}
+
+// ToolRiskConfig caps how risky a tool action may be ("low", "medium" or
+// "high"), e.g. git reset --hard is high.
+type ToolRiskConfig struct {
+	MaxRisk            string `yaml:"max_risk"`              // default "medium"
+	ControlChatMaxRisk string `yaml:"control_chat_max_risk"` // default "high"
+}
+
+// ToolPolicyConfig allows or denies tools for matching chats. Rules apply in
+// order: allow replaces the permitted set, deny removes from it, so put broad
+// rules first and specific overrides after. Tool names accept globs.
+type ToolPolicyConfig struct {
+	Channels  []string `yaml:"channels"`   // e.g. "discord", "telegram", "web"
+	ChatTypes []string `yaml:"chat_types"` // e.g. "discord_guild", "telegram_group"
+	ChatIDs   []int64  `yaml:"chat_ids"`
+	Profiles  []string `yaml:"profiles"` // "default", "scheduler"
+	Allow     []string `yaml:"allow"`
+	Deny      []string `yaml:"deny"`
+}
+
+// OutboundConfig controls which hosts tools may reach over HTTP. Private,
+// loopback and link-local addresses are blocked unless exempted here.
+type OutboundConfig struct {
+	AllowPrivate bool     `yaml:"allow_private"` // disable the private-range block
+	AllowHosts   []string `yaml:"allow_hosts"`   // exempt hostnames, e.g. "wiki.corp.example", "*.internal"
+	AllowCIDRs   []string `yaml:"allow_cidrs"`   // exempt address ranges
+	DenyHosts    []string `yaml:"deny_hosts"`    // hostnames always refused
+	DenyCIDRs    []string `yaml:"deny_cidrs"`    // address ranges always refused
+}
+
+// WebSearchConfig selects the web_search backend. DuckDuckGo is used when
+// no backend is set and as a fallback when the configured one fails.
+type WebSearchConfig struct {
+	Backend      string `yaml:"backend"`        // "ddg", "searxng", "brave", "tavily" or "json"
+	URL          string `yaml:"url"`            // searxng base URL or json endpoint
+	APIKey       string `yaml:"api_key"`        // brave / tavily / json bearer token
+	CacheTTLSecs int    `yaml:"cache_ttl_secs"` // default 300; 0 disables caching
+
+	// Generic JSON endpoint mapping
+	QueryParam   string            `yaml:"query_param"`   // default "q"
+	CountParam   string            `yaml:"count_param"`
+	ResultsPath  string            `yaml:"results_path"`  // e.g. "data.items"
+	TitleField   string            `yaml:"title_field"`   // default "title"
+	URLField     string            `yaml:"url_field"`     // default "url"
+	SnippetField string            `yaml:"snippet_field"` // default "snippet"
+	Headers      map[string]string `yaml:"headers"`
+}
+
+// HTTPRequestConfig configures the http_request tool.
+type HTTPRequestConfig struct {
+	AllowHosts  []string                        `yaml:"allow_hosts"` // empty allows any public host
+	Credentials map[string]HTTPCredentialConfig `yaml:"credentials"` // keyed by alias
+}
+
+// HTTPCredentialConfig is a secret the agent can use by alias without seeing it.
+type HTTPCredentialConfig struct {
+	Hosts      []string `yaml:"hosts"`       // required; hosts the credential may be sent to
+	Header     string   `yaml:"header"`      // default "Authorization"
+	Scheme     string   `yaml:"scheme"`      // e.g. "Bearer", "token"; empty sends the raw value
+	QueryParam string   `yaml:"query_param"` // send as a query parameter instead of a header
+	Value      string   `yaml:"value"`
+	ValueEnv   string   `yaml:"value_env"` // read the value from this environment variable
+	AllowHTTP  bool     `yaml:"allow_http"` // also send over plain http, e.g. to a local endpoint
 }
 
 // ModelPrice defines per-model token pricing.
@@ -139,12 +226,26 @@ func DefaultConfig() Config {
 		ReflectorIntervalMins:   15,
 		ClawHubRegistry:         "https://clawhub.ai",
 		ClawHubAgentToolsEnabled: true,
+		ToolRisk: ToolRiskConfig{
+			MaxRisk:            "medium",
+			ControlChatMaxRisk: "high",
+		},
+		WebSearch: WebSearchConfig{
+			Backend:      "ddg",
+			CacheTTLSecs: 300,
+		},
+		MCPServe: MCPServeConfig{
+			Tools: append([]string(nil), DefaultMCPServeTools...),
+			Host:  "127.0.0.1",
+			Port:  10962,
+		},
 		Sandbox: SandboxConfig{
 			Mode:            "off",
 			Backend:         "auto",
 			Image:           "ubuntu:25.10",
 			ContainerPrefix: "miniclawd-sandbox",
-			NoNetwork:       true,
+			MaxProcesses:          4,
+			ProcessMaxRuntimeSecs: 3600,
 		},
 	}
 }
@@ -175,6 +276,9 @@ func LoadConfig() (*Config, error) {
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("parsing config %s: %w", path, err)
 	}
+	if err := cfg.loadMCPFile(); err != nil {
+		return nil, err
+	}
 
 	cfg.postDeserialize()
 	return &cfg, nil
@@ -196,6 +300,31 @@ func (c *Config) postDeserialize() {
 		}
 	}
 
+	// Anything other than "shared" isolates working directories per chat.
+	c.WorkingDirIsolation = WorkingDirIsolation(strings.ToLower(strings.TrimSpace(string(c.WorkingDirIsolation))))
+	if c.WorkingDirIsolation != IsolationShared {
+		c.WorkingDirIsolation = IsolationChat
+	}
+
+	c.WebSearch.Backend = strings.ToLower(strings.TrimSpace(c.WebSearch.Backend))
+	if c.WebSearch.CacheTTLSecs < 0 {
+		c.WebSearch.CacheTTLSecs = 0
+	}
+
+	c.normalizeMCPServers()
+
+	for alias, cred := range c.HTTPRequest.Credentials {
+		if cred.Value == "" && cred.ValueEnv != "" {
+			cred.Value = os.Getenv(cred.ValueEnv)
+			c.HTTPRequest.Credentials[alias] = cred
+		}
+	}
+
+	// Default path policy: file tools stay inside the working directory and exports.
+	if len(c.PathPolicy.AllowedRoots) == 0 {
+		c.PathPolicy.AllowedRoots = []string{c.WorkingDir, filepath.Join(c.DataDir, "exports")}
+	}
+
 	// Ensure critical limits have sane minimums.
 	if c.MemoryTokenBudget <= 0 {
 		c.MemoryTokenBudget = 1500
@@ -244,6 +373,23 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if err := c.validateSandbox(); err != nil {
+		return err
+	}
+	return c.validateMCPServers()
+}
+
+// validateSandbox rejects sandbox settings that need a container runtime.
+// Commands run directly on the host, so they would silently do nothing.
+func (c *Config) validateSandbox() error {
+	switch {
+	case c.Sandbox.Mode != "" && c.Sandbox.Mode != "off":
+		return fmt.Errorf("sandbox.mode %q is not supported: commands run on the host", c.Sandbox.Mode)
+	case c.Sandbox.CPUQuota != nil:
+		return fmt.Errorf("sandbox.cpu_quota is not supported: commands run on the host")
+	case c.Sandbox.NoNetwork:
+		return fmt.Errorf("sandbox.no_network is not supported: commands run on the host")
+	}
 	return nil
 }
 
@@ -271,6 +417,14 @@ func (c *Config) DBPath() string {
 	return filepath.Join(c.DataDir, "miniclawd.db")
 }
 
+// SecretsKeyPath returns the file holding the secrets encryption key.
+func (c *Config) SecretsKeyPath() string {
+	if c.SecretsKeyFile != "" {
+		return c.SecretsKeyFile
+	}
+	return filepath.Join(c.DataDir, "secrets.key")
+}
+
 // GroupDir returns the per-chat data directory.
 func (c *Config) GroupDir(chatID int64) string {
 	return filepath.Join(c.RuntimeDir(), "groups", fmt.Sprintf("%d", chatID))
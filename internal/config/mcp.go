@@ -0,0 +1,144 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MCPServerConfig declares an MCP server. The fields match the "mcpServers"
+// entries of the mcp.json files used by common MCP clients, so the same
+// definitions work in YAML and in mcp.json.
+type MCPServerConfig struct {
+	Transport   string            `yaml:"transport" json:"transport"` // "stdio", "http" or "sse"; inferred from url when empty
+	Type        string            `yaml:"type" json:"type"`           // mcp.json spelling of transport
+	Command     string            `yaml:"command" json:"command"`
+	Args        []string          `yaml:"args" json:"args"`
+	Env         map[string]string `yaml:"env" json:"env"`
+	Cwd         string            `yaml:"cwd" json:"cwd"`
+	URL         string            `yaml:"url" json:"url"`
+	Headers     map[string]string `yaml:"headers" json:"headers"`           // sent with every HTTP request
+	BearerToken string            `yaml:"bearer_token" json:"bearer_token"` // sets Authorization: Bearer
+	TimeoutSecs int               `yaml:"timeout_secs" json:"timeout_secs"` // per tool call, default 120
+	Disabled    bool              `yaml:"disabled" json:"disabled"`
+}
+
+// MCPServeConfig configures `miniclawd mcp-serve`, which exposes a subset of
+// miniclawd's own tools to other MCP clients.
+type MCPServeConfig struct {
+	Tools []string `yaml:"tools"` // registry tools to expose; default DefaultMCPServeTools
+	Host  string   `yaml:"host"`  // Streamable HTTP listen address
+	Port  uint16   `yaml:"port"`
+}
+
+// DefaultMCPServeTools are the tools mcp-serve exposes unless configured.
+var DefaultMCPServeTools = []string{
+	"structured_memory_search",
+	"structured_memory_update",
+	"schedule_task",
+	"send_message",
+	"export_chat",
+}
+
+// MCPConfigPath returns the mcp.json file merged into mcp_servers.
+func (c *Config) MCPConfigPath() string {
+	if c.MCPConfigFile != "" {
+		return c.MCPConfigFile
+	}
+	return filepath.Join(c.DataDir, "mcp.json")
+}
+
+// MCPServerNames returns the names of the enabled MCP servers, sorted.
+func (c *Config) MCPServerNames() []string {
+	var names []string
+	for name, s := range c.MCPServers {
+		if !s.Disabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadMCPFile merges servers from mcp.json into c.MCPServers. Servers
+// declared in YAML take precedence. A missing file is only an error when
+// mcp_config_file names it explicitly.
+func (c *Config) loadMCPFile() error {
+	path := c.MCPConfigPath()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && c.MCPConfigFile == "" {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	var file struct {
+		MCPServers map[string]MCPServerConfig `json:"mcpServers"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if c.MCPServers == nil {
+		c.MCPServers = make(map[string]MCPServerConfig)
+	}
+	for name, s := range file.MCPServers {
+		if _, ok := c.MCPServers[name]; !ok {
+			c.MCPServers[name] = s
+		}
+	}
+	return nil
+}
+
+// normalizeMCPServers resolves the transport of every server: "type" is
+// accepted as an alias, the Streamable HTTP spellings collapse to "http",
+// "sse" selects the legacy HTTP+SSE transport, and an empty transport means
+// http when a url is set and stdio otherwise. Unknown transports are kept
+// for validateMCPServers to reject.
+func (c *Config) normalizeMCPServers() {
+	for name, s := range c.MCPServers {
+		s.URL = strings.TrimSpace(s.URL)
+		t := strings.ToLower(strings.TrimSpace(s.Transport))
+		if t == "" {
+			t = strings.ToLower(strings.TrimSpace(s.Type))
+		}
+		switch t {
+		case "http", "streamable-http", "streamable_http", "streamablehttp":
+			t = "http"
+		case "":
+			if s.URL != "" {
+				t = "http"
+			} else {
+				t = "stdio"
+			}
+		}
+		s.Transport, s.Type = t, ""
+		c.MCPServers[name] = s
+	}
+}
+
+func (c *Config) validateMCPServers() error {
+	for _, name := range c.MCPServerNames() {
+		s := c.MCPServers[name]
+		switch s.Transport {
+		case "http", "sse":
+			if s.URL == "" {
+				return fmt.Errorf("mcp_servers.%s: url is required for %s transport", name, s.Transport)
+			}
+			u, err := url.Parse(s.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("mcp_servers.%s: url must be an http or https URL, got %q", name, s.URL)
+			}
+		case "stdio":
+			if s.Command == "" {
+				return fmt.Errorf("mcp_servers.%s: command is required for stdio transport", name)
+			}
+		default:
+			return fmt.Errorf("mcp_servers.%s: unknown transport %q (use stdio, http or sse)", name, s.Transport)
+		}
+	}
+	return nil
+}
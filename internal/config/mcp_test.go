@@ -0,0 +1,45 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateMCPServers(t *testing.T) {
+	tests := []struct {
+		name      string
+		server    MCPServerConfig
+		transport string
+		wantErr   string
+	}{
+		{"stdio", MCPServerConfig{Command: "mcp-fs"}, "stdio", ""},
+		{"stdio without command", MCPServerConfig{Transport: "stdio"}, "stdio", "command is required"},
+		{"url implies http", MCPServerConfig{URL: "https://mcp.example.com/mcp"}, "http", ""},
+		{"streamable spelling", MCPServerConfig{Type: "streamable-http", URL: "http://localhost:8080/mcp"}, "http", ""},
+		{"http without url", MCPServerConfig{Transport: "http"}, "http", "url is required for http"},
+		{"sse without url", MCPServerConfig{Type: "sse", Command: "ignored"}, "sse", "url is required for sse"},
+		{"blank url", MCPServerConfig{Transport: "sse", URL: "  "}, "sse", "url is required for sse"},
+		{"non-http url", MCPServerConfig{Transport: "http", URL: "ftp://example.com"}, "http", "http or https URL"},
+		{"url without host", MCPServerConfig{Transport: "sse", URL: "localhost:8080"}, "sse", "http or https URL"},
+		{"unknown transport", MCPServerConfig{Transport: "websocket", URL: "wss://example.com"}, "websocket", "unknown transport"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Config{MCPServers: map[string]MCPServerConfig{"s": tc.server}}
+			c.normalizeMCPServers()
+			if got := c.MCPServers["s"].Transport; got != tc.transport {
+				t.Errorf("transport = %q, want %q", got, tc.transport)
+			}
+			err := c.validateMCPServers()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/yifanes/miniclawd/internal/core"
+)
+
+// HasSecretRefs reports whether any config value contains ${secret:NAME}.
+func (c *Config) HasSecretRefs() bool {
+	found := false
+	walkStrings(reflect.ValueOf(c).Elem(), func(s string) (string, error) {
+		if core.HasSecretRefs(s) {
+			found = true
+		}
+		return s, nil
+	})
+	return found
+}
+
+// ExpandSecrets replaces ${secret:NAME} references in every string value of
+// the config, including nested structs, pointers, slices and maps.
+func (c *Config) ExpandSecrets(lookup core.SecretLookup) error {
+	var errs []error
+	walkStrings(reflect.ValueOf(c).Elem(), func(s string) (string, error) {
+		out, err := core.ExpandSecretRefs(s, lookup)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return out, nil
+	})
+	return errors.Join(errs...)
+}
+
+// walkStrings calls fn on every settable string reachable from v and stores
+// the result back.
+func walkStrings(v reflect.Value, fn func(string) (string, error)) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.CanSet() {
+			if out, err := fn(v.String()); err == nil && out != v.String() {
+				v.SetString(out)
+			}
+		}
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			walkStrings(v.Elem(), fn)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				walkStrings(v.Field(i), fn)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkStrings(v.Index(i), fn)
+		}
+	case reflect.Map:
+		// Map elements are not addressable: copy, walk, store back.
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(iter.Value().Type()).Elem()
+			elem.Set(iter.Value())
+			walkStrings(elem, fn)
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SecretLookup resolves a secret by name.
+type SecretLookup func(name string) (string, error)
+
+var secretRefRe = regexp.MustCompile(`\$\{secret:([A-Za-z0-9_.\-]+)\}`)
+
+// HasSecretRefs reports whether s contains a ${secret:NAME} reference.
+func HasSecretRefs(s string) bool {
+	return strings.Contains(s, "${secret:")
+}
+
+// ExpandSecretRefs replaces every ${secret:NAME} in s with its value.
+func ExpandSecretRefs(s string, lookup SecretLookup) (string, error) {
+	if !HasSecretRefs(s) {
+		return s, nil
+	}
+	var firstErr error
+	out := secretRefRe.ReplaceAllStringFunc(s, func(ref string) string {
+		name := secretRefRe.FindStringSubmatch(ref)[1]
+		if lookup == nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("secret %q: secrets store is not available", name)
+			}
+			return ref
+		}
+		v, err := lookup(name)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("secret %q: %w", name, err)
+			}
+			return ref
+		}
+		return v
+	})
+	return out, firstErr
+}
+
+// --- Redaction ---
+
+// minRedactLen skips values too short to redact without mangling output.
+const minRedactLen = 6
+
+var redactor struct {
+	mu       sync.RWMutex
+	values   map[string]struct{}
+	replacer *strings.Replacer
+}
+
+// RegisterSecret marks values as secret so RedactSecrets masks them.
+func RegisterSecret(values ...string) {
+	redactor.mu.Lock()
+	defer redactor.mu.Unlock()
+	if redactor.values == nil {
+		redactor.values = make(map[string]struct{})
+	}
+	for _, v := range values {
+		if len(v) >= minRedactLen {
+			redactor.values[v] = struct{}{}
+		}
+	}
+	// Longest first, so a secret containing another is masked whole.
+	vals := make([]string, 0, len(redactor.values))
+	for v := range redactor.values {
+		vals = append(vals, v)
+	}
+	sort.Slice(vals, func(i, j int) bool { return len(vals[i]) > len(vals[j]) })
+	pairs := make([]string, 0, 2*len(vals))
+	for _, v := range vals {
+		pairs = append(pairs, v, "[REDACTED]")
+	}
+	redactor.replacer = strings.NewReplacer(pairs...)
+}
+
+// RedactSecrets masks every registered secret value in s.
+func RedactSecrets(s string) string {
+	redactor.mu.RLock()
+	r := redactor.replacer
+	redactor.mu.RUnlock()
+	if r == nil || s == "" {
+		return s
+	}
+	return r.Replace(s)
+}
+
+// RedactBlocks returns a copy of blocks with secrets masked in their text.
+func RedactBlocks(blocks []ContentBlock) []ContentBlock {
+	if len(blocks) == 0 {
+		return blocks
+	}
+	out := make([]ContentBlock, len(blocks))
+	for i, b := range blocks {
+		b.Text = RedactSecrets(b.Text)
+		b.Content = RedactSecrets(b.Content)
+		out[i] = b
+	}
+	return out
+}
+
+// RedactingWriter wraps w so registered secrets are masked before writing.
+// It is meant for line-oriented output such as the standard logger.
+func RedactingWriter(w io.Writer) io.Writer {
+	if rw, ok := w.(*redactingWriter); ok {
+		return rw
+	}
+	return &redactingWriter{w: w}
+}
+
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (rw *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, RedactSecrets(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
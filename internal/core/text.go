@@ -17,6 +17,21 @@ func FloorCharBoundary(s string, index int) int {
 	return index
 }
 
+// CeilCharBoundary returns the smallest byte index >= index that is a valid
+// UTF-8 character boundary in s.
+func CeilCharBoundary(s string, index int) int {
+	if index <= 0 {
+		return 0
+	}
+	for index < len(s) && !utf8.RuneStart(s[index]) {
+		index++
+	}
+	if index > len(s) {
+		index = len(s)
+	}
+	return index
+}
+
 // SplitText splits text into chunks of at most maxLen bytes, preferring to
 // break at newline boundaries.
 func SplitText(text string, maxLen int) []string {
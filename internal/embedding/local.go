@@ -0,0 +1,110 @@
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// localWeights are the relative weights of the features hashed into a
+// local embedding, keyed by feature prefix: words, word bigrams and
+// character trigrams.
+var localWeights = map[byte]float64{'w': 1.0, 'b': 0.6, 'c': 0.3}
+
+// localStopwords are frequent English words that carry no meaning on their
+// own and would otherwise dominate short texts.
+var localStopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "has": true, "have": true,
+	"i": true, "in": true, "is": true, "it": true, "its": true, "me": true,
+	"my": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "were": true, "with": true,
+}
+
+// LocalEmbeddingProvider embeds text offline by hashing word unigrams, word
+// bigrams and character trigrams into a fixed-size vector with sublinear
+// term frequency weighting. It captures lexical rather than deep semantic
+// similarity, but needs no network or model files and is deterministic.
+type LocalEmbeddingProvider struct {
+	dim int
+}
+
+// NewLocalEmbeddingProvider creates a local provider; dim defaults to 512.
+func NewLocalEmbeddingProvider(dim int) *LocalEmbeddingProvider {
+	if dim <= 0 {
+		dim = 512
+	}
+	return &LocalEmbeddingProvider{dim: dim}
+}
+
+// ModelName includes the dimension so stored vectors are recomputed when it
+// changes.
+func (p *LocalEmbeddingProvider) ModelName() string { return fmt.Sprintf("local-ngram-%d", p.dim) }
+func (p *LocalEmbeddingProvider) Dimension() int    { return p.dim }
+
+func (p *LocalEmbeddingProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		out[i] = p.embed(text)
+	}
+	return out, nil
+}
+
+func (p *LocalEmbeddingProvider) embed(text string) []float32 {
+	counts := map[string]int{}
+	words := localTokens(text)
+	for i, w := range words {
+		if !localStopwords[w] {
+			counts["w:"+w]++
+		}
+		if i > 0 {
+			counts["b:"+words[i-1]+" "+w]++
+		}
+		runes := []rune("^" + w + "$")
+		for j := 0; j+3 <= len(runes); j++ {
+			counts["c:"+string(runes[j:j+3])]++
+		}
+	}
+
+	vec := make([]float64, p.dim)
+	for feature, tf := range counts {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		// The hash picks the bucket and, from a separate bit, the sign, so
+		// collisions cancel out on average instead of piling up.
+		sign := 1.0
+		if sum>>63 == 1 {
+			sign = -1
+		}
+		vec[sum%uint64(p.dim)] += sign * localWeights[feature[0]] * (1 + math.Log(float64(tf)))
+	}
+
+	var norm float64
+	for _, v := range vec {
+		norm += v * v
+	}
+	out := make([]float32, p.dim)
+	if norm == 0 {
+		return out
+	}
+	norm = math.Sqrt(norm)
+	for i, v := range vec {
+		out[i] = float32(v / norm)
+	}
+	return out
+}
+
+// localTokens lowercases text and splits it into runs of letters and
+// digits.
+func localTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,48 @@
+package embedding
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestLocalEmbeddingProvider(t *testing.T) {
+	p := NewLocalEmbeddingProvider(0)
+	if p.Dimension() != 512 || p.ModelName() != "local-ngram-512" {
+		t.Fatalf("defaults: %d %s", p.Dimension(), p.ModelName())
+	}
+
+	texts := []string{
+		"The user prefers dark mode in their code editor",
+		"User prefers a dark mode code editor",
+		"Meeting with the dentist on Friday at 3pm",
+		"",
+	}
+	vecs, err := p.Embed(context.Background(), texts)
+	if err != nil || len(vecs) != len(texts) {
+		t.Fatalf("Embed: %d vectors, %v", len(vecs), err)
+	}
+	again, _ := p.Embed(context.Background(), texts[:1])
+	if Cosine(vecs[0], again[0]) < 0.9999 {
+		t.Error("embedding is not deterministic")
+	}
+
+	var norm float64
+	for _, v := range vecs[0] {
+		norm += float64(v) * float64(v)
+	}
+	if math.Abs(norm-1) > 1e-4 {
+		t.Errorf("vector not normalized: %f", norm)
+	}
+
+	similar, unrelated := Cosine(vecs[0], vecs[1]), Cosine(vecs[0], vecs[2])
+	if similar < 0.6 || unrelated > 0.3 {
+		t.Errorf("similar %.3f, unrelated %.3f", similar, unrelated)
+	}
+	if Cosine(vecs[0], vecs[3]) != 0 {
+		t.Error("empty text should embed to the zero vector")
+	}
+	if NewLocalEmbeddingProvider(64).ModelName() == p.ModelName() {
+		t.Error("model name must change with the dimension")
+	}
+}
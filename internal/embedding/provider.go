@@ -1,6 +1,9 @@
 package embedding
 
-import "context"
+import (
+	"context"
+	"math"
+)
 
 // EmbeddingProvider generates vector embeddings for text.
 type EmbeddingProvider interface {
@@ -8,3 +11,21 @@ type EmbeddingProvider interface {
 	Dimension() int
 	ModelName() string
 }
+
+// Cosine returns the cosine similarity of a and b, or 0 if their lengths
+// differ or either is zero.
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
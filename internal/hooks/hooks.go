@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/yifanes/miniclawd/internal/core"
 	"gopkg.in/yaml.v3"
 )
 
@@ -32,6 +33,9 @@ type HookDefinition struct {
 	Command     string `yaml:"command"`
 	Timeout     int    `yaml:"timeout"` // seconds, default 10
 	Enabled     bool   `yaml:"enabled"`
+
+	// Env adds environment variables; values may use ${secret:NAME}.
+	Env map[string]string `yaml:"env"`
 }
 
 // HookResponse is the JSON response from a hook subprocess.
@@ -45,6 +49,7 @@ type HookResponse struct {
 type HookManager struct {
 	hooks   []HookDefinition
 	hooksDir string
+	secrets  core.SecretLookup
 }
 
 // NewHookManager creates a HookManager by scanning the hooks directory.
@@ -55,6 +60,11 @@ func NewHookManager(dataDir string) *HookManager {
 	return hm
 }
 
+// SetSecretLookup sets how ${secret:NAME} references in hook env are resolved.
+func (m *HookManager) SetSecretLookup(lookup core.SecretLookup) {
+	m.secrets = lookup
+}
+
 func (m *HookManager) loadHooks() {
 	entries, err := os.ReadDir(m.hooksDir)
 	if err != nil {
@@ -132,6 +142,17 @@ func (m *HookManager) executeHook(ctx context.Context, hook HookDefinition, inpu
 
 	cmd := exec.CommandContext(cmdCtx, "bash", "-c", hook.Command)
 	cmd.Dir = filepath.Join(m.hooksDir, hook.Name)
+	if len(hook.Env) > 0 {
+		env := os.Environ()
+		for k, v := range hook.Env {
+			val, err := core.ExpandSecretRefs(v, m.secrets)
+			if err != nil {
+				return nil, fmt.Errorf("env %s: %w", k, err)
+			}
+			env = append(env, k+"="+val)
+		}
+		cmd.Env = env
+	}
 
 	// Pass input as JSON on stdin.
 	inputJSON, err := json.Marshal(input)
@@ -346,14 +346,15 @@ func translateToOpenAI(system string, messages []core.Message) []oaiMessage {
 				m.ToolCalls = toolCalls
 			}
 			out = append(out, m)
+			out = append(out, toolResults...)
 		} else if msg.Role == "user" {
+			// Tool results are separate messages and must directly follow the
+			// assistant's tool calls, before any extra user content.
+			out = append(out, toolResults...)
 			if len(textParts) > 0 {
 				out = append(out, oaiMessage{Role: "user", Content: strings.Join(textParts, "\n")})
 			}
 		}
-
-		// Tool results are separate messages.
-		out = append(out, toolResults...)
 	}
 
 	return out
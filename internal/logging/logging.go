@@ -11,6 +11,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/yifanes/miniclawd/internal/core"
 )
 
 const (
@@ -118,14 +120,14 @@ func InitFileLogging(logsDir string) error {
 	}
 	// Write to both file and stderr so early startup errors are still visible.
 	multi := io.MultiWriter(os.Stderr, w)
-	log.SetOutput(multi)
+	log.SetOutput(core.RedactingWriter(multi))
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
 	return nil
 }
 
 // InitConsoleLogging sets up standard console logging (default behavior).
 func InitConsoleLogging() {
-	log.SetOutput(os.Stderr)
+	log.SetOutput(core.RedactingWriter(os.Stderr))
 	log.SetFlags(log.LstdFlags)
 }
 
@@ -0,0 +1,119 @@
+package mcp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/yifanes/miniclawd/internal/core"
+)
+
+// maxImageData bounds inline images passed on to the model (base64 bytes).
+const maxImageData = 5 * 1024 * 1024
+
+// supportedImageTypes are the image formats LLM providers accept inline.
+var supportedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// content is one item of a tools/call or prompts/get result.
+type content struct {
+	Type        string            `json:"type"`
+	Text        string            `json:"text"`
+	Data        string            `json:"data"` // base64, for image and audio
+	MimeType    string            `json:"mimeType"`
+	URI         string            `json:"uri"` // resource_link
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Resource    *resourceContents `json:"resource"` // embedded resource
+}
+
+// resourceContents is the body of a resource, as returned by resources/read
+// or embedded in other results.
+type resourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Blob     string `json:"blob"` // base64
+}
+
+// convertContent flattens MCP content into text for the model, and returns
+// images as separate content blocks. Anything that cannot be passed on is
+// described in the text rather than dropped silently.
+func convertContent(items []content) (string, []core.ContentBlock) {
+	var texts []string
+	var blocks []core.ContentBlock
+	for _, c := range items {
+		switch c.Type {
+		case "text":
+			texts = append(texts, c.Text)
+		case "image":
+			text, block := convertImage(c.MimeType, c.Data, "")
+			texts = append(texts, text)
+			if block != nil {
+				blocks = append(blocks, *block)
+			}
+		case "audio":
+			texts = append(texts, fmt.Sprintf("[audio: %s, %d bytes, not shown]", c.MimeType, base64.StdEncoding.DecodedLen(len(c.Data))))
+		case "resource":
+			if c.Resource != nil {
+				text, block := convertResource(*c.Resource)
+				texts = append(texts, text)
+				if block != nil {
+					blocks = append(blocks, *block)
+				}
+			}
+		case "resource_link":
+			link := fmt.Sprintf("[resource: %s <%s>", c.Name, c.URI)
+			if c.Description != "" {
+				link += " - " + c.Description
+			}
+			texts = append(texts, link+"] (read it with mcp_read_resource)")
+		default:
+			texts = append(texts, fmt.Sprintf("[unsupported %s content]", c.Type))
+		}
+	}
+	return strings.Join(texts, "\n"), blocks
+}
+
+// convertResources converts resources/read contents.
+func convertResources(items []resourceContents) (string, []core.ContentBlock) {
+	var texts []string
+	var blocks []core.ContentBlock
+	for _, r := range items {
+		text, block := convertResource(r)
+		texts = append(texts, text)
+		if block != nil {
+			blocks = append(blocks, *block)
+		}
+	}
+	return strings.Join(texts, "\n\n"), blocks
+}
+
+func convertResource(r resourceContents) (string, *core.ContentBlock) {
+	if r.Blob == "" {
+		return fmt.Sprintf("[resource %s]\n%s", r.URI, r.Text), nil
+	}
+	if strings.HasPrefix(r.MimeType, "image/") {
+		return convertImage(r.MimeType, r.Blob, r.URI)
+	}
+	return fmt.Sprintf("[resource %s: %s, %d bytes of binary data, not shown]", r.URI, r.MimeType, base64.StdEncoding.DecodedLen(len(r.Blob))), nil
+}
+
+func convertImage(mimeType, data, uri string) (string, *core.ContentBlock) {
+	label := mimeType
+	if uri != "" {
+		label = uri + ", " + mimeType
+	}
+	switch {
+	case !supportedImageTypes[mimeType]:
+		return fmt.Sprintf("[image: %s, unsupported format, not shown]", label), nil
+	case len(data) > maxImageData:
+		return fmt.Sprintf("[image: %s, too large to show]", label), nil
+	}
+	block := core.ImageBlock(mimeType, data)
+	return fmt.Sprintf("[image: %s, attached]", label), &block
+}
@@ -0,0 +1,491 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	headerSessionID       = "Mcp-Session-Id"
+	headerProtocolVersion = "Mcp-Protocol-Version"
+
+	// maxResumeAttempts bounds how often a dropped response stream is
+	// resumed with Last-Event-ID before the call fails.
+	maxResumeAttempts = 3
+)
+
+// errSessionExpired means the server no longer knows our session and a new
+// initialize is needed.
+var errSessionExpired = errors.New("MCP session expired")
+
+// httpStatusError is a non-2xx HTTP response from an MCP endpoint.
+type httpStatusError struct {
+	Code int
+	Body string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("HTTP %d: %.200s", e.Code, e.Body)
+}
+
+// legacyFallback reports whether err suggests the server only speaks the
+// older HTTP+SSE transport: it rejected the initialize POST outright.
+func legacyFallback(err error) bool {
+	var se *httpStatusError
+	if !errors.As(err, &se) {
+		return false
+	}
+	return se.Code == http.StatusBadRequest || se.Code == http.StatusNotFound || se.Code == http.StatusMethodNotAllowed
+}
+
+// httpConn implements the Streamable HTTP transport: every message is a
+// POST to one endpoint, answered with either a JSON body or an SSE stream.
+// A Mcp-Session-Id from initialize is echoed on later requests, and a
+// background GET stream carries server-initiated messages.
+type httpConn struct {
+	*router
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+
+	ctx    context.Context // connection lifetime; ends on close
+	cancel context.CancelFunc
+
+	mu              sync.Mutex
+	sessionID       string
+	protocolVersion string
+	listening       bool
+}
+
+func newHTTPConn(ctx context.Context, name, endpoint string, headers map[string]string, onNotify func(string, json.RawMessage)) *httpConn {
+	c := &httpConn{endpoint: endpoint, headers: headers, client: &http.Client{}}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.router = newRouter(name, onNotify, func(b []byte) {
+		go func() {
+			if resp, err := c.post(c.ctx, b); err == nil {
+				drain(resp)
+			}
+		}()
+	})
+	return c
+}
+
+func (c *httpConn) call(ctx context.Context, id int64, method string, params any) (json.RawMessage, error) {
+	body, err := newRequest(id, method, params)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := c.register(id)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.post(ctx, body)
+	if err != nil {
+		c.forget(id)
+		return nil, err
+	}
+	c.readResponse(ctx, id, resp)
+
+	result, err := c.wait(ctx, id, ch)
+	if err == nil && method == "initialize" {
+		var init struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(result, &init)
+		c.mu.Lock()
+		c.protocolVersion = init.ProtocolVersion
+		c.mu.Unlock()
+	}
+	return result, err
+}
+
+func (c *httpConn) notify(ctx context.Context, method string, params any) error {
+	body, err := newNotification(method, params)
+	if err != nil {
+		return err
+	}
+	resp, err := c.post(ctx, body)
+	if err != nil {
+		return err
+	}
+	drain(resp)
+	if method == "notifications/initialized" {
+		c.startListening()
+	}
+	return nil
+}
+
+// readResponse consumes the reply to request id. An SSE reply may carry
+// notifications and server requests before the response itself; if the
+// stream drops first it is resumed from the last event ID.
+func (c *httpConn) readResponse(ctx context.Context, id int64, resp *http.Response) {
+	defer resp.Body.Close()
+	if !isEventStream(resp) {
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxStdioMessage+1))
+		if err != nil {
+			c.fail(id, err)
+			return
+		}
+		if len(data) > maxStdioMessage {
+			c.fail(id, fmt.Errorf("response exceeds %d bytes", maxStdioMessage))
+			return
+		}
+		c.dispatchRaw(data)
+		if c.waiting(id) {
+			c.fail(id, fmt.Errorf("no response in HTTP %d reply", resp.StatusCode))
+		}
+		return
+	}
+
+	lastID, err := c.readStream(resp.Body, func() bool { return c.waiting(id) })
+	for attempt := 0; c.waiting(id) && lastID != "" && attempt < maxResumeAttempts; attempt++ {
+		log.Printf("[mcp] server %q: response stream dropped (%v), resuming after event %s", c.name, err, lastID)
+		var r *http.Response
+		r, err = c.get(ctx, lastID)
+		if err != nil {
+			break
+		}
+		var next string
+		next, err = c.readStream(r.Body, func() bool { return c.waiting(id) })
+		r.Body.Close()
+		if next != "" {
+			lastID = next
+		}
+	}
+	if c.waiting(id) {
+		if err == nil {
+			err = errors.New("stream ended before response")
+		}
+		c.fail(id, err)
+	}
+}
+
+// readStream dispatches SSE messages until the stream ends or more returns
+// false, and returns the last event ID seen.
+func (c *httpConn) readStream(r io.Reader, more func() bool) (string, error) {
+	lastID := ""
+	err := readSSE(r, func(ev sseEvent) bool {
+		if ev.ID != "" {
+			lastID = ev.ID
+		}
+		if ev.Data != "" && (ev.Event == "" || ev.Event == "message") {
+			c.dispatchRaw([]byte(ev.Data))
+		}
+		return more()
+	})
+	return lastID, err
+}
+
+// startListening opens the optional GET stream for server-initiated
+// messages such as list_changed notifications. Servers that don't offer it
+// answer 405, and we stop trying.
+func (c *httpConn) startListening() {
+	c.mu.Lock()
+	if c.listening {
+		c.mu.Unlock()
+		return
+	}
+	c.listening = true
+	c.mu.Unlock()
+
+	go func() {
+		lastID := ""
+		backoff := time.Second
+		for c.ctx.Err() == nil {
+			resp, err := c.get(c.ctx, lastID)
+			var se *httpStatusError
+			if errors.As(err, &se) && se.Code == http.StatusMethodNotAllowed || errors.Is(err, errSessionExpired) {
+				return // the next initialize starts a new listener
+			}
+			if err == nil {
+				backoff = time.Second
+				next, _ := c.readStream(resp.Body, func() bool { return c.ctx.Err() == nil })
+				resp.Body.Close()
+				if next != "" {
+					lastID = next
+				}
+			}
+			select {
+			case <-c.ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, 30*time.Second)
+		}
+	}()
+}
+
+func (c *httpConn) post(ctx context.Context, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	return c.do(req)
+}
+
+func (c *httpConn) get(ctx context.Context, lastEventID string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := c.do(req)
+	if err == nil && !isEventStream(resp) {
+		drain(resp)
+		return nil, &httpStatusError{Code: http.StatusMethodNotAllowed, Body: "no event stream"}
+	}
+	return resp, err
+}
+
+func (c *httpConn) do(req *http.Request) (*http.Response, error) {
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	c.mu.Lock()
+	session, version := c.sessionID, c.protocolVersion
+	c.mu.Unlock()
+	if session != "" {
+		req.Header.Set(headerSessionID, session)
+	}
+	if version != "" {
+		req.Header.Set(headerProtocolVersion, version)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if id := resp.Header.Get(headerSessionID); id != "" && session == "" {
+		c.mu.Lock()
+		c.sessionID = id
+		c.mu.Unlock()
+	}
+	if resp.StatusCode == http.StatusNotFound && session != "" {
+		drain(resp)
+		c.mu.Lock()
+		c.sessionID, c.listening = "", false
+		c.mu.Unlock()
+		return nil, errSessionExpired
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &httpStatusError{Code: resp.StatusCode, Body: string(b)}
+	}
+	return resp, nil
+}
+
+// close ends the session on the server and stops all streams.
+func (c *httpConn) close() {
+	c.mu.Lock()
+	session := c.sessionID
+	c.mu.Unlock()
+	if session != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.endpoint, nil); err == nil {
+			if resp, err := c.do(req); err == nil {
+				drain(resp)
+			}
+		}
+		cancel()
+	}
+	c.cancel()
+	c.shutdown(errConnClosed)
+}
+
+// sseConn implements the legacy HTTP+SSE transport (protocol 2024-11-05):
+// a long-lived GET stream announces a POST endpoint in an "endpoint" event,
+// and every response arrives on the stream.
+type sseConn struct {
+	*router
+	headers  map[string]string
+	client   *http.Client
+	endpoint string
+	cancel   context.CancelFunc
+}
+
+func dialSSE(ctx context.Context, name, streamURL string, headers map[string]string, onNotify func(string, json.RawMessage)) (*sseConn, error) {
+	c := &sseConn{headers: headers, client: &http.Client{}}
+	lifetime, cancel := context.WithCancel(ctx)
+	c.cancel = cancel
+	c.router = newRouter(name, onNotify, func(b []byte) { go c.send(lifetime, b) })
+
+	req, err := http.NewRequestWithContext(lifetime, http.MethodGet, streamURL, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode >= 400 || !isEventStream(resp) {
+		drain(resp)
+		cancel()
+		return nil, &httpStatusError{Code: resp.StatusCode, Body: "expected an SSE stream"}
+	}
+
+	endpoint := make(chan string, 1)
+	go func() {
+		defer resp.Body.Close()
+		err := readSSE(resp.Body, func(ev sseEvent) bool {
+			switch ev.Event {
+			case "endpoint":
+				select {
+				case endpoint <- ev.Data:
+				default:
+				}
+			case "", "message":
+				c.dispatchRaw([]byte(ev.Data))
+			}
+			return true
+		})
+		if err == nil {
+			err = errConnClosed
+		}
+		c.shutdown(err)
+	}()
+
+	select {
+	case ep := <-endpoint:
+		base, _ := url.Parse(streamURL)
+		ref, err := url.Parse(strings.TrimSpace(ep))
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("bad endpoint %q: %w", ep, err)
+		}
+		c.endpoint = base.ResolveReference(ref).String()
+		return c, nil
+	case <-c.done:
+		cancel()
+		return nil, c.closeErr()
+	case <-time.After(initTimeout):
+		cancel()
+		return nil, errors.New("no endpoint event on SSE stream")
+	}
+}
+
+func (c *sseConn) call(ctx context.Context, id int64, method string, params any) (json.RawMessage, error) {
+	body, err := newRequest(id, method, params)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := c.register(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.send(ctx, body); err != nil {
+		c.forget(id)
+		return nil, err
+	}
+	return c.wait(ctx, id, ch)
+}
+
+func (c *sseConn) notify(ctx context.Context, method string, params any) error {
+	body, err := newNotification(method, params)
+	if err != nil {
+		return err
+	}
+	return c.send(ctx, body)
+}
+
+func (c *sseConn) send(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &httpStatusError{Code: resp.StatusCode, Body: string(b)}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (c *sseConn) close() {
+	c.cancel()
+	c.shutdown(errConnClosed)
+}
+
+// --- SSE parsing ---
+
+// sseEvent is one server-sent event.
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// readSSE calls fn for every event in r until r ends or fn returns false.
+func readSSE(r io.Reader, fn func(sseEvent) bool) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxStdioMessage)
+	var ev sseEvent
+	var data []string
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if line == "" {
+			if len(data) > 0 || ev.ID != "" {
+				ev.Data = strings.Join(data, "\n")
+				if !fn(ev) {
+					return nil
+				}
+			}
+			ev, data = sseEvent{}, nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // comment / keep-alive
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			ev.Event = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			ev.ID = value
+		}
+	}
+	return sc.Err()
+}
+
+func isEventStream(resp *http.Response) bool {
+	return strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+}
+
+func drain(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+}
@@ -0,0 +1,210 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeResult answers the requests our client sends during the tests.
+func fakeResult(req *rpcMessage) any {
+	switch req.Method {
+	case "initialize":
+		return map[string]any{"protocolVersion": protocolVersion, "capabilities": map[string]any{}}
+	case "tools/list":
+		return map[string]any{"tools": []map[string]any{{"name": "echo", "inputSchema": map[string]any{"type": "object"}}}}
+	}
+	var p struct {
+		Arguments struct {
+			Text string `json:"text"`
+		} `json:"arguments"`
+	}
+	json.Unmarshal(req.Params, &p)
+	return map[string]any{"content": []map[string]any{{"type": "text", "text": "echo: " + p.Arguments.Text}}}
+}
+
+func encodeResponse(req *rpcMessage) string {
+	b, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": fakeResult(req)})
+	return string(b)
+}
+
+// streamableServer speaks Streamable HTTP: sessions, SSE replies to
+// tools/call, and resumption of a dropped stream via Last-Event-ID.
+type streamableServer struct {
+	mu       sync.Mutex
+	sessions map[string]bool
+	nextSess int
+	dropped  *rpcMessage // call whose stream was cut short
+}
+
+func (f *streamableServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer tok" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	session := r.Header.Get(headerSessionID)
+
+	switch r.Method {
+	case http.MethodGet:
+		if r.Header.Get("Last-Event-ID") != "1" || f.dropped == nil {
+			http.Error(w, "no standalone stream", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "id: 2\ndata: %s\n\n", encodeResponse(f.dropped))
+		f.dropped = nil
+		return
+	case http.MethodDelete:
+		delete(f.sessions, session)
+		return
+	}
+
+	var req rpcMessage
+	body, _ := io.ReadAll(r.Body)
+	json.Unmarshal(body, &req)
+	if req.Method == "initialize" {
+		f.nextSess++
+		session = fmt.Sprintf("s%d", f.nextSess)
+		f.sessions[session] = true
+		w.Header().Set(headerSessionID, session)
+	} else if !f.sessions[session] {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+	if len(req.ID) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if req.Method != "tools/call" {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, encodeResponse(&req))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	fmt.Fprint(w, ": keep-alive\n\nid: 1\nevent: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+	if containsText(req.Params, "drop") {
+		f.dropped = &req
+		return
+	}
+	fmt.Fprintf(w, "data: %s\n\n", encodeResponse(&req))
+}
+
+func containsText(params json.RawMessage, text string) bool {
+	var p struct {
+		Arguments map[string]string `json:"arguments"`
+	}
+	json.Unmarshal(params, &p)
+	return p.Arguments["text"] == text
+}
+
+// legacySSEServer speaks the 2024-11-05 HTTP+SSE transport only.
+type legacySSEServer struct {
+	out chan string
+}
+
+func (f *legacySSEServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/sse":
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: endpoint\ndata: /messages?session=1\n\n")
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case msg := <-f.out:
+				fmt.Fprintf(w, "event: message\ndata: %s\n\n", msg)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	case r.Method == http.MethodPost && r.URL.Path == "/messages":
+		var req rpcMessage
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.ID) > 0 {
+			f.out <- encodeResponse(&req)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func TestStreamableHTTP(t *testing.T) {
+	fake := &streamableServer{sessions: map[string]bool{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	m := NewMcpManager([]McpServerConfig{{Name: "remote", Transport: "http", URL: srv.URL, BearerToken: "tok"}})
+	m.Initialize(context.Background())
+	defer m.Close()
+	if st := m.Status()[0]; st.State != StateRunning || st.Transport != "http" || st.Tools != 1 {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+
+	ctx := context.Background()
+	if got, _, err := m.CallTool(ctx, "remote", "echo", json.RawMessage(`{"text":"hi"}`)); err != nil || got != "echo: hi" {
+		t.Errorf("SSE reply: %q, %v", got, err)
+	}
+	if got, _, err := m.CallTool(ctx, "remote", "echo", json.RawMessage(`{"text":"drop"}`)); err != nil || got != "echo: drop" {
+		t.Errorf("resumed reply: %q, %v", got, err)
+	}
+
+	fake.mu.Lock()
+	fake.sessions = map[string]bool{}
+	fake.mu.Unlock()
+	if got, _, err := m.CallTool(ctx, "remote", "echo", json.RawMessage(`{"text":"again"}`)); err != nil || got != "echo: again" {
+		t.Errorf("after session expiry: %q, %v", got, err)
+	}
+	if fake.nextSess != 2 {
+		t.Errorf("expected a second session, got %d", fake.nextSess)
+	}
+}
+
+func TestLegacySSEFallback(t *testing.T) {
+	srv := httptest.NewServer(&legacySSEServer{out: make(chan string, 8)})
+	defer srv.Close()
+
+	m := NewMcpManager([]McpServerConfig{{Name: "old", Transport: "http", URL: srv.URL + "/sse"}})
+	m.Initialize(context.Background())
+	defer m.Close()
+	if st := m.Status()[0]; st.State != StateRunning || st.Transport != "sse" {
+		t.Fatalf("expected fallback to sse, got %+v", st)
+	}
+	if got, _, err := m.CallTool(context.Background(), "old", "echo", json.RawMessage(`{"text":"hi"}`)); err != nil || got != "echo: hi" {
+		t.Errorf("CallTool = %q, %v", got, err)
+	}
+}
+
+func TestHTTPResponseLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcMessage
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.ID) == 0 {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if req.Method == "tools/call" {
+			io.WriteString(w, strings.Repeat(" ", maxStdioMessage))
+		}
+		io.WriteString(w, encodeResponse(&req))
+	}))
+	defer srv.Close()
+
+	m := NewMcpManager([]McpServerConfig{{Name: "remote", Transport: "http", URL: srv.URL}})
+	m.Initialize(context.Background())
+	defer m.Close()
+	_, _, err := m.CallTool(context.Background(), "remote", "echo", json.RawMessage(`{"text":"hi"}`))
+	if err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Fatalf("oversized reply: err = %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// rpcMessage is any JSON-RPC 2.0 message: a request (Method and ID), a
+// notification (Method only) or a response (ID with Result or Error).
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("MCP error %d: %s", e.Code, e.Message)
+}
+
+const rpcMethodNotFound = -32601
+
+func newRequest(id int64, method string, params any) ([]byte, error) {
+	msg := map[string]any{"jsonrpc": "2.0", "id": id, "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	return json.Marshal(msg)
+}
+
+func newNotification(method string, params any) ([]byte, error) {
+	msg := map[string]any{"jsonrpc": "2.0", "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	return json.Marshal(msg)
+}
+
+// isResponse reports whether msg answers one of our requests.
+func (m *rpcMessage) isResponse() bool {
+	return m.Method == "" && len(m.ID) > 0
+}
+
+// responseID returns the numeric ID of a response. Servers echo the IDs we
+// send, which are always integers, but some quote them.
+func (m *rpcMessage) responseID() (int64, bool) {
+	var n int64
+	if err := json.Unmarshal(m.ID, &n); err == nil {
+		return n, true
+	}
+	var s string
+	if err := json.Unmarshal(m.ID, &s); err == nil {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// replyTo builds our answer to a request the server sent us. Only ping is
+// supported; everything else gets "method not found".
+func replyTo(req *rpcMessage) []byte {
+	resp := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+	if req.Method == "ping" {
+		resp["result"] = map[string]any{}
+	} else {
+		resp["error"] = rpcError{Code: rpcMethodNotFound, Message: "method not supported: " + req.Method}
+	}
+	b, _ := json.Marshal(resp)
+	return b
+}
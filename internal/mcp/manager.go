@@ -1,53 +1,94 @@
 package mcp
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
+	"os"
 	"os/exec"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/yifanes/miniclawd/internal/core"
 )
 
+// initTimeout bounds starting a server and listing its tools, so one hung
+// server cannot stall boot.
+const initTimeout = 30 * time.Second
+
+// defaultCallTimeout applies to tool calls whose context has no deadline.
+const defaultCallTimeout = 2 * time.Minute
+
+// protocolVersion is the MCP revision we request; servers may answer with
+// an older one they support.
+const protocolVersion = "2025-03-26"
+
+// Server states reported by Status.
+const (
+	StateStopped = "stopped"
+	StateRunning = "running"
+	StateFailed  = "failed"
+)
+
 // McpServer represents a configured MCP server.
 type McpServer struct {
-	Name      string
-	Transport string // "stdio" or "http"
-	Command   string // for stdio
-	Args      []string
-	URL       string // for http
-
-	// Runtime state for stdio.
-	cmd    *exec.Cmd
-	stdin  io.WriteCloser
-	stdout *bufio.Scanner
-	mu     sync.Mutex
-	nextID int
-
-	// Cached tools.
+	Name        string
+	Transport   string // "stdio", "http" (Streamable HTTP) or "sse" (legacy HTTP+SSE)
+	Command     string // for stdio
+	Args        []string
+	Env         map[string]string // extra env for stdio
+	Cwd         string            // working directory for stdio
+	URL         string            // for http and sse
+	Headers     map[string]string // extra HTTP headers
+	BearerToken string
+	Timeout     time.Duration // per tool call, unless the caller's context sets one
+
+	mu        sync.Mutex
+	nextID    int64
+	conn      transport
+	legacySSE bool // http server turned out to speak HTTP+SSE
+	state     string
+	lastErr   error
 	tools     []core.ToolDefinition
-	toolsOnce sync.Once
+
+	prompts      []Prompt
+	hasResources bool
+	refresh      chan string // list_changed notifications for the supervisor
 }
 
 // McpManager manages multiple MCP servers.
 type McpManager struct {
 	servers map[string]*McpServer
+
+	onToolsChanged func(server string, tools []core.ToolDefinition)
+	cancel         context.CancelFunc // stops supervision
+	supervisors    sync.WaitGroup
 }
 
 // McpServerConfig describes an MCP server in config.
 type McpServerConfig struct {
-	Name      string   `yaml:"name"`
-	Transport string   `yaml:"transport"` // "stdio" or "http"
-	Command   string   `yaml:"command"`
-	Args      []string `yaml:"args"`
-	URL       string   `yaml:"url"`
+	Name        string            `yaml:"name"`
+	Transport   string            `yaml:"transport"` // "stdio", "http" or "sse"
+	Command     string            `yaml:"command"`
+	Args        []string          `yaml:"args"`
+	Env         map[string]string `yaml:"env"`
+	Cwd         string            `yaml:"cwd"`
+	URL         string            `yaml:"url"`
+	Headers     map[string]string `yaml:"headers"`
+	BearerToken string            `yaml:"bearer_token"`
+	TimeoutSecs int               `yaml:"timeout_secs"`
+}
+
+// ServerStatus summarizes one server for diagnostics.
+type ServerStatus struct {
+	Name      string
+	Transport string
+	State     string
+	Tools     int
+	Error     string
 }
 
 // NewMcpManager creates a manager from config.
@@ -55,55 +96,70 @@ func NewMcpManager(configs []McpServerConfig) *McpManager {
 	m := &McpManager{servers: make(map[string]*McpServer)}
 	for _, cfg := range configs {
 		server := &McpServer{
-			Name:      cfg.Name,
-			Transport: cfg.Transport,
-			Command:   cfg.Command,
-			Args:      cfg.Args,
-			URL:       cfg.URL,
+			Name:        cfg.Name,
+			Transport:   cfg.Transport,
+			Command:     cfg.Command,
+			Args:        cfg.Args,
+			Env:         cfg.Env,
+			Cwd:         cfg.Cwd,
+			URL:         cfg.URL,
+			Headers:     cfg.Headers,
+			BearerToken: cfg.BearerToken,
+			Timeout:     defaultCallTimeout,
+			state:       StateStopped,
+			refresh:     make(chan string, 4),
+		}
+		if cfg.TimeoutSecs > 0 {
+			server.Timeout = time.Duration(cfg.TimeoutSecs) * time.Second
 		}
 		m.servers[cfg.Name] = server
 	}
 	return m
 }
 
-// Initialize starts all servers and discovers tools.
+// Initialize starts all servers concurrently and discovers their tools.
+// Servers that fail are logged and marked failed; the rest stay usable.
 func (m *McpManager) Initialize(ctx context.Context) error {
+	var wg sync.WaitGroup
 	for name, server := range m.servers {
-		if err := server.start(ctx); err != nil {
-			log.Printf("[mcp] failed to start server %q: %v", name, err)
-			continue
-		}
-		if err := server.initialize(ctx); err != nil {
-			log.Printf("[mcp] failed to initialize server %q: %v", name, err)
-		}
-	}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := server.connect(ctx); err != nil {
+				log.Printf("[mcp] server %q: %v", name, err)
+			}
+		}()
+	}
+	wg.Wait()
 	return nil
 }
 
 // GetAllTools returns tool definitions from all MCP servers.
 func (m *McpManager) GetAllTools() []core.ToolDefinition {
 	var allTools []core.ToolDefinition
-	for _, server := range m.servers {
-		allTools = append(allTools, server.tools...)
+	for _, name := range m.ServerNames() {
+		allTools = append(allTools, m.servers[name].Tools()...)
 	}
 	return allTools
 }
 
-// CallTool dispatches a tool call to the appropriate server.
-func (m *McpManager) CallTool(ctx context.Context, serverName, toolName string, input json.RawMessage) (string, error) {
+// CallTool dispatches a tool call to the appropriate server. Images in the
+// result come back as content blocks alongside the text.
+func (m *McpManager) CallTool(ctx context.Context, serverName, toolName string, input json.RawMessage) (string, []core.ContentBlock, error) {
 	server, ok := m.servers[serverName]
 	if !ok {
-		return "", fmt.Errorf("unknown MCP server: %s", serverName)
+		return "", nil, fmt.Errorf("unknown MCP server: %s", serverName)
 	}
 	return server.callTool(ctx, toolName, input)
 }
 
-// ServerNames returns the names of all configured servers.
+// ServerNames returns the sorted names of all configured servers.
 func (m *McpManager) ServerNames() []string {
-	var names []string
+	names := make([]string, 0, len(m.servers))
 	for n := range m.servers {
 		names = append(names, n)
 	}
+	sort.Strings(names)
 	return names
 }
 
@@ -112,39 +168,165 @@ func (m *McpManager) Server(name string) *McpServer {
 	return m.servers[name]
 }
 
+// Status reports the state of every server, sorted by name.
+func (m *McpManager) Status() []ServerStatus {
+	var out []ServerStatus
+	for _, name := range m.ServerNames() {
+		out = append(out, m.servers[name].Status())
+	}
+	return out
+}
+
+// Close stops supervision and terminates all server processes.
+func (m *McpManager) Close() {
+	if m.cancel != nil {
+		m.cancel()
+		m.supervisors.Wait()
+	}
+	for _, server := range m.servers {
+		server.close()
+	}
+}
+
 // --- MCP Server methods ---
 
-func (s *McpServer) start(ctx context.Context) error {
-	if s.Transport == "http" {
-		return nil // No process to start.
+// Tools returns the tools the server advertised.
+func (s *McpServer) Tools() []core.ToolDefinition {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]core.ToolDefinition(nil), s.tools...)
+}
+
+// Status reports the server's current state.
+func (s *McpServer) Status() ServerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := ServerStatus{Name: s.Name, Transport: s.transport(), State: s.state, Tools: len(s.tools)}
+	if s.lastErr != nil {
+		st.Error = s.lastErr.Error()
 	}
+	return st
+}
 
-	cmd := exec.CommandContext(ctx, s.Command, s.Args...)
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return err
+func (s *McpServer) transport() string {
+	switch {
+	case s.legacySSE:
+		return "sse"
+	case s.Transport == "":
+		return "stdio"
+	}
+	return s.Transport
+}
+
+func (s *McpServer) connect(ctx context.Context) error {
+	err := s.start(ctx)
+	if err == nil {
+		err = s.initializeWithTimeout(ctx)
+	}
+	// Servers that reject the Streamable HTTP POST may only speak the
+	// older HTTP+SSE transport.
+	if s.Transport == "http" && legacyFallback(err) {
+		log.Printf("[mcp] server %q: %v; falling back to HTTP+SSE", s.Name, err)
+		s.closeConn()
+		s.mu.Lock()
+		s.legacySSE = true
+		s.mu.Unlock()
+		err = s.start(ctx)
+		if err == nil {
+			err = s.initializeWithTimeout(ctx)
+		}
 	}
-	stdout, err := cmd.StdoutPipe()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if err != nil {
+		s.state, s.lastErr = StateFailed, err
+		if s.conn != nil {
+			s.conn.close()
+			s.conn = nil
+		}
 		return err
 	}
+	s.state, s.lastErr = StateRunning, nil
+	return nil
+}
 
-	if err := cmd.Start(); err != nil {
-		return err
+func (s *McpServer) initializeWithTimeout(ctx context.Context) error {
+	initCtx, cancel := context.WithTimeout(ctx, initTimeout)
+	defer cancel()
+	return s.initialize(initCtx)
+}
+
+func (s *McpServer) start(ctx context.Context) error {
+	if s.Transport == "http" || s.Transport == "sse" {
+		headers := make(map[string]string, len(s.Headers)+1)
+		for k, v := range s.Headers {
+			headers[k] = v
+		}
+		if s.BearerToken != "" {
+			headers["Authorization"] = "Bearer " + s.BearerToken
+		}
+
+		s.mu.Lock()
+		legacy := s.legacySSE || s.Transport == "sse"
+		s.mu.Unlock()
+		var conn transport
+		if legacy {
+			c, err := dialSSE(ctx, s.Name, s.URL, headers, s.handleNotification)
+			if err != nil {
+				return fmt.Errorf("connect SSE stream: %w", err)
+			}
+			conn = c
+		} else {
+			conn = newHTTPConn(ctx, s.Name, s.URL, headers, s.handleNotification)
+		}
+		s.mu.Lock()
+		s.conn = conn
+		s.mu.Unlock()
+		return nil
+	}
+	if s.Command == "" {
+		return fmt.Errorf("no command configured")
 	}
 
-	s.cmd = cmd
-	s.stdin = stdin
-	s.stdout = bufio.NewScanner(stdout)
-	s.stdout.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
+	cmd := exec.CommandContext(ctx, s.Command, s.Args...)
+	cmd.Dir = s.Cwd
+	if len(s.Env) > 0 {
+		env := os.Environ()
+		for k, v := range s.Env {
+			env = append(env, k+"="+v)
+		}
+		cmd.Env = env
+	}
 
+	conn, err := startStdio(s.Name, cmd, s.handleNotification)
+	if err != nil {
+		return fmt.Errorf("start %s: %w", s.Command, err)
+	}
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
 	return nil
 }
 
+func (s *McpServer) handleNotification(method string, _ json.RawMessage) {
+	switch method {
+	case "notifications/message":
+	case "notifications/tools/list_changed", "notifications/prompts/list_changed":
+		// Refreshing needs a round trip, which must not happen on the
+		// read loop delivering this notification; the supervisor does it.
+		select {
+		case s.refresh <- method:
+		default:
+		}
+	default:
+		log.Printf("[mcp] server %q: notification %s", s.Name, method)
+	}
+}
+
 func (s *McpServer) initialize(ctx context.Context) error {
 	// Send initialize request.
 	resp, err := s.jsonRPC(ctx, "initialize", map[string]any{
-		"protocolVersion": "2024-11-05",
+		"protocolVersion": protocolVersion,
 		"capabilities":    map[string]any{},
 		"clientInfo": map[string]string{
 			"name":    "miniclawd",
@@ -154,184 +336,162 @@ func (s *McpServer) initialize(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("initialize: %w", err)
 	}
-	_ = resp
-
-	// Discover tools.
-	toolsResp, err := s.jsonRPC(ctx, "tools/list", nil)
-	if err != nil {
-		return fmt.Errorf("tools/list: %w", err)
-	}
-
-	var toolList struct {
-		Tools []struct {
-			Name        string          `json:"name"`
-			Description string          `json:"description"`
-			InputSchema json.RawMessage `json:"inputSchema"`
-		} `json:"tools"`
+	var init struct {
+		Capabilities struct {
+			Resources json.RawMessage `json:"resources"`
+			Prompts   json.RawMessage `json:"prompts"`
+		} `json:"capabilities"`
 	}
-	if err := json.Unmarshal(toolsResp, &toolList); err != nil {
-		return fmt.Errorf("parsing tools: %w", err)
+	json.Unmarshal(resp, &init)
+	if err := s.notify(ctx, "notifications/initialized", nil); err != nil {
+		return fmt.Errorf("initialized: %w", err)
 	}
 
-	for _, t := range toolList.Tools {
-		s.tools = append(s.tools, core.ToolDefinition{
-			Name:        t.Name,
-			Description: t.Description,
-			InputSchema: t.InputSchema,
-		})
+	// Discover tools and prompts. Resources are listed on demand since
+	// they change more often.
+	tools, err := s.listTools(ctx)
+	if err != nil {
+		return err
+	}
+	var prompts []Prompt
+	if len(init.Capabilities.Prompts) > 0 {
+		if prompts, err = s.listPrompts(ctx); err != nil {
+			log.Printf("[mcp] server %q: %v", s.Name, err)
+		}
 	}
+	s.mu.Lock()
+	s.tools = tools
+	s.prompts = prompts
+	s.hasResources = len(init.Capabilities.Resources) > 0
+	s.mu.Unlock()
 
-	log.Printf("[mcp] server %q: %d tools discovered", s.Name, len(s.tools))
+	log.Printf("[mcp] server %q: %d tools, %d prompts discovered", s.Name, len(tools), len(prompts))
 	return nil
 }
 
-func (s *McpServer) callTool(ctx context.Context, toolName string, input json.RawMessage) (string, error) {
+// listTools fetches every page of tools/list.
+func (s *McpServer) listTools(ctx context.Context) ([]core.ToolDefinition, error) {
+	var tools []core.ToolDefinition
+	err := s.paginate(ctx, "tools/list", func(page json.RawMessage) error {
+		var p struct {
+			Tools []struct {
+				Name        string          `json:"name"`
+				Description string          `json:"description"`
+				InputSchema json.RawMessage `json:"inputSchema"`
+			} `json:"tools"`
+		}
+		if err := json.Unmarshal(page, &p); err != nil {
+			return err
+		}
+		for _, t := range p.Tools {
+			schema := t.InputSchema
+			if len(schema) == 0 || string(schema) == "null" {
+				schema = json.RawMessage(`{"type":"object","properties":{}}`)
+			}
+			tools = append(tools, core.ToolDefinition{
+				Name:        t.Name,
+				Description: t.Description,
+				InputSchema: schema,
+			})
+		}
+		return nil
+	})
+	if isMethodNotFound(err) {
+		return nil, nil // a resources- or prompts-only server
+	}
+	return tools, err
+}
+
+func (s *McpServer) callTool(ctx context.Context, toolName string, input json.RawMessage) (string, []core.ContentBlock, error) {
+	ctx, cancel := s.callContext(ctx)
+	defer cancel()
 	resp, err := s.jsonRPC(ctx, "tools/call", map[string]any{
 		"name":      toolName,
 		"arguments": json.RawMessage(input),
 	})
 	if err != nil {
-		return "", err
+		return "", nil, err
 	}
 
 	var result struct {
-		Content []struct {
-			Type string `json:"type"`
-			Text string `json:"text"`
-		} `json:"content"`
-		IsError bool `json:"isError"`
+		Content           []content       `json:"content"`
+		StructuredContent json.RawMessage `json:"structuredContent"`
+		IsError           bool            `json:"isError"`
 	}
 	if err := json.Unmarshal(resp, &result); err != nil {
-		return string(resp), nil
+		return string(resp), nil, nil
 	}
 
-	var texts []string
-	for _, c := range result.Content {
-		if c.Type == "text" {
-			texts = append(texts, c.Text)
+	text, blocks := convertContent(result.Content)
+	if text == "" && len(result.StructuredContent) > 0 {
+		text = string(result.StructuredContent)
+	}
+	if result.IsError {
+		if text == "" {
+			text = "tool reported an error"
 		}
+		return "", nil, fmt.Errorf("%s", text)
 	}
-	return joinTexts(texts), nil
+	return text, blocks, nil
 }
 
-func (s *McpServer) jsonRPC(ctx context.Context, method string, params any) (json.RawMessage, error) {
-	if s.Transport == "http" {
-		return s.jsonRPCHTTP(ctx, method, params)
+// callContext applies the server's call timeout unless ctx already has a
+// deadline.
+func (s *McpServer) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || s.Timeout <= 0 {
+		return ctx, func() {}
 	}
-	return s.jsonRPCStdio(ctx, method, params)
+	return context.WithTimeout(ctx, s.Timeout)
 }
 
-func (s *McpServer) jsonRPCStdio(_ context.Context, method string, params any) (json.RawMessage, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	s.nextID++
-	req := map[string]any{
-		"jsonrpc": "2.0",
-		"id":      s.nextID,
-		"method":  method,
-	}
-	if params != nil {
-		req["params"] = params
-	}
-
-	reqBytes, err := json.Marshal(req)
-	if err != nil {
-		return nil, err
-	}
-
-	if _, err := s.stdin.Write(append(reqBytes, '\n')); err != nil {
-		return nil, fmt.Errorf("write to MCP: %w", err)
-	}
-
-	if !s.stdout.Scan() {
-		return nil, fmt.Errorf("no response from MCP server")
-	}
-
-	var resp struct {
-		Result json.RawMessage `json:"result"`
-		Error  *struct {
-			Code    int    `json:"code"`
-			Message string `json:"message"`
-		} `json:"error"`
-	}
-	if err := json.Unmarshal(s.stdout.Bytes(), &resp); err != nil {
-		return nil, fmt.Errorf("parsing response: %w", err)
-	}
-	if resp.Error != nil {
-		return nil, fmt.Errorf("MCP error %d: %s", resp.Error.Code, resp.Error.Message)
+// jsonRPC sends a request and waits for its result. The caller's context
+// bounds the call; an expired HTTP session is re-initialized once.
+func (s *McpServer) jsonRPC(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	result, err := s.rawCall(ctx, method, params)
+	if errors.Is(err, errSessionExpired) && method != "initialize" {
+		log.Printf("[mcp] server %q: session expired, re-initializing", s.Name)
+		if err := s.initialize(ctx); err != nil {
+			return nil, err
+		}
+		result, err = s.rawCall(ctx, method, params)
 	}
-
-	return resp.Result, nil
+	return result, err
 }
 
-func (s *McpServer) jsonRPCHTTP(ctx context.Context, method string, params any) (json.RawMessage, error) {
+func (s *McpServer) rawCall(ctx context.Context, method string, params any) (json.RawMessage, error) {
 	s.mu.Lock()
 	s.nextID++
 	id := s.nextID
+	conn := s.conn
 	s.mu.Unlock()
-
-	req := map[string]any{
-		"jsonrpc": "2.0",
-		"id":      id,
-		"method":  method,
-	}
-	if params != nil {
-		req["params"] = params
-	}
-
-	reqBytes, err := json.Marshal(req)
-	if err != nil {
-		return nil, err
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", s.URL, bytes.NewReader(reqBytes))
-	if err != nil {
-		return nil, err
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	httpResp, err := client.Do(httpReq)
-	if err != nil {
-		return nil, err
+	if conn == nil {
+		return nil, fmt.Errorf("MCP server %q is not running", s.Name)
 	}
-	defer httpResp.Body.Close()
+	return conn.call(ctx, id, method, params)
+}
 
-	var resp struct {
-		Result json.RawMessage `json:"result"`
-		Error  *struct {
-			Code    int    `json:"code"`
-			Message string `json:"message"`
-		} `json:"error"`
-	}
-	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
-		return nil, err
-	}
-	if resp.Error != nil {
-		return nil, fmt.Errorf("MCP error %d: %s", resp.Error.Code, resp.Error.Message)
+func (s *McpServer) notify(ctx context.Context, method string, params any) error {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn == nil {
+		return fmt.Errorf("MCP server %q is not running", s.Name)
 	}
-
-	return resp.Result, nil
+	return conn.notify(ctx, method, params)
 }
 
-// Close terminates all server processes.
-func (m *McpManager) Close() {
-	for _, server := range m.servers {
-		if server.cmd != nil && server.cmd.Process != nil {
-			server.stdin.Close()
-			server.cmd.Process.Kill()
-		}
+func (s *McpServer) closeConn() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		s.conn.close()
+		s.conn = nil
 	}
 }
 
-func joinTexts(texts []string) string {
-	if len(texts) == 0 {
-		return ""
-	}
-	result := texts[0]
-	for _, t := range texts[1:] {
-		result += "\n" + t
-	}
-	return result
+func (s *McpServer) close() {
+	s.closeConn()
+	s.mu.Lock()
+	s.state = StateStopped
+	s.mu.Unlock()
 }
@@ -0,0 +1,203 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yifanes/miniclawd/internal/core"
+)
+
+// TestMain lets the test binary double as a stdio MCP server.
+func TestMain(m *testing.M) {
+	if os.Getenv("MCP_FAKE_SERVER") == "1" {
+		runFakeServer()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeServer is a minimal MCP server with one "echo" tool. Echoing
+// "add" adds a tool, echoing "crash" kills the server.
+func runFakeServer() {
+	fmt.Println("fake server starting") // a banner clients must skip
+	fmt.Fprintln(os.Stderr, "fake server ready")
+	out := json.NewEncoder(os.Stdout)
+	sc := bufio.NewScanner(os.Stdin)
+	tools := []map[string]any{{
+		"name":        "echo",
+		"description": "Echo text",
+		"inputSchema": map[string]any{"type": "object", "properties": map[string]any{"text": map[string]any{"type": "string"}}},
+	}}
+	for sc.Scan() {
+		var req rpcMessage
+		if json.Unmarshal(sc.Bytes(), &req) != nil || len(req.ID) == 0 {
+			continue
+		}
+		resp := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "initialize":
+			resp["result"] = map[string]any{"protocolVersion": "2024-11-05", "capabilities": map[string]any{
+				"tools": map[string]any{}, "resources": map[string]any{}, "prompts": map[string]any{},
+			}}
+		case "tools/list":
+			resp["result"] = map[string]any{"tools": tools}
+		case "tools/call":
+			var p struct {
+				Name      string `json:"name"`
+				Arguments struct {
+					Text string `json:"text"`
+				} `json:"arguments"`
+			}
+			json.Unmarshal(req.Params, &p)
+			if p.Name != "echo" {
+				resp["error"] = map[string]any{"code": -32602, "message": "unknown tool " + p.Name}
+				break
+			}
+			switch p.Arguments.Text {
+			case "crash":
+				os.Exit(1)
+			case "add":
+				tools = append(tools, map[string]any{"name": "extra", "inputSchema": map[string]any{"type": "object"}})
+				out.Encode(map[string]any{"jsonrpc": "2.0", "method": "notifications/tools/list_changed"})
+			}
+			out.Encode(map[string]any{"jsonrpc": "2.0", "method": "notifications/message", "params": map[string]any{"level": "info"}})
+			resp["result"] = map[string]any{
+				"content": []map[string]any{
+					{"type": "text", "text": "echo: " + p.Arguments.Text},
+					{"type": "image", "mimeType": "image/png", "data": "aGk="},
+				},
+				"isError": p.Arguments.Text == "fail",
+			}
+		case "ping":
+			resp["result"] = map[string]any{}
+		case "resources/list":
+			resp["result"] = map[string]any{"resources": []map[string]any{{"uri": "file:///notes.txt", "name": "notes"}}}
+		case "resources/read":
+			resp["result"] = map[string]any{"contents": []map[string]any{
+				{"uri": "file:///notes.txt", "mimeType": "text/plain", "text": "hello"},
+				{"uri": "file:///logo.png", "mimeType": "image/png", "blob": "aGk="},
+			}}
+		case "prompts/list":
+			resp["result"] = map[string]any{"prompts": []map[string]any{{
+				"name": "greet", "arguments": []map[string]any{{"name": "who", "required": true}},
+			}}}
+		case "prompts/get":
+			var p struct {
+				Arguments map[string]string `json:"arguments"`
+			}
+			json.Unmarshal(req.Params, &p)
+			resp["result"] = map[string]any{"messages": []map[string]any{{
+				"role": "user", "content": map[string]any{"type": "text", "text": "Say hello to " + p.Arguments["who"]},
+			}}}
+		default:
+			resp["error"] = map[string]any{"code": rpcMethodNotFound, "message": "unknown method"}
+		}
+		out.Encode(resp)
+	}
+}
+
+func fakeServerConfig(name string) McpServerConfig {
+	return McpServerConfig{
+		Name:      name,
+		Transport: "stdio",
+		Command:   os.Args[0],
+		Env:       map[string]string{"MCP_FAKE_SERVER": "1"},
+	}
+}
+
+func TestStdioServer(t *testing.T) {
+	m := NewMcpManager([]McpServerConfig{
+		fakeServerConfig("fake"),
+		{Name: "broken", Transport: "stdio", Command: "/nonexistent/mcp-server"},
+	})
+	m.Initialize(context.Background())
+	defer m.Close()
+
+	status := m.Status()
+	if len(status) != 2 || status[0].Name != "broken" || status[0].State != StateFailed || status[1].State != StateRunning || status[1].Tools != 1 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	got, blocks, err := m.CallTool(context.Background(), "fake", "echo", json.RawMessage(`{"text":"hi"}`))
+	if err != nil || got != "echo: hi\n[image: image/png, attached]" || len(blocks) != 1 || blocks[0].Type != "image" {
+		t.Errorf("CallTool = %q, %+v, %v", got, blocks, err)
+	}
+	if _, _, err := m.CallTool(context.Background(), "fake", "echo", json.RawMessage(`{"text":"fail"}`)); err == nil || !strings.Contains(err.Error(), "echo: fail") {
+		t.Errorf("expected tool error, got %v", err)
+	}
+	if _, _, err := m.CallTool(context.Background(), "fake", "missing", json.RawMessage(`{}`)); err == nil {
+		t.Error("expected error for unknown tool")
+	}
+}
+
+func TestResourcesAndPrompts(t *testing.T) {
+	m := NewMcpManager([]McpServerConfig{fakeServerConfig("fake")})
+	m.Initialize(context.Background())
+	defer m.Close()
+	ctx := context.Background()
+
+	if !m.HasResources() {
+		t.Fatal("expected resources capability")
+	}
+	resources, _, err := m.ListResources(ctx, "")
+	if err != nil || len(resources) != 1 || resources[0].URI != "file:///notes.txt" {
+		t.Fatalf("ListResources = %+v, %v", resources, err)
+	}
+	text, blocks, err := m.ReadResource(ctx, "fake", "file:///notes.txt")
+	if err != nil || !strings.Contains(text, "hello") || len(blocks) != 1 {
+		t.Errorf("ReadResource = %q, %+v, %v", text, blocks, err)
+	}
+
+	p, ok := m.Prompt("fake", "greet")
+	if !ok || len(p.Arguments) != 1 || !p.Arguments[0].Required {
+		t.Fatalf("Prompt = %+v, %v", p, ok)
+	}
+	msgs, err := m.GetPrompt(ctx, "fake", "greet", map[string]string{"who": "Ada"})
+	if err != nil || len(msgs) != 1 || msgs[0].Role != "user" || msgs[0].Text != "Say hello to Ada" {
+		t.Errorf("GetPrompt = %+v, %v", msgs, err)
+	}
+}
+
+func TestSupervise(t *testing.T) {
+	defer func(p, d time.Duration) { pingInterval, minRestartDelay = p, d }(pingInterval, minRestartDelay)
+	pingInterval, minRestartDelay = 50*time.Millisecond, 10*time.Millisecond
+
+	m := NewMcpManager([]McpServerConfig{fakeServerConfig("fake")})
+	m.Initialize(context.Background())
+	changes := make(chan int, 4)
+	m.OnToolsChanged(func(server string, tools []core.ToolDefinition) { changes <- len(tools) })
+	m.Supervise(context.Background())
+	defer m.Close()
+	ctx := context.Background()
+
+	waitTools := func(want int) {
+		t.Helper()
+		select {
+		case got := <-changes:
+			if got != want {
+				t.Fatalf("tools changed to %d, want %d", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %d tools", want)
+		}
+	}
+
+	if _, _, err := m.CallTool(ctx, "fake", "echo", json.RawMessage(`{"text":"add"}`)); err != nil {
+		t.Fatal(err)
+	}
+	waitTools(2)
+
+	if _, _, err := m.CallTool(ctx, "fake", "echo", json.RawMessage(`{"text":"crash"}`)); err == nil {
+		t.Fatal("expected error from crashed server")
+	}
+	waitTools(1) // restarted with a fresh tool list
+	if got, _, err := m.CallTool(ctx, "fake", "echo", json.RawMessage(`{"text":"back"}`)); err != nil || !strings.HasPrefix(got, "echo: back") {
+		t.Errorf("after restart: %q, %v", got, err)
+	}
+}
@@ -0,0 +1,277 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/yifanes/miniclawd/internal/core"
+)
+
+// Resource is a piece of context a server exposes by URI.
+type Resource struct {
+	Server      string
+	URI         string
+	Name        string
+	Description string
+	MimeType    string
+}
+
+// ResourceTemplate describes a family of resources by RFC 6570 URI template.
+type ResourceTemplate struct {
+	Server      string
+	URITemplate string
+	Name        string
+	Description string
+	MimeType    string
+}
+
+// Prompt is a reusable message template offered by a server.
+type Prompt struct {
+	Server      string
+	Name        string
+	Description string
+	Arguments   []PromptArgument
+}
+
+// PromptArgument is one named parameter of a prompt.
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
+
+// PromptMessage is one message of a rendered prompt.
+type PromptMessage struct {
+	Role   string // "user" or "assistant"
+	Text   string
+	Blocks []core.ContentBlock // images
+}
+
+// HasResources reports whether any running server offers resources.
+func (m *McpManager) HasResources() bool {
+	for _, s := range m.servers {
+		s.mu.Lock()
+		ok := s.hasResources
+		s.mu.Unlock()
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ListResources lists the resources and templates of one server, or of all
+// servers offering resources when server is empty.
+func (m *McpManager) ListResources(ctx context.Context, server string) ([]Resource, []ResourceTemplate, error) {
+	var resources []Resource
+	var templates []ResourceTemplate
+	for _, name := range m.ServerNames() {
+		s := m.servers[name]
+		if server != "" && name != server {
+			continue
+		}
+		s.mu.Lock()
+		ok := s.hasResources
+		s.mu.Unlock()
+		if !ok {
+			if server != "" {
+				return nil, nil, fmt.Errorf("MCP server %q does not offer resources", server)
+			}
+			continue
+		}
+		r, t, err := s.listResources(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		resources = append(resources, r...)
+		templates = append(templates, t...)
+	}
+	if server != "" && m.servers[server] == nil {
+		return nil, nil, fmt.Errorf("unknown MCP server: %s", server)
+	}
+	return resources, templates, nil
+}
+
+// ReadResource reads a resource by URI. Text comes back as text and images
+// as content blocks.
+func (m *McpManager) ReadResource(ctx context.Context, server, uri string) (string, []core.ContentBlock, error) {
+	s, ok := m.servers[server]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown MCP server: %s", server)
+	}
+	ctx, cancel := s.callContext(ctx)
+	defer cancel()
+	resp, err := s.jsonRPC(ctx, "resources/read", map[string]string{"uri": uri})
+	if err != nil {
+		return "", nil, err
+	}
+	var result struct {
+		Contents []resourceContents `json:"contents"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return "", nil, fmt.Errorf("parsing resource: %w", err)
+	}
+	text, blocks := convertResources(result.Contents)
+	return text, blocks, nil
+}
+
+// Prompts returns the prompts of all servers, sorted by server.
+func (m *McpManager) Prompts() []Prompt {
+	if m == nil {
+		return nil
+	}
+	var out []Prompt
+	for _, name := range m.ServerNames() {
+		s := m.servers[name]
+		s.mu.Lock()
+		out = append(out, s.prompts...)
+		s.mu.Unlock()
+	}
+	return out
+}
+
+// Prompt looks up a prompt by server and name.
+func (m *McpManager) Prompt(server, name string) (Prompt, bool) {
+	for _, p := range m.Prompts() {
+		if p.Server == server && p.Name == name {
+			return p, true
+		}
+	}
+	return Prompt{}, false
+}
+
+// GetPrompt renders a prompt with the given arguments.
+func (m *McpManager) GetPrompt(ctx context.Context, server, name string, args map[string]string) ([]PromptMessage, error) {
+	s, ok := m.servers[server]
+	if !ok {
+		return nil, fmt.Errorf("unknown MCP server: %s", server)
+	}
+	if args == nil {
+		args = map[string]string{}
+	}
+	ctx, cancel := s.callContext(ctx)
+	defer cancel()
+	resp, err := s.jsonRPC(ctx, "prompts/get", map[string]any{"name": name, "arguments": args})
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Messages []struct {
+			Role    string  `json:"role"`
+			Content content `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return nil, fmt.Errorf("parsing prompt: %w", err)
+	}
+	var out []PromptMessage
+	for _, msg := range result.Messages {
+		text, blocks := convertContent([]content{msg.Content})
+		out = append(out, PromptMessage{Role: msg.Role, Text: text, Blocks: blocks})
+	}
+	return out, nil
+}
+
+// listResources fetches every page of resources/list and
+// resources/templates/list.
+func (s *McpServer) listResources(ctx context.Context) ([]Resource, []ResourceTemplate, error) {
+	var resources []Resource
+	err := s.paginate(ctx, "resources/list", func(page json.RawMessage) error {
+		var p struct {
+			Resources []struct {
+				URI         string `json:"uri"`
+				Name        string `json:"name"`
+				Description string `json:"description"`
+				MimeType    string `json:"mimeType"`
+			} `json:"resources"`
+		}
+		if err := json.Unmarshal(page, &p); err != nil {
+			return err
+		}
+		for _, r := range p.Resources {
+			resources = append(resources, Resource{Server: s.Name, URI: r.URI, Name: r.Name, Description: r.Description, MimeType: r.MimeType})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var templates []ResourceTemplate
+	err = s.paginate(ctx, "resources/templates/list", func(page json.RawMessage) error {
+		var p struct {
+			ResourceTemplates []struct {
+				URITemplate string `json:"uriTemplate"`
+				Name        string `json:"name"`
+				Description string `json:"description"`
+				MimeType    string `json:"mimeType"`
+			} `json:"resourceTemplates"`
+		}
+		if err := json.Unmarshal(page, &p); err != nil {
+			return err
+		}
+		for _, t := range p.ResourceTemplates {
+			templates = append(templates, ResourceTemplate{Server: s.Name, URITemplate: t.URITemplate, Name: t.Name, Description: t.Description, MimeType: t.MimeType})
+		}
+		return nil
+	})
+	// Templates are optional; older servers don't implement the method.
+	if err != nil && !isMethodNotFound(err) {
+		return nil, nil, err
+	}
+	return resources, templates, nil
+}
+
+func (s *McpServer) listPrompts(ctx context.Context) ([]Prompt, error) {
+	var prompts []Prompt
+	err := s.paginate(ctx, "prompts/list", func(page json.RawMessage) error {
+		var p struct {
+			Prompts []struct {
+				Name        string           `json:"name"`
+				Description string           `json:"description"`
+				Arguments   []PromptArgument `json:"arguments"`
+			} `json:"prompts"`
+		}
+		if err := json.Unmarshal(page, &p); err != nil {
+			return err
+		}
+		for _, pr := range p.Prompts {
+			prompts = append(prompts, Prompt{Server: s.Name, Name: pr.Name, Description: pr.Description, Arguments: pr.Arguments})
+		}
+		return nil
+	})
+	return prompts, err
+}
+
+func isMethodNotFound(err error) bool {
+	var rpcErr *rpcError
+	return errors.As(err, &rpcErr) && rpcErr.Code == rpcMethodNotFound
+}
+
+// paginate calls a list method until the server stops returning a cursor.
+func (s *McpServer) paginate(ctx context.Context, method string, page func(json.RawMessage) error) error {
+	cursor := ""
+	for {
+		var params any
+		if cursor != "" {
+			params = map[string]string{"cursor": cursor}
+		}
+		resp, err := s.jsonRPC(ctx, method, params)
+		if err != nil {
+			return fmt.Errorf("%s: %w", method, err)
+		}
+		if err := page(resp); err != nil {
+			return fmt.Errorf("parsing %s: %w", method, err)
+		}
+		var next struct {
+			NextCursor string `json:"nextCursor"`
+		}
+		json.Unmarshal(resp, &next)
+		if next.NextCursor == "" || next.NextCursor == cursor {
+			return nil
+		}
+		cursor = next.NextCursor
+	}
+}
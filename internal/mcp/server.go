@@ -0,0 +1,390 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yifanes/miniclawd/internal/core"
+)
+
+const (
+	rpcParseError     = -32700
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	rpcUnauthorized   = -32001
+	maxServerSessions = 1000
+	serverSessionTTL  = 24 * time.Hour
+)
+
+// supportedVersions are the protocol revisions the server speaks; a client
+// asking for anything else gets protocolVersion.
+var supportedVersions = []string{protocolVersion, "2024-11-05"}
+
+// ServerHandler is what the server offers one authenticated client.
+type ServerHandler interface {
+	ListTools() []core.ToolDefinition
+	CallTool(ctx context.Context, name string, args json.RawMessage) (text string, isError bool)
+	ListResources(ctx context.Context) ([]Resource, []ResourceTemplate, error)
+	ReadResource(ctx context.Context, uri string) (ResourceText, error)
+}
+
+// ResourceText is the body of a resource served as text.
+type ResourceText struct {
+	URI      string
+	MimeType string
+	Text     string
+}
+
+// Authenticator returns the handler for a client presenting an API key, or
+// an error if the key is not accepted.
+type Authenticator func(key string) (ServerHandler, error)
+
+// Server serves MCP over stdio or Streamable HTTP. Clients authenticate
+// with an API key, which is checked again on every message so revocation
+// takes effect immediately.
+type Server struct {
+	name    string
+	version string
+	auth    Authenticator
+
+	mu       sync.Mutex
+	sessions map[string]serverSession
+}
+
+type serverSession struct {
+	keyHash  string // the key that opened it
+	created  time.Time
+	lastUsed time.Time
+}
+
+// NewServer creates a server that introduces itself as name and version.
+func NewServer(name, version string, auth Authenticator) *Server {
+	return &Server{name: name, version: version, auth: auth, sessions: make(map[string]serverSession)}
+}
+
+// ServeStdio serves newline-delimited JSON-RPC from in to out until in is
+// closed. Requests are handled concurrently so a slow tool call does not
+// hold up pings.
+func (s *Server) ServeStdio(ctx context.Context, key string, in io.Reader, out io.Writer) error {
+	if _, err := s.auth(key); err != nil {
+		return err
+	}
+	var writeMu sync.Mutex
+	var wg sync.WaitGroup
+	sc := bufio.NewScanner(in)
+	sc.Buffer(make([]byte, 0, 64*1024), maxStdioMessage)
+	for sc.Scan() {
+		data := bytes.TrimSpace(sc.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		data = bytes.Clone(data)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp := s.handleRaw(ctx, key, data); resp != nil {
+				writeMu.Lock()
+				out.Write(append(resp, '\n'))
+				writeMu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return sc.Err()
+}
+
+// ServeHTTP implements the Streamable HTTP transport. Every response is a
+// plain JSON body; the server never pushes messages, so GET is refused.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || key == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "missing API key", http.StatusUnauthorized)
+		return
+	}
+	h, err := s.auth(key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	session := r.Header.Get(headerSessionID)
+
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodDelete:
+		if !s.endSession(session, key) {
+			http.Error(w, "unknown session", http.StatusNotFound)
+		}
+		return
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxStdioMessage))
+	if err != nil {
+		http.Error(w, "reading body", http.StatusBadRequest)
+		return
+	}
+	var probe rpcMessage
+	isInit := json.Unmarshal(body, &probe) == nil && probe.Method == "initialize"
+	if isInit {
+		session = s.newSession(key)
+		w.Header().Set(headerSessionID, session)
+	} else if session == "" {
+		http.Error(w, "missing "+headerSessionID, http.StatusBadRequest)
+		return
+	} else if !s.validSession(session, key) {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	resp := s.handleMessages(r.Context(), h, nil, body)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resp)
+}
+
+// handleRaw authenticates key and answers one message or batch; it returns
+// nil when there is nothing to send back.
+func (s *Server) handleRaw(ctx context.Context, key string, data []byte) []byte {
+	h, authErr := s.auth(key)
+	return s.handleMessages(ctx, h, authErr, data)
+}
+
+// handleMessages answers one message or batch for an already authenticated
+// client. When authErr is set every request is refused with it.
+func (s *Server) handleMessages(ctx context.Context, h ServerHandler, authErr error, data []byte) []byte {
+	handle := func(msg *rpcMessage) *rpcMessage {
+		if authErr != nil && len(msg.ID) > 0 {
+			return errorResponse(msg.ID, &rpcError{Code: rpcUnauthorized, Message: authErr.Error()})
+		}
+		return s.handle(ctx, h, msg)
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var batch []rpcMessage
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return marshalResponse(errorResponse(nil, &rpcError{Code: rpcParseError, Message: err.Error()}))
+		}
+		var out []*rpcMessage
+		for i := range batch {
+			if resp := handle(&batch[i]); resp != nil {
+				out = append(out, resp)
+			}
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		b, _ := json.Marshal(out)
+		return b
+	}
+
+	var msg rpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return marshalResponse(errorResponse(nil, &rpcError{Code: rpcParseError, Message: err.Error()}))
+	}
+	if resp := handle(&msg); resp != nil {
+		return marshalResponse(resp)
+	}
+	return nil
+}
+
+// handle answers a request; notifications and responses get nothing back.
+func (s *Server) handle(ctx context.Context, h ServerHandler, req *rpcMessage) *rpcMessage {
+	if len(req.ID) == 0 || req.Method == "" {
+		return nil
+	}
+	result, err := s.dispatch(ctx, h, req)
+	if err != nil {
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{Code: rpcInternalError, Message: err.Error()}
+		}
+		return errorResponse(req.ID, rpcErr)
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, &rpcError{Code: rpcInternalError, Message: err.Error()})
+	}
+	return &rpcMessage{JSONRPC: "2.0", ID: req.ID, Result: raw}
+}
+
+func (s *Server) dispatch(ctx context.Context, h ServerHandler, req *rpcMessage) (any, error) {
+	switch req.Method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &p)
+		version := protocolVersion
+		if slices.Contains(supportedVersions, p.ProtocolVersion) {
+			version = p.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}, "resources": map[string]any{}},
+			"serverInfo":      map[string]string{"name": s.name, "version": s.version},
+		}, nil
+
+	case "ping":
+		return map[string]any{}, nil
+
+	case "tools/list":
+		tools := []map[string]any{}
+		for _, def := range h.ListTools() {
+			tools = append(tools, map[string]any{
+				"name":        def.Name,
+				"description": def.Description,
+				"inputSchema": def.InputSchema,
+			})
+		}
+		return map[string]any{"tools": tools}, nil
+
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil || p.Name == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "tools/call needs a tool name"}
+		}
+		if len(p.Arguments) == 0 || string(p.Arguments) == "null" {
+			p.Arguments = json.RawMessage(`{}`)
+		}
+		text, isError := h.CallTool(ctx, p.Name, p.Arguments)
+		return map[string]any{
+			"content": []map[string]string{{"type": "text", "text": text}},
+			"isError": isError,
+		}, nil
+
+	case "resources/list":
+		resources, _, err := h.ListResources(ctx)
+		if err != nil {
+			return nil, err
+		}
+		out := []map[string]string{}
+		for _, r := range resources {
+			out = append(out, map[string]string{"uri": r.URI, "name": r.Name, "description": r.Description, "mimeType": r.MimeType})
+		}
+		return map[string]any{"resources": out}, nil
+
+	case "resources/templates/list":
+		_, templates, err := h.ListResources(ctx)
+		if err != nil {
+			return nil, err
+		}
+		out := []map[string]string{}
+		for _, t := range templates {
+			out = append(out, map[string]string{"uriTemplate": t.URITemplate, "name": t.Name, "description": t.Description, "mimeType": t.MimeType})
+		}
+		return map[string]any{"resourceTemplates": out}, nil
+
+	case "resources/read":
+		var p struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(req.Params, &p); err != nil || p.URI == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "resources/read needs a uri"}
+		}
+		res, err := h.ReadResource(ctx, p.URI)
+		if err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		return map[string]any{"contents": []map[string]string{{"uri": res.URI, "mimeType": res.MimeType, "text": res.Text}}}, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+func errorResponse(id json.RawMessage, err *rpcError) *rpcMessage {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &rpcMessage{JSONRPC: "2.0", ID: id, Error: err}
+}
+
+func marshalResponse(msg *rpcMessage) []byte {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("[mcp] encoding response: %v", err)
+		return nil
+	}
+	return b
+}
+
+// --- HTTP sessions ---
+
+func hashKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+// newSession opens a session for key. When the table is full, expired
+// sessions are dropped first, then the least recently used one.
+func (s *Server) newSession(key string) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for sid, sess := range s.sessions {
+		if now.Sub(sess.created) > serverSessionTTL {
+			delete(s.sessions, sid)
+		}
+	}
+	for len(s.sessions) >= maxServerSessions {
+		var oldest string
+		for sid, sess := range s.sessions {
+			if oldest == "" || sess.lastUsed.Before(s.sessions[oldest].lastUsed) {
+				oldest = sid
+			}
+		}
+		delete(s.sessions, oldest)
+	}
+	s.sessions[id] = serverSession{keyHash: hashKey(key), created: now, lastUsed: now}
+	return id
+}
+
+// validSession reports whether id is a live session opened with key, and
+// marks it used.
+func (s *Server) validSession(id, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok || sess.keyHash != hashKey(key) || time.Since(sess.created) > serverSessionTTL {
+		return false
+	}
+	sess.lastUsed = time.Now()
+	s.sessions[id] = sess
+	return true
+}
+
+func (s *Server) endSession(id, key string) bool {
+	if !s.validSession(id, key) {
+		return false
+	}
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+	return true
+}
@@ -0,0 +1,162 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yifanes/miniclawd/internal/core"
+)
+
+// echoHandler serves one echo tool and one text resource.
+type echoHandler struct{}
+
+func (echoHandler) ListTools() []core.ToolDefinition {
+	return []core.ToolDefinition{{Name: "echo", InputSchema: json.RawMessage(`{"type":"object"}`)}}
+}
+
+func (echoHandler) CallTool(_ context.Context, name string, args json.RawMessage) (string, bool) {
+	return fmt.Sprintf("%s %s", name, args), false
+}
+
+func (echoHandler) ListResources(context.Context) ([]Resource, []ResourceTemplate, error) {
+	return []Resource{{URI: "test://a", Name: "a"}}, nil, nil
+}
+
+func (echoHandler) ReadResource(_ context.Context, uri string) (ResourceText, error) {
+	if uri != "test://a" {
+		return ResourceText{}, errors.New("not found")
+	}
+	return ResourceText{URI: uri, MimeType: "text/plain", Text: "hello"}, nil
+}
+
+func testAuth(key string) (ServerHandler, error) {
+	if key != "good" {
+		return nil, errors.New("bad key")
+	}
+	return echoHandler{}, nil
+}
+
+func TestServerHTTP(t *testing.T) {
+	srv := httptest.NewServer(NewServer("test", "1", testAuth))
+	defer srv.Close()
+	ctx := context.Background()
+
+	m := NewMcpManager([]McpServerConfig{
+		{Name: "self", Transport: "http", URL: srv.URL, BearerToken: "good"},
+		{Name: "denied", Transport: "http", URL: srv.URL, BearerToken: "bad"},
+	})
+	m.Initialize(ctx)
+	defer m.Close()
+	if st := m.Status(); st[0].State != StateFailed || st[1].State != StateRunning || st[1].Tools != 1 {
+		t.Fatalf("unexpected status: %+v", st)
+	}
+
+	got, _, err := m.CallTool(ctx, "self", "echo", json.RawMessage(`{"x":1}`))
+	if err != nil || got != `echo {"x":1}` {
+		t.Errorf("CallTool = %q, %v", got, err)
+	}
+	resources, _, err := m.ListResources(ctx, "self")
+	if err != nil || len(resources) != 1 {
+		t.Fatalf("ListResources = %+v, %v", resources, err)
+	}
+	if text, _, err := m.ReadResource(ctx, "self", "test://a"); err != nil || !strings.Contains(text, "hello") {
+		t.Errorf("ReadResource = %q, %v", text, err)
+	}
+	if _, _, err := m.ReadResource(ctx, "self", "test://missing"); err == nil {
+		t.Error("expected error for missing resource")
+	}
+}
+
+func TestServerHandleRaw(t *testing.T) {
+	s := NewServer("test", "1", testAuth)
+	ctx := context.Background()
+
+	resp := s.handleRaw(ctx, "good", []byte(`[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","method":"notifications/initialized"},{"jsonrpc":"2.0","id":2,"method":"nope"}]`))
+	var batch []rpcMessage
+	if err := json.Unmarshal(resp, &batch); err != nil || len(batch) != 2 {
+		t.Fatalf("batch response %s: %v", resp, err)
+	}
+	if batch[0].Error != nil || batch[1].Error == nil || batch[1].Error.Code != rpcMethodNotFound {
+		t.Errorf("unexpected batch: %s", resp)
+	}
+
+	if resp := s.handleRaw(ctx, "good", []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)); resp != nil {
+		t.Errorf("notification answered: %s", resp)
+	}
+	var msg rpcMessage
+	json.Unmarshal(s.handleRaw(ctx, "bad", []byte(`{"jsonrpc":"2.0","id":3,"method":"tools/list"}`)), &msg)
+	if msg.Error == nil || msg.Error.Code != rpcUnauthorized {
+		t.Errorf("expected unauthorized, got %+v", msg)
+	}
+}
+
+func TestServerHTTPAuthenticatesOnce(t *testing.T) {
+	var calls atomic.Int32
+	s := NewServer("test", "1", func(key string) (ServerHandler, error) {
+		calls.Add(1)
+		return testAuth(key)
+	})
+
+	post := func(session, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer good")
+		if session != "" {
+			req.Header.Set(headerSessionID, session)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := post("", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	session := rec.Header().Get(headerSessionID)
+	if rec.Code != http.StatusOK || session == "" {
+		t.Fatalf("initialize: %d %s", rec.Code, rec.Body)
+	}
+	calls.Store(0)
+	rec = post(session, `[{"jsonrpc":"2.0","id":2,"method":"ping"},{"jsonrpc":"2.0","id":3,"method":"tools/list"}]`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "echo") {
+		t.Fatalf("batch: %d %s", rec.Code, rec.Body)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("authenticator called %d times for one request, want 1", n)
+	}
+}
+
+func TestServerSessionEviction(t *testing.T) {
+	s := NewServer("test", "1", testAuth)
+	now := time.Now()
+	for i := 0; i < maxServerSessions; i++ {
+		s.sessions[fmt.Sprintf("s%d", i)] = serverSession{
+			keyHash:  hashKey("good"),
+			created:  now,
+			lastUsed: now.Add(time.Duration(i-maxServerSessions) * time.Second),
+		}
+	}
+	s.sessions["s5"] = serverSession{keyHash: hashKey("good"), created: now.Add(-2 * serverSessionTTL)}
+
+	// The expired session makes room; nothing live is evicted.
+	s.newSession("good")
+	if _, ok := s.sessions["s5"]; ok {
+		t.Error("expired session kept")
+	}
+	if len(s.sessions) != maxServerSessions || !s.validSession("s0", "good") {
+		t.Fatalf("sessions = %d, s0 evicted early", len(s.sessions))
+	}
+
+	// s0 was just used, so s1 is now the least recently used.
+	s.newSession("good")
+	if _, ok := s.sessions["s1"]; ok {
+		t.Error("least recently used session kept")
+	}
+	if !s.validSession("s0", "good") || len(s.sessions) != maxServerSessions {
+		t.Errorf("sessions = %d, s0 valid = %v", len(s.sessions), s.validSession("s0", "good"))
+	}
+}
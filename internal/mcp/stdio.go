@@ -0,0 +1,148 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// maxStdioMessage bounds a single JSON-RPC line from a stdio server.
+const maxStdioMessage = 16 * 1024 * 1024
+
+// maxStderrLine bounds one logged line of server stderr.
+const maxStderrLine = 2000
+
+var errConnClosed = errors.New("MCP server connection closed")
+
+// stdioConn talks JSON-RPC to a child process over newline-delimited
+// stdin/stdout.
+type stdioConn struct {
+	*router
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+}
+
+func startStdio(name string, cmd *exec.Cmd, onNotify func(string, json.RawMessage)) (*stdioConn, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = &stderrLog{name: name}
+	}
+	// Don't let a grandchild holding stderr open block Wait.
+	cmd.WaitDelay = time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c := &stdioConn{cmd: cmd, stdin: stdin}
+	c.router = newRouter(name, onNotify, func(b []byte) { c.write(b) })
+	go c.readLoop(stdout)
+	return c, nil
+}
+
+func (c *stdioConn) readLoop(r io.Reader) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxStdioMessage)
+	for sc.Scan() {
+		// Servers sometimes print banners to stdout; dispatchRaw skips them.
+		c.dispatchRaw(sc.Bytes())
+	}
+	err := sc.Err()
+	// Without stdout the process is of no use; make sure it is gone.
+	c.cmd.Process.Kill()
+	if werr := c.cmd.Wait(); err == nil {
+		err = errConnClosed
+		if werr != nil {
+			err = fmt.Errorf("%w (%v)", errConnClosed, werr)
+		}
+	}
+	c.shutdown(err)
+}
+
+func (c *stdioConn) write(b []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.stdin.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("write to MCP: %w", err)
+	}
+	return nil
+}
+
+func (c *stdioConn) call(ctx context.Context, id int64, method string, params any) (json.RawMessage, error) {
+	req, err := newRequest(id, method, params)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := c.register(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.write(req); err != nil {
+		c.forget(id)
+		return nil, err
+	}
+	return c.wait(ctx, id, ch)
+}
+
+func (c *stdioConn) notify(_ context.Context, method string, params any) error {
+	b, err := newNotification(method, params)
+	if err != nil {
+		return err
+	}
+	return c.write(b)
+}
+
+func (c *stdioConn) close() {
+	c.stdin.Close()
+	if c.cmd.Process != nil {
+		c.cmd.Process.Kill()
+	}
+}
+
+// stderrLog copies a server's stderr to the log, one line per entry.
+type stderrLog struct {
+	name string
+	buf  []byte
+}
+
+func (w *stderrLog) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.logLine(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) > maxStderrLine {
+		w.logLine(w.buf)
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+func (w *stderrLog) logLine(line []byte) {
+	line = bytes.TrimRight(line, "\r")
+	if len(line) > maxStderrLine {
+		line = line[:maxStderrLine]
+	}
+	if len(bytes.TrimSpace(line)) > 0 {
+		log.Printf("[mcp] server %q stderr: %s", w.name, line)
+	}
+}
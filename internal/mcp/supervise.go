@@ -0,0 +1,159 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/yifanes/miniclawd/internal/core"
+)
+
+// Supervision timings; variables so tests can shorten them.
+var (
+	pingInterval    = 30 * time.Second
+	pingTimeout     = 10 * time.Second
+	minRestartDelay = time.Second
+	maxRestartDelay = 5 * time.Minute
+	// stableAfter is how long a server must stay up before its restart
+	// backoff resets.
+	stableAfter = time.Minute
+)
+
+// OnToolsChanged sets fn to be called with a server's tool list whenever
+// it changes after Initialize: on notifications/tools/list_changed and
+// after a restart. Set it before calling Supervise.
+func (m *McpManager) OnToolsChanged(fn func(server string, tools []core.ToolDefinition)) {
+	m.onToolsChanged = fn
+}
+
+// Supervise keeps servers healthy until ctx ends or Close is called. Stdio
+// servers whose process exits, and servers that stop answering pings, are
+// restarted with exponential backoff; servers that failed to start are
+// retried the same way. It also refreshes tools and prompts when a server
+// reports that they changed.
+func (m *McpManager) Supervise(ctx context.Context) {
+	ctx, m.cancel = context.WithCancel(ctx)
+	for _, s := range m.servers {
+		m.supervisors.Add(1)
+		go func() {
+			defer m.supervisors.Done()
+			s.supervise(ctx, m)
+		}()
+	}
+}
+
+func (s *McpServer) supervise(ctx context.Context, m *McpManager) {
+	delay := minRestartDelay
+	for {
+		s.mu.Lock()
+		conn, running := s.conn, s.state == StateRunning
+		s.mu.Unlock()
+
+		if running && conn != nil {
+			started := time.Now()
+			err := s.watch(ctx, conn, m)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[mcp] server %q: %v; restarting", s.Name, err)
+			s.closeConn()
+			s.mu.Lock()
+			s.state, s.lastErr = StateFailed, err
+			s.mu.Unlock()
+			if time.Since(started) >= stableAfter {
+				delay = minRestartDelay
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRestartDelay)
+
+		if err := s.connect(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[mcp] server %q: restart failed: %v (next attempt in %s)", s.Name, err, delay)
+			continue
+		}
+		log.Printf("[mcp] server %q: restarted", s.Name)
+		m.toolsChanged(s)
+	}
+}
+
+// watch returns when conn dies or stops answering pings, handling list
+// change notifications meanwhile.
+func (s *McpServer) watch(ctx context.Context, conn transport, m *McpManager) error {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-conn.closed():
+			if err := conn.closeErr(); err != nil {
+				return err
+			}
+			return errConnClosed
+		case method := <-s.refresh:
+			s.refreshLists(ctx, m, method)
+		case <-ticker.C:
+			if err := s.ping(ctx); err != nil {
+				return fmt.Errorf("ping: %w", err)
+			}
+		}
+	}
+}
+
+// ping checks the server still answers. A JSON-RPC error still proves it
+// is alive.
+func (s *McpServer) ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	_, err := s.jsonRPC(ctx, "ping", nil)
+	var rpcErr *rpcError
+	if errors.As(err, &rpcErr) {
+		return nil
+	}
+	return err
+}
+
+// refreshLists re-fetches the tools or prompts after a list_changed
+// notification.
+func (s *McpServer) refreshLists(ctx context.Context, m *McpManager, method string) {
+	ctx, cancel := context.WithTimeout(ctx, initTimeout)
+	defer cancel()
+	switch method {
+	case "notifications/tools/list_changed":
+		tools, err := s.listTools(ctx)
+		if err != nil {
+			log.Printf("[mcp] server %q: refreshing tools: %v", s.Name, err)
+			return
+		}
+		s.mu.Lock()
+		s.tools = tools
+		s.mu.Unlock()
+		log.Printf("[mcp] server %q: tool list changed, %d tools", s.Name, len(tools))
+		m.toolsChanged(s)
+	case "notifications/prompts/list_changed":
+		prompts, err := s.listPrompts(ctx)
+		if err != nil {
+			log.Printf("[mcp] server %q: refreshing prompts: %v", s.Name, err)
+			return
+		}
+		s.mu.Lock()
+		s.prompts = prompts
+		s.mu.Unlock()
+	}
+}
+
+func (m *McpManager) toolsChanged(s *McpServer) {
+	if m.onToolsChanged != nil {
+		m.onToolsChanged(s.Name, s.Tools())
+	}
+}
@@ -0,0 +1,154 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+)
+
+// transport carries JSON-RPC messages to one MCP server.
+type transport interface {
+	call(ctx context.Context, id int64, method string, params any) (json.RawMessage, error)
+	notify(ctx context.Context, method string, params any) error
+	closed() <-chan struct{} // closed once the connection is gone
+	closeErr() error         // why it went
+	close()
+}
+
+// router matches responses to waiting calls by ID and hands everything else
+// (notifications, server requests) to the right place. Every transport
+// reads messages from its own streams and feeds them to dispatch.
+type router struct {
+	name     string
+	onNotify func(method string, params json.RawMessage)
+	reply    func(msg []byte) // answers requests the server sends us
+
+	mu      sync.Mutex
+	pending map[int64]chan *rpcMessage
+	done    chan struct{}
+	err     error // why the connection closed
+}
+
+func newRouter(name string, onNotify func(string, json.RawMessage), reply func([]byte)) *router {
+	return &router{
+		name:     name,
+		onNotify: onNotify,
+		reply:    reply,
+		pending:  make(map[int64]chan *rpcMessage),
+		done:     make(chan struct{}),
+	}
+}
+
+// register reserves id for a call about to be sent.
+func (r *router) register(id int64) (chan *rpcMessage, error) {
+	ch := make(chan *rpcMessage, 1)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending == nil {
+		return nil, r.err
+	}
+	r.pending[id] = ch
+	return ch, nil
+}
+
+func (r *router) forget(id int64) {
+	r.mu.Lock()
+	if r.pending != nil {
+		delete(r.pending, id)
+	}
+	r.mu.Unlock()
+}
+
+// fail completes a pending call with err, e.g. when the HTTP request
+// carrying it failed.
+func (r *router) fail(id int64, err error) {
+	r.mu.Lock()
+	ch := r.pending[id]
+	delete(r.pending, id)
+	r.mu.Unlock()
+	if ch != nil {
+		ch <- &rpcMessage{Error: &rpcError{Code: -32000, Message: err.Error()}}
+	}
+}
+
+// waiting reports whether id still awaits its response.
+func (r *router) waiting(id int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.pending[id]
+	return ok
+}
+
+func (r *router) dispatch(msg *rpcMessage) {
+	switch {
+	case msg.isResponse():
+		id, ok := msg.responseID()
+		if !ok {
+			return
+		}
+		r.mu.Lock()
+		ch := r.pending[id]
+		delete(r.pending, id)
+		r.mu.Unlock()
+		if ch != nil {
+			ch <- msg
+		}
+	case len(msg.ID) > 0:
+		if r.reply != nil {
+			r.reply(replyTo(msg))
+		}
+	case msg.Method != "" && r.onNotify != nil:
+		r.onNotify(msg.Method, msg.Params)
+	}
+}
+
+// dispatchRaw decodes and dispatches one message, skipping anything that
+// is not JSON.
+func (r *router) dispatchRaw(data []byte) {
+	var msg rpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Printf("[mcp] server %q: ignoring non-JSON output: %.200s", r.name, data)
+		return
+	}
+	r.dispatch(&msg)
+}
+
+// wait blocks until the response to id arrives, the connection closes or
+// ctx ends.
+func (r *router) wait(ctx context.Context, id int64, ch chan *rpcMessage) (json.RawMessage, error) {
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+		return resp.Result, nil
+	case <-r.done:
+		return nil, r.closeErr()
+	case <-ctx.Done():
+		r.forget(id)
+		return nil, ctx.Err()
+	}
+}
+
+// shutdown fails all pending and future calls with err.
+func (r *router) shutdown(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending == nil {
+		return
+	}
+	r.err = err
+	r.pending = nil
+	close(r.done)
+}
+
+func (r *router) closed() <-chan struct{} {
+	return r.done
+}
+
+func (r *router) closeErr() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
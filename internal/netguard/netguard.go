@@ -0,0 +1,291 @@
+// Package netguard provides an outbound HTTP client that refuses to connect
+// to private, loopback and link-local addresses (SSRF protection). Checks run
+// against the addresses a hostname actually resolves to, and the vetted
+// address is the one dialed, so DNS rebinding cannot slip past them.
+package netguard
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config lists exemptions and extra restrictions on top of the built-in
+// private-range block.
+type Config struct {
+	AllowPrivate bool     // disable the private-range block entirely
+	AllowHosts   []string // hostnames exempt from the private-range block ("*.corp.example" ok)
+	AllowCIDRs   []string // address ranges exempt from the private-range block
+	DenyHosts    []string // hostnames always refused
+	DenyCIDRs    []string // address ranges always refused
+}
+
+// Policy decides which outbound connections are permitted.
+type Policy struct {
+	allowPrivate bool
+	allowHosts   []string
+	allowNets    []*net.IPNet
+	denyHosts    []string
+	denyNets     []*net.IPNet
+
+	transportOnce sync.Once
+	transport     *http.Transport
+}
+
+// BlockedError reports a refused destination.
+type BlockedError struct {
+	Host   string
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("destination %s blocked: %s", e.Host, e.Reason)
+}
+
+// blockedNets are ranges never reachable by default, beyond those covered by
+// net.IP's classification methods.
+var blockedNets = mustCIDRs(
+	"0.0.0.0/8",     // "this" network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"240.0.0.0/4",   // reserved
+	"64:ff9b::/96",  // NAT64 can embed private IPv4
+)
+
+var defaultPolicy = New(Config{})
+
+// Default returns the strict policy with no exemptions.
+func Default() *Policy {
+	return defaultPolicy
+}
+
+// New builds a Policy from cfg. Invalid CIDRs are ignored.
+func New(cfg Config) *Policy {
+	return &Policy{
+		allowPrivate: cfg.AllowPrivate,
+		allowHosts:   normalizeHosts(cfg.AllowHosts),
+		allowNets:    parseCIDRs(cfg.AllowCIDRs),
+		denyHosts:    normalizeHosts(cfg.DenyHosts),
+		denyNets:     parseCIDRs(cfg.DenyCIDRs),
+	}
+}
+
+// WithAllowedHosts returns a copy of p that also exempts hosts from the
+// private-range block. Use it for endpoints the operator configured (e.g. a
+// self-hosted search engine), never for model-supplied URLs.
+func (p *Policy) WithAllowedHosts(hosts ...string) *Policy {
+	if p == nil {
+		p = defaultPolicy
+	}
+	return &Policy{
+		allowPrivate: p.allowPrivate,
+		allowHosts:   append(append([]string{}, p.allowHosts...), normalizeHosts(hosts)...),
+		allowNets:    p.allowNets,
+		denyHosts:    p.denyHosts,
+		denyNets:     p.denyNets,
+	}
+}
+
+// Client returns an HTTP client that enforces the policy on every
+// connection, including redirects. Environment proxies are not used, since a
+// proxy would resolve hostnames out of reach of the checks. A nil Policy
+// behaves like Default().
+func (p *Policy) Client(timeout time.Duration) *http.Client {
+	if p == nil {
+		p = defaultPolicy
+	}
+	p.transportOnce.Do(func() {
+		p.transport = &http.Transport{
+			Proxy:                 nil,
+			DialContext:           p.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          50,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		}
+	})
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: p.transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			return p.CheckURL(req.URL)
+		},
+	}
+}
+
+// CheckURL validates the scheme and hostname of u. Address checks happen at
+// dial time.
+func (p *Policy) CheckURL(u *url.URL) error {
+	if p == nil {
+		p = defaultPolicy
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return &BlockedError{Host: u.String(), Reason: "only http and https URLs are allowed"}
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return &BlockedError{Host: u.String(), Reason: "missing host"}
+	}
+	if matchHost(p.denyHosts, host) {
+		return &BlockedError{Host: host, Reason: "host is on the deny list"}
+	}
+	return nil
+}
+
+// DialContext resolves addr, checks every resolved address and dials the
+// first permitted one.
+func (p *Policy) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if matchHost(p.denyHosts, host) {
+		return nil, &BlockedError{Host: host, Reason: "host is on the deny list"}
+	}
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	exempt := p.allowPrivate || matchHost(p.allowHosts, host)
+
+	var lastErr error
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	for _, ip := range ips {
+		if err := p.checkIP(host, ip.IP, exempt); err != nil {
+			lastErr = err
+			continue
+		}
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.IP.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no addresses found for %s", host)
+	}
+	return nil, lastErr
+}
+
+// CheckIP reports whether ip may be contacted for host.
+func (p *Policy) CheckIP(host string, ip net.IP) error {
+	if p == nil {
+		p = defaultPolicy
+	}
+	return p.checkIP(host, ip, p.allowPrivate || matchHost(p.allowHosts, host))
+}
+
+func (p *Policy) checkIP(host string, ip net.IP, exempt bool) error {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	for _, n := range p.denyNets {
+		if n.Contains(ip) {
+			return &BlockedError{Host: host, Reason: fmt.Sprintf("address %s is on the deny list", ip)}
+		}
+	}
+	if exempt {
+		return nil
+	}
+	for _, n := range p.allowNets {
+		if n.Contains(ip) {
+			return nil
+		}
+	}
+	if reason := privateReason(ip); reason != "" {
+		return &BlockedError{Host: host, Reason: fmt.Sprintf("resolves to %s address %s", reason, ip)}
+	}
+	return nil
+}
+
+func privateReason(ip net.IP) string {
+	switch {
+	case ip.IsLoopback():
+		return "loopback"
+	case ip.IsPrivate():
+		return "private"
+	case ip.IsLinkLocalUnicast(), ip.IsLinkLocalMulticast():
+		return "link-local"
+	case ip.IsUnspecified():
+		return "unspecified"
+	case ip.IsMulticast(), ip.IsInterfaceLocalMulticast():
+		return "multicast"
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return "reserved"
+		}
+	}
+	return ""
+}
+
+// MatchHost reports whether host matches any of patterns, using the same
+// rules as AllowHosts and DenyHosts.
+func MatchHost(patterns []string, host string) bool {
+	return matchHost(normalizeHosts(patterns), strings.ToLower(strings.TrimSuffix(host, ".")))
+}
+
+// matchHost reports whether host matches any pattern. "*.example.com"
+// matches subdomains; a bare domain matches only itself.
+func matchHost(patterns []string, host string) bool {
+	for _, p := range patterns {
+		if rest, ok := strings.CutPrefix(p, "*."); ok {
+			if strings.HasSuffix(host, "."+rest) {
+				return true
+			}
+		} else if host == p {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeHosts(hosts []string) []string {
+	out := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			out = append(out, strings.TrimSuffix(h, "."))
+		}
+	}
+	return out
+}
+
+func parseCIDRs(cidrs []string) []*net.IPNet {
+	var out []*net.IPNet
+	for _, c := range cidrs {
+		c = strings.TrimSpace(c)
+		if !strings.Contains(c, "/") {
+			if ip := net.ParseIP(c); ip != nil {
+				if ip.To4() != nil {
+					c += "/32"
+				} else {
+					c += "/128"
+				}
+			}
+		}
+		if _, n, err := net.ParseCIDR(c); err == nil {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func mustCIDRs(cidrs ...string) []*net.IPNet {
+	nets := parseCIDRs(cidrs)
+	if len(nets) != len(cidrs) {
+		panic("netguard: invalid built-in CIDR")
+	}
+	return nets
+}
@@ -0,0 +1,59 @@
+package netguard
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckIP(t *testing.T) {
+	p := New(Config{
+		AllowHosts: []string{"*.corp.example"},
+		AllowCIDRs: []string{"10.1.0.0/16"},
+		DenyCIDRs:  []string{"203.0.113.0/24"},
+	})
+
+	tests := []struct {
+		host string
+		ip   string
+		ok   bool
+	}{
+		{"example.com", "93.184.216.34", true},
+		{"localhost", "127.0.0.1", false},
+		{"metadata", "169.254.169.254", false},
+		{"lan", "192.168.1.10", false},
+		{"v6-loopback", "::1", false},
+		{"v6-mapped", "::ffff:10.0.0.1", false},
+		{"cgnat", "100.64.0.1", false},
+		{"allowed-cidr", "10.1.2.3", true},
+		{"wiki.corp.example", "10.9.9.9", true},
+		{"corp.example", "10.9.9.9", false},
+		{"denied", "203.0.113.7", false},
+	}
+	for _, tc := range tests {
+		err := p.CheckIP(tc.host, net.ParseIP(tc.ip))
+		if (err == nil) != tc.ok {
+			t.Errorf("CheckIP(%s, %s) = %v, want ok=%v", tc.host, tc.ip, err, tc.ok)
+		}
+	}
+}
+
+func TestClientBlocksLoopback(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, err := Default().Client(5 * time.Second).Get(srv.URL)
+	var blocked *BlockedError
+	if !errors.As(err, &blocked) {
+		t.Fatalf("expected BlockedError, got %v", err)
+	}
+
+	resp, err := New(Config{AllowCIDRs: []string{"127.0.0.0/8"}}).Client(5 * time.Second).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("allowed loopback: %v", err)
+	}
+	resp.Body.Close()
+}
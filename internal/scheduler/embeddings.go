@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/yifanes/miniclawd/internal/embedding"
+	"github.com/yifanes/miniclawd/internal/storage"
+)
+
+const (
+	embeddingBatchSize = 32
+	embeddingInterval  = time.Minute
+)
+
+// SpawnEmbeddingIndexer starts the loop that embeds memories. The first
+// pass backfills every memory without a current embedding; after that it
+// picks up new and edited memories each interval.
+func SpawnEmbeddingIndexer(ctx context.Context, db *storage.Database, provider embedding.EmbeddingProvider) {
+	go func() {
+		if n, err := embedPendingMemories(ctx, db, provider); err != nil {
+			log.Printf("[embeddings] backfill stopped after %d memories: %v", n, err)
+		} else if n > 0 {
+			log.Printf("[embeddings] backfilled %d memories", n)
+		}
+
+		ticker := time.NewTicker(embeddingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := embedPendingMemories(ctx, db, provider); err != nil {
+					log.Printf("[embeddings] %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// embedPendingMemories embeds memories in batches until none are left,
+// returning how many it stored.
+func embedPendingMemories(ctx context.Context, db *storage.Database, provider embedding.EmbeddingProvider) (int, error) {
+	model := provider.ModelName()
+	done := 0
+	for ctx.Err() == nil {
+		mems, err := db.GetMemoriesWithoutEmbedding(model, embeddingBatchSize)
+		if err != nil {
+			return done, err
+		}
+		if len(mems) == 0 {
+			return done, nil
+		}
+		texts := make([]string, len(mems))
+		for i, m := range mems {
+			texts[i] = m.Content
+		}
+		vectors, err := provider.Embed(ctx, texts)
+		if err == nil && len(vectors) != len(mems) {
+			err = fmt.Errorf("provider returned %d embeddings for %d memories", len(vectors), len(mems))
+		}
+		if err != nil {
+			n, err := embedIndividually(ctx, db, provider, mems, err)
+			done += n
+			if err != nil {
+				return done, err
+			}
+			continue
+		}
+		for i, m := range mems {
+			if err := db.SaveMemoryEmbedding(m.ID, m.Content, model, vectors[i]); err != nil {
+				return done, err
+			}
+			done++
+		}
+	}
+	return done, ctx.Err()
+}
+
+// embedIndividually retries a failed batch one memory at a time, so one
+// memory the provider rejects (e.g. over its input limit) cannot stall the
+// rest. Memories that still fail are marked so the next pass moves on. If
+// none succeed the provider itself is likely failing: nothing is marked
+// and batchErr is returned, to retry on the next tick.
+func embedIndividually(ctx context.Context, db *storage.Database, provider embedding.EmbeddingProvider, mems []storage.Memory, batchErr error) (int, error) {
+	model := provider.ModelName()
+	done := 0
+	var failed []storage.Memory
+	for _, m := range mems {
+		vectors, err := provider.Embed(ctx, []string{m.Content})
+		if err == nil && len(vectors) != 1 {
+			err = fmt.Errorf("provider returned %d embeddings for 1 memory", len(vectors))
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return done, ctx.Err()
+			}
+			log.Printf("[embeddings] memory %d: %v", m.ID, err)
+			failed = append(failed, m)
+			continue
+		}
+		if err := db.SaveMemoryEmbedding(m.ID, m.Content, model, vectors[0]); err != nil {
+			return done, err
+		}
+		done++
+	}
+	if done == 0 {
+		return 0, batchErr
+	}
+	for _, m := range failed {
+		if err := db.MarkMemoryEmbeddingFailed(m.ID, m.Content, model); err != nil {
+			return done, err
+		}
+	}
+	return done, nil
+}
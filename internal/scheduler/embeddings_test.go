@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yifanes/miniclawd/internal/storage"
+)
+
+// limitedProvider rejects any batch containing text longer than max, like
+// a provider enforcing an input limit.
+type limitedProvider struct {
+	max  int
+	down bool
+}
+
+func (p *limitedProvider) ModelName() string { return "limited" }
+func (p *limitedProvider) Dimension() int    { return 2 }
+func (p *limitedProvider) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	if p.down {
+		return nil, errors.New("connection refused")
+	}
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		if len(t) > p.max {
+			return nil, errors.New("input too long")
+		}
+		out[i] = []float32{1, float32(len(t))}
+	}
+	return out, nil
+}
+
+func TestEmbedPendingMemoriesSkipsRejected(t *testing.T) {
+	db, err := storage.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	long, _ := db.InsertMemory(nil, strings.Repeat("x", 100), "KNOWLEDGE")
+	for i := 0; i < embeddingBatchSize+5; i++ {
+		db.InsertMemory(nil, "short memory", "KNOWLEDGE")
+	}
+
+	p := &limitedProvider{max: 50, down: true}
+	if n, err := embedPendingMemories(context.Background(), db, p); err == nil || n != 0 {
+		t.Fatalf("provider down: n=%d err=%v", n, err)
+	}
+	if pending, _ := db.GetMemoriesWithoutEmbedding("limited", 100); len(pending) != embeddingBatchSize+6 {
+		t.Fatalf("outage marked memories as failed: %d pending", len(pending))
+	}
+
+	p.down = false
+	n, err := embedPendingMemories(context.Background(), db, p)
+	if err != nil || n != embeddingBatchSize+5 {
+		t.Fatalf("n=%d err=%v, want %d embedded", n, err, embeddingBatchSize+5)
+	}
+	if pending, _ := db.GetMemoriesWithoutEmbedding("limited", 100); len(pending) != 0 {
+		t.Errorf("%d memories still pending", len(pending))
+	}
+	m, _ := db.GetMemoryByID(long)
+	if m.EmbeddingModel == nil || *m.EmbeddingModel != storage.EmbeddingFailedPrefix+"limited" {
+		t.Errorf("rejected memory embedding_model = %v", m.EmbeddingModel)
+	}
+
+	// Editing the memory makes it eligible again.
+	db.UpdateMemoryContent(long, "now short", "KNOWLEDGE")
+	if n, err := embedPendingMemories(context.Background(), db, p); err != nil || n != 1 {
+		t.Errorf("after edit: n=%d err=%v", n, err)
+	}
+}
@@ -10,12 +10,15 @@ import (
 
 	"github.com/yifanes/miniclawd/internal/agent"
 	"github.com/yifanes/miniclawd/internal/core"
+	"github.com/yifanes/miniclawd/internal/embedding"
 	"github.com/yifanes/miniclawd/internal/llm"
 	"github.com/yifanes/miniclawd/internal/storage"
 )
 
 // SpawnReflector starts the memory extraction background loop.
-func SpawnReflector(ctx context.Context, db *storage.Database, provider llm.LLMProvider, intervalMins int) {
+// With an embedding provider, extracted memories are also deduplicated by
+// meaning.
+func SpawnReflector(ctx context.Context, db *storage.Database, provider llm.LLMProvider, embedder embedding.EmbeddingProvider, intervalMins int) {
 	go func() {
 		interval := time.Duration(intervalMins) * time.Minute
 		ticker := time.NewTicker(interval)
@@ -26,13 +29,13 @@ func SpawnReflector(ctx context.Context, db *storage.Database, provider llm.LLMP
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				runReflector(ctx, db, provider, intervalMins)
+				runReflector(ctx, db, provider, embedder, intervalMins)
 			}
 		}
 	}()
 }
 
-func runReflector(ctx context.Context, db *storage.Database, provider llm.LLMProvider, intervalMins int) {
+func runReflector(ctx context.Context, db *storage.Database, provider llm.LLMProvider, embedder embedding.EmbeddingProvider, intervalMins int) {
 	// Archive stale memories (30 days).
 	db.ArchiveStaleMemories(30 * 24 * time.Hour)
 
@@ -48,11 +51,11 @@ func runReflector(ctx context.Context, db *storage.Database, provider llm.LLMPro
 		if ctx.Err() != nil {
 			return
 		}
-		reflectForChat(ctx, db, provider, chatID)
+		reflectForChat(ctx, db, provider, embedder, chatID)
 	}
 }
 
-func reflectForChat(ctx context.Context, db *storage.Database, provider llm.LLMProvider, chatID int64) {
+func reflectForChat(ctx context.Context, db *storage.Database, provider llm.LLMProvider, embedder embedding.EmbeddingProvider, chatID int64) {
 	startedAt := time.Now().UTC()
 
 	// Get cursor.
@@ -155,23 +158,38 @@ Rules:
 
 	inserted, updated, skipped := 0, 0, 0
 
-	for _, em := range extracted {
+	contents := make([]string, len(extracted))
+	for i, em := range extracted {
+		contents[i] = em.Content
+	}
+	dedupMethod := "keyword"
+	semanticDups := semanticDuplicates(ctx, db, embedder, existing, contents)
+	if semanticDups != nil {
+		dedupMethod = "embedding"
+	}
+
+	for i, em := range extracted {
 		if len(em.Content) < 20 {
 			skipped++
 			continue
 		}
 
-		// Check for duplicate via Jaccard similarity.
-		isDup := false
-		for _, ex := range existing {
-			if !ex.IsArchived && jaccardSimilarity(em.Content, ex.Content) > 0.5 {
-				isDup = true
-				// Touch the existing memory.
-				db.TouchMemoryLastSeen(ex.ID)
-				break
+		// Check for duplicate by meaning, then via Jaccard similarity.
+		dupID := int64(0)
+		if semanticDups != nil {
+			dupID = semanticDups[i]
+		}
+		if dupID == 0 {
+			for _, ex := range existing {
+				if !ex.IsArchived && jaccardSimilarity(em.Content, ex.Content) > 0.5 {
+					dupID = ex.ID
+					break
+				}
 			}
 		}
-		if isDup {
+		if dupID != 0 {
+			// Touch the existing memory.
+			db.TouchMemoryLastSeen(dupID)
 			skipped++
 			continue
 		}
@@ -194,7 +212,7 @@ Rules:
 
 	finishedAt := time.Now().UTC()
 	db.LogReflectorRun(chatID, startedAt.Format(time.RFC3339), finishedAt.Format(time.RFC3339),
-		len(extracted), inserted, updated, skipped, "keyword", true, nil)
+		len(extracted), inserted, updated, skipped, dedupMethod, true, nil)
 
 	// Update cursor to latest message timestamp.
 	if len(messages) > 0 {
@@ -202,6 +220,60 @@ Rules:
 	}
 }
 
+// semanticDuplicateThreshold is the cosine similarity at or above which an
+// extracted memory duplicates an existing one.
+const semanticDuplicateThreshold = 0.85
+
+// semanticDuplicates returns, for each candidate, the ID of the active
+// existing memory it duplicates by embedding similarity, or 0. Existing
+// memories not yet indexed are embedded alongside the candidates. It returns
+// nil when embeddings are unavailable.
+func semanticDuplicates(ctx context.Context, db *storage.Database, embedder embedding.EmbeddingProvider, existing []storage.Memory, candidates []string) []int64 {
+	if embedder == nil || len(candidates) == 0 {
+		return nil
+	}
+	var active []storage.Memory
+	var ids []int64
+	for _, m := range existing {
+		if !m.IsArchived {
+			active = append(active, m)
+			ids = append(ids, m.ID)
+		}
+	}
+	vectors, err := db.GetMemoryEmbeddings(ids, embedder.ModelName())
+	if err != nil {
+		log.Printf("[reflector] loading embeddings: %v", err)
+		return nil
+	}
+	texts := append([]string(nil), candidates...)
+	var missing []int64
+	for _, m := range active {
+		if _, ok := vectors[m.ID]; !ok {
+			texts = append(texts, m.Content)
+			missing = append(missing, m.ID)
+		}
+	}
+	embedded, err := embedder.Embed(ctx, texts)
+	if err != nil || len(embedded) != len(texts) {
+		log.Printf("[reflector] embedding for dedup, falling back to keywords: %v", err)
+		return nil
+	}
+	for i, id := range missing {
+		vectors[id] = embedded[len(candidates)+i]
+	}
+
+	dups := make([]int64, len(candidates))
+	for i := range candidates {
+		best := semanticDuplicateThreshold
+		for _, m := range active {
+			if sim := embedding.Cosine(embedded[i], vectors[m.ID]); sim >= best {
+				best, dups[i] = sim, m.ID
+			}
+		}
+	}
+	return dups
+}
+
 // jaccardSimilarity computes the Jaccard similarity of two strings by word sets.
 func jaccardSimilarity(a, b string) float64 {
 	wordsA := wordSet(strings.ToLower(a))
@@ -59,6 +59,7 @@ func executeTask(ctx context.Context, db *storage.Database, deps *agent.AgentDep
 		CallerChannel: channels.SessionSourceForChat(chatType),
 		ChatID:        task.ChatID,
 		ChatType:      chatType,
+		Profile:       "scheduler",
 	}
 
 	prompt := task.Prompt
@@ -1,6 +1,10 @@
 package storage
 
-import "database/sql"
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+)
 
 // AuthAPIKeyRecord represents an API key with its scopes.
 type AuthAPIKeyRecord struct {
@@ -155,6 +159,12 @@ func (d *Database) ListAPIKeys() ([]AuthAPIKeyRecord, error) {
 	return keys, nil
 }
 
+// HashAPIKey returns the hash under which an API key is stored.
+func HashAPIKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
 // ValidateAPIKeyHash checks if an API key hash is valid and updates last_used.
 func (d *Database) ValidateAPIKeyHash(keyHash string) (int64, []string, bool, error) {
 	var id int64
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"math"
+	"strings"
+)
+
+// SaveMemoryEmbedding stores the embedding of a memory's content and marks
+// it as embedded by model. It does nothing if the content has changed
+// since it was read, so a stale vector is never marked current.
+func (d *Database) SaveMemoryEmbedding(id int64, content, model string, vector []float32) error {
+	return d.execTx(func(tx *sql.Tx) error {
+		res, err := tx.Exec(
+			`UPDATE memories SET embedding_model = ? WHERE id = ? AND content = ?`,
+			model, id, content,
+		)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil
+		}
+		_, err = tx.Exec(
+			`INSERT OR REPLACE INTO memory_embeddings (memory_id, model, dim, vector, updated_at)
+			 VALUES (?, ?, ?, ?, ?)`,
+			id, model, len(vector), encodeVector(vector), nowRFC3339(),
+		)
+		return err
+	})
+}
+
+// EmbeddingFailedPrefix marks, in memories.embedding_model, a memory the
+// model could not embed. It is retried once the content or model changes.
+const EmbeddingFailedPrefix = "failed:"
+
+// MarkMemoryEmbeddingFailed records that model could not embed a memory's
+// content, so the indexer skips it until the content changes.
+func (d *Database) MarkMemoryEmbeddingFailed(id int64, content, model string) error {
+	_, err := d.exec(
+		`UPDATE memories SET embedding_model = ? WHERE id = ? AND content = ?`,
+		EmbeddingFailedPrefix+model, id, content,
+	)
+	return err
+}
+
+// GetMemoryEmbeddings returns the current embeddings from model for the
+// given memories. Memories without one are absent from the map.
+func (d *Database) GetMemoryEmbeddings(ids []int64, model string) (map[int64][]float32, error) {
+	if len(ids) == 0 {
+		return map[int64][]float32{}, nil
+	}
+	args := []any{model, model}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := d.query(
+		`SELECT e.memory_id, e.vector FROM memory_embeddings e
+		 JOIN memories m ON m.id = e.memory_id
+		 WHERE m.embedding_model = ? AND e.model = ?
+		   AND e.memory_id IN (?`+strings.Repeat(",?", len(ids)-1)+`)`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanVectors(rows)
+}
+
+// GetContextMemoryEmbeddings returns the current embeddings from model for
+// every memory that may be injected into chatID's context: active, at least
+// ContextMinConfidence, and belonging to the chat or global.
+func (d *Database) GetContextMemoryEmbeddings(chatID int64, model string) (map[int64][]float32, error) {
+	rows, err := d.query(
+		`SELECT e.memory_id, e.vector FROM memory_embeddings e
+		 JOIN memories m ON m.id = e.memory_id
+		 WHERE m.embedding_model = ? AND e.model = ?
+		   AND m.is_archived = 0 AND m.confidence >= ?
+		   AND (m.chat_id = ? OR m.chat_id IS NULL)`,
+		model, model, ContextMinConfidence, chatID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanVectors(rows)
+}
+
+func scanVectors(rows *sql.Rows) (map[int64][]float32, error) {
+	defer rows.Close()
+	out := make(map[int64][]float32)
+	for rows.Next() {
+		var id int64
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			return nil, err
+		}
+		out[id] = decodeVector(blob)
+	}
+	return out, rows.Err()
+}
+
+// encodeVector packs a vector as little-endian float32s.
+func encodeVector(v []float32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(f))
+	}
+	return b
+}
+
+func decodeVector(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
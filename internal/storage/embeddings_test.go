@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMemoryEmbeddings(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	a, _ := db.InsertMemory(nil, "likes green tea", "preference")
+	b, _ := db.InsertMemory(nil, "lives in Berlin", "fact")
+
+	pending, err := db.GetMemoriesWithoutEmbedding("m1", 10)
+	if err != nil || len(pending) != 2 {
+		t.Fatalf("pending = %d, %v", len(pending), err)
+	}
+	if err := db.SaveMemoryEmbedding(a, "likes green tea", "m1", []float32{0.5, -1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	// Content changed since it was read: the vector must not be stored.
+	if err := db.SaveMemoryEmbedding(b, "lives in Paris", "m1", []float32{1, 1, 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	vecs, err := db.GetMemoryEmbeddings([]int64{a, b}, "m1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vecs) != 1 || len(vecs[a]) != 3 || vecs[a][1] != -1 || vecs[a][2] != 2 {
+		t.Fatalf("embeddings = %v", vecs)
+	}
+	if pending, _ := db.GetMemoriesWithoutEmbedding("m1", 10); len(pending) != 1 || pending[0].ID != b {
+		t.Errorf("pending after save = %+v", pending)
+	}
+	if pending, _ := db.GetMemoriesWithoutEmbedding("m2", 10); len(pending) != 2 {
+		t.Errorf("a model change should re-embed everything, got %d", len(pending))
+	}
+
+	// Editing a memory invalidates its embedding; deleting removes it.
+	db.UpdateMemoryContent(a, "likes black tea", "preference")
+	if vecs, _ := db.GetMemoryEmbeddings([]int64{a}, "m1"); len(vecs) != 0 {
+		t.Errorf("edited memory still has an embedding")
+	}
+	db.DeleteMemory(a)
+	var n int
+	db.queryRow(`SELECT COUNT(*) FROM memory_embeddings`).Scan(&n)
+	if n != 0 {
+		t.Errorf("%d embeddings left after delete", n)
+	}
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -39,6 +40,25 @@ type MemoryReflectorRun struct {
 	ErrorText      *string
 }
 
+// MemorySupersedeEdge records that one memory replaced another.
+type MemorySupersedeEdge struct {
+	ID           int64
+	FromMemoryID int64 // the memory that was replaced
+	ToMemoryID   int64 // the memory that replaced it
+	Reason       *string
+	CreatedAt    string
+}
+
+// MemoryFilter selects memories for ListMemories. A nil ChatID with Global
+// unset means every scope.
+type MemoryFilter struct {
+	ChatID          *int64
+	Global          bool
+	Category        string
+	IncludeArchived bool
+	Limit           int // 0 means no limit
+}
+
 // MemoryInjectionLog records a memory injection event.
 type MemoryInjectionLog struct {
 	ID              int64
@@ -75,6 +95,10 @@ func (d *Database) InsertMemoryWithMetadata(chatID *int64, content, category, so
 	return id, err
 }
 
+// ContextMinConfidence is the confidence a memory needs to be injected into
+// the system prompt.
+const ContextMinConfidence = 0.45
+
 // GetMemoriesForContext fetches active memories for system prompt injection.
 // Returns memories for the given chat + global (chat_id IS NULL), confidence >= 0.45.
 func (d *Database) GetMemoriesForContext(chatID int64, limit int) ([]Memory, error) {
@@ -82,10 +106,10 @@ func (d *Database) GetMemoriesForContext(chatID int64, limit int) ([]Memory, err
 		`SELECT id, chat_id, content, category, created_at, updated_at, embedding_model,
 		        confidence, source, last_seen_at, is_archived, archived_at, chat_channel, external_chat_id
 		 FROM memories
-		 WHERE is_archived = 0 AND confidence >= 0.45
+		 WHERE is_archived = 0 AND confidence >= ?
 		   AND (chat_id = ? OR chat_id IS NULL)
 		 ORDER BY updated_at DESC LIMIT ?`,
-		chatID, limit,
+		ContextMinConfidence, chatID, limit,
 	)
 	if err != nil {
 		return nil, err
@@ -116,8 +140,18 @@ func (d *Database) SearchMemories(chatID int64, query string, limit int) ([]Memo
 	return d.SearchMemoriesWithOptions(chatID, query, limit, false, false)
 }
 
-// SearchMemoriesWithOptions provides advanced memory search.
+// SearchMemoriesWithOptions provides advanced memory search. It uses the
+// full-text index, ranked by BM25, and falls back to substring matching
+// when that finds nothing (e.g. part of a word, or CJK text).
 func (d *Database) SearchMemoriesWithOptions(chatID int64, query string, limit int, includeArchived, broadRecall bool) ([]Memory, error) {
+	mems, err := d.searchMemoriesFTS(chatID, query, limit, includeArchived, broadRecall)
+	if err != nil || len(mems) > 0 {
+		return mems, err
+	}
+	return d.searchMemoriesLike(chatID, query, limit, includeArchived, broadRecall)
+}
+
+func (d *Database) searchMemoriesLike(chatID int64, query string, limit int, includeArchived, broadRecall bool) ([]Memory, error) {
 	archivedClause := "AND is_archived = 0"
 	if includeArchived {
 		archivedClause = ""
@@ -131,7 +165,7 @@ func (d *Database) SearchMemoriesWithOptions(chatID int64, query string, limit i
 		`SELECT id, chat_id, content, category, created_at, updated_at, embedding_model,
 		        confidence, source, last_seen_at, is_archived, archived_at, chat_channel, external_chat_id
 		 FROM memories
-		 WHERE %s AND content LIKE ? %s
+		 WHERE %s AND content LIKE ? ESCAPE '\' %s
 		 ORDER BY updated_at DESC LIMIT ?`,
 		chatClause, archivedClause,
 	)
@@ -140,7 +174,7 @@ func (d *Database) SearchMemoriesWithOptions(chatID int64, query string, limit i
 	if !broadRecall {
 		args = append(args, chatID)
 	}
-	args = append(args, "%"+query+"%", limit)
+	args = append(args, likePattern(query), limit)
 
 	rows, err := d.query(q, args...)
 	if err != nil {
@@ -150,6 +184,29 @@ func (d *Database) SearchMemoriesWithOptions(chatID int64, query string, limit i
 	return scanMemories(rows)
 }
 
+// GetMemoriesByIDs fetches the given memories, most recently updated first.
+func (d *Database) GetMemoriesByIDs(ids []int64) ([]Memory, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := d.query(
+		`SELECT id, chat_id, content, category, created_at, updated_at, embedding_model,
+		        confidence, source, last_seen_at, is_archived, archived_at, chat_channel, external_chat_id
+		 FROM memories WHERE id IN (?`+strings.Repeat(",?", len(ids)-1)+`)
+		 ORDER BY updated_at DESC`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMemories(rows)
+}
+
 // GetMemoryByID fetches a single memory.
 func (d *Database) GetMemoryByID(id int64) (*Memory, error) {
 	row := d.queryRow(
@@ -176,6 +233,18 @@ func (d *Database) UpdateMemoryContent(id int64, content, category string) error
 	return err
 }
 
+// EditMemory changes a memory's content, category and confidence, keeping its
+// source and archived state.
+func (d *Database) EditMemory(id int64, content, category string, confidence float64) error {
+	_, err := d.exec(
+		`UPDATE memories SET content = ?, category = ?, confidence = ?, updated_at = ?,
+		        embedding_model = CASE WHEN content = ? THEN embedding_model END
+		 WHERE id = ?`,
+		content, category, confidence, nowRFC3339(), content, id,
+	)
+	return err
+}
+
 // UpdateMemoryWithMetadata updates a memory with full metadata control.
 func (d *Database) UpdateMemoryWithMetadata(id int64, content, category string, confidence float64, source string) error {
 	now := nowRFC3339()
@@ -194,10 +263,17 @@ func (d *Database) UpdateMemoryEmbeddingModel(id int64, model string) error {
 	return err
 }
 
-// DeleteMemory removes a memory.
+// DeleteMemory removes a memory and its supersede edges.
 func (d *Database) DeleteMemory(id int64) error {
-	_, err := d.exec(`DELETE FROM memories WHERE id = ?`, id)
-	return err
+	return d.execTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(
+			`DELETE FROM memory_supersede_edges WHERE from_memory_id = ? OR to_memory_id = ?`, id, id,
+		); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM memories WHERE id = ?`, id)
+		return err
+	})
 }
 
 // ArchiveMemory soft-deletes a memory by setting is_archived = 1.
@@ -224,12 +300,16 @@ func (d *Database) GetAllActiveMemories() ([]Memory, error) {
 	return scanMemories(rows)
 }
 
-// GetMemoriesWithoutEmbedding returns memories lacking an embedding model.
-func (d *Database) GetMemoriesWithoutEmbedding() ([]Memory, error) {
+// GetMemoriesWithoutEmbedding returns up to limit active memories with no
+// current embedding from model.
+func (d *Database) GetMemoriesWithoutEmbedding(model string, limit int) ([]Memory, error) {
 	rows, err := d.query(
 		`SELECT id, chat_id, content, category, created_at, updated_at, embedding_model,
 		        confidence, source, last_seen_at, is_archived, archived_at, chat_channel, external_chat_id
-		 FROM memories WHERE is_archived = 0 AND embedding_model IS NULL`,
+		 FROM memories WHERE is_archived = 0
+		   AND (embedding_model IS NULL OR (embedding_model != ? AND embedding_model != ?))
+		 ORDER BY id LIMIT ?`,
+		model, EmbeddingFailedPrefix+model, limit,
 	)
 	if err != nil {
 		return nil, err
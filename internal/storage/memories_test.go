@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestEditMemoryKeepsArchivedState(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	id, err := db.InsertMemoryWithMetadata(nil, "Office is in Berlin", "KNOWLEDGE", "tool", 0.8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.UpdateMemoryEmbeddingModel(id, "m1")
+	if err := db.ArchiveMemory(id); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.EditMemory(id, "Office is in Berlin", "PROFILE", 0.9); err != nil {
+		t.Fatal(err)
+	}
+	m, _ := db.GetMemoryByID(id)
+	if !m.IsArchived || m.Category != "PROFILE" || m.Confidence != 0.9 || m.Source != "tool" {
+		t.Fatalf("after metadata edit: %+v", m)
+	}
+	if m.EmbeddingModel == nil || *m.EmbeddingModel != "m1" {
+		t.Fatalf("embedding model cleared without a content change: %v", m.EmbeddingModel)
+	}
+
+	if err := db.EditMemory(id, "Office is in Paris", "PROFILE", 0.9); err != nil {
+		t.Fatal(err)
+	}
+	m, _ = db.GetMemoryByID(id)
+	if !m.IsArchived || m.Content != "Office is in Paris" || m.EmbeddingModel != nil {
+		t.Fatalf("after content edit: %+v", m)
+	}
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// ListMemories returns memories matching f, most recently updated first.
+func (d *Database) ListMemories(f MemoryFilter) ([]Memory, error) {
+	var where []string
+	var args []any
+	switch {
+	case f.ChatID != nil:
+		where = append(where, "chat_id = ?")
+		args = append(args, *f.ChatID)
+	case f.Global:
+		where = append(where, "chat_id IS NULL")
+	}
+	if f.Category != "" {
+		where = append(where, "category = ?")
+		args = append(args, f.Category)
+	}
+	if !f.IncludeArchived {
+		where = append(where, "is_archived = 0")
+	}
+	q := `SELECT id, chat_id, content, category, created_at, updated_at, embedding_model,
+	        confidence, source, last_seen_at, is_archived, archived_at, chat_channel, external_chat_id
+	 FROM memories`
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	q += " ORDER BY updated_at DESC, id DESC"
+	if f.Limit > 0 {
+		q += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+	rows, err := d.query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMemories(rows)
+}
+
+// FindMemoryByContent returns a memory in the given scope (nil for
+// global) with exactly this content and archived state, or nil.
+func (d *Database) FindMemoryByContent(chatID *int64, content string, archived bool) (*Memory, error) {
+	row := d.queryRow(
+		`SELECT id, chat_id, content, category, created_at, updated_at, embedding_model,
+		        confidence, source, last_seen_at, is_archived, archived_at, chat_channel, external_chat_id
+		 FROM memories WHERE chat_id IS ? AND content = ? AND is_archived = ?
+		 ORDER BY id LIMIT 1`,
+		chatID, content, boolToInt(archived),
+	)
+	m, err := scanMemory(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return m, err
+}
+
+// RestoreMemory inserts a memory with all of its metadata, as read from an
+// export. Empty timestamps default to now. The ID field is ignored.
+func (d *Database) RestoreMemory(m Memory) (int64, error) {
+	now := nowRFC3339()
+	orNow := func(s string) string {
+		if s == "" {
+			return now
+		}
+		return s
+	}
+	var id int64
+	err := d.withLock(func() error {
+		result, e := d.db.Exec(
+			`INSERT INTO memories (chat_id, content, category, created_at, updated_at, confidence, source,
+			        last_seen_at, is_archived, archived_at, chat_channel, external_chat_id)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			m.ChatID, m.Content, m.Category, orNow(m.CreatedAt), orNow(m.UpdatedAt), m.Confidence, m.Source,
+			orNow(m.LastSeenAt), boolToInt(m.IsArchived), m.ArchivedAt, m.ChatChannel, m.ExternalChatID,
+		)
+		if e != nil {
+			return e
+		}
+		id, e = result.LastInsertId()
+		return e
+	})
+	return id, err
+}
+
+// GetSupersedeEdges returns the supersede edges touching a memory, or all
+// edges if memoryID is 0, oldest first.
+func (d *Database) GetSupersedeEdges(memoryID int64) ([]MemorySupersedeEdge, error) {
+	q := `SELECT id, from_memory_id, to_memory_id, reason, created_at FROM memory_supersede_edges`
+	var args []any
+	if memoryID != 0 {
+		q += ` WHERE from_memory_id = ? OR to_memory_id = ?`
+		args = append(args, memoryID, memoryID)
+	}
+	rows, err := d.query(q+` ORDER BY created_at, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var edges []MemorySupersedeEdge
+	for rows.Next() {
+		var e MemorySupersedeEdge
+		if err := rows.Scan(&e.ID, &e.FromMemoryID, &e.ToMemoryID, &e.Reason, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		edges = append(edges, e)
+	}
+	return edges, rows.Err()
+}
+
+// AddSupersedeEdge records that toID replaced fromID without archiving
+// anything, for restoring exported history. An empty createdAt means now.
+// It reports false if the edge already existed.
+func (d *Database) AddSupersedeEdge(fromID, toID int64, reason *string, createdAt string) (bool, error) {
+	if createdAt == "" {
+		createdAt = nowRFC3339()
+	}
+	res, err := d.exec(
+		`INSERT INTO memory_supersede_edges (from_memory_id, to_memory_id, reason, created_at)
+		 SELECT ?, ?, ?, ?
+		 WHERE NOT EXISTS (
+			SELECT 1 FROM memory_supersede_edges WHERE from_memory_id = ? AND to_memory_id = ?
+		 )`,
+		fromID, toID, reason, createdAt, fromID, toID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// FindChatByExternalID returns the chat with the given channel and external
+// ID, or 0 if there is none.
+func (d *Database) FindChatByExternalID(channel, externalID string) (int64, error) {
+	var chatID int64
+	err := d.queryRow(
+		`SELECT chat_id FROM chats WHERE channel = ? AND external_chat_id = ?`,
+		channel, externalID,
+	).Scan(&chatID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return chatID, err
+}
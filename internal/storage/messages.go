@@ -15,11 +15,14 @@ type StoredMessage struct {
 	Timestamp  string // RFC 3339
 }
 
-// StoreMessage inserts or replaces a message.
+// StoreMessage inserts or replaces a message. It updates an existing row in
+// place so the full-text index stays in sync.
 func (d *Database) StoreMessage(msg StoredMessage) error {
 	_, err := d.exec(
-		`INSERT OR REPLACE INTO messages (id, chat_id, sender_name, content, is_from_bot, timestamp)
-		 VALUES (?, ?, ?, ?, ?, ?)`,
+		`INSERT INTO messages (id, chat_id, sender_name, content, is_from_bot, timestamp)
+		 VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (id, chat_id) DO UPDATE SET sender_name = excluded.sender_name,
+		   content = excluded.content, is_from_bot = excluded.is_from_bot, timestamp = excluded.timestamp`,
 		msg.ID, msg.ChatID, msg.SenderName, msg.Content, boolToInt(msg.IsFromBot), msg.Timestamp,
 	)
 	return err
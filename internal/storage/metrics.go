@@ -1,5 +1,12 @@
 package storage
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // MetricsPoint represents a metrics snapshot.
 type MetricsPoint struct {
 	TimestampMs    int64
@@ -14,14 +21,42 @@ type MetricsPoint struct {
 
 // AuditLogRecord represents an audit log entry.
 type AuditLogRecord struct {
-	ID        int64
-	Kind      string
-	Actor     string
-	Action    string
-	Target    *string
-	Status    string
-	Detail    *string
-	CreatedAt string
+	ID         int64
+	Kind       string
+	Actor      string
+	Action     string
+	Target     *string
+	Status     string
+	Detail     *string
+	ChatID     *int64
+	DurationMs *int64
+	ErrorType  *string
+	CreatedAt  string
+}
+
+// AuditEvent is a new audit log entry with the optional per-call fields.
+type AuditEvent struct {
+	Kind       string
+	Actor      string
+	Action     string
+	Target     *string
+	Status     string
+	Detail     *string
+	ChatID     *int64
+	DurationMs *int64
+	ErrorType  *string
+}
+
+// AuditFilter selects audit logs. Zero values match everything; Since and
+// Until are RFC 3339 timestamps.
+type AuditFilter struct {
+	Kind   string
+	Action string // the tool name for tool entries
+	Status string
+	ChatID *int64
+	Since  string
+	Until  string
+	Limit  int
 }
 
 // UpsertMetricsHistory inserts or updates a metrics snapshot.
@@ -71,27 +106,58 @@ func (d *Database) GetMetricsHistory(sinceMs int64, limit int) ([]MetricsPoint,
 
 // LogAuditEvent records an audit log entry.
 func (d *Database) LogAuditEvent(kind, actor, action string, target *string, status string, detail *string) error {
+	return d.InsertAuditEvent(AuditEvent{Kind: kind, Actor: actor, Action: action, Target: target, Status: status, Detail: detail})
+}
+
+// InsertAuditEvent records an audit log entry including per-call fields.
+func (d *Database) InsertAuditEvent(e AuditEvent) error {
 	_, err := d.exec(
-		`INSERT INTO audit_logs (kind, actor, action, target, status, detail, created_at)
-		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		kind, actor, action, target, status, detail, nowRFC3339(),
+		`INSERT INTO audit_logs (kind, actor, action, target, status, detail, chat_id, duration_ms, error_type, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Kind, e.Actor, e.Action, e.Target, e.Status, e.Detail, e.ChatID, e.DurationMs, e.ErrorType, nowRFC3339(),
 	)
 	return err
 }
 
 // ListAuditLogs fetches audit logs with optional kind filter.
 func (d *Database) ListAuditLogs(kind string, limit int) ([]AuditLogRecord, error) {
-	var q string
+	return d.QueryAuditLogs(AuditFilter{Kind: kind, Limit: limit})
+}
+
+// QueryAuditLogs fetches audit logs matching f, newest first.
+func (d *Database) QueryAuditLogs(f AuditFilter) ([]AuditLogRecord, error) {
+	q := `SELECT id, kind, actor, action, target, status, detail, chat_id, duration_ms, error_type, created_at
+	      FROM audit_logs WHERE 1=1`
 	var args []any
-	if kind != "" {
-		q = `SELECT id, kind, actor, action, target, status, detail, created_at
-		     FROM audit_logs WHERE kind = ? ORDER BY created_at DESC LIMIT ?`
-		args = []any{kind, limit}
-	} else {
-		q = `SELECT id, kind, actor, action, target, status, detail, created_at
-		     FROM audit_logs ORDER BY created_at DESC LIMIT ?`
-		args = []any{limit}
+	if f.Kind != "" {
+		q += ` AND kind = ?`
+		args = append(args, f.Kind)
+	}
+	if f.Action != "" {
+		q += ` AND action = ?`
+		args = append(args, f.Action)
+	}
+	if f.Status != "" {
+		q += ` AND status = ?`
+		args = append(args, f.Status)
+	}
+	if f.ChatID != nil {
+		q += ` AND chat_id = ?`
+		args = append(args, *f.ChatID)
 	}
+	if f.Since != "" {
+		q += ` AND created_at >= ?`
+		args = append(args, f.Since)
+	}
+	if f.Until != "" {
+		q += ` AND created_at <= ?`
+		args = append(args, f.Until)
+	}
+	if f.Limit <= 0 {
+		f.Limit = 100
+	}
+	q += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, f.Limit)
 
 	rows, err := d.query(q, args...)
 	if err != nil {
@@ -102,10 +168,35 @@ func (d *Database) ListAuditLogs(kind string, limit int) ([]AuditLogRecord, erro
 	var logs []AuditLogRecord
 	for rows.Next() {
 		var l AuditLogRecord
-		if err := rows.Scan(&l.ID, &l.Kind, &l.Actor, &l.Action, &l.Target, &l.Status, &l.Detail, &l.CreatedAt); err != nil {
+		if err := rows.Scan(&l.ID, &l.Kind, &l.Actor, &l.Action, &l.Target, &l.Status, &l.Detail,
+			&l.ChatID, &l.DurationMs, &l.ErrorType, &l.CreatedAt); err != nil {
 			return nil, err
 		}
 		logs = append(logs, l)
 	}
 	return logs, rows.Err()
 }
+
+// ParseTimeBound parses an audit time filter: an RFC 3339 timestamp, a date
+// (2006-01-02), or a duration before now such as "90m", "24h" or "7d".
+func ParseTimeBound(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC().Format(time.RFC3339), nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t.UTC().Format(time.RFC3339), nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return time.Now().UTC().AddDate(0, 0, -n).Format(time.RFC3339), nil
+		}
+	}
+	if dur, err := time.ParseDuration(s); err == nil && dur >= 0 {
+		return time.Now().UTC().Add(-dur).Format(time.RFC3339), nil
+	}
+	return "", fmt.Errorf("invalid time %q (use RFC 3339, YYYY-MM-DD, or a duration like 24h or 7d)", s)
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTimeBound(t *testing.T) {
+	now := time.Now().UTC()
+	tests := []struct {
+		in      string
+		want    time.Time // zero for the empty bound
+		approx  bool      // relative to now; allow clock drift
+		wantErr bool
+	}{
+		{in: "", want: time.Time{}},
+		{in: "2024-03-01T12:30:00Z", want: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)},
+		{in: "2024-03-01T14:30:00+02:00", want: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)},
+		{in: " 2024-03-01 ", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{in: "90m", want: now.Add(-90 * time.Minute), approx: true},
+		{in: "24h", want: now.Add(-24 * time.Hour), approx: true},
+		{in: "7d", want: now.AddDate(0, 0, -7), approx: true},
+		{in: "0d", want: now, approx: true},
+		{in: "-2h", wantErr: true},
+		{in: "-1d", wantErr: true},
+		{in: "yesterday", wantErr: true},
+		{in: "2024-13-01", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseTimeBound(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ParseTimeBound(%q) = %q, want error", tc.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.want.IsZero() {
+				if got != "" {
+					t.Fatalf("ParseTimeBound(%q) = %q, want empty", tc.in, got)
+				}
+				return
+			}
+			parsed, err := time.Parse(time.RFC3339, got)
+			if err != nil || !strings.HasSuffix(got, "Z") {
+				t.Fatalf("ParseTimeBound(%q) = %q, not UTC RFC 3339", tc.in, got)
+			}
+			diff := parsed.Sub(tc.want).Abs()
+			if (tc.approx && diff > 5*time.Second) || (!tc.approx && diff != 0) {
+				t.Errorf("ParseTimeBound(%q) = %s, want %s", tc.in, parsed, tc.want)
+			}
+		})
+	}
+}
+
+func TestQueryAuditLogsFilters(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	chat5, chat6 := int64(5), int64(6)
+	events := []struct {
+		e  AuditEvent
+		at string
+	}{
+		{AuditEvent{Kind: "tool", Action: "bash", Status: "ok", ChatID: &chat5}, "2024-03-01T10:00:00Z"},
+		{AuditEvent{Kind: "tool", Action: "bash", Status: "denied", ChatID: &chat5}, "2024-03-02T10:00:00Z"},
+		{AuditEvent{Kind: "tool", Action: "read_file", Status: "ok", ChatID: &chat6}, "2024-03-03T10:00:00Z"},
+		{AuditEvent{Kind: "tool", Action: "bash", Status: "error", ChatID: &chat6}, "2024-03-04T10:00:00Z"},
+		{AuditEvent{Kind: "auth", Action: "login", Status: "denied"}, "2024-03-05T10:00:00Z"},
+	}
+	for _, ev := range events {
+		if err := db.InsertAuditEvent(ev.e); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.exec(`UPDATE audit_logs SET created_at = ? WHERE id = (SELECT MAX(id) FROM audit_logs)`, ev.at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		f    AuditFilter
+		want []string // action/status, newest first
+	}{
+		{"all", AuditFilter{}, []string{"login/denied", "bash/error", "read_file/ok", "bash/denied", "bash/ok"}},
+		{"kind", AuditFilter{Kind: "tool"}, []string{"bash/error", "read_file/ok", "bash/denied", "bash/ok"}},
+		{"tool", AuditFilter{Kind: "tool", Action: "bash"}, []string{"bash/error", "bash/denied", "bash/ok"}},
+		{"status", AuditFilter{Status: "denied"}, []string{"login/denied", "bash/denied"}},
+		{"chat", AuditFilter{ChatID: &chat6}, []string{"bash/error", "read_file/ok"}},
+		{"tool and chat", AuditFilter{Action: "bash", ChatID: &chat5}, []string{"bash/denied", "bash/ok"}},
+		{"tool, chat and status", AuditFilter{Action: "bash", ChatID: &chat5, Status: "ok"}, []string{"bash/ok"}},
+		{"time range", AuditFilter{Since: "2024-03-02T00:00:00Z", Until: "2024-03-03T23:59:59Z"}, []string{"read_file/ok", "bash/denied"}},
+		{"limit", AuditFilter{Kind: "tool", Limit: 2}, []string{"bash/error", "read_file/ok"}},
+		{"no match", AuditFilter{Action: "bash", Status: "ok", ChatID: &chat6}, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			logs, err := db.QueryAuditLogs(tc.f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, l := range logs {
+				got = append(got, l.Action+"/"+l.Status)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package storage
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
 	"fmt"
 	"time"
 )
@@ -50,6 +52,10 @@ func (d *Database) runMigrations() error {
 			{6, "session metadata", migrateV6},
 			{7, "metrics history", migrateV7},
 			{8, "audit logs and api key expiration", migrateV8},
+			{9, "encrypted secrets", migrateV9},
+			{10, "tool execution audit fields", migrateV10},
+			{11, "memory embeddings", migrateV11},
+			{12, "full-text search", migrateV12},
 		}
 
 		for _, m := range migrations {
@@ -374,3 +380,118 @@ func migrateV8(tx *sql.Tx) error {
 	}
 	return nil
 }
+
+// migrateV9 adds the encrypted secrets store and the random salt that
+// passphrase keys are stretched with.
+func migrateV9(tx *sql.Tx) error {
+	if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS secrets (
+		name TEXT PRIMARY KEY,
+		nonce BLOB NOT NULL,
+		ciphertext BLOB NOT NULL,
+		key_version INTEGER NOT NULL DEFAULT 1,
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	)`); err != nil {
+		return err
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`INSERT OR IGNORE INTO db_meta (key, value) VALUES (?, ?)`,
+		secretsSaltKey, base64.StdEncoding.EncodeToString(salt))
+	return err
+}
+
+// migrateV10 adds chat, duration and error type to audit logs so tool
+// executions can be recorded and filtered per chat.
+func migrateV10(tx *sql.Tx) error {
+	stmts := []string{
+		`ALTER TABLE audit_logs ADD COLUMN chat_id INTEGER`,
+		`ALTER TABLE audit_logs ADD COLUMN duration_ms INTEGER`,
+		`ALTER TABLE audit_logs ADD COLUMN error_type TEXT`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_logs_chat_created ON audit_logs(chat_id, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_logs_created ON audit_logs(created_at DESC)`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.Exec(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateV11 stores memory embedding vectors. memories.embedding_model
+// records which model the stored vector came from and is cleared whenever
+// the content changes.
+func migrateV11(tx *sql.Tx) error {
+	_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS memory_embeddings (
+		memory_id INTEGER PRIMARY KEY REFERENCES memories(id) ON DELETE CASCADE,
+		model TEXT NOT NULL,
+		dim INTEGER NOT NULL,
+		vector BLOB NOT NULL,
+		updated_at TEXT NOT NULL
+	)`)
+	return err
+}
+
+// migrateV12 adds FTS5 indexes over memory and message content, kept in
+// sync by triggers, and indexes the existing rows. messages is rebuilt with
+// an INTEGER PRIMARY KEY to key its index on, since the implicit rowid may
+// be renumbered by VACUUM; StoreMessage upserts rather than replaces so a
+// message keeps its key.
+func migrateV12(tx *sql.Tx) error {
+	stmts := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS memories_fts USING fts5(
+			content, content='memories', content_rowid='id', tokenize='unicode61 remove_diacritics 2'
+		)`,
+		`CREATE TRIGGER IF NOT EXISTS memories_fts_insert AFTER INSERT ON memories BEGIN
+			INSERT INTO memories_fts(rowid, content) VALUES (new.id, new.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS memories_fts_delete AFTER DELETE ON memories BEGIN
+			INSERT INTO memories_fts(memories_fts, rowid, content) VALUES ('delete', old.id, old.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS memories_fts_update AFTER UPDATE OF content ON memories BEGIN
+			INSERT INTO memories_fts(memories_fts, rowid, content) VALUES ('delete', old.id, old.content);
+			INSERT INTO memories_fts(rowid, content) VALUES (new.id, new.content);
+		END`,
+		`INSERT INTO memories_fts(memories_fts) VALUES ('rebuild')`,
+
+		`CREATE TABLE messages_v12 (
+			seq INTEGER PRIMARY KEY,
+			id TEXT NOT NULL,
+			chat_id INTEGER NOT NULL,
+			sender_name TEXT NOT NULL,
+			content TEXT NOT NULL,
+			is_from_bot INTEGER NOT NULL DEFAULT 0,
+			timestamp TEXT NOT NULL,
+			UNIQUE (id, chat_id)
+		)`,
+		`INSERT INTO messages_v12 (id, chat_id, sender_name, content, is_from_bot, timestamp)
+		 SELECT id, chat_id, sender_name, content, is_from_bot, timestamp FROM messages ORDER BY rowid`,
+		`DROP TABLE messages`,
+		`ALTER TABLE messages_v12 RENAME TO messages`,
+		`CREATE INDEX IF NOT EXISTS idx_messages_chat_timestamp ON messages(chat_id, timestamp)`,
+
+		`CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
+			content, content='messages', content_rowid='seq', tokenize='unicode61 remove_diacritics 2'
+		)`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_insert AFTER INSERT ON messages BEGIN
+			INSERT INTO messages_fts(rowid, content) VALUES (new.seq, new.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_delete AFTER DELETE ON messages BEGIN
+			INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.seq, old.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS messages_fts_update AFTER UPDATE OF content ON messages BEGIN
+			INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.seq, old.content);
+			INSERT INTO messages_fts(rowid, content) VALUES (new.seq, new.content);
+		END`,
+		`INSERT INTO messages_fts(messages_fts) VALUES ('rebuild')`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.Exec(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"unicode"
+)
+
+// MessageSearchResult is a message found by SearchMessages, with an excerpt
+// around the match.
+type MessageSearchResult struct {
+	StoredMessage
+	Snippet string
+}
+
+// Snippet settings: matched terms are wrapped in snippetMark, and excerpts
+// are about snippetTokens words (snippetRunes characters for substring
+// matches) long.
+const (
+	snippetMark   = "**"
+	snippetTokens = 16
+	snippetRunes  = 120
+)
+
+// ftsQuery turns user input into an FTS5 query. Double-quoted text is a
+// phrase, and a trailing * makes a word or phrase a prefix; terms are ANDed,
+// or ORed when or is set. Everything else is reduced to letters and
+// digits, so no input is a syntax error. It returns "" if nothing
+// searchable is left.
+func ftsQuery(query string, or bool) string {
+	var terms []string
+	rest := query
+	for {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if rest == "" {
+			break
+		}
+		var raw string
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				raw, rest = rest[1:], ""
+			} else {
+				raw, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				end = len(rest)
+			}
+			raw, rest = rest[:end], rest[end:]
+		}
+		prefix := strings.HasSuffix(raw, "*")
+		if strings.HasPrefix(rest, "*") {
+			prefix, rest = true, rest[1:]
+		}
+		words := strings.FieldsFunc(raw, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(words) == 0 {
+			continue
+		}
+		term := `"` + strings.Join(words, " ") + `"`
+		if prefix {
+			term += "*"
+		}
+		terms = append(terms, term)
+	}
+	if or {
+		return strings.Join(terms, " OR ")
+	}
+	return strings.Join(terms, " ")
+}
+
+// ftsQueries returns the queries to try for input: all terms, then any of
+// them.
+func ftsQueries(query string) []string {
+	all, anyOf := ftsQuery(query, false), ftsQuery(query, true)
+	switch {
+	case all == "":
+		return nil
+	case all == anyOf:
+		return []string{all}
+	}
+	return []string{all, anyOf}
+}
+
+func (d *Database) searchMemoriesFTS(chatID int64, query string, limit int, includeArchived, broadRecall bool) ([]Memory, error) {
+	for _, match := range ftsQueries(query) {
+		q := `SELECT m.id, m.chat_id, m.content, m.category, m.created_at, m.updated_at, m.embedding_model,
+		        m.confidence, m.source, m.last_seen_at, m.is_archived, m.archived_at, m.chat_channel, m.external_chat_id
+		 FROM memories_fts JOIN memories m ON m.id = memories_fts.rowid
+		 WHERE memories_fts MATCH ?`
+		args := []any{match}
+		if !includeArchived {
+			q += ` AND m.is_archived = 0`
+		}
+		if !broadRecall {
+			q += ` AND (m.chat_id = ? OR m.chat_id IS NULL)`
+			args = append(args, chatID)
+		}
+		q += ` ORDER BY bm25(memories_fts) LIMIT ?`
+		args = append(args, limit)
+
+		rows, err := d.query(q, args...)
+		if err != nil {
+			return nil, err
+		}
+		mems, err := scanMemories(rows)
+		rows.Close()
+		if err != nil || len(mems) > 0 {
+			return mems, err
+		}
+	}
+	return nil, nil
+}
+
+// SearchMessages searches a chat's message history, best matches first.
+// since and until optionally bound the timestamp (since inclusive, until
+// exclusive). Like memory search, it falls back to substring matching when
+// the full-text index finds nothing.
+func (d *Database) SearchMessages(chatID int64, query, since, until string, limit int) ([]MessageSearchResult, error) {
+	var bounds string
+	var boundArgs []any
+	if since != "" {
+		bounds += ` AND m.timestamp >= ?`
+		boundArgs = append(boundArgs, since)
+	}
+	if until != "" {
+		bounds += ` AND m.timestamp < ?`
+		boundArgs = append(boundArgs, until)
+	}
+
+	for _, match := range ftsQueries(query) {
+		args := append([]any{snippetMark, snippetMark, snippetTokens, match, chatID}, boundArgs...)
+		rows, err := d.query(
+			`SELECT m.id, m.chat_id, m.sender_name, m.content, m.is_from_bot, m.timestamp,
+			        snippet(messages_fts, 0, ?, ?, '…', ?)
+			 FROM messages_fts JOIN messages m ON m.seq = messages_fts.rowid
+			 WHERE messages_fts MATCH ? AND m.chat_id = ?`+bounds+`
+			 ORDER BY bm25(messages_fts) LIMIT ?`,
+			append(args, limit)...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		results, err := scanMessageSearchResults(rows, "")
+		rows.Close()
+		if err != nil || len(results) > 0 {
+			return results, err
+		}
+	}
+
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, nil
+	}
+	args := append([]any{chatID, likePattern(query)}, boundArgs...)
+	rows, err := d.query(
+		`SELECT m.id, m.chat_id, m.sender_name, m.content, m.is_from_bot, m.timestamp, ''
+		 FROM messages m WHERE m.chat_id = ? AND m.content LIKE ? ESCAPE '\'`+bounds+`
+		 ORDER BY m.timestamp DESC LIMIT ?`,
+		append(args, limit)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMessageSearchResults(rows, query)
+}
+
+// likePattern builds a LIKE pattern matching s anywhere, with LIKE's
+// wildcards escaped for use with ESCAPE '\'.
+func likePattern(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + r.Replace(s) + "%"
+}
+
+// scanMessageSearchResults reads search rows. For substring matches
+// (query set) the snippet is cut around the first occurrence of query.
+func scanMessageSearchResults(rows *sql.Rows, query string) ([]MessageSearchResult, error) {
+	var results []MessageSearchResult
+	for rows.Next() {
+		var r MessageSearchResult
+		var bot int
+		if err := rows.Scan(&r.ID, &r.ChatID, &r.SenderName, &r.Content, &bot, &r.Timestamp, &r.Snippet); err != nil {
+			return nil, err
+		}
+		r.IsFromBot = bot != 0
+		if query != "" {
+			r.Snippet = substringSnippet(r.Content, query)
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// substringSnippet returns about snippetRunes characters of content around
+// the first case-insensitive occurrence of query, with the match marked.
+func substringSnippet(content, query string) string {
+	text := []rune(content)
+	lower := []rune(strings.ToLower(content))
+	q := []rune(strings.ToLower(query))
+	at := -1
+	if len(lower) == len(text) {
+		for i := 0; i+len(q) <= len(lower); i++ {
+			if string(lower[i:i+len(q)]) == string(q) {
+				at = i
+				break
+			}
+		}
+	}
+	if at < 0 {
+		if len(text) <= snippetRunes {
+			return content
+		}
+		return string(text[:snippetRunes]) + "…"
+	}
+
+	start := max(at-max(snippetRunes-len(q), 0)/2, 0)
+	end := min(start+max(snippetRunes, len(q)), len(text))
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	sb.WriteString(string(text[start:at]))
+	sb.WriteString(snippetMark + string(text[at:at+len(q)]) + snippetMark)
+	sb.WriteString(string(text[at+len(q) : end]))
+	if end < len(text) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
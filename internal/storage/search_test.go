@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFTSQuery(t *testing.T) {
+	cases := map[string]string{
+		`trip to japan`:        `"trip" "to" "japan"`,
+		`"dark mode" editor*`:  `"dark mode" "editor"*`,
+		`"new york"* e-mail`:   `"new york"* "e mail"`,
+		`OR AND "unterminated`: `"OR" "AND" "unterminated"`,
+		`*** "" -`:             ``,
+	}
+	for in, want := range cases {
+		if got := ftsQuery(in, false); got != want {
+			t.Errorf("ftsQuery(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := ftsQuery("a b", true); got != `"a" OR "b"` {
+		t.Errorf("or query = %q", got)
+	}
+}
+
+func TestFullTextSearch(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	chat := int64(7)
+	db.InsertMemory(&chat, "Planning a trip to Japan in April", "EVENT")
+	tea, _ := db.InsertMemory(&chat, "Prefers green tea over coffee; tea every morning", "PROFILE")
+	db.InsertMemory(nil, "Works as a backend engineer", "PROFILE")
+
+	mems, err := db.SearchMemories(chat, "tea", 10)
+	if err != nil || len(mems) != 1 || mems[0].ID != tea {
+		t.Fatalf("search tea = %+v, %v", mems, err)
+	}
+	if mems, _ := db.SearchMemories(chat, "engin*", 10); len(mems) != 1 {
+		t.Errorf("prefix search found %d", len(mems))
+	}
+	if mems, _ := db.SearchMemories(chat, `"japan in april"`, 10); len(mems) != 1 {
+		t.Errorf("phrase search found %d", len(mems))
+	}
+	if mems, _ := db.SearchMemories(chat, `"april in japan"`, 10); len(mems) != 0 {
+		t.Errorf("phrase in the wrong order matched %d", len(mems))
+	}
+	// No memory has both words, so any of them is enough.
+	if mems, _ := db.SearchMemories(chat, "coffee japan", 10); len(mems) != 2 {
+		t.Errorf("fallback to any term found %d", len(mems))
+	}
+	// Part of a word falls back to substring matching.
+	if mems, _ := db.SearchMemories(chat, "ckend", 10); len(mems) != 1 {
+		t.Errorf("substring fallback found %d", len(mems))
+	}
+	// Edits and deletes keep the index in sync.
+	db.UpdateMemoryContent(tea, "Prefers espresso", "PROFILE")
+	if mems, _ := db.SearchMemories(chat, "green", 10); len(mems) != 0 {
+		t.Errorf("stale index entry after edit")
+	}
+	db.DeleteMemory(tea)
+	if mems, _ := db.SearchMemories(chat, "espresso", 10); len(mems) != 0 {
+		t.Errorf("stale index entry after delete")
+	}
+
+	msgs := []StoredMessage{
+		{ID: "1", ChatID: chat, SenderName: "ana", Content: "the wifi password is hunter2", Timestamp: "2024-01-05T10:00:00Z"},
+		{ID: "2", ChatID: chat, SenderName: "bot", Content: "Noted the wifi details", IsFromBot: true, Timestamp: "2024-03-01T10:00:00Z"},
+		{ID: "1", ChatID: 8, SenderName: "bo", Content: "wifi is down again", Timestamp: "2024-03-02T10:00:00Z"},
+	}
+	for _, m := range msgs {
+		if err := db.StoreMessage(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results, err := db.SearchMessages(chat, "wifi password", "", "", 10)
+	if err != nil || len(results) != 1 || !strings.Contains(results[0].Snippet, "**wifi**") {
+		t.Fatalf("SearchMessages = %+v, %v", results, err)
+	}
+	if results, _ := db.SearchMessages(chat, "wifi", "2024-02-01T00:00:00Z", "", 10); len(results) != 1 || results[0].ID != "2" {
+		t.Errorf("since filter: %+v", results)
+	}
+	// Re-storing a message replaces its indexed content.
+	msgs[0].Content = "the router is in the hallway"
+	db.StoreMessage(msgs[0])
+	if results, _ := db.SearchMessages(chat, "hunter2", "", "", 10); len(results) != 0 {
+		t.Errorf("stale message index entry: %+v", results)
+	}
+	if results, _ := db.SearchMessages(chat, "hallway", "", "", 10); len(results) != 1 {
+		t.Errorf("updated message not found")
+	}
+	if results, _ := db.SearchMessages(chat, "ute", "", "", 10); len(results) != 1 || !strings.Contains(results[0].Snippet, "**ute**") {
+		t.Errorf("substring fallback: %+v", results)
+	}
+}
+
+func TestSearchLikeFallbackEscapesWildcards(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	chat := int64(7)
+	db.InsertMemory(&chat, "Discount is 50% off", "KNOWLEDGE")
+	db.InsertMemory(&chat, "Discount is 50 percent off", "KNOWLEDGE")
+	db.InsertMemory(&chat, `Config lives in C:\app\my_dir`, "KNOWLEDGE")
+	db.InsertMemory(&chat, "Config lives in myXdir", "KNOWLEDGE")
+	// None of these match a whole token, so they reach the LIKE fallback.
+	for _, q := range []string{"0% o", "y_d", `p\m`} {
+		if mems, _ := db.SearchMemories(chat, q, 10); len(mems) != 1 {
+			t.Errorf("memory search %q found %d, want 1", q, len(mems))
+		}
+	}
+
+	db.StoreMessage(StoredMessage{ID: "1", ChatID: chat, SenderName: "a", Content: "use snake_case names", Timestamp: "2024-01-01T00:00:00Z"})
+	db.StoreMessage(StoredMessage{ID: "2", ChatID: chat, SenderName: "a", Content: "use snakeXcase names", Timestamp: "2024-01-01T00:00:01Z"})
+	if results, _ := db.SearchMessages(chat, "e_c", "", "", 10); len(results) != 1 || results[0].ID != "1" {
+		t.Errorf("message search e_c = %+v", results)
+	}
+}
+
+func TestMigrateV12KeysMessageIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Recreate the v11 layout: no search indexes, messages without a key.
+	stmts := []string{
+		`DROP TRIGGER memories_fts_insert`,
+		`DROP TRIGGER memories_fts_delete`,
+		`DROP TRIGGER memories_fts_update`,
+		`DROP TABLE memories_fts`,
+		`DROP TABLE messages_fts`,
+		`DROP TABLE messages`,
+		`CREATE TABLE messages (
+			id TEXT NOT NULL,
+			chat_id INTEGER NOT NULL,
+			sender_name TEXT NOT NULL,
+			content TEXT NOT NULL,
+			is_from_bot INTEGER NOT NULL DEFAULT 0,
+			timestamp TEXT NOT NULL,
+			PRIMARY KEY (id, chat_id)
+		)`,
+		`UPDATE db_meta SET value = '11' WHERE key = 'schema_version'`,
+	}
+	for _, s := range stmts {
+		if _, err := db.db.Exec(s); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+	for i, content := range []string{"first note about apples", "second note about pears", "third note about plums"} {
+		db.StoreMessage(StoredMessage{ID: fmt.Sprint(i), ChatID: 7, SenderName: "a", Content: content, Timestamp: "2024-01-01T00:00:00Z"})
+	}
+	db.db.Exec(`DELETE FROM messages WHERE id = '0'`)
+	db.Close()
+
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.db.Exec(`VACUUM`); err != nil {
+		t.Fatal(err)
+	}
+	for q, want := range map[string]string{"pears": "1", "plums": "2"} {
+		results, err := db.SearchMessages(7, q, "", "", 10)
+		if err != nil || len(results) != 1 || results[0].ID != want {
+			t.Errorf("search %q = %+v, %v", q, results, err)
+		}
+	}
+	db.StoreMessage(StoredMessage{ID: "2", ChatID: 7, SenderName: "a", Content: "third note about figs", Timestamp: "2024-01-01T00:00:00Z"})
+	if results, _ := db.SearchMessages(7, "plums", "", "", 10); len(results) != 0 {
+		t.Errorf("stale index entry after upsert: %+v", results)
+	}
+	if results, _ := db.SearchMessages(7, "figs", "", "", 10); len(results) != 1 {
+		t.Errorf("upserted message not found")
+	}
+}
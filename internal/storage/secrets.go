@@ -0,0 +1,255 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// SecretsKeyEnv names the environment variable holding the secrets key. It
+// takes precedence over the key file.
+const SecretsKeyEnv = "MINICLAWD_SECRETS_KEY"
+
+// ErrNoSecretsKey is returned when neither the env var nor the key file exist.
+var ErrNoSecretsKey = errors.New("no secrets key: set " + SecretsKeyEnv + " or create a key file")
+
+var secretNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.\-]*$`)
+
+// SecretInfo describes a stored secret without its value.
+type SecretInfo struct {
+	Name      string
+	CreatedAt string
+	UpdatedAt string
+}
+
+// secretsKeyVersion is stored with each secret so the key derivation can
+// change later without guessing how old rows were encrypted.
+const secretsKeyVersion = 1
+
+// argon2id parameters for passphrase keys (64 MiB, 3 passes).
+const (
+	secretsKDFTime    = 3
+	secretsKDFMemory  = 64 * 1024
+	secretsKDFThreads = 4
+	secretsSaltKey    = "secrets_kdf_salt" // db_meta key holding the salt
+)
+
+// SecretsKey is the configured secrets key: 32 random bytes, or a
+// passphrase that NewSecretStore stretches with argon2id.
+type SecretsKey struct {
+	raw        []byte
+	passphrase string
+}
+
+// SecretStore encrypts secrets with AES-256-GCM and keeps them in the
+// secrets table. The secret name is bound as associated data, so ciphertexts
+// cannot be swapped between names.
+type SecretStore struct {
+	db   *Database
+	aead cipher.AEAD
+}
+
+// LoadSecretsKey returns the key from SecretsKeyEnv or keyFile. With create
+// set, a random key is written to keyFile (mode 0600) if neither source
+// exists.
+func LoadSecretsKey(keyFile string, create bool) (*SecretsKey, error) {
+	if v := strings.TrimSpace(os.Getenv(SecretsKeyEnv)); v != "" {
+		return parseSecretsKey(v), nil
+	}
+	data, err := os.ReadFile(keyFile)
+	if err == nil {
+		return parseSecretsKey(strings.TrimSpace(string(data))), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading secrets key %s: %w", keyFile, err)
+	}
+	if !create {
+		return nil, ErrNoSecretsKey
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0o700); err != nil {
+		return nil, fmt.Errorf("creating key directory: %w", err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(key) + "\n"
+	if err := os.WriteFile(keyFile, []byte(encoded), 0o600); err != nil {
+		return nil, fmt.Errorf("writing secrets key %s: %w", keyFile, err)
+	}
+	return &SecretsKey{raw: key}, nil
+}
+
+// parseSecretsKey accepts a base64 or hex encoded 32-byte key; anything
+// else is a passphrase.
+func parseSecretsKey(v string) *SecretsKey {
+	if b, err := base64.StdEncoding.DecodeString(v); err == nil && len(b) == 32 {
+		return &SecretsKey{raw: b}
+	}
+	if b, err := hex.DecodeString(v); err == nil && len(b) == 32 {
+		return &SecretsKey{raw: b}
+	}
+	return &SecretsKey{passphrase: v}
+}
+
+// NewSecretStore returns a store using key. A passphrase is stretched with
+// argon2id and the database's salt.
+func NewSecretStore(db *Database, key *SecretsKey) (*SecretStore, error) {
+	k := key.raw
+	if k == nil {
+		salt, err := db.secretsSalt()
+		if err != nil {
+			return nil, fmt.Errorf("secrets salt: %w", err)
+		}
+		k = argon2.IDKey([]byte(key.passphrase), salt,
+			secretsKDFTime, secretsKDFMemory, secretsKDFThreads, 32)
+	}
+	aead, err := newSecretsAEAD(k)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretStore{db: db, aead: aead}, nil
+}
+
+func newSecretsAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("secrets key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// secretsSalt returns the database's argon2id salt, created by migrateV9.
+func (d *Database) secretsSalt() ([]byte, error) {
+	var encoded string
+	if err := d.queryRow(`SELECT value FROM db_meta WHERE key = ?`, secretsSaltKey).Scan(&encoded); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+func (s *SecretStore) seal(name, value string) (nonce, ct []byte, err error) {
+	nonce = make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, s.aead.Seal(nil, nonce, []byte(value), []byte(name)), nil
+}
+
+// ValidSecretName reports whether name can be stored and referenced.
+func ValidSecretName(name string) bool {
+	return secretNameRe.MatchString(name)
+}
+
+// Set stores or replaces a secret.
+func (s *SecretStore) Set(name, value string) error {
+	if !ValidSecretName(name) {
+		return fmt.Errorf("invalid secret name %q (letters, digits, _ . - ; must not start with a digit)", name)
+	}
+	nonce, ct, err := s.seal(name, value)
+	if err != nil {
+		return err
+	}
+	now := nowRFC3339()
+	_, err = s.db.exec(
+		`INSERT INTO secrets (name, nonce, ciphertext, key_version, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(name) DO UPDATE SET nonce = excluded.nonce, ciphertext = excluded.ciphertext,
+		        key_version = excluded.key_version, updated_at = excluded.updated_at`,
+		name, nonce, ct, secretsKeyVersion, now, now,
+	)
+	return err
+}
+
+// Get decrypts a secret. The bool is false if it does not exist.
+func (s *SecretStore) Get(name string) (string, bool, error) {
+	var nonce, ct []byte
+	var version int
+	err := s.db.queryRow(`SELECT nonce, ciphertext, key_version FROM secrets WHERE name = ?`, name).
+		Scan(&nonce, &ct, &version)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if version != secretsKeyVersion {
+		return "", false, fmt.Errorf("secret %q has unknown key version %d", name, version)
+	}
+	plain, err := s.aead.Open(nil, nonce, ct, []byte(name))
+	if err != nil {
+		return "", false, fmt.Errorf("decrypting secret %q: wrong key or corrupted data", name)
+	}
+	return string(plain), true, nil
+}
+
+// Lookup returns a secret's value, failing if it does not exist. A nil store
+// reports that no key is configured.
+func (s *SecretStore) Lookup(name string) (string, error) {
+	if s == nil {
+		return "", ErrNoSecretsKey
+	}
+	v, ok, err := s.Get(name)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("not found (add it with 'miniclawd secrets set %s')", name)
+	}
+	return v, nil
+}
+
+// List returns all secret names, sorted.
+func (s *SecretStore) List() ([]SecretInfo, error) {
+	rows, err := s.db.query(`SELECT name, created_at, updated_at FROM secrets ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []SecretInfo
+	for rows.Next() {
+		var info SecretInfo
+		if err := rows.Scan(&info.Name, &info.CreatedAt, &info.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, info)
+	}
+	return out, rows.Err()
+}
+
+// Values decrypts every secret, for registering them with the redactor.
+// Secrets that fail to decrypt are skipped.
+func (s *SecretStore) Values() ([]string, error) {
+	infos, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, info := range infos {
+		if v, ok, err := s.Get(info.Name); err == nil && ok {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// Delete removes a secret, reporting whether it existed.
+func (s *SecretStore) Delete(name string) (bool, error) {
+	res, err := s.db.exec(`DELETE FROM secrets WHERE name = ?`, name)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
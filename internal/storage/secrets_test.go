@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"path/filepath"
+	"testing"
+)
+
+func TestSecretStore(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	t.Setenv(SecretsKeyEnv, "")
+	keyFile := filepath.Join(dir, "secrets.key")
+	if _, err := LoadSecretsKey(keyFile, false); err != ErrNoSecretsKey {
+		t.Fatalf("expected ErrNoSecretsKey, got %v", err)
+	}
+	key, err := LoadSecretsKey(keyFile, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := LoadSecretsKey(keyFile, false)
+	if err != nil || string(again.raw) != string(key.raw) {
+		t.Fatalf("reloaded key differs: %v", err)
+	}
+
+	store, err := NewSecretStore(db, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("GITHUB_TOKEN", "ghp_abc123"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("1bad", "x"); err == nil {
+		t.Error("expected invalid name error")
+	}
+	if v, err := store.Lookup("GITHUB_TOKEN"); err != nil || v != "ghp_abc123" {
+		t.Fatalf("Lookup = %q, %v", v, err)
+	}
+	if _, err := store.Lookup("MISSING"); err == nil {
+		t.Error("expected error for missing secret")
+	}
+
+	other, _ := NewSecretStore(db, parseSecretsKey("another passphrase"))
+	if _, _, err := other.Get("GITHUB_TOKEN"); err == nil {
+		t.Error("expected decryption failure with the wrong key")
+	}
+
+	if ok, err := store.Delete("GITHUB_TOKEN"); !ok || err != nil {
+		t.Fatalf("Delete = %v, %v", ok, err)
+	}
+	if infos, _ := store.List(); len(infos) != 0 {
+		t.Errorf("expected no secrets, got %v", infos)
+	}
+}
+
+func TestSecretStorePassphrase(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	const pass = "correct horse battery staple"
+	key := parseSecretsKey(pass)
+	if key.raw != nil {
+		t.Fatal("passphrase parsed as a raw key")
+	}
+	store, err := NewSecretStore(db, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("NEW", "new-value"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopening derives the same key from the stored salt.
+	reopened, err := NewSecretStore(db, parseSecretsKey(pass))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := reopened.Lookup("NEW"); err != nil || v != "new-value" {
+		t.Errorf("Lookup(NEW) = %q, %v", v, err)
+	}
+
+	// The key is salted, not a bare hash of the passphrase.
+	sum := sha256.Sum256([]byte(pass))
+	unsalted, _ := newSecretsAEAD(sum[:])
+	nonce := make([]byte, unsalted.NonceSize())
+	if string(store.aead.Seal(nil, nonce, []byte("x"), nil)) == string(unsalted.Seal(nil, nonce, []byte("x"), nil)) {
+		t.Error("passphrase key is the unsalted SHA-256")
+	}
+}
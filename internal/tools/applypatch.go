@@ -0,0 +1,343 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yifanes/miniclawd/internal/core"
+)
+
+type ApplyPatchTool struct {
+	workspace *Workspace
+	policy    *PathPolicy
+}
+
+func NewApplyPatchTool(workspace *Workspace, policy *PathPolicy) *ApplyPatchTool {
+	return &ApplyPatchTool{workspace: workspace, policy: policy}
+}
+
+func (t *ApplyPatchTool) Name() string { return "apply_patch" }
+
+func (t *ApplyPatchTool) Definition() core.ToolDefinition {
+	return MakeDef("apply_patch",
+		"Apply changes to multiple files atomically: either all changes apply or none do. "+
+			"Pass `patch` as a unified diff (git diff format; /dev/null for created or deleted files, "+
+			"rename from/to headers for renames), or pass `files` as structured edits. "+
+			"Failed hunks are reported with the surrounding file content so you can retry.",
+		map[string]any{
+			"patch": StringProp("A unified diff covering one or more files"),
+			"files": map[string]any{
+				"type":        "array",
+				"description": "Structured file changes, applied in order",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"path":     StringProp("File path (relative to working directory or absolute)"),
+						"action":   EnumProp("Change to make", []string{patchCreate, patchUpdate, patchDelete, patchRename}),
+						"content":  StringProp("Full file content (create)"),
+						"new_path": StringProp("Destination path (rename)"),
+						"edits": map[string]any{
+							"type":        "array",
+							"description": "Sequential string replacements (update, or rename with changes)",
+							"items": map[string]any{
+								"type": "object",
+								"properties": map[string]any{
+									"old_string":  StringProp("The exact string to find (must be unique unless replace_all)"),
+									"new_string":  StringProp("The replacement string"),
+									"replace_all": BoolProp("Replace every occurrence"),
+								},
+								"required": []string{"old_string", "new_string"},
+							},
+						},
+					},
+					"required": []string{"path", "action"},
+				},
+			},
+		},
+		nil,
+	)
+}
+
+// stagedFile tracks a file's original and pending state while a patch is
+// being applied in memory.
+type stagedFile struct {
+	path    string
+	existed bool
+	orig    []byte
+	mode    fs.FileMode
+
+	exists  bool
+	content string
+}
+
+func (t *ApplyPatchTool) Execute(_ context.Context, input json.RawMessage) ToolResult {
+	var params struct {
+		Patch string `json:"patch"`
+		Files []struct {
+			Path    string       `json:"path"`
+			Action  string       `json:"action"`
+			Content *string      `json:"content"`
+			NewPath string       `json:"new_path"`
+			Edits   []stringEdit `json:"edits"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(input, &params); err != nil {
+		return Error("invalid input: " + err.Error())
+	}
+
+	var ops []patchOp
+	switch {
+	case params.Patch != "" && len(params.Files) > 0:
+		return Error("provide either patch or files, not both")
+	case params.Patch != "":
+		parsed, err := parseUnifiedDiff(params.Patch)
+		if err != nil {
+			return Error("cannot parse patch: " + err.Error())
+		}
+		ops = parsed
+	case len(params.Files) > 0:
+		for i, f := range params.Files {
+			if f.Path == "" {
+				return Error(fmt.Sprintf("files[%d]: path is required", i))
+			}
+			op := patchOp{kind: f.Action, path: f.Path, newPath: f.NewPath, content: f.Content, edits: f.Edits}
+			switch f.Action {
+			case patchCreate:
+				if f.Content == nil {
+					return Error(fmt.Sprintf("files[%d]: content is required for create", i))
+				}
+			case patchUpdate:
+				if len(f.Edits) == 0 {
+					return Error(fmt.Sprintf("files[%d]: edits are required for update", i))
+				}
+			case patchRename:
+				if f.NewPath == "" {
+					return Error(fmt.Sprintf("files[%d]: new_path is required for rename", i))
+				}
+			case patchDelete:
+			default:
+				return Error(fmt.Sprintf("files[%d]: unknown action %q", i, f.Action))
+			}
+			ops = append(ops, op)
+		}
+	default:
+		return Error("patch or files is required")
+	}
+
+	auth := ExtractAuthContext(input)
+	staged := make(map[string]*stagedFile)
+	var order []string
+	stage := func(p string) (*stagedFile, error) {
+		path, err := t.workspace.Resolve(auth, p)
+		if err != nil {
+			return nil, err
+		}
+		if err := t.policy.Enforce(auth, t.Name(), path, true); err != nil {
+			return nil, err
+		}
+		if sf, ok := staged[path]; ok {
+			return sf, nil
+		}
+		sf := &stagedFile{path: path, mode: 0o644}
+		if info, err := os.Stat(path); err == nil {
+			if info.IsDir() {
+				return nil, fmt.Errorf("%s is a directory", p)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read %s: %v", p, err)
+			}
+			sf.existed, sf.exists = true, true
+			sf.orig, sf.content = data, string(data)
+			sf.mode = info.Mode().Perm()
+		}
+		staged[path] = sf
+		order = append(order, path)
+		return sf, nil
+	}
+
+	// Stage every change in memory, collecting all failures.
+	var failures, summary []string
+	for _, op := range ops {
+		src, err := stage(op.path)
+		if err != nil {
+			failures = append(failures, err.Error())
+			continue
+		}
+
+		switch op.kind {
+		case patchCreate:
+			if src.exists {
+				failures = append(failures, fmt.Sprintf("%s: cannot create, file already exists", op.path))
+				continue
+			}
+			src.exists, src.content = true, *op.content
+			summary = append(summary, fmt.Sprintf("A %s (%d lines)", op.path, countLines(src.content)))
+
+		case patchDelete:
+			if !src.exists {
+				failures = append(failures, fmt.Sprintf("%s: cannot delete, file does not exist", op.path))
+				continue
+			}
+			src.exists, src.content = false, ""
+			summary = append(summary, "D "+op.path)
+
+		case patchUpdate, patchRename:
+			if !src.exists {
+				failures = append(failures, fmt.Sprintf("%s: file does not exist", op.path))
+				continue
+			}
+			before := src.content
+			after, errs := applyOpContent(op, before)
+			if len(errs) > 0 {
+				failures = append(failures, errs...)
+				continue
+			}
+
+			if op.kind == patchUpdate {
+				src.content = after
+				added, removed := lineDelta(before, after)
+				summary = append(summary, fmt.Sprintf("M %s (+%d -%d)", op.path, added, removed))
+				continue
+			}
+
+			dst, err := stage(op.newPath)
+			if err != nil {
+				failures = append(failures, err.Error())
+				continue
+			}
+			if dst.exists {
+				failures = append(failures, fmt.Sprintf("%s: cannot rename to %s, destination exists", op.path, op.newPath))
+				continue
+			}
+			dst.exists, dst.content = true, after
+			if !dst.existed {
+				dst.mode = src.mode
+			}
+			src.exists, src.content = false, ""
+			summary = append(summary, fmt.Sprintf("R %s -> %s", op.path, op.newPath))
+		}
+	}
+
+	if len(failures) > 0 {
+		return ErrorWithType(fmt.Sprintf("patch not applied, no files were changed. %d problem(s):\n\n%s",
+			len(failures), strings.Join(failures, "\n\n")), "patch_failed")
+	}
+
+	if err := commitStaged(staged, order); err != nil {
+		return Error(fmt.Sprintf("patch not applied: %v (changes rolled back)", err))
+	}
+	return Success(fmt.Sprintf("applied patch to %d file(s):\n%s", len(summary), strings.Join(summary, "\n")))
+}
+
+// applyOpContent applies a unified-diff or structured update to content.
+func applyOpContent(op patchOp, content string) (string, []string) {
+	if len(op.hunks) > 0 {
+		return applyHunks(op.path, content, op)
+	}
+	for i, e := range op.edits {
+		next, _, err := applyStringEdit(content, e)
+		if err != nil {
+			return "", []string{fmt.Sprintf("%s: edit %d/%d failed: %v", op.path, i+1, len(op.edits), err)}
+		}
+		content = next
+	}
+	return content, nil
+}
+
+// commitStaged writes staged files to disk. If any write fails, files
+// already written are restored to their original state.
+func commitStaged(staged map[string]*stagedFile, order []string) error {
+	var done []*stagedFile
+	rollback := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			sf := done[i]
+			if sf.existed {
+				writeFileAtomic(sf.path, sf.orig, sf.mode)
+			} else {
+				os.Remove(sf.path)
+			}
+		}
+	}
+
+	for _, path := range order {
+		sf := staged[path]
+		switch {
+		case sf.exists:
+			if sf.existed && sf.content == string(sf.orig) {
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				rollback()
+				return fmt.Errorf("cannot create directory for %s: %v", path, err)
+			}
+			if err := writeFileAtomic(path, []byte(sf.content), sf.mode); err != nil {
+				rollback()
+				return fmt.Errorf("cannot write %s: %v", path, err)
+			}
+		case sf.existed:
+			if err := os.Remove(path); err != nil {
+				rollback()
+				return fmt.Errorf("cannot delete %s: %v", path, err)
+			}
+		default:
+			continue
+		}
+		done = append(done, sf)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte, mode fs.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	name := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(name)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(name)
+		return err
+	}
+	if err := os.Chmod(name, mode); err != nil {
+		os.Remove(name)
+		return err
+	}
+	if err := os.Rename(name, path); err != nil {
+		os.Remove(name)
+		return err
+	}
+	return nil
+}
+
+func countLines(s string) int {
+	if s == "" {
+		return 0
+	}
+	return strings.Count(strings.TrimSuffix(s, "\n"), "\n") + 1
+}
+
+// lineDelta approximates added and removed line counts between two texts
+// by trimming their common prefix and suffix lines.
+func lineDelta(before, after string) (int, int) {
+	a := strings.Split(before, "\n")
+	b := strings.Split(after, "\n")
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+	return len(b), len(a)
+}
@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string // initial files
+		input   map[string]any
+		want    map[string]string // expected files; "" means absent
+		wantErr string
+	}{
+		{
+			name:  "update with shifted hunk",
+			files: map[string]string{"a.txt": "zero\none\ntwo\nthree\n"},
+			input: map[string]any{"patch": `--- a/a.txt
++++ b/a.txt
+@@ -1,2 +1,2 @@
+ one
+-two
++TWO
+`},
+			want: map[string]string{"a.txt": "zero\none\nTWO\nthree\n"},
+		},
+		{
+			name:  "create delete and rename",
+			files: map[string]string{"old.txt": "x\n", "gone.txt": "bye\n"},
+			input: map[string]any{"patch": `diff --git a/new.txt b/new.txt
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,2 @@
++hello
++world
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/old.txt b/sub/moved.txt
+similarity index 100%
+rename from old.txt
+rename to sub/moved.txt
+`},
+			want: map[string]string{
+				"new.txt":       "hello\nworld\n",
+				"gone.txt":      "",
+				"old.txt":       "",
+				"sub/moved.txt": "x\n",
+			},
+		},
+		{
+			name:  "removed and added lines that look like file headers",
+			files: map[string]string{"s.sql": "select 1;\n-- old note\nselect 2;\n"},
+			input: map[string]any{"patch": "--- a/s.sql\n+++ b/s.sql\n@@ -1,3 +1,3 @@\n select 1;\n--- old note\n+++ new note\n select 2;\n"},
+			want:  map[string]string{"s.sql": "select 1;\n++ new note\nselect 2;\n"},
+		},
+		{
+			name:  "crlf preserved",
+			files: map[string]string{"w.txt": "a\r\nb\r\n"},
+			input: map[string]any{"patch": "--- a/w.txt\n+++ b/w.txt\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n"},
+			want:  map[string]string{"w.txt": "a\r\nc\r\n"},
+		},
+		{
+			name:  "mixed endings kept per line",
+			files: map[string]string{"m.txt": "a\r\nb\nc\r\n"},
+			input: map[string]any{"patch": "--- a/m.txt\n+++ b/m.txt\n@@ -1,3 +1,3 @@\n a\n b\n-c\n+C\n"},
+			want:  map[string]string{"m.txt": "a\r\nb\nC\r\n"},
+		},
+		{
+			name:  "failed hunk changes nothing",
+			files: map[string]string{"a.txt": "one\n", "b.txt": "two\n"},
+			input: map[string]any{"patch": `--- a/a.txt
++++ b/a.txt
+@@ -1 +1 @@
+-one
++ONE
+--- a/b.txt
++++ b/b.txt
+@@ -1 +1 @@
+-missing
++TWO
+`},
+			want:    map[string]string{"a.txt": "one\n", "b.txt": "two\n"},
+			wantErr: "b.txt: hunk 1/1",
+		},
+		{
+			name:  "structured edits",
+			files: map[string]string{"c.go": "a := 1\nb := 1\n"},
+			input: map[string]any{"files": []map[string]any{
+				{"path": "c.go", "action": "update", "edits": []map[string]any{
+					{"old_string": "1", "new_string": "2", "replace_all": true},
+					{"old_string": "b :=", "new_string": "c :="},
+				}},
+				{"path": "d.go", "action": "create", "content": "package d\n"},
+			}},
+			want: map[string]string{"c.go": "a := 2\nc := 2\n", "d.go": "package d\n"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			input, _ := json.Marshal(tc.input)
+			tool := NewApplyPatchTool(NewWorkspace(dir, "shared"), nil)
+			res := tool.Execute(context.Background(), input)
+
+			if tc.wantErr != "" {
+				if !res.IsError || !strings.Contains(res.Content, tc.wantErr) {
+					t.Fatalf("want error containing %q, got %+v", tc.wantErr, res.Content)
+				}
+			} else if res.IsError {
+				t.Fatalf("unexpected error: %s", res.Content)
+			}
+
+			for name, want := range tc.want {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if want == "" {
+					if err == nil {
+						t.Errorf("%s should not exist", name)
+					}
+					continue
+				}
+				if err != nil || string(data) != want {
+					t.Errorf("%s = %q (err %v), want %q", name, data, err, want)
+				}
+			}
+		})
+	}
+}
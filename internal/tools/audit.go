@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/yifanes/miniclawd/internal/core"
+	"github.com/yifanes/miniclawd/internal/storage"
+)
+
+const maxAuditDigest = 400
+
+// sensitiveKeys are input fields whose values are never written to the
+// audit log, whatever the tool.
+var sensitiveKeys = []string{"password", "secret", "token", "api_key", "apikey", "authorization", "cookie", "credential_value"}
+
+// recordExecution writes one tool call to audit_logs.
+func (r *ToolRegistry) recordExecution(name string, input json.RawMessage, auth *ToolAuthContext, result ToolResult) {
+	r.mu.RLock()
+	db := r.audit
+	r.mu.RUnlock()
+	if db == nil {
+		return
+	}
+	e := storage.AuditEvent{
+		Kind:       "tool",
+		Actor:      "internal",
+		Action:     name,
+		Status:     "ok",
+		DurationMs: result.DurationMs,
+		ErrorType:  result.ErrorType,
+	}
+	if auth != nil {
+		e.Actor = fmt.Sprintf("%s:%d", auth.CallerChannel, auth.CallerChatID)
+		chatID := auth.CallerChatID
+		e.ChatID = &chatID
+	}
+	if result.IsError {
+		e.Status = "error"
+		if result.ErrorType != nil {
+			switch *result.ErrorType {
+			case "policy_denied", "risk_denied", "blocked":
+				e.Status = "denied"
+			}
+		}
+	}
+	digest := inputDigest(input)
+	e.Detail = &digest
+	if err := db.InsertAuditEvent(e); err != nil {
+		log.Printf("[tools] audit log write failed: %v", err)
+	}
+}
+
+// inputDigest summarizes tool input for the audit log: a hash of the full
+// input followed by a truncated copy with the auth context removed,
+// sensitive fields masked and known secrets redacted.
+func inputDigest(input json.RawMessage) string {
+	var m map[string]any
+	if err := json.Unmarshal(input, &m); err != nil {
+		m = nil
+	}
+	delete(m, "__miniclawd_auth")
+	maskSensitive(m)
+	clean, _ := json.Marshal(m)
+	sum := sha256.Sum256(clean)
+
+	text := core.RedactSecrets(string(clean))
+	if len(text) > maxAuditDigest {
+		text = text[:core.FloorCharBoundary(text, maxAuditDigest)] + "..."
+	}
+	return "sha256:" + hex.EncodeToString(sum[:6]) + " " + text
+}
+
+func maskSensitive(v any) {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			lk := strings.ToLower(k)
+			masked := false
+			for _, s := range sensitiveKeys {
+				if strings.Contains(lk, s) {
+					t[k] = "[REDACTED]"
+					masked = true
+					break
+				}
+			}
+			if !masked {
+				maskSensitive(val)
+			}
+		}
+	case []any:
+		for _, val := range t {
+			maskSensitive(val)
+		}
+	}
+}
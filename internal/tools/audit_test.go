@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yifanes/miniclawd/internal/storage"
+)
+
+func TestToolExecutionAudit(t *testing.T) {
+	db, err := storage.Open(filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	r := NewToolRegistry()
+	r.Register(NewTodoReadTool(t.TempDir()))
+	r.SetAuditLog(db)
+	r.SetToolPolicy(NewToolPolicy([]ToolPolicyRule{{ChatIDs: []int64{9}, Deny: []string{"*"}}}))
+
+	auth := &ToolAuthContext{CallerChannel: "telegram", CallerChatID: 5}
+	r.ExecuteWithAuth(context.Background(), "todo_read", json.RawMessage(`{"api_token":"hunter2hunter2","note":"x"}`), auth)
+	r.ExecuteWithAuth(context.Background(), "todo_read", json.RawMessage(`{}`), &ToolAuthContext{CallerChannel: "discord", CallerChatID: 9})
+
+	chat := int64(5)
+	logs, err := db.QueryAuditLogs(storage.AuditFilter{Kind: "tool", ChatID: &chat})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("expected 1 entry for chat 5, got %d", len(logs))
+	}
+	l := logs[0]
+	if l.Action != "todo_read" || l.Actor != "telegram:5" || l.Status != "ok" || l.DurationMs == nil {
+		t.Errorf("unexpected entry: %+v", l)
+	}
+	if d := *l.Detail; strings.Contains(d, "hunter2") || strings.Contains(d, "__miniclawd_auth") || !strings.Contains(d, `"note":"x"`) {
+		t.Errorf("digest not redacted as expected: %s", d)
+	}
+
+	denied, _ := db.QueryAuditLogs(storage.AuditFilter{Kind: "tool", Since: "2000-01-01T00:00:00Z"})
+	if len(denied) != 2 || denied[0].Status != "denied" {
+		t.Errorf("expected newest entry to be denied, got %+v", denied)
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os/exec"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/yifanes/miniclawd/internal/core"
@@ -14,11 +16,11 @@ import (
 const maxOutputBytes = 30000
 
 type BashTool struct {
-	workingDir string
+	workspace *Workspace
 }
 
-func NewBashTool(workingDir string) *BashTool {
-	return &BashTool{workingDir: workingDir}
+func NewBashTool(workspace *Workspace) *BashTool {
+	return &BashTool{workspace: workspace}
 }
 
 func (t *BashTool) Name() string { return "bash" }
@@ -34,6 +36,33 @@ func (t *BashTool) Definition() core.ToolDefinition {
 	)
 }
 
+// Risk classifies the command for the registry's risk policy.
+func (t *BashTool) Risk(input json.RawMessage) (ToolRisk, string) {
+	var p struct {
+		Command string `json:"command"`
+	}
+	json.Unmarshal(input, &p)
+	return commandRisk(p.Command)
+}
+
+// highRiskCommandRe matches shell commands that act on the whole machine or
+// run code fetched from the network.
+var highRiskCommandRe = regexp.MustCompile(
+	`(^|[;&|(\s])(sudo|su|doas|mkfs(\.\w+)?|shutdown|reboot|poweroff|halt)(\s|$)` +
+		`|\brm\s+-[a-zA-Z]*[rR][a-zA-Z]*\s+(-\S+\s+)*(/|~|\$HOME)/?\*?(\s|$)` +
+		`|\bdd\s[^;&|]*\bof=/dev/` +
+		`|\b(curl|wget)\b[^;&]*\|\s*(sudo\s+)?(ba|z)?sh\b`)
+
+// commandRisk rates a shell command for bash and process_start alike:
+// medium by default, high for privilege changes, wiping the filesystem or
+// piping downloads into a shell.
+func commandRisk(command string) (ToolRisk, string) {
+	if m := highRiskCommandRe.FindString(command); m != "" {
+		return RiskHigh, "command " + strings.TrimSpace(m)
+	}
+	return RiskMedium, "command"
+}
+
 func (t *BashTool) Execute(ctx context.Context, input json.RawMessage) ToolResult {
 	var params struct {
 		Command     string `json:"command"`
@@ -54,18 +83,23 @@ func (t *BashTool) Execute(ctx context.Context, input json.RawMessage) ToolResul
 		}
 	}
 
+	dir, err := t.workspace.DirFor(ExtractAuthContext(input))
+	if err != nil {
+		return Error(err.Error())
+	}
+
 	cmdCtx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
 	defer cancel()
 
 	cmd := exec.CommandContext(cmdCtx, "bash", "-c", params.Command)
-	cmd.Dir = t.workingDir
+	cmd.Dir = dir
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
 	start := time.Now()
-	err := cmd.Run()
+	err = cmd.Run()
 	duration := time.Since(start).Milliseconds()
 
 	output := stdout.String()
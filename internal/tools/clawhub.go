@@ -13,16 +13,18 @@ import (
 	"time"
 
 	"github.com/yifanes/miniclawd/internal/core"
+	"github.com/yifanes/miniclawd/internal/netguard"
 )
 
 // ClawHubSearchTool searches the ClawHub registry.
 type ClawHubSearchTool struct {
 	registryURL string
 	token       *string
+	guard       *netguard.Policy
 }
 
-func NewClawHubSearchTool(registryURL string, token *string) *ClawHubSearchTool {
-	return &ClawHubSearchTool{registryURL: registryURL, token: token}
+func NewClawHubSearchTool(registryURL string, token *string, guard *netguard.Policy) *ClawHubSearchTool {
+	return &ClawHubSearchTool{registryURL: registryURL, token: token, guard: guard}
 }
 
 func (t *ClawHubSearchTool) Name() string { return "clawhub_search" }
@@ -49,7 +51,7 @@ func (t *ClawHubSearchTool) Execute(ctx context.Context, input json.RawMessage)
 	}
 
 	u := fmt.Sprintf("%s/api/v1/skills/search?q=%s", strings.TrimRight(t.registryURL, "/"), url.QueryEscape(params.Query))
-	client := &http.Client{Timeout: 15 * time.Second}
+	client := t.guard.Client(15 * time.Second)
 	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
 	if err != nil {
 		return Error(fmt.Sprintf("request error: %v", err))
@@ -77,10 +79,11 @@ type ClawHubInstallTool struct {
 	registryURL string
 	token       *string
 	skillsDir   string
+	guard       *netguard.Policy
 }
 
-func NewClawHubInstallTool(registryURL string, token *string, skillsDir string) *ClawHubInstallTool {
-	return &ClawHubInstallTool{registryURL: registryURL, token: token, skillsDir: skillsDir}
+func NewClawHubInstallTool(registryURL string, token *string, skillsDir string, guard *netguard.Policy) *ClawHubInstallTool {
+	return &ClawHubInstallTool{registryURL: registryURL, token: token, skillsDir: skillsDir, guard: guard}
 }
 
 func (t *ClawHubInstallTool) Name() string { return "clawhub_install" }
@@ -113,7 +116,7 @@ func (t *ClawHubInstallTool) Execute(ctx context.Context, input json.RawMessage)
 	u := fmt.Sprintf("%s/api/v1/skills/%s/download%s",
 		strings.TrimRight(t.registryURL, "/"), url.PathEscape(params.SkillName), versionParam)
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := t.guard.Client(30 * time.Second)
 	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
 	if err != nil {
 		return Error(fmt.Sprintf("request error: %v", err))
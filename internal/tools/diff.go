@@ -0,0 +1,265 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FileDiff describes a change a tool made to a file, for display in UIs.
+type FileDiff struct {
+	Path    string
+	Diff    string // unified diff
+	Added   int
+	Removed int
+}
+
+const (
+	diffContext  = 3
+	maxDiffBytes = 6000
+	// maxDiffCells bounds the LCS table; larger changes are shown as a
+	// wholesale replacement of the differing region.
+	maxDiffCells = 4_000_000
+)
+
+type diffLine struct {
+	op   byte // ' ', '-' or '+'
+	text string
+}
+
+// unifiedDiff renders a compact unified diff between two versions of a file.
+func unifiedDiff(path, before, after string) FileDiff {
+	a := splitDiffLines(before)
+	b := splitDiffLines(after)
+	script := diffScript(a, b)
+
+	fd := FileDiff{Path: path}
+	for _, l := range script {
+		switch l.op {
+		case '+':
+			fd.Added++
+		case '-':
+			fd.Removed++
+		}
+	}
+	if fd.Added == 0 && fd.Removed == 0 {
+		return fd
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", path, path)
+	for i := 0; i < len(script); {
+		if script[i].op == ' ' {
+			i++
+			continue
+		}
+		// Extend the hunk until a run of unchanged lines longer than twice
+		// the context separates it from the next change.
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(script); j++ {
+			if script[j].op != ' ' {
+				end = j + 1
+			} else if j-end >= 2*diffContext {
+				break
+			}
+		}
+		end += diffContext
+		if end > len(script) {
+			end = len(script)
+		}
+
+		oldStart, newStart := 1, 1
+		for _, l := range script[:start] {
+			if l.op != '+' {
+				oldStart++
+			}
+			if l.op != '-' {
+				newStart++
+			}
+		}
+		oldLen, newLen := 0, 0
+		for _, l := range script[start:end] {
+			if l.op != '+' {
+				oldLen++
+			}
+			if l.op != '-' {
+				newLen++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldLen, newStart, newLen)
+		for _, l := range script[start:end] {
+			sb.WriteByte(l.op)
+			sb.WriteString(l.text)
+			sb.WriteByte('\n')
+		}
+		i = end
+	}
+
+	fd.Diff = sb.String()
+	if len(fd.Diff) > maxDiffBytes {
+		cut := strings.LastIndex(fd.Diff[:maxDiffBytes], "\n") + 1
+		fd.Diff = fd.Diff[:cut] + "... (diff truncated)\n"
+	}
+	return fd
+}
+
+// diffScript returns the line-level edit script turning a into b.
+func diffScript(a, b []string) []diffLine {
+	// Trim the common prefix and suffix, diffing only the middle.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	script := make([]diffLine, 0, len(a)+len(b))
+	for _, l := range a[:pre] {
+		script = append(script, diffLine{' ', l})
+	}
+	script = append(script, diffMiddle(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, l := range a[len(a)-suf:] {
+		script = append(script, diffLine{' ', l})
+	}
+	return script
+}
+
+// diffMiddle computes a line-level edit script using an LCS table.
+func diffMiddle(a, b []string) []diffLine {
+	var out []diffLine
+	if len(a)*len(b) > maxDiffCells {
+		for _, l := range a {
+			out = append(out, diffLine{'-', l})
+		}
+		for _, l := range b {
+			out = append(out, diffLine{'+', l})
+		}
+		return out
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, diffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, diffLine{'-', a[i]})
+			i++
+		default:
+			out = append(out, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		out = append(out, diffLine{'+', b[j]})
+	}
+	return out
+}
+
+func splitDiffLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// splitLineEndings returns s with CRLF line breaks turned into LF and, for
+// each line break, whether it was CRLF. crlf is nil if s has none.
+func splitLineEndings(s string) (lf string, crlf []bool) {
+	if !strings.Contains(s, "\r\n") {
+		return s, nil
+	}
+	lines := strings.Split(s, "\n")
+	crlf = make([]bool, len(lines)-1)
+	for i := range crlf {
+		if strings.HasSuffix(lines[i], "\r") {
+			crlf[i] = true
+			lines[i] = lines[i][:len(lines[i])-1]
+		}
+	}
+	return strings.Join(lines, "\n"), crlf
+}
+
+// restoreLineEndings converts after, an edited version of the LF text
+// before, back to the original line endings: unchanged lines keep their
+// own, replaced lines take those of the lines they replace, and added lines
+// the file's most common ending.
+func restoreLineEndings(before, after string, crlf []bool) string {
+	if crlf == nil {
+		return after
+	}
+	n := 0
+	for _, c := range crlf {
+		if c {
+			n++
+		}
+	}
+	common := 2*n >= len(crlf)
+	ending := func(i int) bool {
+		if i < len(crlf) {
+			return crlf[i]
+		}
+		return common
+	}
+
+	b := strings.Split(after, "\n")
+	var sb strings.Builder
+	i, j := 0, 0 // next line of before and after
+	var removed []bool
+	write := func(line string, c bool) {
+		sb.WriteString(line)
+		if j++; j < len(b) {
+			if c {
+				sb.WriteString("\r\n")
+			} else {
+				sb.WriteString("\n")
+			}
+		}
+	}
+	for _, l := range diffScript(strings.Split(before, "\n"), b) {
+		switch l.op {
+		case ' ':
+			write(l.text, ending(i))
+			i++
+			removed = removed[:0]
+		case '-':
+			removed = append(removed, ending(i))
+			i++
+		case '+':
+			c := common
+			if len(removed) > 0 {
+				c, removed = removed[0], removed[1:]
+			}
+			write(l.text, c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	numbered := func(n int) string {
+		var sb strings.Builder
+		for i := 1; i <= n; i++ {
+			fmt.Fprintf(&sb, "line %d\n", i)
+		}
+		return sb.String()
+	}
+	long := numbered(20)
+
+	tests := []struct {
+		name           string
+		before         string
+		after          string
+		want           string
+		added, removed int
+	}{
+		{"no change", "a\nb\n", "a\nb\n", "", 0, 0},
+		{"line ending only", "a\r\nb\r\n", "a\nb\n", "", 0, 0},
+		{
+			"replace in middle", "a\nb\nc\n", "a\nB\nc\n",
+			"--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n", 1, 1,
+		},
+		{
+			"insert into empty file", "", "x\ny\n",
+			"--- a/f\n+++ b/f\n@@ -1,0 +1,2 @@\n+x\n+y\n", 2, 0,
+		},
+		{
+			"separate hunks",
+			long,
+			strings.Replace(strings.Replace(long, "line 2\n", "two\n", 1), "line 19\n", "nineteen\n", 1),
+			"--- a/f\n+++ b/f\n" +
+				"@@ -1,5 +1,5 @@\n line 1\n-line 2\n+two\n line 3\n line 4\n line 5\n" +
+				"@@ -16,5 +16,5 @@\n line 16\n line 17\n line 18\n-line 19\n+nineteen\n line 20\n",
+			2, 2,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := unifiedDiff("f", tc.before, tc.after)
+			if d.Diff != tc.want || d.Added != tc.added || d.Removed != tc.removed {
+				t.Errorf("diff +%d -%d:\n%s\nwant +%d -%d:\n%s", d.Added, d.Removed, d.Diff, tc.added, tc.removed, tc.want)
+			}
+		})
+	}
+
+	big := unifiedDiff("f", numbered(2000), strings.ReplaceAll(numbered(2000), "line", "LINE"))
+	if len(big.Diff) > maxDiffBytes+len("... (diff truncated)\n") || !strings.HasSuffix(big.Diff, "... (diff truncated)\n") {
+		t.Errorf("large diff not truncated: %d bytes", len(big.Diff))
+	}
+	if big.Added != 2000 || big.Removed != 2000 {
+		t.Errorf("large diff counts +%d -%d", big.Added, big.Removed)
+	}
+}
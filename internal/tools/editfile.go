@@ -11,63 +11,111 @@ import (
 )
 
 type EditFileTool struct {
-	workingDir string
+	workspace *Workspace
+	policy    *PathPolicy
 }
 
-func NewEditFileTool(workingDir string) *EditFileTool {
-	return &EditFileTool{workingDir: workingDir}
+func NewEditFileTool(workspace *Workspace, policy *PathPolicy) *EditFileTool {
+	return &EditFileTool{workspace: workspace, policy: policy}
 }
 
 func (t *EditFileTool) Name() string { return "edit_file" }
 
 func (t *EditFileTool) Definition() core.ToolDefinition {
 	return MakeDef("edit_file",
-		"Edit a file by replacing an exact string match. The old_string must appear exactly once in the file.",
+		"Edit a file by replacing exact string matches. Pass old_string/new_string for a single edit, "+
+			"or edits for several sequential edits applied atomically. old_string must appear exactly once "+
+			"unless replace_all is set. Returns a unified diff of the change.",
 		map[string]any{
-			"path":       StringProp("File path to edit"),
-			"old_string": StringProp("The exact string to find (must be unique in the file)"),
-			"new_string": StringProp("The replacement string"),
+			"path":        StringProp("File path to edit"),
+			"old_string":  StringProp("The exact string to find (must be unique in the file unless replace_all)"),
+			"new_string":  StringProp("The replacement string"),
+			"replace_all": BoolProp("Replace every occurrence of old_string"),
+			"edits": map[string]any{
+				"type":        "array",
+				"description": "Sequential edits; each applies to the result of the previous one",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"old_string":  StringProp("The exact string to find"),
+						"new_string":  StringProp("The replacement string"),
+						"replace_all": BoolProp("Replace every occurrence"),
+					},
+					"required": []string{"old_string", "new_string"},
+				},
+			},
 		},
-		[]string{"path", "old_string", "new_string"},
+		[]string{"path"},
 	)
 }
 
 func (t *EditFileTool) Execute(_ context.Context, input json.RawMessage) ToolResult {
 	var params struct {
-		Path      string `json:"path"`
-		OldString string `json:"old_string"`
-		NewString string `json:"new_string"`
+		Path       string       `json:"path"`
+		OldString  string       `json:"old_string"`
+		NewString  string       `json:"new_string"`
+		ReplaceAll bool         `json:"replace_all"`
+		Edits      []stringEdit `json:"edits"`
 	}
 	if err := json.Unmarshal(input, &params); err != nil {
 		return Error("invalid input: " + err.Error())
 	}
-	if params.Path == "" || params.OldString == "" {
-		return Error("path and old_string are required")
+	edits := params.Edits
+	if params.OldString != "" {
+		edits = append([]stringEdit{{OldString: params.OldString, NewString: params.NewString, ReplaceAll: params.ReplaceAll}}, edits...)
+	}
+	if params.Path == "" || len(edits) == 0 {
+		return Error("path and old_string (or edits) are required")
 	}
 
-	path := resolvePath(t.workingDir, params.Path)
-	if err := CheckPath(path); err != nil {
+	auth := ExtractAuthContext(input)
+	path, err := t.workspace.Resolve(auth, params.Path)
+	if err != nil {
+		return Error(err.Error())
+	}
+	if err := t.policy.Enforce(auth, t.Name(), path, true); err != nil {
 		return Error(err.Error())
 	}
 
+	info, err := os.Stat(path)
+	if err != nil {
+		return Error(fmt.Sprintf("cannot read file: %v", err))
+	}
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return Error(fmt.Sprintf("cannot read file: %v", err))
 	}
 
-	text := string(content)
-	count := strings.Count(text, params.OldString)
-	if count == 0 {
-		return Error("old_string not found in file")
-	}
-	if count > 1 {
-		return Error(fmt.Sprintf("old_string found %d times (must be unique). Provide more context to make it unique.", count))
+	// Match against LF-normalized text so edits written with \n also apply to
+	// CRLF files, then restore the file's line endings.
+	text, crlf := splitLineEndings(string(content))
+	original := text
+
+	replaced := 0
+	for i, e := range edits {
+		if crlf != nil {
+			e.OldString = strings.ReplaceAll(e.OldString, "\r\n", "\n")
+			e.NewString = strings.ReplaceAll(e.NewString, "\r\n", "\n")
+		}
+		next, n, err := applyStringEdit(text, e)
+		if err != nil {
+			if len(edits) > 1 {
+				return Error(fmt.Sprintf("edit %d/%d failed: %v. No changes were written.", i+1, len(edits), err))
+			}
+			return Error(err.Error())
+		}
+		text = next
+		replaced += n
 	}
 
-	newText := strings.Replace(text, params.OldString, params.NewString, 1)
-	if err := os.WriteFile(path, []byte(newText), 0o644); err != nil {
+	out := restoreLineEndings(original, text, crlf)
+	if err := writeFileAtomic(path, []byte(out), info.Mode().Perm()); err != nil {
 		return Error(fmt.Sprintf("cannot write file: %v", err))
 	}
 
-	return Success(fmt.Sprintf("edited %s (replaced 1 occurrence)", path))
+	diff := unifiedDiff(params.Path, original, text)
+	result := Success(fmt.Sprintf("edited %s (replaced %d occurrence(s), +%d -%d)\n%s",
+		path, replaced, diff.Added, diff.Removed, diff.Diff))
+	result.Diff = &diff
+	return result
 }
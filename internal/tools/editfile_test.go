@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEditFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		input   map[string]any
+		want    string // file content afterwards
+		wantErr string
+	}{
+		{
+			name:    "single edit",
+			content: "alpha\nbeta\n",
+			input:   map[string]any{"old_string": "beta", "new_string": "gamma"},
+			want:    "alpha\ngamma\n",
+		},
+		{
+			name:    "multiple matches rejected",
+			content: "x = 1\ny = 1\n",
+			input:   map[string]any{"old_string": "1", "new_string": "2"},
+			want:    "x = 1\ny = 1\n",
+			wantErr: "found 2 times",
+		},
+		{
+			name:    "replace all",
+			content: "x = 1\ny = 1\n",
+			input:   map[string]any{"old_string": "1", "new_string": "2", "replace_all": true},
+			want:    "x = 2\ny = 2\n",
+		},
+		{
+			name:    "missing match",
+			content: "alpha\n",
+			input:   map[string]any{"old_string": "omega", "new_string": "x"},
+			want:    "alpha\n",
+			wantErr: "not found",
+		},
+		{
+			name:    "failed edit in a batch writes nothing",
+			content: "alpha\nbeta\n",
+			input: map[string]any{"edits": []map[string]any{
+				{"old_string": "alpha", "new_string": "ALPHA"},
+				{"old_string": "omega", "new_string": "x"},
+			}},
+			want:    "alpha\nbeta\n",
+			wantErr: "edit 2/2 failed",
+		},
+		{
+			name:    "sequential edits",
+			content: "alpha\nbeta\n",
+			input: map[string]any{"edits": []map[string]any{
+				{"old_string": "alpha", "new_string": "ALPHA"},
+				{"old_string": "ALPHA\nbeta", "new_string": "ALPHA\nBETA"},
+			}},
+			want: "ALPHA\nBETA\n",
+		},
+		{
+			name:    "crlf file with lf edit",
+			content: "one\r\ntwo\r\nthree\r\n",
+			input:   map[string]any{"old_string": "two\nthree", "new_string": "2\n2.5\n3"},
+			want:    "one\r\n2\r\n2.5\r\n3\r\n",
+		},
+		{
+			name:    "mixed endings kept on untouched lines",
+			content: "a\r\nb\nc\r\nd\n",
+			input:   map[string]any{"old_string": "c", "new_string": "C"},
+			want:    "a\r\nb\nC\r\nd\n",
+		},
+		{
+			name:    "mixed endings: inserted lines take the common ending",
+			content: "a\r\nb\r\nc\n",
+			input:   map[string]any{"old_string": "c", "new_string": "c\nd"},
+			want:    "a\r\nb\r\nc\nd\r\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "f.txt")
+			if err := os.WriteFile(path, []byte(tc.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			tc.input["path"] = "f.txt"
+			input, _ := json.Marshal(tc.input)
+			res := NewEditFileTool(NewWorkspace(dir, "shared"), nil).Execute(context.Background(), input)
+
+			if tc.wantErr != "" {
+				if !res.IsError || !strings.Contains(res.Content, tc.wantErr) {
+					t.Fatalf("want error containing %q, got %q", tc.wantErr, res.Content)
+				}
+			} else if res.IsError {
+				t.Fatalf("unexpected error: %s", res.Content)
+			}
+			if data, _ := os.ReadFile(path); string(data) != tc.want {
+				t.Errorf("file = %q, want %q", data, tc.want)
+			}
+		})
+	}
+}
@@ -16,10 +16,11 @@ import (
 type ExportChatTool struct {
 	db      *storage.Database
 	dataDir string
+	policy  *PathPolicy
 }
 
-func NewExportChatTool(db *storage.Database, dataDir string) *ExportChatTool {
-	return &ExportChatTool{db: db, dataDir: dataDir}
+func NewExportChatTool(db *storage.Database, dataDir string, policy *PathPolicy) *ExportChatTool {
+	return &ExportChatTool{db: db, dataDir: dataDir, policy: policy}
 }
 
 func (t *ExportChatTool) Name() string { return "export_chat" }
@@ -65,6 +66,9 @@ func (t *ExportChatTool) Execute(_ context.Context, input json.RawMessage) ToolR
 		ts := time.Now().UTC().Format("20060102_150405")
 		outputPath = filepath.Join(t.dataDir, "exports", fmt.Sprintf("%d_%s.md", params.ChatID, ts))
 	}
+	if err := t.policy.Enforce(auth, t.Name(), outputPath, true); err != nil {
+		return Error(err.Error())
+	}
 
 	dir := filepath.Dir(outputPath)
 	if err := os.MkdirAll(dir, 0o755); err != nil {
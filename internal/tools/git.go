@@ -0,0 +1,697 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yifanes/miniclawd/internal/core"
+)
+
+const (
+	maxGitOutput  = 30000
+	maxGitEntries = 200
+	gitTimeout    = 60 * time.Second
+)
+
+type GitTool struct {
+	workspace *Workspace
+	policy    *PathPolicy
+}
+
+func NewGitTool(workspace *Workspace, policy *PathPolicy) *GitTool {
+	return &GitTool{workspace: workspace, policy: policy}
+}
+
+type gitParams struct {
+	Operation        string   `json:"operation"`
+	Repo             string   `json:"repo"`
+	Paths            []string `json:"paths"`
+	Ref              string   `json:"ref"`
+	Name             string   `json:"name"`
+	Action           string   `json:"action"`
+	Message          string   `json:"message"`
+	Mode             string   `json:"mode"`
+	Remote           string   `json:"remote"`
+	MaxCount         int      `json:"max_count"`
+	Staged           bool     `json:"staged"`
+	All              bool     `json:"all"`
+	Create           bool     `json:"create"`
+	Force            bool     `json:"force"`
+	DryRun           bool     `json:"dry_run"`
+	IncludeUntracked bool     `json:"include_untracked"`
+}
+
+func (t *GitTool) Name() string { return "git" }
+
+func (t *GitTool) Definition() core.ToolDefinition {
+	return MakeDef("git",
+		"Run git operations on a repository in the working directory and get parsed, size-bounded results. "+
+			"Prefer this over git in bash. Operations: status; diff (staged, ref, paths); log (ref, paths, max_count); "+
+			"show (ref, paths); branch (action list|create|delete, name, ref, all); commit (message, paths to stage, all); "+
+			"checkout (ref, create+name for a new branch, paths to restore files); stash (action push|list|show|apply|pop|drop, "+
+			"message, include_untracked); reset (mode soft|mixed|hard, ref, paths to unstage); clean (dry_run); push (remote, ref). "+
+			"Destructive variants (force, +ref or :branch refspecs, reset --hard, clean, stash drop, restoring paths) need elevated permission.",
+		map[string]any{
+			"operation": EnumProp("The git operation", []string{"status", "diff", "log", "show", "branch", "commit", "checkout", "stash", "reset", "clean", "push"}),
+			"repo":      StringProp("Repository directory (default: working directory)"),
+			"paths": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Path filters relative to the repository",
+			},
+			"ref":               StringProp("Commit, branch, tag or stash reference"),
+			"name":              StringProp("Branch name for branch create/delete or checkout with create"),
+			"action":            StringProp("Sub-action for branch and stash"),
+			"message":           StringProp("Commit or stash message"),
+			"mode":              EnumProp("Reset mode (default mixed)", []string{"soft", "mixed", "hard"}),
+			"remote":            StringProp("Remote for push (default: the branch's upstream)"),
+			"max_count":         IntProp("Maximum log entries (default 20, max 200)"),
+			"staged":            BoolProp("diff: show staged changes"),
+			"all":               BoolProp("commit: stage all tracked changes; branch list: include remote branches"),
+			"create":            BoolProp("checkout: create a new branch called name from ref"),
+			"force":             BoolProp("Force the operation (checkout -f, branch -D, push --force-with-lease)"),
+			"dry_run":           BoolProp("clean: only list what would be removed"),
+			"include_untracked": BoolProp("stash push: include untracked files"),
+		},
+		[]string{"operation"},
+	)
+}
+
+// Risk classifies the requested operation for the registry's risk policy.
+func (t *GitTool) Risk(input json.RawMessage) (ToolRisk, string) {
+	var p gitParams
+	json.Unmarshal(input, &p)
+	op := p.Operation
+	switch op {
+	case "status", "diff", "log", "show":
+		return RiskLow, op
+	case "branch":
+		switch p.Action {
+		case "", "list":
+			return RiskLow, "branch list"
+		case "delete":
+			if p.Force {
+				return RiskHigh, "branch delete --force"
+			}
+		}
+		return RiskMedium, "branch " + p.Action
+	case "checkout":
+		if p.Force {
+			return RiskHigh, "checkout --force"
+		}
+		if len(p.Paths) > 0 {
+			return RiskHigh, "checkout -- <paths> (discards changes)"
+		}
+		return RiskMedium, op
+	case "stash":
+		switch p.Action {
+		case "list", "show":
+			return RiskLow, "stash " + p.Action
+		case "drop", "clear":
+			return RiskHigh, "stash " + p.Action
+		}
+		return RiskMedium, "stash " + p.Action
+	case "reset":
+		if p.Mode == "hard" {
+			return RiskHigh, "reset --hard"
+		}
+		return RiskMedium, op
+	case "clean":
+		if p.DryRun {
+			return RiskLow, "clean --dry-run"
+		}
+		return RiskHigh, op
+	case "push":
+		if p.Force {
+			return RiskHigh, "push --force"
+		}
+		// Refspecs can force or delete on their own: "+src:dst" forces
+		// the update and ":dst" deletes the remote branch.
+		if strings.HasPrefix(p.Ref, "+") || strings.Contains(p.Ref, ":+") {
+			return RiskHigh, "push +<refspec> (force)"
+		}
+		if strings.HasPrefix(p.Ref, ":") {
+			return RiskHigh, "push :<branch> (delete)"
+		}
+		return RiskMedium, op
+	}
+	return RiskMedium, op
+}
+
+func (t *GitTool) Execute(ctx context.Context, input json.RawMessage) ToolResult {
+	var p gitParams
+	if err := json.Unmarshal(input, &p); err != nil {
+		return Error("invalid input: " + err.Error())
+	}
+	if p.Operation == "" {
+		return Error("operation is required")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return Error("git is not installed")
+	}
+	for _, v := range []string{p.Ref, p.Name, p.Remote} {
+		if !validGitArg(v) {
+			return Error(fmt.Sprintf("invalid reference %q", v))
+		}
+	}
+	for _, path := range p.Paths {
+		if strings.HasPrefix(path, "-") {
+			return Error(fmt.Sprintf("invalid path %q", path))
+		}
+	}
+
+	auth := ExtractAuthContext(input)
+	dir, err := t.workspace.Resolve(auth, p.Repo)
+	if err != nil {
+		return Error(err.Error())
+	}
+	risk, _ := t.Risk(input)
+	if err := t.policy.Enforce(auth, t.Name(), dir, risk > RiskLow); err != nil {
+		return Error(err.Error())
+	}
+	for _, path := range p.Paths {
+		if err := t.policy.Enforce(auth, t.Name(), resolvePath(dir, path), risk > RiskLow); err != nil {
+			return Error(err.Error())
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+	g := gitRunner{ctx: ctx, dir: dir}
+
+	var out string
+	switch p.Operation {
+	case "status":
+		out, err = g.status(p)
+	case "diff":
+		out, err = g.diff(p)
+	case "log":
+		out, err = g.log(p)
+	case "show":
+		out, err = g.show(p)
+	case "branch":
+		out, err = g.branch(p)
+	case "commit":
+		out, err = g.commit(p)
+	case "checkout":
+		out, err = g.checkout(p)
+	case "stash":
+		out, err = g.stash(p)
+	case "reset":
+		out, err = g.reset(p)
+	case "clean":
+		out, err = g.clean(p)
+	case "push":
+		out, err = g.push(p)
+	default:
+		return Error(fmt.Sprintf("unknown operation %q", p.Operation))
+	}
+	if err != nil {
+		return ErrorWithType(boundOutput(err.Error(), maxGitOutput, ""), "git_error")
+	}
+	return Success(out)
+}
+
+// validGitArg rejects values git would parse as options or that contain
+// whitespace or control characters.
+func validGitArg(s string) bool {
+	if strings.HasPrefix(s, "-") {
+		return false
+	}
+	for _, r := range s {
+		if r <= ' ' || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// boundOutput truncates s to max bytes, appending hint when it does.
+func boundOutput(s string, max int, hint string) string {
+	if len(s) <= max {
+		return s
+	}
+	cut := core.FloorCharBoundary(s, max)
+	note := fmt.Sprintf("\n... (truncated, %d of %d bytes shown", cut, len(s))
+	if hint != "" {
+		note += "; " + hint
+	}
+	return s[:cut] + note + ")"
+}
+
+type gitRunner struct {
+	ctx context.Context
+	dir string
+}
+
+// run executes git and returns stdout. Failures carry git's stderr.
+func (g gitRunner) run(args ...string) (string, error) {
+	cmd := exec.CommandContext(g.ctx, "git", args...)
+	cmd.Dir = g.dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_PAGER=cat", "GIT_EDITOR=true", "LC_ALL=C")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(g.ctx.Err(), context.DeadlineExceeded) {
+			return "", fmt.Errorf("git %s timed out after %s", args[0], gitTimeout)
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s failed: %s", args[0], msg)
+	}
+	return stdout.String(), nil
+}
+
+// withPaths appends "--" and the path filters.
+func withPaths(args []string, paths []string) []string {
+	if len(paths) == 0 {
+		return args
+	}
+	return append(append(args, "--"), paths...)
+}
+
+// --- status ---
+
+func (g gitRunner) status(p gitParams) (string, error) {
+	raw, err := g.run(withPaths([]string{"status", "--porcelain=v2", "--branch", "-z"}, p.Paths)...)
+	if err != nil {
+		return "", err
+	}
+
+	var head, upstream, ab string
+	var staged, unstaged, untracked, conflicts []string
+	fields := strings.Split(raw, "\x00")
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		switch {
+		case strings.HasPrefix(f, "# branch.head "):
+			head = strings.TrimPrefix(f, "# branch.head ")
+		case strings.HasPrefix(f, "# branch.upstream "):
+			upstream = strings.TrimPrefix(f, "# branch.upstream ")
+		case strings.HasPrefix(f, "# branch.ab "):
+			ab = strings.TrimPrefix(f, "# branch.ab ")
+		case strings.HasPrefix(f, "1 "), strings.HasPrefix(f, "2 "):
+			parts := strings.SplitN(f, " ", 9)
+			if f[0] == '2' {
+				parts = strings.SplitN(f, " ", 10)
+			}
+			if len(parts) < 9 {
+				continue
+			}
+			xy, path := parts[1], parts[len(parts)-1]
+			if f[0] == '2' && i+1 < len(fields) {
+				i++
+				path = fields[i] + " -> " + path
+			}
+			if xy[0] != '.' {
+				staged = append(staged, fmt.Sprintf("%c %s", xy[0], path))
+			}
+			if xy[1] != '.' {
+				unstaged = append(unstaged, fmt.Sprintf("%c %s", xy[1], path))
+			}
+		case strings.HasPrefix(f, "u "):
+			parts := strings.SplitN(f, " ", 11)
+			if len(parts) == 11 {
+				conflicts = append(conflicts, parts[1]+" "+parts[10])
+			}
+		case strings.HasPrefix(f, "? "):
+			untracked = append(untracked, strings.TrimPrefix(f, "? "))
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Branch: %s", head)
+	if upstream != "" {
+		fmt.Fprintf(&sb, " -> %s", upstream)
+		var ahead, behind int
+		fmt.Sscanf(ab, "+%d -%d", &ahead, &behind)
+		fmt.Fprintf(&sb, " (ahead %d, behind %d)", ahead, behind)
+	}
+	sb.WriteString("\n")
+	writeGitList(&sb, "Conflicts", conflicts)
+	writeGitList(&sb, "Staged", staged)
+	writeGitList(&sb, "Unstaged", unstaged)
+	writeGitList(&sb, "Untracked", untracked)
+	if len(staged)+len(unstaged)+len(untracked)+len(conflicts) == 0 {
+		sb.WriteString("Working tree clean.\n")
+	}
+	return sb.String(), nil
+}
+
+func writeGitList(sb *strings.Builder, title string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	fmt.Fprintf(sb, "%s (%d):\n", title, len(items))
+	for i, it := range items {
+		if i == maxGitEntries {
+			fmt.Fprintf(sb, "  ... and %d more\n", len(items)-i)
+			break
+		}
+		fmt.Fprintf(sb, "  %s\n", it)
+	}
+}
+
+// --- diff / show / log ---
+
+func (g gitRunner) diff(p gitParams) (string, error) {
+	base := []string{"diff", "--no-color", "--no-ext-diff"}
+	if p.Staged {
+		base = append(base, "--cached")
+	}
+	if p.Ref != "" {
+		base = append(base, p.Ref)
+	}
+	stats, err := g.run(withPaths(append(append([]string{}, base...), "--numstat"), p.Paths)...)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(stats) == "" {
+		return "No changes.", nil
+	}
+	patch, err := g.run(withPaths(base, p.Paths)...)
+	if err != nil {
+		return "", err
+	}
+	return formatNumstat(stats) + "\n" + boundOutput(patch, maxGitOutput, "narrow the diff with paths"), nil
+}
+
+// formatNumstat summarizes "added\tremoved\tpath" lines.
+func formatNumstat(stats string) string {
+	var files []string
+	var added, removed int
+	for _, line := range strings.Split(strings.TrimSpace(stats), "\n") {
+		parts := strings.SplitN(line, "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		a, errA := strconv.Atoi(parts[0])
+		r, errR := strconv.Atoi(parts[1])
+		if errA != nil || errR != nil {
+			files = append(files, fmt.Sprintf("(binary) %s", parts[2]))
+			continue
+		}
+		added += a
+		removed += r
+		files = append(files, fmt.Sprintf("+%d -%d %s", a, r, parts[2]))
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d file(s) changed, +%d -%d\n", len(files), added, removed)
+	writeGitList(&sb, "Files", files)
+	return sb.String()
+}
+
+func (g gitRunner) log(p gitParams) (string, error) {
+	n := p.MaxCount
+	if n <= 0 {
+		n = 20
+	}
+	if n > maxGitEntries {
+		n = maxGitEntries
+	}
+	args := []string{"log", "-n", strconv.Itoa(n), "--date=short", "--format=%h%x1f%ad%x1f%an%x1f%d%x1f%s%x1e"}
+	if p.Ref != "" {
+		args = append(args, p.Ref)
+	}
+	raw, err := g.run(withPaths(args, p.Paths)...)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	count := 0
+	for _, rec := range strings.Split(raw, "\x1e") {
+		f := strings.Split(strings.TrimSpace(rec), "\x1f")
+		if len(f) != 5 {
+			continue
+		}
+		count++
+		fmt.Fprintf(&sb, "%s %s %s%s: %s\n", f[0], f[1], f[2], f[3], f[4])
+	}
+	if count == 0 {
+		return "No commits.", nil
+	}
+	return boundOutput(sb.String(), maxGitOutput, "lower max_count"), nil
+}
+
+func (g gitRunner) show(p gitParams) (string, error) {
+	ref := p.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	args := []string{"show", "--no-color", "--no-ext-diff", "--stat", "--patch",
+		"--format=commit %H%nAuthor: %an <%ae>%nDate:   %aI%n%n%B", ref}
+	out, err := g.run(withPaths(args, p.Paths)...)
+	if err != nil {
+		return "", err
+	}
+	return boundOutput(out, maxGitOutput, "narrow with paths"), nil
+}
+
+// --- branch / commit / checkout ---
+
+func (g gitRunner) branch(p gitParams) (string, error) {
+	switch p.Action {
+	case "", "list":
+		args := []string{"branch", "--no-color",
+			"--format=%(HEAD)\x1f%(refname:short)\x1f%(upstream:short)\x1f%(upstream:track)\x1f%(objectname:short)\x1f%(contents:subject)"}
+		if p.All {
+			args = append(args, "--all")
+		}
+		raw, err := g.run(args...)
+		if err != nil {
+			return "", err
+		}
+		var lines []string
+		for _, line := range strings.Split(strings.TrimSpace(raw), "\n") {
+			f := strings.Split(line, "\x1f")
+			if len(f) != 6 {
+				continue
+			}
+			s := f[1] + " " + f[4]
+			if f[0] == "*" {
+				s = "* " + s
+			}
+			if f[2] != "" {
+				track := f[2]
+				if f[3] != "" {
+					track += ": " + strings.Trim(f[3], "[]")
+				}
+				s += " [" + track + "]"
+			}
+			lines = append(lines, s+" "+f[5])
+		}
+		if len(lines) == 0 {
+			return "No branches.", nil
+		}
+		var sb strings.Builder
+		writeGitList(&sb, "Branches", lines)
+		return sb.String(), nil
+	case "create":
+		if p.Name == "" {
+			return "", fmt.Errorf("name is required")
+		}
+		args := []string{"branch", p.Name}
+		if p.Ref != "" {
+			args = append(args, p.Ref)
+		}
+		if _, err := g.run(args...); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Created branch %s.", p.Name), nil
+	case "delete":
+		if p.Name == "" {
+			return "", fmt.Errorf("name is required")
+		}
+		flag := "-d"
+		if p.Force {
+			flag = "-D"
+		}
+		out, err := g.run("branch", flag, p.Name)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(out), nil
+	}
+	return "", fmt.Errorf("unknown branch action %q (use list, create or delete)", p.Action)
+}
+
+func (g gitRunner) commit(p gitParams) (string, error) {
+	if strings.TrimSpace(p.Message) == "" {
+		return "", fmt.Errorf("message is required")
+	}
+	if len(p.Paths) > 0 {
+		if _, err := g.run(append([]string{"add", "--"}, p.Paths...)...); err != nil {
+			return "", err
+		}
+	}
+	args := []string{"commit", "-m", p.Message}
+	if p.All {
+		args = append(args, "-a")
+	}
+	if _, err := g.run(args...); err != nil {
+		return "", err
+	}
+	out, err := g.run("show", "--no-color", "--stat", "--format=Committed %h on %D%n%s%n", "HEAD")
+	if err != nil {
+		return "Committed.", nil
+	}
+	return boundOutput(strings.TrimSpace(out), maxGitOutput, ""), nil
+}
+
+func (g gitRunner) checkout(p gitParams) (string, error) {
+	var args []string
+	switch {
+	case len(p.Paths) > 0:
+		args = []string{"checkout"}
+		if p.Ref != "" {
+			args = append(args, p.Ref)
+		}
+		args = append(append(args, "--"), p.Paths...)
+	case p.Create:
+		if p.Name == "" {
+			return "", fmt.Errorf("name is required to create a branch")
+		}
+		args = []string{"checkout", "-b", p.Name}
+		if p.Ref != "" {
+			args = append(args, p.Ref)
+		}
+	default:
+		if p.Ref == "" {
+			return "", fmt.Errorf("ref is required")
+		}
+		args = []string{"checkout"}
+		if p.Force {
+			args = append(args, "-f")
+		}
+		args = append(args, p.Ref)
+	}
+	if _, err := g.run(args...); err != nil {
+		return "", err
+	}
+	if len(p.Paths) > 0 {
+		return fmt.Sprintf("Restored %d path(s) from %s.", len(p.Paths), orDefault(p.Ref, "the index")), nil
+	}
+	head, _ := g.run("rev-parse", "--abbrev-ref", "HEAD")
+	return fmt.Sprintf("Now on %s.", strings.TrimSpace(head)), nil
+}
+
+// --- stash / reset / clean / push ---
+
+func (g gitRunner) stash(p gitParams) (string, error) {
+	switch p.Action {
+	case "", "push":
+		args := []string{"stash", "push"}
+		if p.IncludeUntracked {
+			args = append(args, "--include-untracked")
+		}
+		if p.Message != "" {
+			args = append(args, "-m", p.Message)
+		}
+		out, err := g.run(withPaths(args, p.Paths)...)
+		return strings.TrimSpace(out), err
+	case "list":
+		out, err := g.run("stash", "list", "--format=%gd %cr %gs")
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(out) == "" {
+			return "No stashes.", nil
+		}
+		return boundOutput(out, maxGitOutput, ""), nil
+	case "show":
+		args := []string{"stash", "show", "--stat", "--patch", "--no-color"}
+		if p.Ref != "" {
+			args = append(args, p.Ref)
+		}
+		out, err := g.run(args...)
+		return boundOutput(out, maxGitOutput, ""), err
+	case "apply", "pop", "drop":
+		args := []string{"stash", p.Action}
+		if p.Ref != "" {
+			args = append(args, p.Ref)
+		}
+		out, err := g.run(args...)
+		return boundOutput(strings.TrimSpace(out), maxGitOutput, ""), err
+	case "clear":
+		_, err := g.run("stash", "clear")
+		return "All stashes removed.", err
+	}
+	return "", fmt.Errorf("unknown stash action %q (use push, list, show, apply, pop, drop or clear)", p.Action)
+}
+
+func (g gitRunner) reset(p gitParams) (string, error) {
+	mode := p.Mode
+	if mode == "" {
+		mode = "mixed"
+	}
+	var args []string
+	if len(p.Paths) > 0 {
+		if mode != "mixed" {
+			return "", fmt.Errorf("paths can only be used with mode mixed (unstage)")
+		}
+		args = []string{"reset", "-q"}
+		if p.Ref != "" {
+			args = append(args, p.Ref)
+		}
+		args = append(append(args, "--"), p.Paths...)
+	} else {
+		args = []string{"reset", "-q", "--" + mode}
+		if p.Ref != "" {
+			args = append(args, p.Ref)
+		}
+	}
+	if _, err := g.run(args...); err != nil {
+		return "", err
+	}
+	head, _ := g.run("log", "-1", "--format=%h %s")
+	return fmt.Sprintf("Reset (%s). HEAD is now %s", mode, strings.TrimSpace(head)), nil
+}
+
+func (g gitRunner) clean(p gitParams) (string, error) {
+	flag := "-fd"
+	if p.DryRun {
+		flag = "-nd"
+	}
+	out, err := g.run(withPaths([]string{"clean", flag}, p.Paths)...)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(out) == "" {
+		return "Nothing to clean.", nil
+	}
+	return boundOutput(out, maxGitOutput, ""), nil
+}
+
+func (g gitRunner) push(p gitParams) (string, error) {
+	args := []string{"push", "--porcelain"}
+	if p.Force {
+		args = append(args, "--force-with-lease")
+	}
+	if p.Remote != "" {
+		args = append(args, p.Remote)
+		if p.Ref != "" {
+			args = append(args, p.Ref)
+		}
+	} else if p.Ref != "" {
+		return "", fmt.Errorf("remote is required when ref is given")
+	}
+	out, err := g.run(args...)
+	if err != nil {
+		return "", err
+	}
+	return boundOutput(strings.TrimSpace(out), maxGitOutput, ""), nil
+}
@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitTool(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	for k, v := range map[string]string{
+		"GIT_AUTHOR_NAME": "Test", "GIT_AUTHOR_EMAIL": "t@example.com",
+		"GIT_COMMITTER_NAME": "Test", "GIT_COMMITTER_EMAIL": "t@example.com",
+		"GIT_CONFIG_GLOBAL": "/dev/null",
+	} {
+		t.Setenv(k, v)
+	}
+	if out, err := exec.Command("git", "init", "-q", "-b", "main", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v %s", err, out)
+	}
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\n"), 0o644)
+
+	r := NewToolRegistry()
+	r.Register(NewGitTool(NewWorkspace(dir, "shared"), nil))
+	user := &ToolAuthContext{CallerChannel: "telegram", CallerChatID: 5}
+	control := &ToolAuthContext{CallerChannel: "telegram", CallerChatID: 1, ControlChatIDs: []int64{1}}
+
+	tests := []struct {
+		name    string
+		auth    *ToolAuthContext
+		input   string
+		isError bool
+		want    string
+	}{
+		{"untracked file", user, `{"operation":"status"}`, false, "Untracked (1):\n  a.txt"},
+		{"commit", user, `{"operation":"commit","message":"first","paths":["a.txt"]}`, false, "first"},
+		{"clean tree", user, `{"operation":"status"}`, false, "Branch: main\nWorking tree clean."},
+		{"log", user, `{"operation":"log"}`, false, "Test (HEAD -> main): first"},
+		{"option injection", user, `{"operation":"log","ref":"--output=/tmp/x"}`, true, "invalid reference"},
+		{"hard reset denied", user, `{"operation":"reset","mode":"hard"}`, true, "high risk"},
+		{"hard reset in control chat", control, `{"operation":"reset","mode":"hard"}`, false, "Reset (hard)"},
+		{"clean dry run", user, `{"operation":"clean","dry_run":true}`, false, "Nothing to clean."},
+		{"force refspec denied", user, `{"operation":"push","remote":"origin","ref":"+main"}`, true, "high risk"},
+		{"force refspec with destination denied", user, `{"operation":"push","remote":"origin","ref":"+HEAD:main"}`, true, "high risk"},
+		{"forced destination denied", user, `{"operation":"push","remote":"origin","ref":"HEAD:+main"}`, true, "high risk"},
+		{"branch delete refspec denied", user, `{"operation":"push","remote":"origin","ref":":main"}`, true, "high risk"},
+		{"plain push without remote", user, `{"operation":"push","ref":"main"}`, true, "remote is required"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res := r.ExecuteWithAuth(context.Background(), "git", json.RawMessage(tc.input), tc.auth)
+			if res.IsError != tc.isError {
+				t.Fatalf("IsError = %v, want %v: %s", res.IsError, tc.isError, res.Content)
+			}
+			if !strings.Contains(res.Content, tc.want) {
+				t.Errorf("result missing %q:\n%s", tc.want, res.Content)
+			}
+		})
+	}
+
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\ntwo\n"), 0o644)
+	res := r.ExecuteWithAuth(context.Background(), "git", json.RawMessage(`{"operation":"diff"}`), user)
+	if !strings.Contains(res.Content, "1 file(s) changed, +1 -0") || !strings.Contains(res.Content, "+two") {
+		t.Errorf("unexpected diff:\n%s", res.Content)
+	}
+}
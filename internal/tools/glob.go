@@ -16,11 +16,12 @@ import (
 const globCap = 500
 
 type GlobTool struct {
-	workingDir string
+	workspace *Workspace
+	policy    *PathPolicy
 }
 
-func NewGlobTool(workingDir string) *GlobTool {
-	return &GlobTool{workingDir: workingDir}
+func NewGlobTool(workspace *Workspace, policy *PathPolicy) *GlobTool {
+	return &GlobTool{workspace: workspace, policy: policy}
 }
 
 func (t *GlobTool) Name() string { return "glob" }
@@ -48,9 +49,13 @@ func (t *GlobTool) Execute(_ context.Context, input json.RawMessage) ToolResult
 		return Error("pattern is required")
 	}
 
-	base := t.workingDir
-	if params.Path != "" {
-		base = resolvePath(t.workingDir, params.Path)
+	auth := ExtractAuthContext(input)
+	base, err := t.workspace.Resolve(auth, params.Path)
+	if err != nil {
+		return Error(err.Error())
+	}
+	if err := t.policy.Enforce(auth, t.Name(), base, false); err != nil {
+		return Error(err.Error())
 	}
 
 	// Use doublestar to match files.
@@ -68,7 +73,7 @@ func (t *GlobTool) Execute(_ context.Context, input json.RawMessage) ToolResult
 		if containsHiddenDir(m) && !strings.HasPrefix(params.Pattern, ".") {
 			continue
 		}
-		if IsBlocked(abs) {
+		if !t.policy.Allows(abs, false) {
 			continue
 		}
 		results = append(results, abs)
@@ -25,11 +25,12 @@ var skipDirs = map[string]bool{
 }
 
 type GrepTool struct {
-	workingDir string
+	workspace *Workspace
+	policy    *PathPolicy
 }
 
-func NewGrepTool(workingDir string) *GrepTool {
-	return &GrepTool{workingDir: workingDir}
+func NewGrepTool(workspace *Workspace, policy *PathPolicy) *GrepTool {
+	return &GrepTool{workspace: workspace, policy: policy}
 }
 
 func (t *GrepTool) Name() string { return "grep" }
@@ -64,9 +65,13 @@ func (t *GrepTool) Execute(ctx context.Context, input json.RawMessage) ToolResul
 		return Error(fmt.Sprintf("invalid regex: %v", err))
 	}
 
-	base := t.workingDir
-	if params.Path != "" {
-		base = resolvePath(t.workingDir, params.Path)
+	auth := ExtractAuthContext(input)
+	base, err := t.workspace.Resolve(auth, params.Path)
+	if err != nil {
+		return Error(err.Error())
+	}
+	if err := t.policy.Enforce(auth, t.Name(), base, false); err != nil {
+		return Error(err.Error())
 	}
 
 	info, err := os.Stat(base)
@@ -95,7 +100,7 @@ func (t *GrepTool) Execute(ctx context.Context, input json.RawMessage) ToolResul
 				}
 				return nil
 			}
-			if IsBlocked(path) {
+			if !t.policy.Allows(path, false) {
 				return nil
 			}
 			if params.Glob != "" {
@@ -0,0 +1,334 @@
+package tools
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// noiseRe matches class/id values of page chrome that is never main content.
+var noiseRe = regexp.MustCompile(`(?i)(^|[-_ ])(nav|navbar|menu|sidebar|footer|masthead|breadcrumbs?|cookie|consent|banner|promo|advert|ads?|share|social|related|popup|modal|newsletter|subscribe|skip)($|[-_ ])`)
+
+// commentsRe matches a whole class or id token naming a comment section.
+// Partial matches such as "comment-body" or "has-comments" are left alone,
+// since article wrappers often carry them.
+var commentsRe = regexp.MustCompile(`(?i)^(comments?|comments?[-_](section|area|list|thread|wrapper|container)|disqus_thread)$`)
+
+var blankLinesRe = regexp.MustCompile(`\n{3,}`)
+
+// htmlToMarkdown extracts the main content of an HTML page, readability
+// style, and renders it as Markdown. Links and images are resolved against
+// base. It returns the page title and the Markdown body.
+func htmlToMarkdown(page string, base *url.URL) (string, string) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		return "", page
+	}
+	title := strings.TrimSpace(doc.Find("title").First().Text())
+
+	// Forms are kept: ASP.NET and similar pages wrap the whole body in one.
+	// Only the controls themselves are dropped.
+	doc.Find("script, style, noscript, template, svg, canvas, iframe, input, select, textarea, button, nav, footer, aside, [hidden], [aria-hidden=true]").Remove()
+	doc.Find("[class], [id]").Each(func(_ int, s *goquery.Selection) {
+		if goquery.NodeName(s) == "body" || goquery.NodeName(s) == "main" || goquery.NodeName(s) == "article" {
+			return
+		}
+		class, _ := s.Attr("class")
+		id, _ := s.Attr("id")
+		if noiseRe.MatchString(class) || noiseRe.MatchString(id) || isCommentSection(class, id) {
+			s.Remove()
+		}
+	})
+
+	root := mainContent(doc)
+	r := &mdRenderer{base: base}
+	r.render(root)
+	md := strings.TrimSpace(blankLinesRe.ReplaceAllString(r.sb.String(), "\n\n"))
+	return title, md
+}
+
+// isCommentSection reports whether any class token or the id names a
+// comment section.
+func isCommentSection(class, id string) bool {
+	if commentsRe.MatchString(id) {
+		return true
+	}
+	for _, tok := range strings.Fields(class) {
+		if commentsRe.MatchString(tok) {
+			return true
+		}
+	}
+	return false
+}
+
+// mainContent picks the node most likely to hold the article body: an
+// explicit <article>/<main> when it carries most of the text, otherwise the
+// element whose paragraphs score highest, penalized by link density.
+func mainContent(doc *goquery.Document) *html.Node {
+	body := doc.Find("body")
+	if body.Length() == 0 {
+		return doc.Selection.Nodes[0]
+	}
+	bodyLen := len(strings.TrimSpace(body.Text()))
+
+	for _, sel := range []string{"article", "main", "[role=main]"} {
+		s := doc.Find(sel)
+		if s.Length() == 1 && bodyLen > 0 && len(strings.TrimSpace(s.Text()))*2 >= bodyLen {
+			return s.Nodes[0]
+		}
+	}
+
+	scores := make(map[*html.Node]float64)
+	doc.Find("p, pre, td, blockquote, li").Each(func(_ int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Text())
+		if len(text) < 25 {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")) + float64(min(len(text)/100, 3))
+		parent := s.Parent()
+		if parent.Length() == 0 {
+			return
+		}
+		scores[parent.Nodes[0]] += score
+		if gp := parent.Parent(); gp.Length() > 0 {
+			scores[gp.Nodes[0]] += score / 2
+		}
+	})
+
+	var best *html.Node
+	bestScore := 0.0
+	for n, score := range scores {
+		s := goquery.NewDocumentFromNode(n).Selection
+		textLen := len(s.Text())
+		if textLen == 0 {
+			continue
+		}
+		linkLen := len(s.Find("a").Text())
+		score *= 1 - float64(linkLen)/float64(textLen)
+		if score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	if best == nil {
+		return body.Nodes[0]
+	}
+	return best
+}
+
+// mdRenderer renders an HTML subtree to Markdown.
+type mdRenderer struct {
+	base  *url.URL
+	sb    strings.Builder
+	lists []listState
+	quote int
+	inPre bool
+}
+
+type listState struct {
+	ordered bool
+	n       int
+}
+
+func (r *mdRenderer) write(s string) {
+	if r.quote > 0 && strings.Contains(s, "\n") {
+		s = strings.ReplaceAll(s, "\n", "\n"+strings.Repeat("> ", r.quote))
+	}
+	r.sb.WriteString(s)
+}
+
+// block starts a new paragraph-level block.
+func (r *mdRenderer) block() {
+	r.write("\n\n")
+}
+
+func (r *mdRenderer) render(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if r.inPre {
+			r.write(n.Data)
+			return
+		}
+		text := strings.Join(strings.Fields(n.Data), " ")
+		if text == "" {
+			if strings.TrimSpace(n.Data) == "" && n.Data != "" && !strings.HasSuffix(r.sb.String(), " ") {
+				r.write(" ")
+			}
+			return
+		}
+		if n.Data[0] == ' ' || n.Data[0] == '\n' || n.Data[0] == '\t' {
+			text = " " + text
+		}
+		if last := n.Data[len(n.Data)-1]; last == ' ' || last == '\n' || last == '\t' {
+			text += " "
+		}
+		r.write(text)
+		return
+	case html.ElementNode:
+	default:
+		r.children(n)
+		return
+	}
+
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		r.block()
+		r.write(strings.Repeat("#", int(n.Data[1]-'0')) + " " + inlineText(n))
+		r.block()
+	case "p", "div", "section", "article", "main", "header", "figure", "figcaption", "dl", "dt", "dd":
+		r.block()
+		r.children(n)
+		r.block()
+	case "br":
+		r.write("\n")
+	case "hr":
+		r.block()
+		r.write("---")
+		r.block()
+	case "a":
+		href := r.resolve(attr(n, "href"))
+		text := strings.TrimSpace(inlineText(n))
+		switch {
+		case text == "":
+		case href == "" || strings.HasPrefix(href, "javascript:") || strings.HasPrefix(href, "#"):
+			r.write(text)
+		default:
+			r.write("[" + text + "](" + href + ")")
+		}
+	case "img":
+		if src := r.resolve(attr(n, "src")); src != "" && !strings.HasPrefix(src, "data:") {
+			r.write("![" + attr(n, "alt") + "](" + src + ")")
+		}
+	case "strong", "b":
+		r.wrapInline(n, "**")
+	case "em", "i":
+		r.wrapInline(n, "_")
+	case "code":
+		if r.inPre {
+			r.children(n)
+		} else {
+			r.write("`" + inlineText(n) + "`")
+		}
+	case "pre":
+		r.block()
+		r.write("```\n")
+		r.inPre = true
+		r.children(n)
+		r.inPre = false
+		r.write("\n```")
+		r.block()
+	case "blockquote":
+		r.block()
+		r.quote++
+		r.write("> ")
+		r.children(n)
+		r.quote--
+		r.block()
+	case "ul", "ol":
+		nested := len(r.lists) > 0
+		if !nested {
+			r.block()
+		}
+		r.lists = append(r.lists, listState{ordered: n.Data == "ol"})
+		r.children(n)
+		r.lists = r.lists[:len(r.lists)-1]
+		if !nested {
+			r.block()
+		}
+	case "li":
+		indent := ""
+		marker := "- "
+		if depth := len(r.lists); depth > 0 {
+			indent = strings.Repeat("  ", depth-1)
+			if l := &r.lists[depth-1]; l.ordered {
+				l.n++
+				marker = fmt.Sprintf("%d. ", l.n)
+			}
+		}
+		r.write("\n" + indent + marker)
+		r.children(n)
+	case "table":
+		r.block()
+		r.table(n)
+		r.block()
+	default:
+		r.children(n)
+	}
+}
+
+func (r *mdRenderer) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.render(c)
+	}
+}
+
+func (r *mdRenderer) wrapInline(n *html.Node, marker string) {
+	text := strings.TrimSpace(inlineText(n))
+	if text == "" {
+		return
+	}
+	r.write(marker + text + marker)
+}
+
+// table renders an HTML table as a Markdown pipe table, using the first row
+// as the header.
+func (r *mdRenderer) table(n *html.Node) {
+	var rows [][]string
+	s := goquery.NewDocumentFromNode(n).Selection
+	s.Find("tr").Each(func(_ int, tr *goquery.Selection) {
+		var row []string
+		tr.ChildrenFiltered("th, td").Each(func(_ int, cell *goquery.Selection) {
+			sub := &mdRenderer{base: r.base}
+			sub.children(cell.Nodes[0])
+			text := strings.Join(strings.Fields(sub.sb.String()), " ")
+			row = append(row, strings.ReplaceAll(text, "|", `\|`))
+		})
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+	})
+	if len(rows) == 0 {
+		return
+	}
+	cols := 0
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	for i, row := range rows {
+		for len(row) < cols {
+			row = append(row, "")
+		}
+		r.write("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			r.write("|" + strings.Repeat(" --- |", cols) + "\n")
+		}
+	}
+}
+
+func (r *mdRenderer) resolve(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || r.base == nil {
+		return ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return r.base.ResolveReference(u).String()
+}
+
+func inlineText(n *html.Node) string {
+	return strings.Join(strings.Fields(goquery.NewDocumentFromNode(n).Text()), " ")
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,356 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yifanes/miniclawd/internal/core"
+	"github.com/yifanes/miniclawd/internal/netguard"
+)
+
+const (
+	defaultHTTPResponseLength = 20000
+	maxHTTPResponseBody       = 5 * 1024 * 1024
+	maxHTTPTimeout            = 120
+)
+
+// HTTPCredential is a named secret injected into http_request calls. The
+// model refers to it by alias only and never sees the value.
+type HTTPCredential struct {
+	Hosts      []string // hosts the credential may be sent to (required)
+	Header     string   // header to set, default "Authorization"
+	Scheme     string   // value prefix, e.g. "Bearer"; empty sends the raw value
+	QueryParam string   // send as a query parameter instead of a header
+	Value      string
+	AllowHTTP  bool // also send over plain http; otherwise https only
+}
+
+func (c HTTPCredential) allowsScheme(scheme string) bool {
+	return scheme == "https" || c.AllowHTTP && scheme == "http"
+}
+
+// HTTPRequestConfig configures the http_request tool.
+type HTTPRequestConfig struct {
+	AllowHosts  []string // hosts the tool may call; empty allows any public host
+	Credentials map[string]HTTPCredential
+}
+
+type HTTPRequestTool struct {
+	guard       *netguard.Policy
+	allowHosts  []string
+	credentials map[string]HTTPCredential
+}
+
+// NewHTTPRequestTool creates the http_request tool. Credentials without any
+// hosts are dropped, since they could otherwise be sent anywhere.
+func NewHTTPRequestTool(cfg HTTPRequestConfig, guard *netguard.Policy) *HTTPRequestTool {
+	creds := make(map[string]HTTPCredential, len(cfg.Credentials))
+	for alias, c := range cfg.Credentials {
+		if len(c.Hosts) == 0 || c.Value == "" {
+			log.Printf("[tools] http_request: credential %q has no hosts or value; ignoring", alias)
+			continue
+		}
+		creds[alias] = c
+	}
+	return &HTTPRequestTool{guard: guard, allowHosts: cfg.AllowHosts, credentials: creds}
+}
+
+func (t *HTTPRequestTool) Name() string { return "http_request" }
+
+func (t *HTTPRequestTool) Definition() core.ToolDefinition {
+	desc := "Make an HTTP request to an API and return the status, key headers and body (JSON is pretty-printed). " +
+		"To authenticate, pass a credential alias; the secret is added server-side and never shown to you. " +
+		"Do not put tokens in headers or URLs yourself."
+	if len(t.credentials) > 0 {
+		desc += " Available credentials: " + t.describeCredentials()
+	}
+	if len(t.allowHosts) > 0 {
+		desc += " Allowed hosts: " + strings.Join(t.allowHosts, ", ") + "."
+	}
+	return MakeDef("http_request", desc,
+		map[string]any{
+			"method": EnumProp("HTTP method (default GET)", []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"}),
+			"url":    StringProp("The request URL"),
+			"headers": map[string]any{
+				"type":                 "object",
+				"description":          "Extra request headers",
+				"additionalProperties": map[string]any{"type": "string"},
+			},
+			"query": map[string]any{
+				"type":                 "object",
+				"description":          "Query parameters added to the URL",
+				"additionalProperties": map[string]any{"type": "string"},
+			},
+			"json":         map[string]any{"description": "JSON request body; sets Content-Type: application/json"},
+			"body":         StringProp("Raw request body (ignored when json is given)"),
+			"credential":   StringProp("Alias of a configured credential to authenticate with"),
+			"extract":      StringProp("Dot path into a JSON response to return only that part, e.g. 'data.items.0.id'"),
+			"timeout_secs": IntProp("Request timeout in seconds (default 30, max 120)"),
+			"max_length":   IntProp("Maximum characters of body to return (default 20000, max 100000)"),
+		},
+		[]string{"url"},
+	)
+}
+
+func (t *HTTPRequestTool) describeCredentials() string {
+	aliases := make([]string, 0, len(t.credentials))
+	for alias := range t.credentials {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	parts := make([]string, len(aliases))
+	for i, alias := range aliases {
+		parts[i] = fmt.Sprintf("%s (%s)", alias, strings.Join(t.credentials[alias].Hosts, ", "))
+	}
+	return strings.Join(parts, "; ") + "."
+}
+
+func (t *HTTPRequestTool) Execute(ctx context.Context, input json.RawMessage) ToolResult {
+	var params struct {
+		Method      string            `json:"method"`
+		URL         string            `json:"url"`
+		Headers     map[string]string `json:"headers"`
+		Query       map[string]string `json:"query"`
+		JSON        json.RawMessage   `json:"json"`
+		Body        string            `json:"body"`
+		Credential  string            `json:"credential"`
+		Extract     string            `json:"extract"`
+		TimeoutSecs int               `json:"timeout_secs"`
+		MaxLength   int               `json:"max_length"`
+	}
+	if err := json.Unmarshal(input, &params); err != nil {
+		return Error("invalid input: " + err.Error())
+	}
+	if params.URL == "" {
+		return Error("url is required")
+	}
+	method := strings.ToUpper(params.Method)
+	if method == "" {
+		method = "GET"
+	}
+	timeout := params.TimeoutSecs
+	if timeout <= 0 {
+		timeout = 30
+	}
+	if timeout > maxHTTPTimeout {
+		timeout = maxHTTPTimeout
+	}
+	maxLen := params.MaxLength
+	if maxLen <= 0 {
+		maxLen = defaultHTTPResponseLength
+	}
+	if maxLen > maxFetchLength {
+		maxLen = maxFetchLength
+	}
+
+	u, err := url.Parse(params.URL)
+	if err != nil {
+		return Error(fmt.Sprintf("invalid url: %v", err))
+	}
+	if err := t.checkHost(u); err != nil {
+		return ErrorWithType(err.Error(), "blocked")
+	}
+	if len(params.Query) > 0 {
+		q := u.Query()
+		for k, v := range params.Query {
+			q.Set(k, v)
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	var cred *HTTPCredential
+	if params.Credential != "" {
+		c, ok := t.credentials[params.Credential]
+		if !ok {
+			return Error(fmt.Sprintf("unknown credential %q", params.Credential))
+		}
+		if !netguard.MatchHost(c.Hosts, u.Hostname()) {
+			return ErrorWithType(fmt.Sprintf("credential %q may not be sent to %s", params.Credential, u.Hostname()), "blocked")
+		}
+		if !c.allowsScheme(u.Scheme) {
+			return ErrorWithType(fmt.Sprintf("credential %q is only sent over https", params.Credential), "blocked")
+		}
+		cred = &c
+		if c.QueryParam != "" {
+			q := u.Query()
+			q.Set(c.QueryParam, c.Value)
+			u.RawQuery = q.Encode()
+		}
+	}
+
+	var body io.Reader
+	contentType := ""
+	switch {
+	case len(params.JSON) > 0 && string(params.JSON) != "null":
+		body = bytes.NewReader(params.JSON)
+		contentType = "application/json"
+	case params.Body != "":
+		body = strings.NewReader(params.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return Error(fmt.Sprintf("invalid request: %v", err))
+	}
+	if err := t.guard.CheckURL(req.URL); err != nil {
+		return ErrorWithType(err.Error(), "blocked")
+	}
+	req.Header.Set("User-Agent", "MiniClawd/1.0")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for k, v := range params.Headers {
+		req.Header.Set(k, v)
+	}
+	if cred != nil && cred.QueryParam == "" {
+		value := cred.Value
+		if cred.Scheme != "" {
+			value = cred.Scheme + " " + value
+		}
+		req.Header.Set(orDefault(cred.Header, "Authorization"), value)
+	}
+
+	client := t.guard.Client(time.Duration(timeout) * time.Second)
+	client.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+		if len(via) >= maxFetchRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxFetchRedirects)
+		}
+		if err := t.checkHost(next.URL); err != nil {
+			return err
+		}
+		// Never follow a redirect off the credential's hosts, or down to
+		// plain http, with it attached.
+		if cred != nil && (!netguard.MatchHost(cred.Hosts, next.URL.Hostname()) || !cred.allowsScheme(next.URL.Scheme)) {
+			next.Header.Del(orDefault(cred.Header, "Authorization"))
+		}
+		return t.guard.CheckURL(next.URL)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		var blocked *netguard.BlockedError
+		if errors.As(err, &blocked) {
+			return ErrorWithType(t.redact(fmt.Sprintf("request error: %v", blocked)), "blocked")
+		}
+		return Error(t.redact(fmt.Sprintf("request error: %v", err)))
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponseBody))
+	if err != nil {
+		return Error(fmt.Sprintf("read error: %v", err))
+	}
+	elapsed := time.Since(start).Milliseconds()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s\nHTTP %s (%d ms)\n", method, t.redact(resp.Request.URL.String()), resp.Status, elapsed)
+	for _, h := range []string{"Content-Type", "Content-Length", "Location", "Retry-After", "X-RateLimit-Remaining", "Link", "ETag"} {
+		if v := resp.Header.Get(h); v != "" {
+			fmt.Fprintf(&sb, "%s: %s\n", h, t.redact(v))
+		}
+	}
+
+	text, err := formatHTTPBody(raw, resp.Header.Get("Content-Type"), params.Extract)
+	if err != nil {
+		return Error(err.Error())
+	}
+	text = t.redact(text)
+	if len(text) > maxLen {
+		text = text[:core.FloorCharBoundary(text, maxLen)] + fmt.Sprintf("\n... (truncated, %d characters total)", len(text))
+	}
+	if text != "" {
+		sb.WriteString("\n" + text)
+	}
+
+	out := sb.String()
+	code := resp.StatusCode
+	result := ToolResult{Content: out, StatusCode: &code, Bytes: len(out), DurationMs: &elapsed}
+	if code >= 400 {
+		et := "http_status"
+		result.IsError = true
+		result.ErrorType = &et
+	}
+	return result
+}
+
+// checkHost applies the tool's host allowlist.
+func (t *HTTPRequestTool) checkHost(u *url.URL) error {
+	if len(t.allowHosts) == 0 || netguard.MatchHost(t.allowHosts, u.Hostname()) {
+		return nil
+	}
+	return &netguard.BlockedError{Host: u.Hostname(), Reason: "host is not in the http_request allowlist"}
+}
+
+// redact replaces any credential value echoed back by the server.
+func (t *HTTPRequestTool) redact(s string) string {
+	for alias, c := range t.credentials {
+		if len(c.Value) >= 4 {
+			s = strings.ReplaceAll(s, c.Value, "[credential:"+alias+"]")
+			s = strings.ReplaceAll(s, url.QueryEscape(c.Value), "[credential:"+alias+"]")
+		}
+	}
+	return s
+}
+
+// formatHTTPBody pretty-prints JSON bodies and applies the extract path.
+func formatHTTPBody(raw []byte, contentType, extract string) (string, error) {
+	var doc any
+	isJSON := strings.Contains(strings.ToLower(contentType), "json") || json.Valid(bytes.TrimSpace(raw))
+	if !isJSON || len(bytes.TrimSpace(raw)) == 0 || json.Unmarshal(raw, &doc) != nil {
+		if extract != "" {
+			return "", fmt.Errorf("extract requires a JSON response")
+		}
+		return string(raw), nil
+	}
+	if extract != "" {
+		node, err := jsonPath(doc, extract)
+		if err != nil {
+			return "", err
+		}
+		doc = node
+	}
+	if s, ok := doc.(string); ok && extract != "" {
+		return s, nil
+	}
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return string(raw), nil
+	}
+	return string(out), nil
+}
+
+// jsonPath walks a dot path such as "data.items.0.id" through decoded JSON.
+func jsonPath(doc any, path string) (any, error) {
+	node := doc
+	for _, key := range strings.Split(path, ".") {
+		switch n := node.(type) {
+		case map[string]any:
+			v, ok := n[key]
+			if !ok {
+				return nil, fmt.Errorf("extract path %q: key %q not found", path, key)
+			}
+			node = v
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil, fmt.Errorf("extract path %q: invalid index %q for array of %d", path, key, len(n))
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("extract path %q: cannot descend into %q", path, key)
+		}
+	}
+	return node, nil
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yifanes/miniclawd/internal/netguard"
+)
+
+func TestHTTPRequestCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"method": r.Method,
+			"auth":   r.Header.Get("Authorization"),
+			"key":    r.URL.Query().Get("api_key"),
+			"body":   string(body),
+			"items":  []map[string]any{{"id": 7}},
+		})
+	}))
+	defer srv.Close()
+
+	guard := netguard.New(netguard.Config{AllowCIDRs: []string{"127.0.0.0/8"}})
+	tool := NewHTTPRequestTool(HTTPRequestConfig{
+		AllowHosts: []string{"127.0.0.1"},
+		Credentials: map[string]HTTPCredential{
+			"api":    {Hosts: []string{"127.0.0.1"}, Scheme: "Bearer", Value: "s3cret-token", AllowHTTP: true},
+			"query":  {Hosts: []string{"127.0.0.1"}, QueryParam: "api_key", Value: "q-secret/1", AllowHTTP: true},
+			"remote": {Hosts: []string{"api.example.com"}, Value: "other-secret"},
+			"tls":    {Hosts: []string{"127.0.0.1"}, Value: "tls-secret"},
+		},
+	}, guard)
+
+	tests := []struct {
+		name    string
+		input   string
+		isError bool
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "header credential is injected and redacted",
+			input:   `{"method":"POST","url":"` + srv.URL + `","credential":"api","json":{"a":1}}`,
+			want:    []string{"HTTP 200", `"auth": "Bearer [credential:api]"`, `"method": "POST"`, `{\"a\":1}`},
+			notWant: []string{"s3cret-token"},
+		},
+		{
+			name:    "query credential",
+			input:   `{"url":"` + srv.URL + `","credential":"query"}`,
+			want:    []string{`"key": "[credential:query]"`},
+			notWant: []string{"q-secret"},
+		},
+		{
+			name:  "extract path",
+			input: `{"url":"` + srv.URL + `","extract":"items.0.id"}`,
+			want:  []string{"\n7"},
+		},
+		{
+			name:    "credential restricted to its hosts",
+			input:   `{"url":"` + srv.URL + `","credential":"remote"}`,
+			isError: true,
+			want:    []string{`credential "remote" may not be sent to 127.0.0.1`},
+		},
+		{
+			name:    "credential requires https",
+			input:   `{"url":"` + srv.URL + `","credential":"tls"}`,
+			isError: true,
+			want:    []string{`credential "tls" is only sent over https`},
+		},
+		{
+			name:    "unknown credential",
+			input:   `{"url":"` + srv.URL + `","credential":"nope"}`,
+			isError: true,
+		},
+		{
+			name:    "host not in allowlist",
+			input:   `{"url":"https://example.org/"}`,
+			isError: true,
+			want:    []string{"not in the http_request allowlist"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res := tool.Execute(context.Background(), json.RawMessage(tc.input))
+			if res.IsError != tc.isError {
+				t.Fatalf("IsError = %v, want %v: %s", res.IsError, tc.isError, res.Content)
+			}
+			for _, w := range tc.want {
+				if !strings.Contains(res.Content, w) {
+					t.Errorf("result missing %q:\n%s", w, res.Content)
+				}
+			}
+			for _, w := range tc.notWant {
+				if strings.Contains(res.Content, w) {
+					t.Errorf("result leaks %q:\n%s", w, res.Content)
+				}
+			}
+		})
+	}
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/yifanes/miniclawd/internal/core"
 )
 
+// maxToolNameLen is the longest tool name LLM APIs accept.
+const maxToolNameLen = 64
+
 // McpCaller is the interface for calling MCP tool servers.
 type McpCaller interface {
-	CallTool(ctx context.Context, serverName, toolName string, input json.RawMessage) (string, error)
+	CallTool(ctx context.Context, serverName, toolName string, input json.RawMessage) (string, []core.ContentBlock, error)
 }
 
 // McpBridgeTool wraps an MCP server tool as a local tool.
@@ -23,7 +27,7 @@ type McpBridgeTool struct {
 }
 
 func NewMcpBridgeTool(serverName, toolName string, def core.ToolDefinition, caller McpCaller) *McpBridgeTool {
-	fullName := fmt.Sprintf("mcp_%s_%s", serverName, toolName)
+	fullName := McpToolName(serverName, toolName)
 	// Override the definition name to be namespaced.
 	def.Name = fullName
 	return &McpBridgeTool{
@@ -35,6 +39,22 @@ func NewMcpBridgeTool(serverName, toolName string, def core.ToolDefinition, call
 	}
 }
 
+// McpToolName returns the registry name for an MCP tool,
+// mcp_<server>_<tool>, reduced to the characters and length LLM APIs accept.
+func McpToolName(serverName, toolName string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, fmt.Sprintf("mcp_%s_%s", serverName, toolName))
+	if len(name) > maxToolNameLen {
+		name = name[:maxToolNameLen]
+	}
+	return name
+}
+
 func (t *McpBridgeTool) Name() string                 { return t.fullName }
 func (t *McpBridgeTool) Definition() core.ToolDefinition { return t.def }
 
@@ -43,9 +63,11 @@ func (t *McpBridgeTool) Execute(ctx context.Context, input json.RawMessage) Tool
 		return Error("MCP caller not configured")
 	}
 
-	result, err := t.caller.CallTool(ctx, t.serverName, t.toolName, input)
+	text, blocks, err := t.caller.CallTool(ctx, t.serverName, t.toolName, StripAuthContext(input))
 	if err != nil {
 		return Error(fmt.Sprintf("MCP error: %v", err))
 	}
-	return Success(result)
+	result := Success(text)
+	result.Blocks = blocks
+	return result
 }
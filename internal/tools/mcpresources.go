@@ -0,0 +1,222 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/yifanes/miniclawd/internal/core"
+	"github.com/yifanes/miniclawd/internal/mcp"
+)
+
+// McpResources is the part of the MCP manager the resource and prompt tools
+// use.
+type McpResources interface {
+	ListResources(ctx context.Context, server string) ([]mcp.Resource, []mcp.ResourceTemplate, error)
+	ReadResource(ctx context.Context, server, uri string) (string, []core.ContentBlock, error)
+	Prompts() []mcp.Prompt
+	GetPrompt(ctx context.Context, server, name string, args map[string]string) ([]mcp.PromptMessage, error)
+}
+
+// --- mcp_list_resources ---
+
+type McpListResourcesTool struct {
+	mcp McpResources
+}
+
+func NewMcpListResourcesTool(m McpResources) *McpListResourcesTool {
+	return &McpListResourcesTool{mcp: m}
+}
+
+func (t *McpListResourcesTool) Name() string { return "mcp_list_resources" }
+
+func (t *McpListResourcesTool) Definition() core.ToolDefinition {
+	return MakeDef("mcp_list_resources",
+		"List the resources (files, records, documents) and resource templates that connected MCP servers expose. Read one with mcp_read_resource.",
+		map[string]any{
+			"server": StringProp("Only list this MCP server's resources"),
+		},
+		nil,
+	)
+}
+
+func (t *McpListResourcesTool) Execute(ctx context.Context, input json.RawMessage) ToolResult {
+	var params struct {
+		Server string `json:"server"`
+	}
+	json.Unmarshal(input, &params)
+
+	resources, templates, err := t.mcp.ListResources(ctx, params.Server)
+	if err != nil {
+		return Error(fmt.Sprintf("MCP error: %v", err))
+	}
+	if len(resources) == 0 && len(templates) == 0 {
+		return Success("No MCP resources available.")
+	}
+	var sb strings.Builder
+	for _, r := range resources {
+		fmt.Fprintf(&sb, "- [%s] %s <%s>", r.Server, r.Name, r.URI)
+		if r.MimeType != "" {
+			fmt.Fprintf(&sb, " (%s)", r.MimeType)
+		}
+		if r.Description != "" {
+			sb.WriteString(": " + r.Description)
+		}
+		sb.WriteString("\n")
+	}
+	if len(templates) > 0 {
+		sb.WriteString("\nTemplates (fill in the {placeholders} to get a URI):\n")
+		for _, tpl := range templates {
+			fmt.Fprintf(&sb, "- [%s] %s <%s>", tpl.Server, tpl.Name, tpl.URITemplate)
+			if tpl.Description != "" {
+				sb.WriteString(": " + tpl.Description)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return Success(strings.TrimRight(sb.String(), "\n"))
+}
+
+// --- mcp_read_resource ---
+
+type McpReadResourceTool struct {
+	mcp McpResources
+}
+
+func NewMcpReadResourceTool(m McpResources) *McpReadResourceTool {
+	return &McpReadResourceTool{mcp: m}
+}
+
+func (t *McpReadResourceTool) Name() string { return "mcp_read_resource" }
+
+func (t *McpReadResourceTool) Definition() core.ToolDefinition {
+	return MakeDef("mcp_read_resource",
+		"Read a resource from an MCP server by URI. Images are attached for you to view.",
+		map[string]any{
+			"server": StringProp("MCP server name"),
+			"uri":    StringProp("Resource URI, from mcp_list_resources or a filled-in template"),
+		},
+		[]string{"server", "uri"},
+	)
+}
+
+func (t *McpReadResourceTool) Execute(ctx context.Context, input json.RawMessage) ToolResult {
+	var params struct {
+		Server string `json:"server"`
+		URI    string `json:"uri"`
+	}
+	if err := json.Unmarshal(input, &params); err != nil {
+		return Error("invalid input: " + err.Error())
+	}
+
+	text, blocks, err := t.mcp.ReadResource(ctx, params.Server, params.URI)
+	if err != nil {
+		return Error(fmt.Sprintf("MCP error: %v", err))
+	}
+	result := Success(text)
+	result.Blocks = blocks
+	return result
+}
+
+// --- mcp_list_prompts ---
+
+type McpListPromptsTool struct {
+	mcp McpResources
+}
+
+func NewMcpListPromptsTool(m McpResources) *McpListPromptsTool {
+	return &McpListPromptsTool{mcp: m}
+}
+
+func (t *McpListPromptsTool) Name() string { return "mcp_list_prompts" }
+
+func (t *McpListPromptsTool) Definition() core.ToolDefinition {
+	return MakeDef("mcp_list_prompts",
+		"List the prompt templates connected MCP servers offer. Users can run them as /<server>:<prompt>; you can render one with mcp_get_prompt.",
+		map[string]any{},
+		nil,
+	)
+}
+
+func (t *McpListPromptsTool) Execute(_ context.Context, _ json.RawMessage) ToolResult {
+	prompts := t.mcp.Prompts()
+	if len(prompts) == 0 {
+		return Success("No MCP prompts available.")
+	}
+	return Success(FormatMcpPrompts(prompts))
+}
+
+// FormatMcpPrompts lists prompts as slash commands with their arguments.
+func FormatMcpPrompts(prompts []mcp.Prompt) string {
+	var sb strings.Builder
+	for i, p := range prompts {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "/%s:%s", p.Server, p.Name)
+		for _, a := range p.Arguments {
+			if a.Required {
+				fmt.Fprintf(&sb, " %s=...", a.Name)
+			} else {
+				fmt.Fprintf(&sb, " [%s=...]", a.Name)
+			}
+		}
+		if p.Description != "" {
+			sb.WriteString(" - " + p.Description)
+		}
+	}
+	return sb.String()
+}
+
+// --- mcp_get_prompt ---
+
+type McpGetPromptTool struct {
+	mcp McpResources
+}
+
+func NewMcpGetPromptTool(m McpResources) *McpGetPromptTool {
+	return &McpGetPromptTool{mcp: m}
+}
+
+func (t *McpGetPromptTool) Name() string { return "mcp_get_prompt" }
+
+func (t *McpGetPromptTool) Definition() core.ToolDefinition {
+	return MakeDef("mcp_get_prompt",
+		"Render an MCP prompt template with arguments and return its messages.",
+		map[string]any{
+			"server":    StringProp("MCP server name"),
+			"name":      StringProp("Prompt name"),
+			"arguments": map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}, "description": "Prompt arguments"},
+		},
+		[]string{"server", "name"},
+	)
+}
+
+func (t *McpGetPromptTool) Execute(ctx context.Context, input json.RawMessage) ToolResult {
+	var params struct {
+		Server    string            `json:"server"`
+		Name      string            `json:"name"`
+		Arguments map[string]string `json:"arguments"`
+	}
+	if err := json.Unmarshal(input, &params); err != nil {
+		return Error("invalid input: " + err.Error())
+	}
+
+	msgs, err := t.mcp.GetPrompt(ctx, params.Server, params.Name, params.Arguments)
+	if err != nil {
+		return Error(fmt.Sprintf("MCP error: %v", err))
+	}
+	var sb strings.Builder
+	var blocks []core.ContentBlock
+	for i, m := range msgs {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		fmt.Fprintf(&sb, "[%s]\n%s", m.Role, m.Text)
+		blocks = append(blocks, m.Blocks...)
+	}
+	result := Success(sb.String())
+	result.Blocks = blocks
+	return result
+}
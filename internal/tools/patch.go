@@ -0,0 +1,444 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Patch operation kinds.
+const (
+	patchCreate = "create"
+	patchUpdate = "update"
+	patchDelete = "delete"
+	patchRename = "rename"
+)
+
+// patchOp is a single file change, parsed either from a unified diff or from
+// the structured edit format.
+type patchOp struct {
+	kind    string
+	path    string
+	newPath string // rename target
+
+	// Unified diff changes.
+	hunks    []hunk
+	noEOLOld bool // "\ No newline at end of file" after an old line
+	noEOLNew bool // "\ No newline at end of file" after a new line
+
+	// Structured changes.
+	content *string // full content for create
+	edits   []stringEdit
+}
+
+type hunk struct {
+	header   string
+	oldStart int
+	lines    []hunkLine
+
+	// Lines the header says are still to come; only used to tell a
+	// "--- "/"+++ " file header from removed "-- " and added "++ " lines.
+	oldLeft, newLeft int
+}
+
+// complete reports whether the hunk has as many lines as its header says.
+func (h *hunk) complete() bool {
+	return h.oldLeft <= 0 && h.newLeft <= 0
+}
+
+func (h *hunk) add(op byte, text string) {
+	h.lines = append(h.lines, hunkLine{op: op, text: text})
+	if op != '+' {
+		h.oldLeft--
+	}
+	if op != '-' {
+		h.newLeft--
+	}
+}
+
+type hunkLine struct {
+	op   byte // ' ', '-' or '+'
+	text string
+}
+
+type stringEdit struct {
+	OldString  string `json:"old_string"`
+	NewString  string `json:"new_string"`
+	ReplaceAll bool   `json:"replace_all"`
+}
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// hunkCount parses a hunk header line count, which defaults to 1.
+func hunkCount(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// parseUnifiedDiff parses a (git-style or plain) unified diff into patch ops.
+// Hunk line counts in headers are mostly ignored: a hunk ends at the first
+// line that is not a context, removal, addition or "\" marker line, which
+// tolerates the miscounted headers that hand-written diffs often contain.
+// The counts only decide whether a "---"/"+++" pair inside a hunk starts the
+// next file or removes and adds lines beginning with "--" and "++".
+func parseUnifiedDiff(diff string) ([]patchOp, error) {
+	lines := strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n")
+	if n := len(lines); n > 0 && lines[n-1] == "" {
+		lines = lines[:n-1]
+	}
+
+	var ops []patchOp
+	var cur *patchOp
+	var curHunk *hunk
+	var gitOld, gitNew string
+	var renameFrom, renameTo string
+	var lastOp byte
+
+	flush := func() error {
+		if cur == nil {
+			return nil
+		}
+		if curHunk != nil {
+			cur.hunks = append(cur.hunks, *curHunk)
+			curHunk = nil
+		}
+		switch {
+		case cur.path == "" && cur.newPath == "":
+			return fmt.Errorf("file header without a path")
+		case cur.path == "":
+			cur.kind = patchCreate
+			cur.path = cur.newPath
+			cur.newPath = ""
+		case cur.newPath == "":
+			cur.kind = patchDelete
+		case cur.path != cur.newPath:
+			cur.kind = patchRename
+		default:
+			cur.kind = patchUpdate
+			cur.newPath = ""
+		}
+		if cur.kind != patchRename && cur.kind != patchDelete && len(cur.hunks) == 0 {
+			return fmt.Errorf("%s: no hunks", cur.path)
+		}
+		ops = append(ops, *cur)
+		cur = nil
+		return nil
+	}
+
+	// startFile begins a new file patch for a "diff --git" or "---" header.
+	startFile := func() error {
+		if err := flush(); err != nil {
+			return err
+		}
+		cur = &patchOp{}
+		return nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			if err := startFile(); err != nil {
+				return nil, err
+			}
+			gitOld, gitNew = parseGitHeader(line)
+			renameFrom, renameTo = "", ""
+			cur.path, cur.newPath = gitOld, gitNew
+			continue
+
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") &&
+			(curHunk == nil || curHunk.complete()):
+			if cur == nil || curHunk != nil || len(cur.hunks) > 0 {
+				if err := startFile(); err != nil {
+					return nil, err
+				}
+				gitOld, gitNew, renameFrom, renameTo = "", "", "", ""
+			}
+			cur.path = diffPath(line[4:], "a/")
+			cur.newPath = diffPath(lines[i+1][4:], "b/")
+			if renameFrom != "" && cur.path != "" {
+				cur.path = renameFrom
+			}
+			if renameTo != "" && cur.newPath != "" {
+				cur.newPath = renameTo
+			}
+			i++
+			continue
+
+		case hunkHeaderRe.MatchString(line):
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: hunk before any file header", i+1)
+			}
+			if curHunk != nil {
+				cur.hunks = append(cur.hunks, *curHunk)
+			}
+			m := hunkHeaderRe.FindStringSubmatch(line)
+			start, _ := strconv.Atoi(m[1])
+			curHunk = &hunk{header: m[0], oldStart: start, oldLeft: hunkCount(m[2]), newLeft: hunkCount(m[4])}
+			lastOp = 0
+			continue
+		}
+
+		if curHunk != nil {
+			switch {
+			case line == "":
+				// Editors and models often strip the single space of empty context lines.
+				curHunk.add(' ', "")
+				lastOp = ' '
+				continue
+			case line[0] == ' ' || line[0] == '-' || line[0] == '+':
+				curHunk.add(line[0], line[1:])
+				lastOp = line[0]
+				continue
+			case line[0] == '\\':
+				switch lastOp {
+				case '-':
+					cur.noEOLOld = true
+				case '+':
+					cur.noEOLNew = true
+				case ' ':
+					cur.noEOLOld, cur.noEOLNew = true, true
+				}
+				continue
+			}
+			cur.hunks = append(cur.hunks, *curHunk)
+			curHunk = nil
+		}
+
+		if cur == nil {
+			continue // preamble text before the first header
+		}
+		switch {
+		case strings.HasPrefix(line, "rename from "):
+			renameFrom = strings.TrimPrefix(line, "rename from ")
+			cur.path = renameFrom
+		case strings.HasPrefix(line, "rename to "):
+			renameTo = strings.TrimPrefix(line, "rename to ")
+			cur.newPath = renameTo
+		case strings.HasPrefix(line, "new file mode"):
+			cur.path = ""
+			cur.newPath = gitNew
+		case strings.HasPrefix(line, "deleted file mode"):
+			cur.path = gitOld
+			cur.newPath = ""
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("no file changes found in patch")
+	}
+
+	// Creations take their content from the added lines.
+	for i := range ops {
+		if ops[i].kind != patchCreate {
+			continue
+		}
+		var b strings.Builder
+		for _, h := range ops[i].hunks {
+			for _, l := range h.lines {
+				if l.op == '+' {
+					b.WriteString(l.text)
+					b.WriteString("\n")
+				}
+			}
+		}
+		content := b.String()
+		if ops[i].noEOLNew {
+			content = strings.TrimSuffix(content, "\n")
+		}
+		ops[i].content = &content
+		ops[i].hunks = nil
+	}
+	return ops, nil
+}
+
+// parseGitHeader extracts the paths from "diff --git a/x b/y".
+func parseGitHeader(line string) (string, string) {
+	rest := strings.TrimPrefix(line, "diff --git ")
+	if i := strings.Index(rest, " b/"); i >= 0 && strings.HasPrefix(rest, "a/") {
+		return rest[2:i], rest[i+3:]
+	}
+	parts := strings.Fields(rest)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return "", ""
+}
+
+// diffPath normalizes a ---/+++ path, mapping /dev/null to "" and stripping
+// the conventional a/ or b/ prefix and any trailing timestamp.
+func diffPath(p, prefix string) string {
+	if i := strings.IndexByte(p, '\t'); i >= 0 {
+		p = p[:i]
+	}
+	p = strings.TrimSpace(p)
+	if p == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(p, prefix)
+}
+
+// applyHunks applies hunks to text, returning the new text or one failure
+// message per hunk that could not be placed. Hunks are located at their
+// stated line first, then by searching the file, first exactly and then
+// ignoring trailing whitespace.
+func applyHunks(path, text string, op patchOp) (string, []string) {
+	lf, crlf := splitLineEndings(text)
+	hadEOL := strings.HasSuffix(text, "\n")
+	body := strings.TrimSuffix(lf, "\n")
+	var lines []string
+	if text != "" {
+		lines = strings.Split(body, "\n")
+	}
+
+	var failures []string
+	delta := 0  // line shift from previously applied hunks
+	minPos := 0 // hunks must apply after the previous one
+	for hi, h := range op.hunks {
+		var oldLines, newLines []string
+		for _, l := range h.lines {
+			if l.op != '+' {
+				oldLines = append(oldLines, l.text)
+			}
+			if l.op != '-' {
+				newLines = append(newLines, l.text)
+			}
+		}
+
+		want := h.oldStart - 1 + delta
+		if len(oldLines) == 0 {
+			want++ // "@@ -N,0" inserts after line N
+		}
+		pos := findHunk(lines, oldLines, want, minPos)
+		if pos < 0 {
+			failures = append(failures, hunkFailure(path, hi, len(op.hunks), h, oldLines, lines, want))
+			continue
+		}
+
+		out := make([]string, 0, len(lines)-len(oldLines)+len(newLines))
+		out = append(out, lines[:pos]...)
+		out = append(out, newLines...)
+		out = append(out, lines[pos+len(oldLines):]...)
+		lines = out
+		delta += len(newLines) - len(oldLines)
+		minPos = pos + len(newLines)
+	}
+	if len(failures) > 0 {
+		return "", failures
+	}
+
+	if len(lines) == 0 {
+		return "", nil
+	}
+	result := strings.Join(lines, "\n")
+	switch {
+	case op.noEOLNew:
+	case op.noEOLOld || hadEOL || text == "":
+		result += "\n"
+	}
+	return restoreLineEndings(lf, result, crlf), nil
+}
+
+// findHunk returns the index where old matches lines, or -1.
+func findHunk(lines, old []string, want, minPos int) int {
+	if want < minPos {
+		want = minPos
+	}
+	if want > len(lines) {
+		want = len(lines)
+	}
+	if len(old) == 0 {
+		return want
+	}
+	for _, eq := range []func(a, b string) bool{
+		func(a, b string) bool { return a == b },
+		func(a, b string) bool { return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t") },
+	} {
+		// Search outward from the expected position.
+		for d := 0; d <= len(lines); d++ {
+			for _, p := range []int{want - d, want + d} {
+				if d == 0 && p != want {
+					continue
+				}
+				if p >= minPos && p+len(old) <= len(lines) && linesMatch(lines[p:p+len(old)], old, eq) {
+					return p
+				}
+			}
+			if want-d < minPos && want+d+len(old) > len(lines) {
+				break
+			}
+		}
+	}
+	return -1
+}
+
+func linesMatch(a, b []string, eq func(a, b string) bool) bool {
+	for i := range b {
+		if !eq(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// hunkFailure describes a hunk that did not apply, showing the expected lines
+// next to the file's actual content around the stated position.
+func hunkFailure(path string, idx, total int, h hunk, old, lines []string, want int) string {
+	const maxShow = 8
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: hunk %d/%d (%s) failed: expected lines not found", path, idx+1, total, h.header)
+	b.WriteString("\n  expected:")
+	for i, l := range old {
+		if i == maxShow {
+			fmt.Fprintf(&b, "\n    ... (%d more)", len(old)-maxShow)
+			break
+		}
+		b.WriteString("\n    | " + l)
+	}
+	start := want
+	if start < 0 {
+		start = 0
+	}
+	if start > len(lines) {
+		start = len(lines)
+	}
+	end := start + maxShow
+	if end > len(lines) {
+		end = len(lines)
+	}
+	if start == end {
+		fmt.Fprintf(&b, "\n  file has only %d lines", len(lines))
+		return b.String()
+	}
+	fmt.Fprintf(&b, "\n  file at line %d:", start+1)
+	for i := start; i < end; i++ {
+		fmt.Fprintf(&b, "\n    %4d| %s", i+1, lines[i])
+	}
+	return b.String()
+}
+
+// applyStringEdit replaces old with new in text. Unless replaceAll is set, old
+// must occur exactly once.
+func applyStringEdit(text string, e stringEdit) (string, int, error) {
+	if e.OldString == "" {
+		return "", 0, fmt.Errorf("old_string is required")
+	}
+	count := strings.Count(text, e.OldString)
+	if count == 0 {
+		return "", 0, fmt.Errorf("old_string not found in file")
+	}
+	if e.ReplaceAll {
+		return strings.ReplaceAll(text, e.OldString, e.NewString), count, nil
+	}
+	if count > 1 {
+		return "", 0, fmt.Errorf("old_string found %d times (must be unique). Provide more context to make it unique.", count)
+	}
+	return strings.Replace(text, e.OldString, e.NewString, 1), 1, nil
+}
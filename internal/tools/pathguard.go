@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/yifanes/miniclawd/internal/storage"
 )
 
 // blockedDirs are directory names that are always blocked.
@@ -83,3 +86,139 @@ func FilterPaths(paths []string) []string {
 	}
 	return result
 }
+
+// PathPolicy confines file tools to a set of allowed roots. Paths are resolved
+// through symlinks before checking, so a link inside an allowed root cannot be
+// used to reach outside it. A nil *PathPolicy only applies CheckPath.
+type PathPolicy struct {
+	allowedRoots  []string
+	readOnlyRoots []string
+	denyGlobs     []string
+	db            *storage.Database
+}
+
+// NewPathPolicy creates a policy. Writes are permitted under allowedRoots,
+// reads under allowedRoots and readOnlyRoots. When both root lists are empty
+// no root confinement is applied. Violations are recorded in audit_logs when
+// db is non-nil.
+func NewPathPolicy(allowedRoots, readOnlyRoots, denyGlobs []string, db *storage.Database) *PathPolicy {
+	p := &PathPolicy{denyGlobs: denyGlobs, db: db}
+	for _, r := range allowedRoots {
+		p.allowedRoots = append(p.allowedRoots, realPath(r))
+	}
+	for _, r := range readOnlyRoots {
+		p.readOnlyRoots = append(p.readOnlyRoots, realPath(r))
+	}
+	return p
+}
+
+// Enforce checks path for the given access and records violations.
+func (p *PathPolicy) Enforce(auth *ToolAuthContext, tool, path string, write bool) error {
+	err := CheckPath(path)
+	if err == nil && p != nil {
+		err = p.check(path, write)
+	}
+	if err != nil {
+		p.audit(auth, tool, path, write, err)
+	}
+	return err
+}
+
+// Allows reports whether path is permitted without recording anything.
+// Used to silently filter glob and grep results.
+func (p *PathPolicy) Allows(path string, write bool) bool {
+	if IsBlocked(path) {
+		return false
+	}
+	return p == nil || p.check(path, write) == nil
+}
+
+func (p *PathPolicy) check(path string, write bool) error {
+	real := realPath(path)
+	if err := CheckPath(real); err != nil {
+		return err
+	}
+
+	for _, g := range p.denyGlobs {
+		if matchDenyGlob(g, real) {
+			return fmt.Errorf("access to %s is blocked by path policy (matches %q)", path, g)
+		}
+	}
+
+	if len(p.allowedRoots) == 0 && len(p.readOnlyRoots) == 0 {
+		return nil
+	}
+
+	inReadOnly := withinAny(p.readOnlyRoots, real)
+	if write {
+		if inReadOnly {
+			return fmt.Errorf("access to %s is denied: path is read-only", path)
+		}
+		if !withinAny(p.allowedRoots, real) {
+			return fmt.Errorf("access to %s is denied: outside allowed roots", path)
+		}
+		return nil
+	}
+	if !inReadOnly && !withinAny(p.allowedRoots, real) {
+		return fmt.Errorf("access to %s is denied: outside allowed roots", path)
+	}
+	return nil
+}
+
+func (p *PathPolicy) audit(auth *ToolAuthContext, tool, path string, write bool, reason error) {
+	if p == nil || p.db == nil {
+		return
+	}
+	actor := "unknown"
+	if auth != nil {
+		actor = fmt.Sprintf("%s:%d", auth.CallerChannel, auth.CallerChatID)
+	}
+	action := tool + ":read"
+	if write {
+		action = tool + ":write"
+	}
+	detail := reason.Error()
+	p.db.LogAuditEvent("path_policy", actor, action, &path, "denied", &detail)
+}
+
+// realPath returns the absolute, symlink-resolved form of path. When the
+// path does not exist yet, the longest existing ancestor is resolved and the
+// remaining components are appended.
+func realPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = filepath.Clean(path)
+	}
+	cur, rest := abs, ""
+	for {
+		if resolved, err := filepath.EvalSymlinks(cur); err == nil {
+			return filepath.Join(resolved, rest)
+		}
+		parent := filepath.Dir(cur)
+		if parent == cur {
+			return abs
+		}
+		rest = filepath.Join(filepath.Base(cur), rest)
+		cur = parent
+	}
+}
+
+func withinAny(roots []string, path string) bool {
+	for _, r := range roots {
+		if isWithin(r, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDenyGlob matches patterns containing a slash against the full path and
+// bare patterns against the basename.
+func matchDenyGlob(pattern, path string) bool {
+	if strings.Contains(pattern, "/") {
+		ok, _ := doublestar.Match(pattern, filepath.ToSlash(path))
+		return ok
+	}
+	ok, _ := doublestar.Match(pattern, filepath.Base(path))
+	return ok
+}
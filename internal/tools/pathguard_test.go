@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPathPolicy(t *testing.T) {
+	base := t.TempDir()
+	allowed := filepath.Join(base, "work")
+	readOnly := filepath.Join(base, "docs")
+	outside := filepath.Join(base, "outside")
+	for _, d := range []string{allowed, readOnly, outside} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(allowed, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPathPolicy([]string{allowed}, []string{readOnly}, []string{"*.pem"}, nil)
+
+	tests := []struct {
+		name  string
+		path  string
+		write bool
+		ok    bool
+	}{
+		{"read inside allowed root", filepath.Join(allowed, "a.txt"), false, true},
+		{"write new nested file", filepath.Join(allowed, "new", "dir", "b.txt"), true, true},
+		{"read read-only root", filepath.Join(readOnly, "c.md"), false, true},
+		{"write read-only root", filepath.Join(readOnly, "c.md"), true, false},
+		{"read outside roots", filepath.Join(outside, "d.txt"), false, false},
+		{"symlink escape", filepath.Join(allowed, "escape", "d.txt"), false, false},
+		{"deny glob", filepath.Join(allowed, "key.pem"), false, false},
+		{"builtin denylist", filepath.Join(allowed, ".env"), false, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := p.Enforce(nil, "read_file", tc.path, tc.write)
+			if (err == nil) != tc.ok {
+				t.Errorf("Enforce(%q, write=%v) error = %v, want ok=%v", tc.path, tc.write, err, tc.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,633 @@
+package tools
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// pdfToText extracts text from a PDF without external dependencies. It scans
+// every content stream (inflating FlateDecode streams) and interprets the
+// text-showing operators. Each font's ToUnicode CMap is applied to the text
+// shown in that font, which covers most CID fonts; layout is approximated
+// with line breaks on text positioning operators. Scanned (image-only) PDFs
+// yield no text.
+func pdfToText(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", fmt.Errorf("not a PDF file")
+	}
+
+	doc := parsePDF(data)
+	// Content whose fonts cannot be resolved falls back to the union of all
+	// CMaps in the file.
+	merged := make(map[uint16]string)
+	var contents []int
+	for _, num := range doc.order {
+		s := doc.objs[num].stream
+		switch {
+		case bytes.Contains(s, []byte("begincmap")):
+			parseToUnicode(s, merged)
+		case bytes.Contains(s, []byte("BT")) && (bytes.Contains(s, []byte("Tj")) || bytes.Contains(s, []byte("TJ"))):
+			contents = append(contents, num)
+		}
+	}
+	fonts := doc.contentFonts()
+
+	var sb strings.Builder
+	for _, num := range contents {
+		extractPDFText(doc.objs[num].stream, fonts[num], merged, &sb)
+		sb.WriteString("\n\n")
+	}
+	text := strings.TrimSpace(blankLinesRe.ReplaceAllString(sb.String(), "\n\n"))
+	if text == "" {
+		return "", fmt.Errorf("no extractable text (the PDF may be scanned images)")
+	}
+	return text, nil
+}
+
+// pdfObject is an indirect object: its dictionary (or whole body for
+// non-stream objects) and its decoded stream, if any.
+type pdfObject struct {
+	dict   []byte
+	stream []byte
+}
+
+type pdfDoc struct {
+	objs  map[int]*pdfObject
+	order []int // stream objects in file order
+	cmaps map[int]map[uint16]string
+}
+
+var (
+	objStartRe    = regexp.MustCompile(`(\d+)\s+\d+\s+obj\b`)
+	streamStartRe = regexp.MustCompile(`stream\r?\n`)
+	objStmFirstRe = regexp.MustCompile(`/First\s+(\d+)`)
+	refRe         = regexp.MustCompile(`(\d+)\s+\d+\s+R\b`)
+	imageRe       = regexp.MustCompile(`/Subtype\s*/Image\b`)
+	namedRefRe    = regexp.MustCompile(`/([^\s/<>\[\]()]+)\s*(\d+)\s+\d+\s+R\b`)
+)
+
+// parsePDF indexes the file's objects, including those packed in object
+// streams. Streams with filters other than FlateDecode are left empty.
+func parsePDF(data []byte) *pdfDoc {
+	doc := &pdfDoc{objs: make(map[int]*pdfObject), cmaps: make(map[int]map[uint16]string)}
+	pos := 0
+	for {
+		loc := objStartRe.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		start := pos + loc[1]
+		end := bytes.Index(data[start:], []byte("endobj"))
+		if end < 0 {
+			end = len(data) - start
+		}
+		obj := &pdfObject{dict: data[start : start+end]}
+		pos = start + end
+
+		if sloc := streamStartRe.FindIndex(obj.dict); sloc != nil {
+			bodyStart := start + sloc[1]
+			bodyEnd := bytes.Index(data[bodyStart:], []byte("endstream"))
+			if bodyEnd < 0 {
+				break
+			}
+			obj.dict = data[start : start+sloc[0]]
+			obj.stream = decodePDFStream(obj.dict, bytes.TrimRight(data[bodyStart:bodyStart+bodyEnd], "\r\n"))
+			pos = bodyStart + bodyEnd + len("endstream")
+			doc.order = append(doc.order, num)
+		}
+		doc.objs[num] = obj
+
+		if bytes.Contains(obj.dict, []byte("/ObjStm")) {
+			doc.unpackObjStm(obj)
+		}
+	}
+	return doc
+}
+
+// decodePDFStream returns a stream body ready for scanning, or nil for images
+// and unsupported filters.
+func decodePDFStream(dict, body []byte) []byte {
+	if imageRe.Match(dict) {
+		return nil
+	}
+	if !bytes.Contains(dict, []byte("/Filter")) {
+		return body
+	}
+	if !bytes.Contains(dict, []byte("/FlateDecode")) {
+		return nil
+	}
+	r, err := zlib.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+	// Keep whatever inflated before an error; truncated streams are common.
+	inflated, _ := io.ReadAll(io.LimitReader(r, 16<<20))
+	r.Close()
+	return inflated
+}
+
+// unpackObjStm adds the objects stored in an object stream.
+func (d *pdfDoc) unpackObjStm(obj *pdfObject) {
+	m := objStmFirstRe.FindSubmatch(obj.dict)
+	if m == nil {
+		return
+	}
+	first, _ := strconv.Atoi(string(m[1]))
+	if first <= 0 || first > len(obj.stream) {
+		return
+	}
+	header := strings.Fields(string(obj.stream[:first]))
+	for i := 0; i+1 < len(header); i += 2 {
+		num, err1 := strconv.Atoi(header[i])
+		off, err2 := strconv.Atoi(header[i+1])
+		if err1 != nil || err2 != nil || first+off > len(obj.stream) {
+			return
+		}
+		end := len(obj.stream)
+		if i+3 < len(header) {
+			if next, err := strconv.Atoi(header[i+3]); err == nil && first+next <= end && next >= off {
+				end = first + next
+			}
+		}
+		if _, ok := d.objs[num]; !ok {
+			d.objs[num] = &pdfObject{dict: obj.stream[first+off : end]}
+		}
+	}
+}
+
+// contentFonts maps each page content stream and form XObject to the
+// ToUnicode CMaps of the fonts in its resources, keyed by resource name
+// ("/F1").
+func (d *pdfDoc) contentFonts() map[int]map[string]map[uint16]string {
+	out := make(map[int]map[string]map[uint16]string)
+	for num, obj := range d.objs {
+		if bytes.Contains(obj.dict, []byte("/Form")) && obj.stream != nil {
+			if res := d.resources(obj.dict, 0); res != nil {
+				out[num] = d.fonts(res)
+			}
+			continue
+		}
+		i := bytes.Index(obj.dict, []byte("/Contents"))
+		if i < 0 {
+			continue
+		}
+		res := d.resources(obj.dict, 0)
+		if res == nil {
+			continue
+		}
+		fonts := d.fonts(res)
+		val := obj.dict[i+len("/Contents"):]
+		if v := bytes.TrimLeft(val, " \r\n\t"); len(v) > 0 && v[0] == '[' {
+			if j := bytes.IndexByte(v, ']'); j >= 0 {
+				val = v[:j]
+			}
+		} else if loc := refRe.FindIndex(val); loc != nil {
+			val = val[:loc[1]]
+		}
+		for _, ref := range refRe.FindAllSubmatch(val, -1) {
+			c, _ := strconv.Atoi(string(ref[1]))
+			out[c] = fonts
+		}
+	}
+	return out
+}
+
+// resources returns the resource dictionary of a page or form, following
+// /Parent links for inherited page resources.
+func (d *pdfDoc) resources(dict []byte, depth int) []byte {
+	if res := d.entry(dict, "/Resources"); res != nil {
+		return res
+	}
+	if depth > 8 {
+		return nil
+	}
+	i := bytes.Index(dict, []byte("/Parent"))
+	if i < 0 {
+		return nil
+	}
+	m := refRe.FindSubmatch(dict[i:])
+	if m == nil {
+		return nil
+	}
+	num, _ := strconv.Atoi(string(m[1]))
+	parent, ok := d.objs[num]
+	if !ok {
+		return nil
+	}
+	return d.resources(parent.dict, depth+1)
+}
+
+// entry returns the dictionary value of key, resolving an indirect
+// reference.
+func (d *pdfDoc) entry(dict []byte, key string) []byte {
+	i := bytes.Index(dict, []byte(key))
+	if i < 0 {
+		return nil
+	}
+	val := bytes.TrimLeft(dict[i+len(key):], " \r\n\t")
+	if bytes.HasPrefix(val, []byte("<<")) {
+		return pdfDictAt(val)
+	}
+	m := refRe.FindSubmatchIndex(val)
+	if m == nil || m[0] != 0 {
+		return nil
+	}
+	num, _ := strconv.Atoi(string(val[m[2]:m[3]]))
+	if obj, ok := d.objs[num]; ok {
+		return obj.dict
+	}
+	return nil
+}
+
+// fonts resolves the /Font entries of a resource dictionary to their
+// ToUnicode CMaps. Fonts without one map to nil.
+func (d *pdfDoc) fonts(res []byte) map[string]map[uint16]string {
+	fontDict := d.entry(res, "/Font")
+	if fontDict == nil {
+		return nil
+	}
+	out := make(map[string]map[uint16]string)
+	for _, m := range namedRefRe.FindAllSubmatch(fontDict, -1) {
+		num, _ := strconv.Atoi(string(m[2]))
+		font, ok := d.objs[num]
+		if !ok {
+			continue
+		}
+		var cmap map[uint16]string
+		if i := bytes.Index(font.dict, []byte("/ToUnicode")); i >= 0 {
+			if r := refRe.FindSubmatch(font.dict[i:]); r != nil {
+				tu, _ := strconv.Atoi(string(r[1]))
+				cmap = d.cmap(tu)
+			}
+		}
+		out["/"+string(m[1])] = cmap
+	}
+	return out
+}
+
+// cmap parses the ToUnicode CMap stored in object num, once.
+func (d *pdfDoc) cmap(num int) map[uint16]string {
+	if m, ok := d.cmaps[num]; ok {
+		return m
+	}
+	var m map[uint16]string
+	if obj, ok := d.objs[num]; ok && obj.stream != nil {
+		m = make(map[uint16]string)
+		parseToUnicode(obj.stream, m)
+	}
+	d.cmaps[num] = m
+	return m
+}
+
+// pdfDictAt returns the balanced <<...>> dictionary at the start of b.
+func pdfDictAt(b []byte) []byte {
+	depth := 0
+	for i := 0; i+1 < len(b); i++ {
+		switch {
+		case b[i] == '<' && b[i+1] == '<':
+			depth++
+			i++
+		case b[i] == '>' && b[i+1] == '>':
+			depth--
+			i++
+			if depth == 0 {
+				return b[:i+1]
+			}
+		}
+	}
+	return b
+}
+
+var (
+	bfcharRe  = regexp.MustCompile(`(?s)beginbfchar(.*?)endbfchar`)
+	bfrangeRe = regexp.MustCompile(`(?s)beginbfrange(.*?)endbfrange`)
+	hexTokRe  = regexp.MustCompile(`<([0-9A-Fa-f]*)>|\[([^\]]*)\]`)
+	hexOnlyRe = regexp.MustCompile(`<([0-9A-Fa-f]*)>`)
+)
+
+// parseToUnicode merges a ToUnicode CMap's bfchar and bfrange mappings into m.
+func parseToUnicode(s []byte, m map[uint16]string) {
+	for _, block := range bfcharRe.FindAllSubmatch(s, -1) {
+		toks := hexTokRe.FindAllSubmatch(block[1], -1)
+		for i := 0; i+1 < len(toks); i += 2 {
+			src, ok := hexCode(toks[i][1])
+			if ok {
+				m[src] = utf16Hex(toks[i+1][1])
+			}
+		}
+	}
+	for _, block := range bfrangeRe.FindAllSubmatch(s, -1) {
+		toks := hexTokRe.FindAllSubmatch(block[1], -1)
+		for i := 0; i+2 < len(toks); i += 3 {
+			lo, ok1 := hexCode(toks[i][1])
+			hi, ok2 := hexCode(toks[i+1][1])
+			if !ok1 || !ok2 || hi < lo || hi-lo > 0x2000 {
+				continue
+			}
+			if toks[i+2][2] != nil {
+				// Array form: one destination per code.
+				dsts := hexOnlyRe.FindAllSubmatch(toks[i+2][2], -1)
+				for j, d := range dsts {
+					if int(lo)+j > int(hi) {
+						break
+					}
+					m[lo+uint16(j)] = utf16Hex(d[1])
+				}
+				continue
+			}
+			base := []rune(utf16Hex(toks[i+2][1]))
+			if len(base) == 0 {
+				continue
+			}
+			for c := int(lo); c <= int(hi); c++ {
+				r := append([]rune{}, base...)
+				r[len(r)-1] += rune(c - int(lo))
+				m[uint16(c)] = string(r)
+			}
+		}
+	}
+}
+
+func hexCode(h []byte) (uint16, bool) {
+	if len(h) == 0 || len(h) > 4 {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(string(h), 16, 16)
+	return uint16(v), err == nil
+}
+
+func utf16Hex(h []byte) string {
+	b := decodeHex(h)
+	if len(b)%2 != 0 {
+		return string(b)
+	}
+	u := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(u))
+}
+
+func decodeHex(h []byte) []byte {
+	var clean []byte
+	for _, c := range h {
+		if isHexDigit(c) {
+			clean = append(clean, c)
+		}
+	}
+	if len(clean)%2 == 1 {
+		clean = append(clean, '0')
+	}
+	out := make([]byte, len(clean)/2)
+	for i := range out {
+		v, _ := strconv.ParseUint(string(clean[2*i:2*i+2]), 16, 8)
+		out[i] = byte(v)
+	}
+	return out
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// pdfString is a string operand together with how it was written.
+type pdfString struct {
+	b   []byte
+	hex bool
+}
+
+// extractPDFText interprets the text operators of one content stream. fonts
+// maps font resource names to their CMaps; text in fonts missing from it is
+// decoded with fallback.
+func extractPDFText(c []byte, fonts map[string]map[uint16]string, fallback map[uint16]string, sb *strings.Builder) {
+	var operands []any
+	cmap := fallback
+	lastY := 0.0
+	show := func(s pdfString) {
+		sb.WriteString(decodePDFString(s, cmap))
+	}
+	newline := func() {
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteString("\n")
+		}
+	}
+
+	for i := 0; i < len(c); {
+		ch := c[i]
+		switch {
+		case ch == '%':
+			for i < len(c) && c[i] != '\n' && c[i] != '\r' {
+				i++
+			}
+		case ch == '(':
+			s, n := readLiteralString(c[i:])
+			operands = append(operands, pdfString{b: s})
+			i += n
+		case ch == '<' && i+1 < len(c) && c[i+1] == '<':
+			i += 2 // inline dictionaries are irrelevant for text
+		case ch == '>' && i+1 < len(c) && c[i+1] == '>':
+			i += 2
+		case ch == '<':
+			end := bytes.IndexByte(c[i:], '>')
+			if end < 0 {
+				return
+			}
+			operands = append(operands, pdfString{b: decodeHex(c[i+1 : i+end]), hex: true})
+			i += end + 1
+		case ch == '[':
+			operands = append(operands, '[')
+			i++
+		case ch == ']':
+			// Collapse back to the matching '[' into an array operand.
+			j := len(operands) - 1
+			for j >= 0 && operands[j] != '[' {
+				j--
+			}
+			if j < 0 {
+				i++
+				continue
+			}
+			arr := append([]any{}, operands[j+1:]...)
+			operands = append(operands[:j], arr)
+			i++
+		case ch == '/':
+			j := i + 1
+			for j < len(c) && !isPDFDelim(c[j]) {
+				j++
+			}
+			operands = append(operands, string(c[i:j]))
+			i = j
+		case isPDFSpace(ch):
+			i++
+		default:
+			j := i
+			for j < len(c) && !isPDFDelim(c[j]) {
+				j++
+			}
+			if j == i {
+				i++
+				continue
+			}
+			tok := string(c[i:j])
+			i = j
+			if f, err := strconv.ParseFloat(tok, 64); err == nil {
+				operands = append(operands, f)
+				continue
+			}
+
+			switch tok {
+			case "Tf":
+				if n := len(operands); n >= 2 {
+					if name, ok := operands[n-2].(string); ok {
+						if m, ok := fonts[name]; ok {
+							cmap = m
+						} else {
+							cmap = fallback
+						}
+					}
+				}
+			case "Tj", "'", "\"":
+				if tok != "Tj" {
+					newline()
+				}
+				if n := len(operands); n > 0 {
+					if s, ok := operands[n-1].(pdfString); ok {
+						show(s)
+					}
+				}
+			case "TJ":
+				if n := len(operands); n > 0 {
+					if arr, ok := operands[n-1].([]any); ok {
+						for _, el := range arr {
+							switch v := el.(type) {
+							case pdfString:
+								show(v)
+							case float64:
+								if v < -200 {
+									sb.WriteString(" ")
+								}
+							}
+						}
+					}
+				}
+			case "Td", "TD":
+				if n := len(operands); n >= 2 {
+					if ty, ok := operands[n-1].(float64); ok && ty != 0 {
+						newline()
+					} else if !strings.HasSuffix(sb.String(), " ") {
+						sb.WriteString(" ")
+					}
+				}
+			case "Tm":
+				if n := len(operands); n >= 6 {
+					if y, ok := operands[n-1].(float64); ok && y != lastY {
+						newline()
+						lastY = y
+					}
+				}
+			case "T*", "ET":
+				newline()
+			}
+			operands = operands[:0]
+		}
+	}
+}
+
+// readLiteralString parses a (...) string with escapes and nested parens,
+// returning its bytes and the number of input bytes consumed.
+func readLiteralString(c []byte) ([]byte, int) {
+	var out []byte
+	depth := 0
+	for i := 0; i < len(c); i++ {
+		ch := c[i]
+		switch ch {
+		case '(':
+			if depth > 0 {
+				out = append(out, ch)
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out, i + 1
+			}
+			out = append(out, ch)
+		case '\\':
+			i++
+			if i >= len(c) {
+				return out, i
+			}
+			switch e := c[i]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r', '\n':
+				// Line continuation.
+			default:
+				if e >= '0' && e <= '7' {
+					j := i
+					for j < len(c) && j < i+3 && c[j] >= '0' && c[j] <= '7' {
+						j++
+					}
+					v, _ := strconv.ParseUint(string(c[i:j]), 8, 8)
+					out = append(out, byte(v))
+					i = j - 1
+				} else {
+					out = append(out, e)
+				}
+			}
+		default:
+			out = append(out, ch)
+		}
+	}
+	return out, len(c)
+}
+
+// decodePDFString maps string bytes to text: UTF-16 with a BOM, 2-byte codes
+// through the ToUnicode map for hex strings, otherwise Latin-1.
+func decodePDFString(s pdfString, cmap map[uint16]string) string {
+	b := s.b
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		return utf16Hex([]byte(fmt.Sprintf("%X", b[2:])))
+	}
+	if s.hex && len(cmap) > 0 && len(b)%2 == 0 {
+		var sb strings.Builder
+		for i := 0; i+1 < len(b); i += 2 {
+			if t, ok := cmap[uint16(b[i])<<8|uint16(b[i+1])]; ok {
+				sb.WriteString(t)
+			}
+		}
+		return sb.String()
+	}
+	runes := make([]rune, 0, len(b))
+	for _, c := range b {
+		if t, ok := cmap[uint16(c)]; ok && s.hex {
+			runes = append(runes, []rune(t)...)
+			continue
+		}
+		runes = append(runes, rune(c))
+	}
+	return string(runes)
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelim(c byte) bool {
+	return isPDFSpace(c) || strings.IndexByte("()<>[]{}/%", c) >= 0
+}
@@ -0,0 +1,625 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yifanes/miniclawd/internal/core"
+)
+
+// maxProcessBuffer caps the output retained per background process. Older
+// output is discarded once the buffer is full.
+const maxProcessBuffer = 1 << 20
+
+// ProcessLimits bounds background processes started by the agent.
+type ProcessLimits struct {
+	MaxPerChat  int           // running processes per chat (default 4)
+	MaxRuntime  time.Duration // processes are killed after this long (default 1h)
+	MemoryLimit string        // optional virtual memory cap, e.g. "512m"
+	PidsLimit   int           // optional cap on user processes (ulimit -u)
+}
+
+// ProcessManager tracks background processes, scoped per chat.
+type ProcessManager struct {
+	workspace *Workspace
+	limits    ProcessLimits
+
+	mu     sync.Mutex
+	nextID int
+	procs  map[string]*managedProcess
+}
+
+type managedProcess struct {
+	id      string
+	chatID  int64
+	command string
+	dir     string
+	started time.Time
+
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	out   *outputBuffer
+	done  chan struct{}
+
+	// Guarded by ProcessManager.mu.
+	readOff  int64
+	ended    time.Time
+	exitCode int
+	exitErr  string
+	killed   bool
+}
+
+// NewProcessManager creates a ProcessManager that runs commands in the
+// caller's workspace directory.
+func NewProcessManager(workspace *Workspace, limits ProcessLimits) *ProcessManager {
+	if limits.MaxPerChat <= 0 {
+		limits.MaxPerChat = 4
+	}
+	if limits.MaxRuntime <= 0 {
+		limits.MaxRuntime = time.Hour
+	}
+	return &ProcessManager{
+		workspace: workspace,
+		limits:    limits,
+		procs:     make(map[string]*managedProcess),
+	}
+}
+
+// ResolveDir resolves cwd against the caller's working directory. Callers
+// other than control chats must stay inside their own working directory.
+func (m *ProcessManager) ResolveDir(auth *ToolAuthContext, cwd string) (string, error) {
+	dir, err := m.workspace.Resolve(auth, cwd)
+	if err != nil {
+		return "", err
+	}
+	if auth != nil && !auth.IsControlChat() && !isWithin(m.workspace.ChatDir(auth.CallerChatID), dir) {
+		return "", fmt.Errorf("access to %s is denied: outside the chat's working directory", cwd)
+	}
+	return dir, nil
+}
+
+// Start launches command in the background for the caller's chat. dir
+// must come from ResolveDir.
+func (m *ProcessManager) Start(auth *ToolAuthContext, command, dir string) (*managedProcess, error) {
+	var chatID int64
+	if auth != nil {
+		chatID = auth.CallerChatID
+	}
+
+	m.mu.Lock()
+	m.pruneLocked()
+	running := 0
+	for _, p := range m.procs {
+		if p.chatID == chatID && !p.exited() {
+			running++
+		}
+	}
+	if running >= m.limits.MaxPerChat {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("too many background processes (%d running, limit %d); kill one first", running, m.limits.MaxPerChat)
+	}
+	m.nextID++
+	id := "p" + strconv.Itoa(m.nextID)
+	m.mu.Unlock()
+
+	script := command
+	if kb := memoryLimitKB(m.limits.MemoryLimit); kb > 0 {
+		script = fmt.Sprintf("ulimit -v %d\n%s", kb, script)
+	}
+	if m.limits.PidsLimit > 0 {
+		script = fmt.Sprintf("ulimit -u %d\n%s", m.limits.PidsLimit, script)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.limits.MaxRuntime)
+	cmd := exec.CommandContext(ctx, "bash", "-c", script)
+	cmd.Dir = dir
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error { return killProcessGroup(cmd) }
+	cmd.WaitDelay = 5 * time.Second
+
+	out := &outputBuffer{}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("spawn error: %w", err)
+	}
+
+	p := &managedProcess{
+		id:      id,
+		chatID:  chatID,
+		command: command,
+		dir:     dir,
+		started: time.Now(),
+		cmd:     cmd,
+		stdin:   stdin,
+		out:     out,
+		done:    make(chan struct{}),
+	}
+
+	m.mu.Lock()
+	m.procs[id] = p
+	m.mu.Unlock()
+
+	go func() {
+		err := cmd.Wait()
+		m.mu.Lock()
+		p.ended = time.Now()
+		p.exitCode = cmd.ProcessState.ExitCode()
+		if ctx.Err() == context.DeadlineExceeded {
+			p.exitErr = fmt.Sprintf("killed after exceeding max runtime of %s", m.limits.MaxRuntime)
+		} else if err != nil && p.exitCode < 0 {
+			p.exitErr = err.Error()
+		}
+		m.mu.Unlock()
+		cancel()
+		close(p.done)
+	}()
+
+	return p, nil
+}
+
+// get returns the process with id if the caller may access it.
+func (m *ProcessManager) get(auth *ToolAuthContext, id string) (*managedProcess, error) {
+	m.mu.Lock()
+	p, ok := m.procs[id]
+	m.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("process %s not found", id)
+	}
+	if auth != nil && !auth.CanAccessChat(p.chatID) {
+		return nil, fmt.Errorf("process %s not found", id)
+	}
+	return p, nil
+}
+
+// List returns processes visible to the caller, oldest first. Control chats
+// see every chat's processes.
+func (m *ProcessManager) List(auth *ToolAuthContext) []*managedProcess {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*managedProcess
+	for _, p := range m.procs {
+		if auth == nil || p.chatID == auth.CallerChatID || auth.IsControlChat() {
+			out = append(out, p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].started.Before(out[j].started) })
+	return out
+}
+
+// Kill terminates a process and forgets it.
+func (m *ProcessManager) Kill(auth *ToolAuthContext, id string) (*managedProcess, error) {
+	p, err := m.get(auth, id)
+	if err != nil {
+		return nil, err
+	}
+	m.stop(p)
+	m.mu.Lock()
+	delete(m.procs, id)
+	m.mu.Unlock()
+	return p, nil
+}
+
+// KillChat terminates all processes belonging to chatID. Called when a
+// chat's session is reset. Safe to call on a nil manager.
+func (m *ProcessManager) KillChat(chatID int64) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	var victims []*managedProcess
+	for id, p := range m.procs {
+		if p.chatID == chatID {
+			victims = append(victims, p)
+			delete(m.procs, id)
+		}
+	}
+	m.mu.Unlock()
+	for _, p := range victims {
+		m.stop(p)
+	}
+}
+
+// Shutdown terminates every tracked process.
+func (m *ProcessManager) Shutdown() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	victims := make([]*managedProcess, 0, len(m.procs))
+	for id, p := range m.procs {
+		victims = append(victims, p)
+		delete(m.procs, id)
+	}
+	m.mu.Unlock()
+	for _, p := range victims {
+		m.stop(p)
+	}
+}
+
+func (m *ProcessManager) stop(p *managedProcess) {
+	if p.exited() {
+		return
+	}
+	m.mu.Lock()
+	p.killed = true
+	m.mu.Unlock()
+	p.stdin.Close()
+	killProcessGroup(p.cmd)
+	select {
+	case <-p.done:
+	case <-time.After(5 * time.Second):
+	}
+}
+
+// pruneLocked forgets processes that exited more than an hour ago.
+func (m *ProcessManager) pruneLocked() {
+	cutoff := time.Now().Add(-time.Hour)
+	for id, p := range m.procs {
+		if p.exited() && p.ended.Before(cutoff) {
+			delete(m.procs, id)
+		}
+	}
+}
+
+// ReadNew returns output produced since the last read, waiting up to wait
+// for new output or process exit.
+func (m *ProcessManager) ReadNew(p *managedProcess, wait time.Duration) (string, int64) {
+	m.mu.Lock()
+	off := p.readOff
+	m.mu.Unlock()
+
+	if wait > 0 && p.out.Total() == off && !p.exited() {
+		deadline := time.After(wait)
+		tick := time.NewTicker(100 * time.Millisecond)
+	loop:
+		for {
+			select {
+			case <-p.done:
+				break loop
+			case <-deadline:
+				break loop
+			case <-tick.C:
+				if p.out.Total() > off {
+					break loop
+				}
+			}
+		}
+		tick.Stop()
+	}
+
+	text, next, dropped := p.out.ReadFrom(off)
+	m.mu.Lock()
+	p.readOff = next
+	m.mu.Unlock()
+	return text, dropped
+}
+
+func (p *managedProcess) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// status renders a one-line status. The caller must hold ProcessManager.mu.
+func (p *managedProcess) status() string {
+	if !p.exited() {
+		return fmt.Sprintf("running for %s", time.Since(p.started).Round(time.Second))
+	}
+	switch {
+	case p.killed:
+		return "killed"
+	case p.exitErr != "":
+		return "exited: " + p.exitErr
+	default:
+		return fmt.Sprintf("exited with code %d", p.exitCode)
+	}
+}
+
+func (m *ProcessManager) describe(p *managedProcess) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fmt.Sprintf("%s (pid %d, chat %d): %s — %s", p.id, p.cmd.Process.Pid, p.chatID, p.status(), p.command)
+}
+
+// memoryLimitKB parses sizes like "512m", "2g" or "1048576" into kilobytes.
+func memoryLimitKB(limit string) int64 {
+	s := strings.ToLower(strings.TrimSpace(limit))
+	s = strings.TrimSuffix(s, "b")
+	if s == "" {
+		return 0
+	}
+	mult := int64(1)
+	switch s[len(s)-1] {
+	case 'k':
+		mult = 1 << 10
+	case 'm':
+		mult = 1 << 20
+	case 'g':
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0
+	}
+	return n * mult / 1024
+}
+
+// outputBuffer collects combined stdout/stderr, keeping the most recent
+// maxProcessBuffer bytes. Offsets are absolute byte counts since start.
+type outputBuffer struct {
+	mu    sync.Mutex
+	data  []byte
+	start int64
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if over := len(b.data) - maxProcessBuffer; over > 0 {
+		b.data = append(b.data[:0:0], b.data[over:]...)
+		b.start += int64(over)
+	}
+	return len(p), nil
+}
+
+// Total returns the number of bytes written so far.
+func (b *outputBuffer) Total() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.start + int64(len(b.data))
+}
+
+// ReadFrom returns retained output from absolute offset off, the next offset,
+// and how many bytes were discarded before they could be read.
+func (b *outputBuffer) ReadFrom(off int64) (string, int64, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var dropped int64
+	if off < b.start {
+		dropped = b.start - off
+		off = b.start
+	}
+	chunk := b.data[off-b.start:]
+	return string(chunk), b.start + int64(len(b.data)), dropped
+}
+
+// --- Tools ---
+
+type ProcessStartTool struct {
+	m      *ProcessManager
+	policy *PathPolicy
+}
+
+func NewProcessStartTool(m *ProcessManager, policy *PathPolicy) *ProcessStartTool {
+	return &ProcessStartTool{m: m, policy: policy}
+}
+
+func (t *ProcessStartTool) Name() string { return "process_start" }
+
+func (t *ProcessStartTool) Definition() core.ToolDefinition {
+	return MakeDef("process_start",
+		"Start a bash command in the background (dev servers, long builds, log tails). Returns a process id for process_output, process_write and process_kill.",
+		map[string]any{
+			"command": StringProp("The bash command to run in the background"),
+			"cwd":     StringProp("Working directory, relative to the chat's working directory (optional)"),
+		},
+		[]string{"command"},
+	)
+}
+
+// Risk rates the command the same way as the bash tool, so a risk policy
+// cannot be sidestepped by starting the command in the background.
+func (t *ProcessStartTool) Risk(input json.RawMessage) (ToolRisk, string) {
+	var p struct {
+		Command string `json:"command"`
+	}
+	json.Unmarshal(input, &p)
+	return commandRisk(p.Command)
+}
+
+func (t *ProcessStartTool) Execute(ctx context.Context, input json.RawMessage) ToolResult {
+	var params struct {
+		Command string `json:"command"`
+		Cwd     string `json:"cwd"`
+	}
+	if err := json.Unmarshal(input, &params); err != nil {
+		return Error("invalid input: " + err.Error())
+	}
+	if params.Command == "" {
+		return Error("command is required")
+	}
+
+	auth := ExtractAuthContext(input)
+	dir, err := t.m.ResolveDir(auth, params.Cwd)
+	if err != nil {
+		return Error(err.Error())
+	}
+	if err := t.policy.Enforce(auth, t.Name(), dir, true); err != nil {
+		return Error(err.Error())
+	}
+
+	p, err := t.m.Start(auth, params.Command, dir)
+	if err != nil {
+		return Error(err.Error())
+	}
+	return Success(fmt.Sprintf("Started %s (pid %d) in %s", p.id, p.cmd.Process.Pid, p.dir))
+}
+
+type ProcessOutputTool struct{ m *ProcessManager }
+
+func NewProcessOutputTool(m *ProcessManager) *ProcessOutputTool { return &ProcessOutputTool{m: m} }
+
+func (t *ProcessOutputTool) Name() string { return "process_output" }
+
+func (t *ProcessOutputTool) Definition() core.ToolDefinition {
+	return MakeDef("process_output",
+		"Read new output (stdout and stderr) from a background process since the last call, plus its status.",
+		map[string]any{
+			"id":        StringProp("Process id returned by process_start"),
+			"wait_secs": IntProp("Wait up to this many seconds for new output or exit (default 0, max 30)"),
+		},
+		[]string{"id"},
+	)
+}
+
+func (t *ProcessOutputTool) Execute(ctx context.Context, input json.RawMessage) ToolResult {
+	var params struct {
+		ID       string `json:"id"`
+		WaitSecs int    `json:"wait_secs"`
+	}
+	if err := json.Unmarshal(input, &params); err != nil {
+		return Error("invalid input: " + err.Error())
+	}
+	p, err := t.m.get(ExtractAuthContext(input), params.ID)
+	if err != nil {
+		return Error(err.Error())
+	}
+
+	wait := params.WaitSecs
+	if wait > 30 {
+		wait = 30
+	}
+	text, dropped := t.m.ReadNew(p, time.Duration(wait)*time.Second)
+
+	var sb strings.Builder
+	sb.WriteString(t.m.describe(p))
+	sb.WriteString("\n")
+	if dropped > 0 {
+		fmt.Fprintf(&sb, "... (%d bytes of earlier output discarded)\n", dropped)
+	}
+	if len(text) > maxOutputBytes {
+		skip := len(text) - maxOutputBytes
+		text = text[core.CeilCharBoundary(text, skip):]
+		fmt.Fprintf(&sb, "... (%d bytes skipped)\n", skip)
+	}
+	if text == "" {
+		sb.WriteString("(no new output)")
+	} else {
+		sb.WriteString(text)
+	}
+	return Success(sb.String())
+}
+
+type ProcessWriteTool struct{ m *ProcessManager }
+
+func NewProcessWriteTool(m *ProcessManager) *ProcessWriteTool { return &ProcessWriteTool{m: m} }
+
+func (t *ProcessWriteTool) Name() string { return "process_write" }
+
+func (t *ProcessWriteTool) Definition() core.ToolDefinition {
+	return MakeDef("process_write",
+		"Write input to a background process's stdin. Include a trailing newline to submit a line.",
+		map[string]any{
+			"id":          StringProp("Process id returned by process_start"),
+			"input":       StringProp("Text to write to stdin"),
+			"close_stdin": BoolProp("Close stdin after writing (sends EOF)"),
+		},
+		[]string{"id"},
+	)
+}
+
+func (t *ProcessWriteTool) Execute(ctx context.Context, input json.RawMessage) ToolResult {
+	var params struct {
+		ID         string `json:"id"`
+		Input      string `json:"input"`
+		CloseStdin bool   `json:"close_stdin"`
+	}
+	if err := json.Unmarshal(input, &params); err != nil {
+		return Error("invalid input: " + err.Error())
+	}
+	p, err := t.m.get(ExtractAuthContext(input), params.ID)
+	if err != nil {
+		return Error(err.Error())
+	}
+	if p.exited() {
+		return Error(fmt.Sprintf("process %s has already exited", p.id))
+	}
+	if params.Input != "" {
+		if _, err := io.WriteString(p.stdin, params.Input); err != nil {
+			return Error("write error: " + err.Error())
+		}
+	}
+	if params.CloseStdin {
+		p.stdin.Close()
+		return Success(fmt.Sprintf("Wrote %d bytes to %s and closed stdin", len(params.Input), p.id))
+	}
+	return Success(fmt.Sprintf("Wrote %d bytes to %s", len(params.Input), p.id))
+}
+
+type ProcessListTool struct{ m *ProcessManager }
+
+func NewProcessListTool(m *ProcessManager) *ProcessListTool { return &ProcessListTool{m: m} }
+
+func (t *ProcessListTool) Name() string { return "process_list" }
+
+func (t *ProcessListTool) Definition() core.ToolDefinition {
+	return MakeDef("process_list",
+		"List background processes started in this chat.",
+		map[string]any{},
+		nil,
+	)
+}
+
+func (t *ProcessListTool) Execute(ctx context.Context, input json.RawMessage) ToolResult {
+	procs := t.m.List(ExtractAuthContext(input))
+	if len(procs) == 0 {
+		return Success("No background processes.")
+	}
+	lines := make([]string, 0, len(procs))
+	for _, p := range procs {
+		lines = append(lines, t.m.describe(p))
+	}
+	return Success(strings.Join(lines, "\n"))
+}
+
+type ProcessKillTool struct{ m *ProcessManager }
+
+func NewProcessKillTool(m *ProcessManager) *ProcessKillTool { return &ProcessKillTool{m: m} }
+
+func (t *ProcessKillTool) Name() string { return "process_kill" }
+
+func (t *ProcessKillTool) Definition() core.ToolDefinition {
+	return MakeDef("process_kill",
+		"Kill a background process (and its children) and discard its output.",
+		map[string]any{
+			"id": StringProp("Process id returned by process_start"),
+		},
+		[]string{"id"},
+	)
+}
+
+func (t *ProcessKillTool) Execute(ctx context.Context, input json.RawMessage) ToolResult {
+	var params struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(input, &params); err != nil {
+		return Error("invalid input: " + err.Error())
+	}
+	p, err := t.m.Kill(ExtractAuthContext(input), params.ID)
+	if err != nil {
+		return Error(err.Error())
+	}
+	return Success(fmt.Sprintf("Killed %s: %s", p.id, p.command))
+}
@@ -0,0 +1,253 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestProcessManager(t *testing.T, limits ProcessLimits) (*ProcessManager, string) {
+	t.Helper()
+	root := t.TempDir()
+	m := NewProcessManager(NewWorkspace(root, "chat"), limits)
+	t.Cleanup(m.Shutdown)
+	return m, root
+}
+
+func TestProcessTools(t *testing.T) {
+	m, root := newTestProcessManager(t, ProcessLimits{})
+	r := NewToolRegistry()
+	r.Register(NewProcessStartTool(m, nil))
+	r.Register(NewProcessOutputTool(m))
+	r.Register(NewProcessWriteTool(m))
+	r.Register(NewProcessListTool(m))
+	r.Register(NewProcessKillTool(m))
+	user := &ToolAuthContext{CallerChannel: "telegram", CallerChatID: 5}
+	other := &ToolAuthContext{CallerChannel: "telegram", CallerChatID: 6}
+	os.MkdirAll(filepath.Join(root, "chat-5", "sub"), 0o755)
+
+	exec := func(auth *ToolAuthContext, name, input string) ToolResult {
+		return r.ExecuteWithAuth(context.Background(), name, json.RawMessage(input), auth)
+	}
+
+	res := exec(user, "process_start", `{"command":"pwd; read line; echo got $line","cwd":"sub"}`)
+	if res.IsError || !strings.HasPrefix(res.Content, "Started p1") {
+		t.Fatalf("start: %s", res.Content)
+	}
+	res = exec(user, "process_output", `{"id":"p1","wait_secs":5}`)
+	if !strings.Contains(res.Content, filepath.Join(root, "chat-5", "sub")) || !strings.Contains(res.Content, "running") {
+		t.Fatalf("first output: %s", res.Content)
+	}
+	if res = exec(other, "process_output", `{"id":"p1"}`); !res.IsError {
+		t.Fatalf("other chat read output: %s", res.Content)
+	}
+	if res = exec(user, "process_write", `{"id":"p1","input":"hello\n","close_stdin":true}`); res.IsError {
+		t.Fatalf("write: %s", res.Content)
+	}
+	p, _ := m.get(user, "p1")
+	select {
+	case <-p.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("process did not exit")
+	}
+	res = exec(user, "process_output", `{"id":"p1"}`)
+	if !strings.Contains(res.Content, "exited with code 0") || !strings.HasSuffix(res.Content, "got hello\n") {
+		t.Fatalf("final output: %s", res.Content)
+	}
+	if res = exec(user, "process_output", `{"id":"p1"}`); !strings.HasSuffix(res.Content, "(no new output)") {
+		t.Fatalf("drained output: %s", res.Content)
+	}
+
+	res = exec(user, "process_start", `{"command":"sleep 30"}`)
+	if res.IsError {
+		t.Fatalf("start sleep: %s", res.Content)
+	}
+	if res = exec(other, "process_list", `{}`); res.Content != "No background processes." {
+		t.Fatalf("other chat list: %s", res.Content)
+	}
+	if res = exec(other, "process_kill", `{"id":"p2"}`); !res.IsError {
+		t.Fatalf("other chat killed process: %s", res.Content)
+	}
+	if res = exec(user, "process_kill", `{"id":"p2"}`); res.IsError || !strings.Contains(res.Content, "sleep 30") {
+		t.Fatalf("kill: %s", res.Content)
+	}
+	if res = exec(user, "process_list", `{}`); strings.Contains(res.Content, "p2") {
+		t.Fatalf("killed process still listed: %s", res.Content)
+	}
+}
+
+func TestProcessStartCwd(t *testing.T) {
+	m, root := newTestProcessManager(t, ProcessLimits{})
+	outside := t.TempDir()
+	user := &ToolAuthContext{CallerChannel: "telegram", CallerChatID: 5}
+	control := &ToolAuthContext{CallerChannel: "telegram", CallerChatID: 1, ControlChatIDs: []int64{1}}
+
+	tests := []struct {
+		name    string
+		auth    *ToolAuthContext
+		cwd     string
+		policy  *PathPolicy
+		wantErr string
+	}{
+		{"own workspace", user, "", nil, ""},
+		{"parent escape", user, "..", nil, "is denied"},
+		{"absolute outside", user, outside, nil, "outside the chat's working directory"},
+		{"other chat", user, filepath.Join(root, "chat-6"), nil, "another chat's workspace"},
+		{"control chat elsewhere", control, outside, nil, ""},
+		{"path policy", control, outside, NewPathPolicy([]string{root}, nil, nil, nil), "outside allowed roots"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tool := NewProcessStartTool(m, tc.policy)
+			input, _ := json.Marshal(map[string]string{"command": "true", "cwd": tc.cwd})
+			res := tool.Execute(context.Background(), InjectAuthContext(input, tc.auth))
+			if tc.wantErr == "" {
+				if res.IsError {
+					t.Fatalf("unexpected error: %s", res.Content)
+				}
+				return
+			}
+			if !res.IsError || !strings.Contains(res.Content, tc.wantErr) {
+				t.Fatalf("got %q, want error containing %q", res.Content, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestProcessLimitsAndPruning(t *testing.T) {
+	m, _ := newTestProcessManager(t, ProcessLimits{MaxPerChat: 1, PidsLimit: 64})
+	user := &ToolAuthContext{CallerChannel: "telegram", CallerChatID: 5}
+	other := &ToolAuthContext{CallerChannel: "telegram", CallerChatID: 6}
+	start := func(auth *ToolAuthContext, command string) (*managedProcess, error) {
+		dir, err := m.ResolveDir(auth, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.Start(auth, command, dir)
+	}
+
+	p, err := start(user, "sleep 30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := start(user, "sleep 30"); err == nil || !strings.Contains(err.Error(), "too many background processes") {
+		t.Fatalf("second start err = %v", err)
+	}
+	first, err := start(other, "ulimit -u")
+	if err != nil {
+		t.Fatalf("other chat start: %v", err)
+	}
+	m.KillChat(5)
+	if len(m.List(user)) != 0 {
+		t.Fatal("KillChat left processes behind")
+	}
+	if !p.exited() {
+		t.Fatal("KillChat did not stop the process")
+	}
+
+	// A process started long ago that only just exited is kept; one that
+	// exited over an hour ago is forgotten on the next Start.
+	waitExit := func(q *managedProcess) {
+		t.Helper()
+		select {
+		case <-q.done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("process did not exit")
+		}
+	}
+	waitExit(first)
+	if text, _ := m.ReadNew(first, 0); text != "64\n" {
+		t.Errorf("pids limit not applied: ulimit -u = %q", text)
+	}
+	recent, err := start(user, "true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := start(other, "true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitExit(recent)
+	waitExit(old)
+	m.mu.Lock()
+	recent.started = time.Now().Add(-2 * time.Hour)
+	old.ended = time.Now().Add(-2 * time.Hour)
+	m.mu.Unlock()
+	if _, err := start(user, "true"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.get(nil, recent.id); err != nil {
+		t.Errorf("recently exited process was pruned: %v", err)
+	}
+	if _, err := m.get(nil, old.id); err == nil {
+		t.Error("long-exited process was not pruned")
+	}
+}
+
+func TestCommandRisk(t *testing.T) {
+	tests := map[string]ToolRisk{
+		"go test ./...":                 RiskMedium,
+		"rm -rf build/":                 RiskMedium,
+		"rm -rf ~/proj":                 RiskMedium,
+		"npm run dev | tee dev.log":     RiskMedium,
+		"sudo apt install jq":           RiskHigh,
+		"cd /tmp && sudo make install":  RiskHigh,
+		"rm -rf /":                      RiskHigh,
+		"rm -fr ~/":                     RiskHigh,
+		"rm -r --no-preserve-root /*":   RiskHigh,
+		"dd if=img of=/dev/sda":         RiskHigh,
+		"curl -fsSL x.sh | bash":        RiskHigh,
+		"wget -qO- x | sudo sh -s -- a": RiskHigh,
+	}
+	for cmd, want := range tests {
+		if got, _ := commandRisk(cmd); got != want {
+			t.Errorf("commandRisk(%q) = %s, want %s", cmd, got, want)
+		}
+	}
+
+	// process_start is gated like bash.
+	m, _ := newTestProcessManager(t, ProcessLimits{})
+	r := NewToolRegistry()
+	r.Register(NewBashTool(m.workspace))
+	r.Register(NewProcessStartTool(m, nil))
+	user := &ToolAuthContext{CallerChannel: "telegram", CallerChatID: 5}
+	for _, name := range []string{"bash", "process_start"} {
+		res := r.ExecuteWithAuth(context.Background(), name, json.RawMessage(`{"command":"sudo id"}`), user)
+		if !res.IsError || res.ErrorType == nil || *res.ErrorType != "risk_denied" {
+			t.Errorf("%s ran a high-risk command: %s", name, res.Content)
+		}
+	}
+}
+
+func TestOutputBufferLimit(t *testing.T) {
+	b := &outputBuffer{}
+	chunk := strings.Repeat("x", 1000)
+	for b.Total() < maxProcessBuffer+5000 {
+		b.Write([]byte(chunk))
+	}
+	b.Write([]byte("tail"))
+
+	text, next, dropped := b.ReadFrom(0)
+	if len(text) != maxProcessBuffer || !strings.HasSuffix(text, "tail") {
+		t.Fatalf("retained %d bytes", len(text))
+	}
+	if next != b.Total() || dropped != b.Total()-maxProcessBuffer {
+		t.Fatalf("next = %d, dropped = %d, total = %d", next, dropped, b.Total())
+	}
+	if text, _, dropped := b.ReadFrom(next - 4); text != "tail" || dropped != 0 {
+		t.Fatalf("ReadFrom(next-4) = %q, %d", text, dropped)
+	}
+}
+
+func TestMemoryLimitKB(t *testing.T) {
+	tests := map[string]int64{"": 0, "512m": 512 << 10, "2G": 2 << 20, "1048576": 1024, "64kb": 64, "junk": 0, "-1m": 0}
+	for in, want := range tests {
+		if got := memoryLimitKB(in); got != want {
+			t.Errorf("memoryLimitKB(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
@@ -13,11 +13,12 @@ import (
 )
 
 type ReadFileTool struct {
-	workingDir string
+	workspace *Workspace
+	policy    *PathPolicy
 }
 
-func NewReadFileTool(workingDir string) *ReadFileTool {
-	return &ReadFileTool{workingDir: workingDir}
+func NewReadFileTool(workspace *Workspace, policy *PathPolicy) *ReadFileTool {
+	return &ReadFileTool{workspace: workspace, policy: policy}
 }
 
 func (t *ReadFileTool) Name() string { return "read_file" }
@@ -47,8 +48,12 @@ func (t *ReadFileTool) Execute(_ context.Context, input json.RawMessage) ToolRes
 		return Error("path is required")
 	}
 
-	path := resolvePath(t.workingDir, params.Path)
-	if err := CheckPath(path); err != nil {
+	auth := ExtractAuthContext(input)
+	path, err := t.workspace.Resolve(auth, params.Path)
+	if err != nil {
+		return Error(err.Error())
+	}
+	if err := t.policy.Enforce(auth, t.Name(), path, false); err != nil {
 		return Error(err.Error())
 	}
 
@@ -3,78 +3,296 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/yifanes/miniclawd/internal/core"
+	"github.com/yifanes/miniclawd/internal/netguard"
 	"github.com/yifanes/miniclawd/internal/storage"
 )
 
+// RegistryChange describes one change to the set of registered tools.
+type RegistryChange struct {
+	Version uint64
+	Added   []string // registered or replaced
+	Removed []string
+}
+
 // ToolRegistry manages tool registration, definition caching, and execution.
+// It is safe for concurrent use: tools may be registered and unregistered
+// while agents are running, e.g. as MCP servers connect and disconnect.
 type ToolRegistry struct {
+	mu       sync.RWMutex
 	tools    map[string]Tool
-	defs     []core.ToolDefinition
-	defsOnce sync.Once
+	schemas  map[string]*inputSchema // parsed input schemas, by tool name
+	version  uint64
+	defs     []core.ToolDefinition // sorted by name; valid for defsVer
+	defsVer  uint64
+	risk     *RiskPolicy
+	policy   *ToolPolicy
+	audit    *storage.Database
+	watchers map[int]func(RegistryChange)
+	nextW    int
 }
 
 // NewToolRegistry creates an empty registry.
 func NewToolRegistry() *ToolRegistry {
 	return &ToolRegistry{
-		tools: make(map[string]Tool),
+		tools:    make(map[string]Tool),
+		schemas:  make(map[string]*inputSchema),
+		watchers: make(map[int]func(RegistryChange)),
 	}
 }
 
-// Register adds a tool to the registry.
+// Register adds a tool to the registry, replacing any tool with the same name.
 func (r *ToolRegistry) Register(t Tool) {
+	schema := compileInputSchema(t.Definition().InputSchema)
+	r.mu.Lock()
 	r.tools[t.Name()] = t
+	r.schemas[t.Name()] = schema
+	change := r.bumpLocked([]string{t.Name()}, nil)
+	r.mu.Unlock()
+	r.notify(change)
+}
+
+// Unregister removes a tool and reports whether it was registered.
+func (r *ToolRegistry) Unregister(name string) bool {
+	r.mu.Lock()
+	if _, ok := r.tools[name]; !ok {
+		r.mu.Unlock()
+		return false
+	}
+	delete(r.tools, name)
+	delete(r.schemas, name)
+	change := r.bumpLocked(nil, []string{name})
+	r.mu.Unlock()
+	r.notify(change)
+	return true
+}
+
+func (r *ToolRegistry) bumpLocked(added, removed []string) RegistryChange {
+	r.version++
+	return RegistryChange{Version: r.version, Added: added, Removed: removed}
+}
+
+// OnChange calls fn after every Register and Unregister until the returned
+// cancel function is called. fn runs synchronously on the registering
+// goroutine, outside the registry lock, so it may call back into the
+// registry but should not block.
+func (r *ToolRegistry) OnChange(fn func(RegistryChange)) (cancel func()) {
+	r.mu.Lock()
+	id := r.nextW
+	r.nextW++
+	r.watchers[id] = fn
+	r.mu.Unlock()
+	return func() {
+		r.mu.Lock()
+		delete(r.watchers, id)
+		r.mu.Unlock()
+	}
+}
+
+func (r *ToolRegistry) notify(change RegistryChange) {
+	r.mu.RLock()
+	fns := make([]func(RegistryChange), 0, len(r.watchers))
+	for _, fn := range r.watchers {
+		fns = append(fns, fn)
+	}
+	r.mu.RUnlock()
+	for _, fn := range fns {
+		fn(change)
+	}
+}
+
+// Version returns a counter that increases whenever the tool set changes.
+func (r *ToolRegistry) Version() uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.version
 }
 
-// Definitions returns cached tool definitions.
-func (r *ToolRegistry) Definitions() []core.ToolDefinition {
-	r.defsOnce.Do(func() {
-		r.defs = make([]core.ToolDefinition, 0, len(r.tools))
-		for _, t := range r.tools {
-			r.defs = append(r.defs, t.Definition())
+// SetRiskPolicy sets the policy checked for tools implementing RiskAssessor.
+// A nil policy applies the default limits.
+func (r *ToolRegistry) SetRiskPolicy(p *RiskPolicy) {
+	r.mu.Lock()
+	r.risk = p
+	r.mu.Unlock()
+}
+
+// SetAuditLog records every ExecuteWithAuth call in db's audit_logs.
+func (r *ToolRegistry) SetAuditLog(db *storage.Database) {
+	r.mu.Lock()
+	r.audit = db
+	r.mu.Unlock()
+}
+
+// SetToolPolicy sets which tools each caller may see and run.
+func (r *ToolRegistry) SetToolPolicy(p *ToolPolicy) {
+	r.mu.Lock()
+	r.policy = p
+	r.mu.Unlock()
+}
+
+// Definitions returns the definitions of the tools auth may use, sorted by
+// name.
+func (r *ToolRegistry) Definitions(auth *ToolAuthContext) []core.ToolDefinition {
+	defs, _ := r.VersionedDefinitions(auth)
+	return defs
+}
+
+// VersionedDefinitions is like Definitions but also returns the registry
+// version the definitions belong to, so callers can tell when to refresh.
+func (r *ToolRegistry) VersionedDefinitions(auth *ToolAuthContext) ([]core.ToolDefinition, uint64) {
+	r.mu.RLock()
+	if r.defs == nil || r.defsVer != r.version {
+		r.mu.RUnlock()
+		r.mu.Lock()
+		if r.defs == nil || r.defsVer != r.version {
+			r.rebuildDefsLocked()
 		}
-	})
-	return r.defs
+		r.mu.Unlock()
+		r.mu.RLock()
+	}
+	all, version, policy := r.defs, r.defsVer, r.policy
+	r.mu.RUnlock()
+
+	if policy == nil {
+		return all, version
+	}
+	defs := make([]core.ToolDefinition, 0, len(all))
+	for _, d := range all {
+		if policy.Allows(auth, d.Name) {
+			defs = append(defs, d)
+		}
+	}
+	return defs, version
+}
+
+// rebuildDefsLocked builds a fresh slice rather than reusing r.defs, which
+// callers may still hold.
+func (r *ToolRegistry) rebuildDefsLocked() {
+	defs := make([]core.ToolDefinition, 0, len(r.tools))
+	for _, t := range r.tools {
+		defs = append(defs, t.Definition())
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	r.defs = defs
+	r.defsVer = r.version
+}
+
+// Get returns the tool registered under name.
+func (r *ToolRegistry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.tools[name]
+	return t, ok
 }
 
 // Execute runs a tool by name and returns its result.
 func (r *ToolRegistry) Execute(ctx context.Context, name string, input json.RawMessage) ToolResult {
+	r.mu.RLock()
 	t, ok := r.tools[name]
+	schema := r.schemas[name]
+	risk := r.risk
+	r.mu.RUnlock()
 	if !ok {
 		return Error("unknown tool: " + name)
 	}
+	// Reject malformed input up front with every problem listed, so the
+	// model can fix its call in one retry.
+	if problems := schema.validate(input); len(problems) > 0 {
+		return ErrorWithType("invalid input for "+name+":\n- "+strings.Join(problems, "\n- "), "invalid_input")
+	}
+	if ra, ok := t.(RiskAssessor); ok {
+		level, action := ra.Risk(input)
+		if err := risk.Check(ExtractAuthContext(input), name, action, level); err != nil {
+			return ErrorWithType(err.Error(), "risk_denied")
+		}
+	}
 
 	start := time.Now()
 	result := t.Execute(ctx, input)
 	dur := time.Since(start).Milliseconds()
 	result.DurationMs = &dur
 
+	// Never hand stored secret values back to the model.
+	result.Content = core.RedactSecrets(result.Content)
+	if result.Diff != nil {
+		result.Diff.Diff = core.RedactSecrets(result.Diff.Diff)
+	}
+	result.Blocks = core.RedactBlocks(result.Blocks)
+
 	return result
 }
 
-// ExecuteWithAuth injects auth context into the input, then executes.
+// ExecuteWithAuth injects auth context into the input, executes, and records
+// the call in the audit log.
 func (r *ToolRegistry) ExecuteWithAuth(ctx context.Context, name string, input json.RawMessage, auth *ToolAuthContext) ToolResult {
-	if auth != nil {
-		input = InjectAuthContext(input, auth)
+	r.mu.RLock()
+	policy := r.policy
+	r.mu.RUnlock()
+
+	var result ToolResult
+	// Definitions already hides denied tools; enforce again in case the
+	// model calls one anyway.
+	if !policy.Allows(auth, name) {
+		result = ErrorWithType("tool "+name+" is not permitted in this chat", "policy_denied")
+	} else {
+		if auth != nil {
+			input = InjectAuthContext(input, auth)
+		}
+		result = r.Execute(ctx, name, input)
+	}
+	r.recordExecution(name, input, auth, result)
+	return result
+}
+
+// Authorize applies the tool policy and risk checks that executing name
+// with input would, without running it, and audits a denial. Other ways of
+// reaching a tool's capability, such as MCP prompt slash commands, use it
+// to be gated the same way as the tool.
+func (r *ToolRegistry) Authorize(auth *ToolAuthContext, name string, input json.RawMessage) error {
+	r.mu.RLock()
+	t, ok := r.tools[name]
+	policy, risk := r.policy, r.risk
+	r.mu.RUnlock()
+
+	var denied ToolResult
+	if !policy.Allows(auth, name) {
+		denied = ErrorWithType("tool "+name+" is not permitted in this chat", "policy_denied")
+	} else if ra, isRA := t.(RiskAssessor); ok && isRA {
+		level, action := ra.Risk(input)
+		if err := risk.Check(auth, name, action, level); err != nil {
+			denied = ErrorWithType(err.Error(), "risk_denied")
+		}
+	}
+	if !denied.IsError {
+		return nil
 	}
-	return r.Execute(ctx, name, input)
+	r.recordExecution(name, input, auth, denied)
+	return errors.New(denied.Content)
 }
 
 // Has returns true if the registry contains a tool with the given name.
 func (r *ToolRegistry) Has(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	_, ok := r.tools[name]
 	return ok
 }
 
 // ToolNames returns a sorted list of registered tool names.
 func (r *ToolRegistry) ToolNames() []string {
+	r.mu.RLock()
 	names := make([]string, 0, len(r.tools))
 	for name := range r.tools {
 		names = append(names, name)
 	}
+	r.mu.RUnlock()
+	sort.Strings(names)
 	return names
 }
 
@@ -89,6 +307,30 @@ type RegistryConfig struct {
 	SubAgent   SubAgentRunner
 	McpCaller  McpCaller
 
+	// Working directory isolation: "chat" (per-chat subdirectories) or "shared".
+	WorkingDirIsolation string
+
+	// PathPolicy confines file tools; nil applies only the built-in denylist.
+	PathPolicy *PathPolicy
+
+	// Processes backs the background process tools; nil disables them.
+	Processes *ProcessManager
+
+	// Outbound guards tool HTTP requests against SSRF; nil uses the strict default.
+	Outbound *netguard.Policy
+
+	// WebSearch selects the web_search backend.
+	WebSearch SearchConfig
+
+	// RiskPolicy limits risky tool actions; nil applies the default limits.
+	RiskPolicy *RiskPolicy
+
+	// ToolPolicy limits which tools each chat may use; nil allows all.
+	ToolPolicy *ToolPolicy
+
+	// HTTPRequest configures the http_request tool's allowlist and credentials.
+	HTTPRequest HTTPRequestConfig
+
 	// ClawHub
 	ClawHubEnabled    bool
 	ClawHubRegistry   string
@@ -98,18 +340,34 @@ type RegistryConfig struct {
 // BuildStandardRegistry creates the full tool registry with all tools.
 func BuildStandardRegistry(cfg RegistryConfig) *ToolRegistry {
 	r := NewToolRegistry()
+	r.SetRiskPolicy(cfg.RiskPolicy)
+	r.SetToolPolicy(cfg.ToolPolicy)
+	r.SetAuditLog(cfg.DB)
+	workspace := NewWorkspace(cfg.WorkingDir, cfg.WorkingDirIsolation)
 
 	// File tools
-	r.Register(NewBashTool(cfg.WorkingDir))
-	r.Register(NewReadFileTool(cfg.WorkingDir))
-	r.Register(NewWriteFileTool(cfg.WorkingDir))
-	r.Register(NewEditFileTool(cfg.WorkingDir))
-	r.Register(NewGlobTool(cfg.WorkingDir))
-	r.Register(NewGrepTool(cfg.WorkingDir))
+	r.Register(NewBashTool(workspace))
+	r.Register(NewReadFileTool(workspace, cfg.PathPolicy))
+	r.Register(NewWriteFileTool(workspace, cfg.PathPolicy))
+	r.Register(NewEditFileTool(workspace, cfg.PathPolicy))
+	r.Register(NewApplyPatchTool(workspace, cfg.PathPolicy))
+	r.Register(NewGlobTool(workspace, cfg.PathPolicy))
+	r.Register(NewGrepTool(workspace, cfg.PathPolicy))
+	r.Register(NewGitTool(workspace, cfg.PathPolicy))
+
+	// Background processes
+	if cfg.Processes != nil {
+		r.Register(NewProcessStartTool(cfg.Processes, cfg.PathPolicy))
+		r.Register(NewProcessOutputTool(cfg.Processes))
+		r.Register(NewProcessWriteTool(cfg.Processes))
+		r.Register(NewProcessListTool(cfg.Processes))
+		r.Register(NewProcessKillTool(cfg.Processes))
+	}
 
 	// Web tools
-	r.Register(NewWebFetchTool())
-	r.Register(NewWebSearchTool())
+	r.Register(NewWebFetchTool(cfg.Outbound))
+	r.Register(NewWebSearchTool(cfg.WebSearch, cfg.Outbound))
+	r.Register(NewHTTPRequestTool(cfg.HTTPRequest, cfg.Outbound))
 	r.Register(NewBrowserTool(cfg.DataDir))
 
 	// Memory tools
@@ -130,8 +388,11 @@ func BuildStandardRegistry(cfg RegistryConfig) *ToolRegistry {
 	r.Register(NewCancelScheduledTaskTool(cfg.DB))
 	r.Register(NewGetScheduledTaskHistoryTool(cfg.DB))
 
+	// History
+	r.Register(NewSearchHistoryTool(cfg.DB))
+
 	// Export
-	r.Register(NewExportChatTool(cfg.DB, cfg.DataDir))
+	r.Register(NewExportChatTool(cfg.DB, cfg.DataDir, cfg.PathPolicy))
 
 	// Sub-agent
 	r.Register(NewSubAgentTool(cfg.SubAgent))
@@ -142,12 +403,12 @@ func BuildStandardRegistry(cfg RegistryConfig) *ToolRegistry {
 
 	// Skills
 	r.Register(NewActivateSkillTool(cfg.SkillsDir))
-	r.Register(NewSyncSkillsTool(cfg.SkillsDir))
+	r.Register(NewSyncSkillsTool(cfg.SkillsDir, cfg.Outbound))
 
 	// ClawHub
 	if cfg.ClawHubEnabled {
-		r.Register(NewClawHubSearchTool(cfg.ClawHubRegistry, cfg.ClawHubToken))
-		r.Register(NewClawHubInstallTool(cfg.ClawHubRegistry, cfg.ClawHubToken, cfg.SkillsDir))
+		r.Register(NewClawHubSearchTool(cfg.ClawHubRegistry, cfg.ClawHubToken, cfg.Outbound))
+		r.Register(NewClawHubInstallTool(cfg.ClawHubRegistry, cfg.ClawHubToken, cfg.SkillsDir, cfg.Outbound))
 	}
 
 	return r
@@ -156,15 +417,21 @@ func BuildStandardRegistry(cfg RegistryConfig) *ToolRegistry {
 // BuildSubAgentRegistry creates a restricted tool registry for sub-agents.
 func BuildSubAgentRegistry(cfg RegistryConfig) *ToolRegistry {
 	r := NewToolRegistry()
+	r.SetRiskPolicy(cfg.RiskPolicy)
+	r.SetToolPolicy(cfg.ToolPolicy)
+	r.SetAuditLog(cfg.DB)
+	workspace := NewWorkspace(cfg.WorkingDir, cfg.WorkingDirIsolation)
 
-	r.Register(NewBashTool(cfg.WorkingDir))
-	r.Register(NewReadFileTool(cfg.WorkingDir))
-	r.Register(NewWriteFileTool(cfg.WorkingDir))
-	r.Register(NewEditFileTool(cfg.WorkingDir))
-	r.Register(NewGlobTool(cfg.WorkingDir))
-	r.Register(NewGrepTool(cfg.WorkingDir))
-	r.Register(NewWebFetchTool())
-	r.Register(NewWebSearchTool())
+	r.Register(NewBashTool(workspace))
+	r.Register(NewReadFileTool(workspace, cfg.PathPolicy))
+	r.Register(NewWriteFileTool(workspace, cfg.PathPolicy))
+	r.Register(NewEditFileTool(workspace, cfg.PathPolicy))
+	r.Register(NewApplyPatchTool(workspace, cfg.PathPolicy))
+	r.Register(NewGlobTool(workspace, cfg.PathPolicy))
+	r.Register(NewGrepTool(workspace, cfg.PathPolicy))
+	r.Register(NewGitTool(workspace, cfg.PathPolicy))
+	r.Register(NewWebFetchTool(cfg.Outbound))
+	r.Register(NewWebSearchTool(cfg.WebSearch, cfg.Outbound))
 	r.Register(NewBrowserTool(cfg.DataDir))
 	r.Register(NewReadMemoryTool(cfg.DataDir, cfg.DB))
 	r.Register(NewActivateSkillTool(cfg.SkillsDir))
@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/yifanes/miniclawd/internal/core"
+)
+
+type stubTool struct{ name string }
+
+func (s stubTool) Name() string { return s.name }
+func (s stubTool) Definition() core.ToolDefinition {
+	return core.ToolDefinition{Name: s.name, Description: "stub", InputSchema: json.RawMessage(`{"type":"object"}`)}
+}
+func (s stubTool) Execute(context.Context, json.RawMessage) ToolResult { return Success(s.name) }
+
+func TestRegistryDynamic(t *testing.T) {
+	r := NewToolRegistry()
+	r.Register(stubTool{"b"})
+	r.Register(stubTool{"a"})
+
+	var changes []RegistryChange
+	cancel := r.OnChange(func(c RegistryChange) { changes = append(changes, c) })
+
+	defs, v := r.VersionedDefinitions(nil)
+	if len(defs) != 2 || defs[0].Name != "a" || v != r.Version() {
+		t.Fatalf("unexpected definitions %v at v%d", defs, v)
+	}
+
+	r.Register(stubTool{"c"})
+	if defs := r.Definitions(nil); len(defs) != 3 {
+		t.Errorf("tool registered after first Definitions not advertised: %v", defs)
+	}
+	if !r.Unregister("a") || r.Unregister("a") {
+		t.Error("Unregister should report whether the tool existed")
+	}
+	if res := r.Execute(context.Background(), "a", json.RawMessage(`{}`)); !res.IsError {
+		t.Error("unregistered tool still executable")
+	}
+	if len(changes) != 2 || changes[1].Removed[0] != "a" || changes[1].Version != r.Version() {
+		t.Errorf("unexpected change notifications: %+v", changes)
+	}
+	cancel()
+	r.Register(stubTool{"d"})
+	if len(changes) != 2 {
+		t.Error("notified after cancel")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("t%d", i)
+			for j := 0; j < 50; j++ {
+				r.Register(stubTool{name})
+				r.Definitions(nil)
+				r.Execute(context.Background(), name, json.RawMessage(`{}`))
+				r.Unregister(name)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if names := r.ToolNames(); len(names) != 3 {
+		t.Errorf("expected 3 tools after concurrent churn, got %v", names)
+	}
+}
+
+type blocksTool struct{ text string }
+
+func (b blocksTool) Name() string { return "blocks" }
+func (b blocksTool) Definition() core.ToolDefinition {
+	return core.ToolDefinition{Name: "blocks", Description: "stub", InputSchema: json.RawMessage(`{"type":"object"}`)}
+}
+func (b blocksTool) Execute(context.Context, json.RawMessage) ToolResult {
+	res := Success("see blocks")
+	res.Blocks = []core.ContentBlock{core.TextBlock(b.text)}
+	return res
+}
+
+func TestRegistryRedactsBlocks(t *testing.T) {
+	core.RegisterSecret("blocks-secret-4f1c9a")
+	r := NewToolRegistry()
+	r.Register(blocksTool{text: "token=blocks-secret-4f1c9a"})
+	res := r.Execute(context.Background(), "blocks", json.RawMessage(`{}`))
+	if len(res.Blocks) != 1 || res.Blocks[0].Text != "token=[REDACTED]" {
+		t.Errorf("blocks = %+v", res.Blocks)
+	}
+}
+
+func TestRegistryAuthorize(t *testing.T) {
+	r := NewToolRegistry()
+	r.Register(stubTool{name: "mcp_get_prompt"})
+	r.Register(NewGitTool(NewWorkspace(t.TempDir(), "shared"), nil))
+	r.SetToolPolicy(NewToolPolicy([]ToolPolicyRule{{ChatIDs: []int64{5}, Deny: []string{"mcp_*"}}}))
+	user := &ToolAuthContext{CallerChatID: 5}
+	other := &ToolAuthContext{CallerChatID: 6}
+
+	if err := r.Authorize(user, "mcp_get_prompt", json.RawMessage(`{}`)); err == nil {
+		t.Error("denied tool authorized")
+	}
+	if err := r.Authorize(other, "mcp_get_prompt", json.RawMessage(`{}`)); err != nil {
+		t.Errorf("allowed tool denied: %v", err)
+	}
+	if err := r.Authorize(other, "git", json.RawMessage(`{"operation":"reset","mode":"hard"}`)); err == nil {
+		t.Error("high-risk call authorized")
+	}
+}
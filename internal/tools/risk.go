@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+func (r ToolRisk) String() string {
+	switch r {
+	case RiskLow:
+		return "low"
+	case RiskMedium:
+		return "medium"
+	default:
+		return "high"
+	}
+}
+
+// ParseToolRisk parses "low", "medium" or "high".
+func ParseToolRisk(s string) (ToolRisk, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "low":
+		return RiskLow, true
+	case "medium":
+		return RiskMedium, true
+	case "high":
+		return RiskHigh, true
+	}
+	return RiskLow, false
+}
+
+// RiskAssessor is implemented by tools whose risk depends on the call, e.g.
+// a git tool where "status" is harmless and "reset --hard" is not. The
+// registry checks the assessed risk against its RiskPolicy before executing.
+type RiskAssessor interface {
+	Risk(input json.RawMessage) (ToolRisk, string)
+}
+
+// RiskPolicy caps the risk a caller may run. Control chats get their own,
+// usually higher, ceiling.
+type RiskPolicy struct {
+	MaxRisk        ToolRisk
+	ControlMaxRisk ToolRisk
+}
+
+// NewRiskPolicy creates a policy with the given ceilings.
+func NewRiskPolicy(maxRisk, controlMaxRisk ToolRisk) *RiskPolicy {
+	return &RiskPolicy{MaxRisk: maxRisk, ControlMaxRisk: controlMaxRisk}
+}
+
+// defaultRiskPolicy lets ordinary chats run medium-risk actions and reserves
+// high-risk ones for control chats.
+var defaultRiskPolicy = &RiskPolicy{MaxRisk: RiskMedium, ControlMaxRisk: RiskHigh}
+
+// Check returns an error if auth may not perform action at the given risk.
+// A nil policy behaves like the default.
+func (p *RiskPolicy) Check(auth *ToolAuthContext, tool, action string, risk ToolRisk) error {
+	if p == nil {
+		p = defaultRiskPolicy
+	}
+	limit := p.MaxRisk
+	if auth != nil && auth.IsControlChat() {
+		limit = p.ControlMaxRisk
+	}
+	if risk <= limit {
+		return nil
+	}
+	return fmt.Errorf("%s %s is %s risk and not permitted here (limit: %s)", tool, action, risk, limit)
+}
@@ -0,0 +1,342 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxInputProblems caps how many problems one invalid_input error lists.
+const maxInputProblems = 20
+
+// inputSchema is a tool's parsed input schema with its patterns compiled.
+// The registry builds one per tool at Register, so a call only has to
+// decode its input.
+type inputSchema struct {
+	root     map[string]any
+	patterns map[string]*regexp.Regexp
+}
+
+// compileInputSchema parses a tool's JSON Schema. It returns nil for an
+// empty or unparseable schema, which accepts everything; invalid patterns
+// are ignored.
+func compileInputSchema(schema json.RawMessage) *inputSchema {
+	if len(bytes.TrimSpace(schema)) == 0 {
+		return nil
+	}
+	var root map[string]any
+	if err := json.Unmarshal(schema, &root); err != nil {
+		return nil
+	}
+	s := &inputSchema{root: root, patterns: map[string]*regexp.Regexp{}}
+	var walk func(v any)
+	walk = func(v any) {
+		switch t := v.(type) {
+		case map[string]any:
+			for k, child := range t {
+				if p, ok := child.(string); ok && k == "pattern" {
+					if re, err := regexp.Compile(p); err == nil {
+						s.patterns[p] = re
+					}
+					continue
+				}
+				walk(child)
+			}
+		case []any:
+			for _, child := range t {
+				walk(child)
+			}
+		}
+	}
+	walk(root)
+	return s
+}
+
+// validate checks input against the schema and returns one message per
+// problem. It covers the subset of JSON Schema tools actually use: type,
+// required, properties, additionalProperties, items, enum, const, numeric
+// and length bounds, pattern, anyOf and oneOf. Unknown keywords are
+// ignored, and null for an optional property counts as absent.
+func (s *inputSchema) validate(input json.RawMessage) []string {
+	if s == nil {
+		return nil
+	}
+	if len(bytes.TrimSpace(input)) == 0 || string(bytes.TrimSpace(input)) == "null" {
+		input = json.RawMessage(`{}`)
+	}
+	dec := json.NewDecoder(bytes.NewReader(input))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return []string{"input is not valid JSON: " + err.Error()}
+	}
+
+	var problems []string
+	s.checkValue(s.root, v, "", &problems)
+	if len(problems) > maxInputProblems {
+		problems = append(problems[:maxInputProblems], fmt.Sprintf("... and %d more", len(problems)-maxInputProblems))
+	}
+	return problems
+}
+
+func (cs *inputSchema) checkValue(s map[string]any, v any, path string, problems *[]string) {
+	add := func(format string, args ...any) {
+		*problems = append(*problems, pathLabel(path)+": "+fmt.Sprintf(format, args...))
+	}
+
+	if types := schemaTypes(s["type"]); len(types) > 0 {
+		if !matchesAnyType(types, v) {
+			add("expected %s, got %s", strings.Join(types, " or "), jsonTypeName(v))
+			return
+		}
+	}
+	if enum, ok := s["enum"].([]any); ok && !containsJSON(enum, v) {
+		add("must be one of %s", formatEnum(enum))
+	}
+	if c, ok := s["const"]; ok && !jsonEqual(c, v) {
+		add("must be %s", formatJSON(c))
+	}
+	for _, key := range []string{"anyOf", "oneOf"} {
+		branches, ok := s[key].([]any)
+		if !ok || len(branches) == 0 {
+			continue
+		}
+		matched := 0
+		for _, b := range branches {
+			bs, ok := b.(map[string]any)
+			if !ok {
+				continue
+			}
+			var sub []string
+			cs.checkValue(bs, v, path, &sub)
+			if len(sub) == 0 {
+				matched++
+			}
+		}
+		if matched == 0 || (key == "oneOf" && matched > 1) {
+			add("does not match %s schema (%d of %d alternatives match)", key, matched, len(branches))
+		}
+	}
+
+	switch t := v.(type) {
+	case map[string]any:
+		cs.checkObject(s, t, path, problems)
+	case []any:
+		if n, ok := schemaNumber(s["minItems"]); ok && float64(len(t)) < n {
+			add("must have at least %v items", n)
+		}
+		if n, ok := schemaNumber(s["maxItems"]); ok && float64(len(t)) > n {
+			add("must have at most %v items", n)
+		}
+		if items, ok := s["items"].(map[string]any); ok {
+			for i, item := range t {
+				cs.checkValue(items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	case string:
+		n := float64(utf8.RuneCountInString(t))
+		if min, ok := schemaNumber(s["minLength"]); ok && n < min {
+			add("must be at least %v characters", min)
+		}
+		if max, ok := schemaNumber(s["maxLength"]); ok && n > max {
+			add("must be at most %v characters", max)
+		}
+		if p, ok := s["pattern"].(string); ok {
+			if re := cs.patterns[p]; re != nil && !re.MatchString(t) {
+				add("must match pattern %s", p)
+			}
+		}
+	case json.Number:
+		f, _ := t.Float64()
+		if min, ok := schemaNumber(s["minimum"]); ok && f < min {
+			add("must be >= %v, got %s", min, t)
+		}
+		if max, ok := schemaNumber(s["maximum"]); ok && f > max {
+			add("must be <= %v, got %s", max, t)
+		}
+		if min, ok := schemaNumber(s["exclusiveMinimum"]); ok && f <= min {
+			add("must be > %v, got %s", min, t)
+		}
+		if max, ok := schemaNumber(s["exclusiveMaximum"]); ok && f >= max {
+			add("must be < %v, got %s", max, t)
+		}
+	}
+}
+
+func (cs *inputSchema) checkObject(s map[string]any, obj map[string]any, path string, problems *[]string) {
+	props, _ := s["properties"].(map[string]any)
+	required := map[string]bool{}
+	for _, r := range schemaStrings(s["required"]) {
+		required[r] = true
+		if _, ok := obj[r]; !ok {
+			*problems = append(*problems, pathLabel(joinPath(path, r))+": required field missing")
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		// The registry injects its own fields; they are not part of any schema.
+		if path == "" && strings.HasPrefix(k, "__miniclawd") {
+			continue
+		}
+		// Tools decode optional fields into pointers, so null means unset.
+		if obj[k] == nil && !required[k] {
+			continue
+		}
+		if ps, ok := props[k].(map[string]any); ok {
+			cs.checkValue(ps, obj[k], joinPath(path, k), problems)
+			continue
+		}
+		switch ap := s["additionalProperties"].(type) {
+		case bool:
+			if !ap {
+				*problems = append(*problems, pathLabel(joinPath(path, k))+": unknown field")
+			}
+		case map[string]any:
+			cs.checkValue(ap, obj[k], joinPath(path, k), problems)
+		}
+	}
+}
+
+func schemaTypes(t any) []string {
+	switch tt := t.(type) {
+	case string:
+		return []string{tt}
+	case []any:
+		return schemaStrings(tt)
+	}
+	return nil
+}
+
+func schemaStrings(v any) []string {
+	list, _ := v.([]any)
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func schemaNumber(v any) (float64, bool) {
+	f, ok := v.(float64)
+	return f, ok
+}
+
+func matchesAnyType(types []string, v any) bool {
+	for _, t := range types {
+		switch t {
+		case "object":
+			if _, ok := v.(map[string]any); ok {
+				return true
+			}
+		case "array":
+			if _, ok := v.([]any); ok {
+				return true
+			}
+		case "string":
+			if _, ok := v.(string); ok {
+				return true
+			}
+		case "boolean":
+			if _, ok := v.(bool); ok {
+				return true
+			}
+		case "null":
+			if v == nil {
+				return true
+			}
+		case "number":
+			if _, ok := v.(json.Number); ok {
+				return true
+			}
+		case "integer":
+			if n, ok := v.(json.Number); ok {
+				if f, err := n.Float64(); err == nil && f == math.Trunc(f) {
+					return true
+				}
+			}
+		default:
+			return true // unknown type keyword: don't second-guess it
+		}
+	}
+	return false
+}
+
+func jsonTypeName(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if f, err := t.Float64(); err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// jsonEqual compares a schema value (decoded as float64) with an input value
+// (decoded with UseNumber).
+func jsonEqual(a, b any) bool {
+	if n, ok := b.(json.Number); ok {
+		f, err := n.Float64()
+		af, aok := a.(float64)
+		return err == nil && aok && f == af
+	}
+	return formatJSON(a) == formatJSON(b)
+}
+
+func containsJSON(list []any, v any) bool {
+	for _, item := range list {
+		if jsonEqual(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatEnum(list []any) string {
+	parts := make([]string, len(list))
+	for i, item := range list {
+		parts[i] = formatJSON(item)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+func formatJSON(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func pathLabel(path string) string {
+	if path == "" {
+		return "input"
+	}
+	return path
+}
@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateInput(t *testing.T) {
+	schema := json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"name":  {"type": "string", "minLength": 1},
+			"count": {"type": "integer", "minimum": 1, "maximum": 10},
+			"mode":  {"type": "string", "enum": ["fast", "slow"]},
+			"tags":  {"type": "array", "items": {"type": "string"}},
+			"opts":  {"type": "object", "properties": {"deep": {"type": "boolean"}}, "additionalProperties": false},
+			"code":  {"type": "string", "pattern": "^[a-z]+$"}
+		},
+		"required": ["name"]
+	}`)
+
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{`{"name":"x","count":3,"mode":"fast","tags":["a"],"opts":{"deep":true}}`, nil},
+		{`{"name":"x","__miniclawd_auth":{"caller_chat_id":1}}`, nil},
+		{`{"name":"x","count":2.0}`, nil},
+		{``, []string{"name: required field missing"}},
+		{`[]`, []string{"input: expected object, got array"}},
+		{`{"name":"x","count":2.5}`, []string{"count: expected integer, got number"}},
+		{`{"name":"x","count":"3"}`, []string{"count: expected integer, got string"}},
+		{`{"name":"x","count":11}`, []string{"count: must be <= 10, got 11"}},
+		{`{"name":"","mode":"medium"}`, []string{"mode: must be one of", "name: must be at least 1 characters"}},
+		{`{"name":"x","count":null,"opts":{"deep":null}}`, nil},
+		{`{"name":null}`, []string{"name: expected string, got null"}},
+		{`{"name":"x","code":"abc"}`, nil},
+		{`{"name":"x","code":"ABC"}`, []string{"code: must match pattern ^[a-z]+$"}},
+		{`{"name":"x","tags":["a",2],"opts":{"deep":1,"extra":true}}`, []string{"opts.deep: expected boolean", "opts.extra: unknown field", "tags[1]: expected string"}},
+	}
+	compiled := compileInputSchema(schema)
+	for _, tc := range tests {
+		got := compiled.validate(json.RawMessage(tc.input))
+		if len(got) != len(tc.want) {
+			t.Errorf("validate(%s) = %q, want %d problems", tc.input, got, len(tc.want))
+			continue
+		}
+		for i, w := range tc.want {
+			if !strings.HasPrefix(got[i], w) {
+				t.Errorf("validate(%s)[%d] = %q, want prefix %q", tc.input, i, got[i], w)
+			}
+		}
+	}
+
+	r := NewToolRegistry()
+	r.Register(NewTodoWriteTool(t.TempDir()))
+	res := r.Execute(context.Background(), "todo_write", json.RawMessage(`{"todos":[{"task":"a","status":"done"}]}`))
+	if !res.IsError || res.ErrorType == nil || *res.ErrorType != "invalid_input" || !strings.Contains(res.Content, "todos[0].status: must be one of") {
+		t.Errorf("expected invalid_input error, got %+v", res)
+	}
+}
+
+type stubSubAgentRunner struct{ extra *string }
+
+func (s stubSubAgentRunner) RunSubAgent(_ context.Context, task, extraContext string, _ *ToolAuthContext) (string, error) {
+	*s.extra = extraContext
+	return "done: " + task, nil
+}
+
+func TestRegistryAcceptsNullOptionalField(t *testing.T) {
+	var extra string
+	r := NewToolRegistry()
+	r.Register(NewSubAgentTool(stubSubAgentRunner{extra: &extra}))
+	res := r.Execute(context.Background(), "sub_agent", json.RawMessage(`{"task":"sum","context":null}`))
+	if res.IsError || res.Content != "done: sum" || extra != "" {
+		t.Errorf("sub_agent with null context = %+v", res)
+	}
+}
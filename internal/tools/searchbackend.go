@@ -0,0 +1,473 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/yifanes/miniclawd/internal/netguard"
+)
+
+// SearchBackend is a web search provider.
+type SearchBackend interface {
+	Name() string
+	Search(ctx context.Context, q SearchQuery) ([]SearchResult, error)
+}
+
+// SearchQuery is a backend-independent search request.
+type SearchQuery struct {
+	Query     string
+	Count     int
+	Freshness string   // "", "day", "week", "month" or "year"
+	Sites     []string // restrict results to these domains
+}
+
+// SearchResult is a normalized search hit.
+type SearchResult struct {
+	Title     string `json:"title"`
+	URL       string `json:"url"`
+	Snippet   string `json:"snippet"`
+	Published string `json:"published,omitempty"`
+}
+
+// SearchConfig selects and configures the web_search backend.
+type SearchConfig struct {
+	Backend  string // "ddg" (default), "searxng", "brave", "tavily" or "json"
+	URL      string // SearXNG base URL or generic JSON endpoint
+	APIKey   string
+	CacheTTL time.Duration
+
+	// Generic JSON backend field mapping.
+	QueryParam   string            // default "q"
+	CountParam   string            // optional
+	ResultsPath  string            // dot path to the result array, e.g. "data.items"
+	TitleField   string            // default "title"
+	URLField     string            // default "url"
+	SnippetField string            // default "snippet"
+	Headers      map[string]string // extra request headers
+}
+
+// NewSearchBackend builds the configured backend. Operator-configured
+// endpoints are exempt from the private-range block so self-hosted engines
+// work; model-supplied URLs never reach these clients.
+func NewSearchBackend(cfg SearchConfig, guard *netguard.Policy) (SearchBackend, error) {
+	if cfg.URL != "" {
+		u, err := url.Parse(cfg.URL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid search url %q", cfg.URL)
+		}
+		guard = guard.WithAllowedHosts(u.Hostname())
+	}
+	client := guard.Client(20 * time.Second)
+
+	switch strings.ToLower(cfg.Backend) {
+	case "", "ddg", "duckduckgo":
+		return &ddgBackend{client: client}, nil
+	case "searxng":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("searxng backend requires url")
+		}
+		return &searxngBackend{client: client, baseURL: strings.TrimRight(cfg.URL, "/")}, nil
+	case "brave":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("brave backend requires api_key")
+		}
+		return &braveBackend{client: client, apiKey: cfg.APIKey}, nil
+	case "tavily":
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("tavily backend requires api_key")
+		}
+		return &tavilyBackend{client: client, apiKey: cfg.APIKey}, nil
+	case "json":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("json backend requires url")
+		}
+		return &jsonBackend{client: client, cfg: cfg}, nil
+	default:
+		return nil, fmt.Errorf("unknown search backend %q", cfg.Backend)
+	}
+}
+
+// withSites appends site: operators for backends without a domain filter.
+func withSites(q SearchQuery) string {
+	if len(q.Sites) == 0 {
+		return q.Query
+	}
+	parts := make([]string, len(q.Sites))
+	for i, s := range q.Sites {
+		parts[i] = "site:" + s
+	}
+	return q.Query + " (" + strings.Join(parts, " OR ") + ")"
+}
+
+func doSearchRequest(client *http.Client, req *http.Request, out any) error {
+	req.Header.Set("Accept", "application/json")
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; MiniClawd/1.0)")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 2*1024*1024))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		snippet := string(body)
+		if len(snippet) > 200 {
+			snippet = snippet[:200]
+		}
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(snippet))
+	}
+	return json.Unmarshal(body, out)
+}
+
+// --- DuckDuckGo (HTML scraping, no key required) ---
+
+type ddgBackend struct {
+	client *http.Client
+}
+
+func (b *ddgBackend) Name() string { return "duckduckgo" }
+
+func (b *ddgBackend) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	v := url.Values{"q": {withSites(q)}}
+	if df := map[string]string{"day": "d", "week": "w", "month": "m", "year": "y"}[q.Freshness]; df != "" {
+		v.Set("df", df)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://html.duckduckgo.com/html/?"+v.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; MiniClawd/1.0)")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult
+	doc.Find(".result").Each(func(i int, s *goquery.Selection) {
+		if len(results) >= q.Count {
+			return
+		}
+		title := strings.TrimSpace(s.Find(".result__a").Text())
+		link, _ := s.Find(".result__a").Attr("href")
+		snippet := strings.TrimSpace(s.Find(".result__snippet").Text())
+
+		if title != "" && link != "" {
+			results = append(results, SearchResult{Title: title, URL: ddgTarget(link), Snippet: snippet})
+		}
+	})
+	return results, nil
+}
+
+// ddgTarget unwraps DuckDuckGo's /l/?uddg= redirect links.
+func ddgTarget(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	if target := u.Query().Get("uddg"); target != "" {
+		return target
+	}
+	if strings.HasPrefix(link, "//") {
+		return "https:" + link
+	}
+	return link
+}
+
+// --- SearXNG ---
+
+type searxngBackend struct {
+	client  *http.Client
+	baseURL string
+}
+
+func (b *searxngBackend) Name() string { return "searxng" }
+
+func (b *searxngBackend) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	v := url.Values{"q": {withSites(q)}, "format": {"json"}}
+	if q.Freshness != "" {
+		v.Set("time_range", q.Freshness)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", b.baseURL+"/search?"+v.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		Results []struct {
+			Title         string `json:"title"`
+			URL           string `json:"url"`
+			Content       string `json:"content"`
+			PublishedDate string `json:"publishedDate"`
+		} `json:"results"`
+	}
+	if err := doSearchRequest(b.client, req, &body); err != nil {
+		return nil, err
+	}
+	var results []SearchResult
+	for _, r := range body.Results {
+		if len(results) >= q.Count {
+			break
+		}
+		results = append(results, SearchResult{Title: r.Title, URL: r.URL, Snippet: r.Content, Published: r.PublishedDate})
+	}
+	return results, nil
+}
+
+// --- Brave Search API ---
+
+type braveBackend struct {
+	client *http.Client
+	apiKey string
+}
+
+func (b *braveBackend) Name() string { return "brave" }
+
+func (b *braveBackend) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	v := url.Values{"q": {withSites(q)}, "count": {strconv.Itoa(min(q.Count, 20))}}
+	if f := map[string]string{"day": "pd", "week": "pw", "month": "pm", "year": "py"}[q.Freshness]; f != "" {
+		v.Set("freshness", f)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.search.brave.com/res/v1/web/search?"+v.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Subscription-Token", b.apiKey)
+	var body struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+				Age         string `json:"age"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	if err := doSearchRequest(b.client, req, &body); err != nil {
+		return nil, err
+	}
+	var results []SearchResult
+	for _, r := range body.Web.Results {
+		results = append(results, SearchResult{Title: r.Title, URL: r.URL, Snippet: stripTags(r.Description), Published: r.Age})
+	}
+	return results, nil
+}
+
+// --- Tavily ---
+
+type tavilyBackend struct {
+	client *http.Client
+	apiKey string
+}
+
+func (b *tavilyBackend) Name() string { return "tavily" }
+
+func (b *tavilyBackend) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	payload := map[string]any{
+		"query":       q.Query,
+		"max_results": min(q.Count, 20),
+	}
+	if q.Freshness != "" {
+		payload["time_range"] = q.Freshness
+	}
+	if len(q.Sites) > 0 {
+		payload["include_domains"] = q.Sites
+	}
+	data, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.tavily.com/search", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+b.apiKey)
+	var body struct {
+		Results []struct {
+			Title         string `json:"title"`
+			URL           string `json:"url"`
+			Content       string `json:"content"`
+			PublishedDate string `json:"published_date"`
+		} `json:"results"`
+	}
+	if err := doSearchRequest(b.client, req, &body); err != nil {
+		return nil, err
+	}
+	var results []SearchResult
+	for _, r := range body.Results {
+		results = append(results, SearchResult{Title: r.Title, URL: r.URL, Snippet: r.Content, Published: r.PublishedDate})
+	}
+	return results, nil
+}
+
+// --- Generic JSON endpoint ---
+
+type jsonBackend struct {
+	client *http.Client
+	cfg    SearchConfig
+}
+
+func (b *jsonBackend) Name() string { return "json" }
+
+func (b *jsonBackend) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	u, err := url.Parse(b.cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	v := u.Query()
+	v.Set(orDefault(b.cfg.QueryParam, "q"), withSites(q))
+	if b.cfg.CountParam != "" {
+		v.Set(b.cfg.CountParam, strconv.Itoa(q.Count))
+	}
+	u.RawQuery = v.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, val := range b.cfg.Headers {
+		req.Header.Set(k, val)
+	}
+	if b.cfg.APIKey != "" && req.Header.Get("Authorization") == "" {
+		req.Header.Set("Authorization", "Bearer "+b.cfg.APIKey)
+	}
+
+	var body any
+	if err := doSearchRequest(b.client, req, &body); err != nil {
+		return nil, err
+	}
+	node := body
+	if b.cfg.ResultsPath != "" {
+		for _, key := range strings.Split(b.cfg.ResultsPath, ".") {
+			m, ok := node.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("results_path %q not found in response", b.cfg.ResultsPath)
+			}
+			node = m[key]
+		}
+	}
+	items, ok := node.([]any)
+	if !ok {
+		return nil, fmt.Errorf("results_path %q is not an array", b.cfg.ResultsPath)
+	}
+
+	var results []SearchResult
+	for _, it := range items {
+		m, ok := it.(map[string]any)
+		if !ok {
+			continue
+		}
+		r := SearchResult{
+			Title:   jsonString(m, orDefault(b.cfg.TitleField, "title")),
+			URL:     jsonString(m, orDefault(b.cfg.URLField, "url")),
+			Snippet: jsonString(m, orDefault(b.cfg.SnippetField, "snippet")),
+		}
+		if r.URL == "" {
+			continue
+		}
+		results = append(results, r)
+		if len(results) >= q.Count {
+			break
+		}
+	}
+	return results, nil
+}
+
+func jsonString(m map[string]any, key string) string {
+	if s, ok := m[key].(string); ok {
+		return s
+	}
+	return ""
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func stripTags(s string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(s))
+	if err != nil {
+		return s
+	}
+	return doc.Text()
+}
+
+// searchCache memoizes results for a short TTL so repeated identical
+// queries within a conversation don't hit the backend again.
+type searchCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]searchCacheEntry
+}
+
+type searchCacheEntry struct {
+	results []SearchResult
+	backend string
+	expires time.Time
+}
+
+const maxSearchCacheEntries = 256
+
+func newSearchCache(ttl time.Duration) *searchCache {
+	return &searchCache{ttl: ttl, entries: make(map[string]searchCacheEntry)}
+}
+
+func (c *searchCache) key(q SearchQuery) string {
+	return fmt.Sprintf("%s|%d|%s|%s", strings.ToLower(strings.TrimSpace(q.Query)), q.Count, q.Freshness, strings.Join(q.Sites, ","))
+}
+
+func (c *searchCache) get(q SearchQuery) (searchCacheEntry, bool) {
+	if c.ttl <= 0 {
+		return searchCacheEntry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[c.key(q)]
+	if !ok || time.Now().After(e.expires) {
+		return searchCacheEntry{}, false
+	}
+	return e, true
+}
+
+func (c *searchCache) put(q SearchQuery, backend string, results []SearchResult) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if len(c.entries) >= maxSearchCacheEntries {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxSearchCacheEntries {
+			c.entries = make(map[string]searchCacheEntry)
+		}
+	}
+	c.entries[c.key(q)] = searchCacheEntry{results: results, backend: backend, expires: now.Add(c.ttl)}
+}
@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yifanes/miniclawd/internal/core"
+	"github.com/yifanes/miniclawd/internal/storage"
+)
+
+// SearchHistoryTool searches a chat's full message history.
+type SearchHistoryTool struct {
+	db *storage.Database
+}
+
+func NewSearchHistoryTool(db *storage.Database) *SearchHistoryTool {
+	return &SearchHistoryTool{db: db}
+}
+
+func (t *SearchHistoryTool) Name() string { return "search_history" }
+
+func (t *SearchHistoryTool) Definition() core.ToolDefinition {
+	return MakeDef("search_history",
+		"Search this chat's entire message history, including messages no longer in context. "+
+			"Results are ranked by relevance with excerpts. Words must all match; \"quoted text\" matches a phrase "+
+			"and word* matches a prefix.",
+		map[string]any{
+			"query":   StringProp("Words or phrases to search for"),
+			"chat_id": IntProp("Chat to search (default: the current chat)"),
+			"since":   StringProp("Only messages on or after this date (YYYY-MM-DD)"),
+			"until":   StringProp("Only messages on or before this date (YYYY-MM-DD)"),
+			"limit":   IntProp("Max results (default 10, max 50)"),
+		},
+		[]string{"query"},
+	)
+}
+
+func (t *SearchHistoryTool) Execute(_ context.Context, input json.RawMessage) ToolResult {
+	var params struct {
+		Query  string `json:"query"`
+		ChatID *int64 `json:"chat_id"`
+		Since  string `json:"since"`
+		Until  string `json:"until"`
+		Limit  *int   `json:"limit"`
+	}
+	if err := json.Unmarshal(input, &params); err != nil {
+		return Error("invalid input: " + err.Error())
+	}
+	if strings.TrimSpace(params.Query) == "" {
+		return Error("query is required")
+	}
+
+	auth := ExtractAuthContext(input)
+	chatID := int64(0)
+	if auth != nil {
+		chatID = auth.CallerChatID
+	}
+	if params.ChatID != nil {
+		chatID = *params.ChatID
+	}
+	if auth != nil && !auth.CanAccessChat(chatID) {
+		return Error("you don't have access to this chat")
+	}
+
+	limit := 10
+	if params.Limit != nil && *params.Limit > 0 {
+		limit = min(*params.Limit, 50)
+	}
+
+	var since, until string
+	if params.Since != "" {
+		d, err := time.Parse("2006-01-02", params.Since)
+		if err != nil {
+			return Error("since must be a date like 2024-01-31")
+		}
+		since = d.Format(time.RFC3339)
+	}
+	if params.Until != "" {
+		d, err := time.Parse("2006-01-02", params.Until)
+		if err != nil {
+			return Error("until must be a date like 2024-01-31")
+		}
+		until = d.AddDate(0, 0, 1).Format(time.RFC3339)
+	}
+
+	results, err := t.db.SearchMessages(chatID, params.Query, since, until, limit)
+	if err != nil {
+		return Error(fmt.Sprintf("search error: %v", err))
+	}
+	if len(results) == 0 {
+		return Success("No messages found matching query.")
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Found %d message(s), best matches first:\n", len(results))
+	for _, r := range results {
+		sender := r.SenderName
+		if r.IsFromBot {
+			sender = "assistant"
+		}
+		snippet := strings.Join(strings.Fields(r.Snippet), " ")
+		fmt.Fprintf(&sb, "\n[%s] %s: %s", r.Timestamp, sender, snippet)
+	}
+	return Success(sb.String())
+}
@@ -23,7 +23,7 @@ func (t *StructuredMemorySearchTool) Name() string { return "structured_memory_s
 
 func (t *StructuredMemorySearchTool) Definition() core.ToolDefinition {
 	return MakeDef("structured_memory_search",
-		"Search stored memories by keyword. Returns matching memories with IDs for reference.",
+		"Search stored memories by keyword, best matches first. \"quoted text\" matches a phrase and word* a prefix. Returns matching memories with IDs for reference.",
 		map[string]any{
 			"query":            StringProp("Keywords to search for"),
 			"limit":            IntProp("Max results (default 10, max 50)"),
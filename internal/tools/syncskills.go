@@ -12,14 +12,16 @@ import (
 	"time"
 
 	"github.com/yifanes/miniclawd/internal/core"
+	"github.com/yifanes/miniclawd/internal/netguard"
 )
 
 type SyncSkillsTool struct {
 	skillsDir string
+	guard     *netguard.Policy
 }
 
-func NewSyncSkillsTool(skillsDir string) *SyncSkillsTool {
-	return &SyncSkillsTool{skillsDir: skillsDir}
+func NewSyncSkillsTool(skillsDir string, guard *netguard.Policy) *SyncSkillsTool {
+	return &SyncSkillsTool{skillsDir: skillsDir, guard: guard}
 }
 
 func (t *SyncSkillsTool) Name() string { return "sync_skills" }
@@ -65,7 +67,7 @@ func (t *SyncSkillsTool) Execute(ctx context.Context, input json.RawMessage) Too
 		fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/SKILL.md", params.SourceRepo, gitRef, params.SkillName),
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := t.guard.Client(30 * time.Second)
 	var content string
 	var found bool
 
@@ -22,6 +22,8 @@ type ToolResult struct {
 	Bytes     int
 	DurationMs *int64
 	ErrorType  *string
+	Diff       *FileDiff // set by tools that modify a file
+	Blocks     []core.ContentBlock // extra content for the model, e.g. images
 }
 
 // Success creates a successful ToolResult.
@@ -55,9 +57,19 @@ const (
 type ToolAuthContext struct {
 	CallerChannel  string  `json:"caller_channel"`
 	CallerChatID   int64   `json:"caller_chat_id"`
+	CallerChatType string  `json:"caller_chat_type,omitempty"`
+	Profile        string  `json:"profile,omitempty"`
 	ControlChatIDs []int64 `json:"control_chat_ids"`
 }
 
+// ProfileName returns the agent profile, "default" when unset.
+func (a *ToolAuthContext) ProfileName() string {
+	if a.Profile == "" {
+		return "default"
+	}
+	return a.Profile
+}
+
 // IsControlChat returns true if the caller is a control chat.
 func (a *ToolAuthContext) IsControlChat() bool {
 	for _, id := range a.ControlChatIDs {
@@ -96,6 +108,21 @@ func InjectAuthContext(input json.RawMessage, auth *ToolAuthContext) json.RawMes
 	return out
 }
 
+// StripAuthContext removes the injected auth context, for tools that pass
+// their input on to an external service.
+func StripAuthContext(input json.RawMessage) json.RawMessage {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(input, &m); err != nil {
+		return input
+	}
+	if _, ok := m["__miniclawd_auth"]; !ok {
+		return input
+	}
+	delete(m, "__miniclawd_auth")
+	out, _ := json.Marshal(m)
+	return out
+}
+
 // MakeDef is a helper to build a ToolDefinition with a JSON schema.
 func MakeDef(name, description string, properties map[string]any, required []string) core.ToolDefinition {
 	schema := map[string]any{
@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"path"
+	"slices"
+)
+
+// ToolPolicyRule allows or denies tools for the callers it matches. Every
+// non-empty selector must match; a rule with no selectors matches everyone.
+type ToolPolicyRule struct {
+	Channels  []string // caller channel, e.g. "discord"
+	ChatTypes []string // e.g. "discord_guild", "telegram_private"
+	ChatIDs   []int64
+	Profiles  []string // agent profile, e.g. "default", "scheduler"
+	Allow     []string // if set, only these tools; globs like "mcp_*" work
+	Deny      []string // tools removed after Allow is applied
+}
+
+// ToolPolicy decides which tools a caller may see and run. Matching rules
+// are applied in order, so later (more specific) rules override earlier
+// ones: an Allow list replaces the permitted set, and Deny removes from it.
+type ToolPolicy struct {
+	rules []ToolPolicyRule
+}
+
+// NewToolPolicy creates a policy from rules. With no rules every tool is
+// permitted.
+func NewToolPolicy(rules []ToolPolicyRule) *ToolPolicy {
+	return &ToolPolicy{rules: rules}
+}
+
+// Allows reports whether auth may use tool. A nil policy allows everything.
+func (p *ToolPolicy) Allows(auth *ToolAuthContext, tool string) bool {
+	if p == nil {
+		return true
+	}
+	allowed := true
+	for _, r := range p.rules {
+		if !r.matches(auth) {
+			continue
+		}
+		if len(r.Allow) > 0 {
+			allowed = matchToolName(r.Allow, tool)
+		}
+		if matchToolName(r.Deny, tool) {
+			allowed = false
+		}
+	}
+	return allowed
+}
+
+func (r *ToolPolicyRule) matches(auth *ToolAuthContext) bool {
+	if auth == nil {
+		auth = &ToolAuthContext{}
+	}
+	if len(r.Channels) > 0 && !slices.Contains(r.Channels, auth.CallerChannel) {
+		return false
+	}
+	if len(r.ChatTypes) > 0 && !slices.Contains(r.ChatTypes, auth.CallerChatType) {
+		return false
+	}
+	if len(r.ChatIDs) > 0 && !slices.Contains(r.ChatIDs, auth.CallerChatID) {
+		return false
+	}
+	if len(r.Profiles) > 0 && !slices.Contains(r.Profiles, auth.ProfileName()) {
+		return false
+	}
+	return true
+}
+
+func matchToolName(patterns []string, tool string) bool {
+	for _, p := range patterns {
+		if p == tool {
+			return true
+		}
+		if ok, _ := path.Match(p, tool); ok {
+			return true
+		}
+	}
+	return false
+}
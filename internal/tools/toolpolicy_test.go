@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestToolPolicy(t *testing.T) {
+	p := NewToolPolicy([]ToolPolicyRule{
+		{ChatTypes: []string{"discord_guild"}, Allow: []string{"web_*", "read_file"}},
+		{Channels: []string{"discord"}, ChatIDs: []int64{42}, Allow: []string{"*"}, Deny: []string{"process_*"}},
+		{Profiles: []string{"scheduler"}, Deny: []string{"bash"}},
+	})
+
+	guild := &ToolAuthContext{CallerChannel: "discord", CallerChatID: 7, CallerChatType: "discord_guild"}
+	trusted := &ToolAuthContext{CallerChannel: "discord", CallerChatID: 42, CallerChatType: "discord_guild"}
+	scheduled := &ToolAuthContext{CallerChannel: "telegram", CallerChatID: 1, Profile: "scheduler"}
+
+	tests := []struct {
+		auth *ToolAuthContext
+		tool string
+		want bool
+	}{
+		{guild, "web_fetch", true},
+		{guild, "read_file", true},
+		{guild, "bash", false},
+		{trusted, "bash", true},
+		{trusted, "process_start", false},
+		{scheduled, "bash", false},
+		{scheduled, "write_file", true},
+		{nil, "bash", true},
+	}
+	for _, tc := range tests {
+		if got := p.Allows(tc.auth, tc.tool); got != tc.want {
+			t.Errorf("Allows(%+v, %s) = %v, want %v", tc.auth, tc.tool, got, tc.want)
+		}
+	}
+
+	r := NewToolRegistry()
+	r.Register(NewTodoReadTool(t.TempDir()))
+	r.SetToolPolicy(NewToolPolicy([]ToolPolicyRule{{Channels: []string{"discord"}, Deny: []string{"todo_read"}}}))
+	discord := &ToolAuthContext{CallerChannel: "discord", CallerChatID: 1}
+	if defs := r.Definitions(discord); len(defs) != 0 {
+		t.Errorf("denied tool exposed: %v", defs)
+	}
+	if defs := r.Definitions(&ToolAuthContext{CallerChannel: "web"}); len(defs) != 1 {
+		t.Errorf("expected todo_read for web, got %d definitions", len(defs))
+	}
+	res := r.ExecuteWithAuth(context.Background(), "todo_read", json.RawMessage(`{}`), discord)
+	if !res.IsError || res.ErrorType == nil || *res.ErrorType != "policy_denied" {
+		t.Errorf("expected policy_denied, got %+v", res)
+	}
+}
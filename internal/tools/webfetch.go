@@ -1,31 +1,49 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/yifanes/miniclawd/internal/core"
+	"github.com/yifanes/miniclawd/internal/netguard"
 )
 
-const maxFetchBytes = 20 * 1024
+const (
+	defaultFetchLength = 20000
+	minFetchLength     = 1000
+	maxFetchLength     = 100000
+	maxFetchBody       = 10 * 1024 * 1024
+	maxFetchRedirects  = 10
+)
 
-type WebFetchTool struct{}
+type WebFetchTool struct {
+	guard *netguard.Policy
+}
 
-func NewWebFetchTool() *WebFetchTool { return &WebFetchTool{} }
+func NewWebFetchTool(guard *netguard.Policy) *WebFetchTool {
+	return &WebFetchTool{guard: guard}
+}
 
 func (t *WebFetchTool) Name() string { return "web_fetch" }
 
 func (t *WebFetchTool) Definition() core.ToolDefinition {
 	return MakeDef("web_fetch",
-		"Fetch a URL and return its text content. HTML is converted to plain text. Max 20KB returned.",
+		"Fetch a URL and return its content. HTML pages are reduced to their main content and converted to Markdown "+
+			"(headings, links, lists and tables kept); PDFs are converted to text. Long documents are paginated: "+
+			"use start_index to continue where the previous call stopped.",
 		map[string]any{
-			"url": StringProp("The URL to fetch"),
+			"url":         StringProp("The URL to fetch"),
+			"start_index": IntProp("Character offset to start from (default 0)"),
+			"max_length":  IntProp("Maximum characters to return (default 20000, min 1000, max 100000)"),
+			"raw":         BoolProp("Return the raw response body instead of extracted content"),
 		},
 		[]string{"url"},
 	)
@@ -33,7 +51,10 @@ func (t *WebFetchTool) Definition() core.ToolDefinition {
 
 func (t *WebFetchTool) Execute(ctx context.Context, input json.RawMessage) ToolResult {
 	var params struct {
-		URL string `json:"url"`
+		URL        string `json:"url"`
+		StartIndex int    `json:"start_index"`
+		MaxLength  int    `json:"max_length"`
+		Raw        bool   `json:"raw"`
 	}
 	if err := json.Unmarshal(input, &params); err != nil {
 		return Error("invalid input: " + err.Error())
@@ -41,50 +62,146 @@ func (t *WebFetchTool) Execute(ctx context.Context, input json.RawMessage) ToolR
 	if params.URL == "" {
 		return Error("url is required")
 	}
+	if params.StartIndex < 0 {
+		return Error("start_index must not be negative")
+	}
+	maxLen := params.MaxLength
+	if maxLen <= 0 {
+		maxLen = defaultFetchLength
+	}
+	if maxLen < minFetchLength {
+		maxLen = minFetchLength
+	}
+	if maxLen > maxFetchLength {
+		maxLen = maxFetchLength
+	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	var redirects []string
+	client := t.guard.Client(30 * time.Second)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxFetchRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxFetchRedirects)
+		}
+		redirects = append(redirects, fmt.Sprintf("%d %s", req.Response.StatusCode, req.URL))
+		return t.guard.CheckURL(req.URL)
+	}
 	req, err := http.NewRequestWithContext(ctx, "GET", params.URL, nil)
 	if err != nil {
 		return Error(fmt.Sprintf("invalid url: %v", err))
 	}
+	if err := t.guard.CheckURL(req.URL); err != nil {
+		return ErrorWithType(err.Error(), "blocked")
+	}
 	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; MiniClawd/1.0)")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/pdf;q=0.9,*/*;q=0.8")
 
 	resp, err := client.Do(req)
 	if err != nil {
+		var blocked *netguard.BlockedError
+		if errors.As(err, &blocked) {
+			return ErrorWithType(fmt.Sprintf("fetch error: %v", blocked), "blocked")
+		}
+		var uerr *url.Error
+		if errors.As(err, &uerr) && len(redirects) > 0 {
+			return ErrorWithType(fmt.Sprintf("fetch error: %v\nredirect chain:\n  %s", uerr.Err,
+				strings.Join(redirects, "\n  ")), "redirect_error")
+		}
 		return Error(fmt.Sprintf("fetch error: %v", err))
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return Error(fmt.Sprintf("HTTP %d: %s", resp.StatusCode, resp.Status))
-	}
-
-	ct := resp.Header.Get("Content-Type")
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024)) // 1MB read limit
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchBody))
 	if err != nil {
 		return Error(fmt.Sprintf("read error: %v", err))
 	}
 
-	var text string
-	if strings.Contains(ct, "text/html") {
-		text = htmlToText(string(body))
-	} else {
+	finalURL := resp.Request.URL
+	var header strings.Builder
+	fmt.Fprintf(&header, "URL: %s\n", finalURL)
+	if len(redirects) > 0 {
+		fmt.Fprintf(&header, "Redirected from: %s (%d hop(s))\n", params.URL, len(redirects))
+	}
+
+	// Non-2xx responses are reported with a short excerpt of the body, which
+	// often explains the failure (login walls, rate limits, moved pages).
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := fmt.Sprintf("HTTP %s for %s", resp.Status, finalURL)
+		switch {
+		case resp.StatusCode >= 300 && resp.StatusCode < 400:
+			if loc := resp.Header.Get("Location"); loc != "" {
+				msg += "\nLocation: " + loc
+			}
+		case resp.StatusCode == http.StatusTooManyRequests:
+			if ra := resp.Header.Get("Retry-After"); ra != "" {
+				msg += "\nRetry-After: " + ra
+			}
+		}
+		if excerpt, _, _ := t.extract(resp, body, finalURL); excerpt != "" {
+			if len(excerpt) > 500 {
+				excerpt = excerpt[:core.FloorCharBoundary(excerpt, 500)] + "..."
+			}
+			msg += "\n\n" + excerpt
+		}
+		code := resp.StatusCode
+		et := "http_status"
+		return ToolResult{Content: msg, IsError: true, StatusCode: &code, Bytes: len(msg), ErrorType: &et}
+	}
+
+	var text, title string
+	if params.Raw {
 		text = string(body)
+	} else {
+		text, title, err = t.extract(resp, body, finalURL)
+		if err != nil {
+			return Error(fmt.Sprintf("cannot extract content from %s: %v", finalURL, err))
+		}
+	}
+	if title != "" {
+		fmt.Fprintf(&header, "Title: %s\n", title)
 	}
 
-	if len(text) > maxFetchBytes {
-		text = text[:maxFetchBytes] + "\n... (content truncated)"
+	page, start, end, total := paginate(text, params.StartIndex, maxLen)
+	if start >= total && total > 0 {
+		return Error(fmt.Sprintf("start_index %d is past the end of the content (%d characters)", start, total))
 	}
 
-	return Success(text)
+	if start > 0 || end < total {
+		fmt.Fprintf(&header, "Showing characters %d-%d of %d\n", start, end, total)
+	}
+	out := header.String() + "\n" + page
+	if end < total {
+		out += fmt.Sprintf("\n\n... (content truncated; call again with start_index=%d to continue)", end)
+	}
+	return Success(out)
 }
 
-func htmlToText(html string) string {
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
-	if err != nil {
-		return html
+// paginate returns up to maxLen characters of text starting at character
+// offset start, along with the page's end offset and the total length.
+// Offsets count runes, not bytes.
+func paginate(text string, start, maxLen int) (string, int, int, int) {
+	runes := []rune(text)
+	total := len(runes)
+	if start >= total {
+		return "", start, start, total
+	}
+	end := min(start+maxLen, total)
+	return string(runes[start:end]), start, end, total
+}
+
+// extract converts a response body to readable text based on its type.
+func (t *WebFetchTool) extract(resp *http.Response, body []byte, base *url.URL) (string, string, error) {
+	ct := strings.ToLower(resp.Header.Get("Content-Type"))
+	switch {
+	case strings.Contains(ct, "application/pdf") || bytes.HasPrefix(body, []byte("%PDF-")):
+		text, err := pdfToText(body)
+		return text, "", err
+	case strings.Contains(ct, "html") || (ct == "" && bytes.Contains(bytes.ToLower(body[:min(len(body), 512)]), []byte("<html"))):
+		title, md := htmlToMarkdown(string(body), base)
+		return md, title, nil
+	case ct == "" || strings.HasPrefix(ct, "text/") || strings.Contains(ct, "json") ||
+		strings.Contains(ct, "xml") || strings.Contains(ct, "javascript"):
+		return string(body), "", nil
+	default:
+		return "", "", fmt.Errorf("unsupported content type %q (%d bytes)", ct, len(body))
 	}
-	// Remove scripts and styles.
-	doc.Find("script, style, noscript").Remove()
-	return strings.TrimSpace(doc.Text())
 }
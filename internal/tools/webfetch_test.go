@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHTMLToMarkdown(t *testing.T) {
+	base, _ := url.Parse("https://example.com/docs/page")
+	page := `<html><head><title>Guide</title></head><body>
+<nav><a href="/">Home</a></nav>
+<div class="sidebar">Related posts</div>
+<div id="content">
+<h2>Install</h2>
+<p>Run the installer, then open the <a href="../setup">setup page</a> in your browser.</p>
+<table><tr><th>OS</th><th>Command</th></tr><tr><td>Linux</td><td>make install</td></tr></table>
+</div>
+<footer>Copyright</footer>
+</body></html>`
+
+	title, md := htmlToMarkdown(page, base)
+	if title != "Guide" {
+		t.Errorf("title = %q, want Guide", title)
+	}
+	for _, want := range []string{
+		"## Install",
+		"[setup page](https://example.com/setup)",
+		"| OS | Command |\n| --- | --- |\n| Linux | make install |",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+	for _, noise := range []string{"Home", "Related posts", "Copyright"} {
+		if strings.Contains(md, noise) {
+			t.Errorf("markdown contains page chrome %q:\n%s", noise, md)
+		}
+	}
+}
+
+func TestHTMLToMarkdownKeepsFormsAndCommentBodies(t *testing.T) {
+	page := `<html><body><form id="aspnetForm" action="/page.aspx">
+<input type="hidden" name="__VIEWSTATE" value="xyz">
+<div class="post has-comments"><p>The release ships on Monday, together with the migration guide and notes.</p>
+<div class="comment-body-intro"><p>Intro kept, as it only mentions comments in passing.</p></div></div>
+<div id="comments"><p>First!</p></div>
+<div class="box comments-area"><p>Nice post.</p></div>
+<button>Submit</button>
+</form></body></html>`
+
+	_, md := htmlToMarkdown(page, nil)
+	for _, want := range []string{"The release ships on Monday", "Intro kept"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+	for _, noise := range []string{"First!", "Nice post.", "Submit", "__VIEWSTATE"} {
+		if strings.Contains(md, noise) {
+			t.Errorf("markdown contains %q:\n%s", noise, md)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	text := "héllo wörld"
+	tests := []struct {
+		start, maxLen int
+		page          string
+		end           int
+	}{
+		{0, 5, "héllo", 5},
+		{5, 5, " wörl", 10},
+		{10, 5, "d", 11},
+		{1, 1, "é", 2},
+		{11, 5, "", 11},
+	}
+	for _, tc := range tests {
+		page, start, end, total := paginate(text, tc.start, tc.maxLen)
+		if page != tc.page || start != tc.start || end != tc.end || total != 11 {
+			t.Errorf("paginate(%d, %d) = %q, %d, %d, %d; want %q, %d, %d, 11",
+				tc.start, tc.maxLen, page, start, end, total, tc.page, tc.start, tc.end)
+		}
+	}
+}
+
+func TestPDFToText(t *testing.T) {
+	var z bytes.Buffer
+	w := zlib.NewWriter(&z)
+	w.Write([]byte("BT /F1 12 Tf 72 700 Td (Hello \\(PDF\\)) Tj 0 -14 Td [(Wor) -20 (ld) -300 (again)] TJ ET"))
+	w.Close()
+	pdf := fmt.Sprintf("%%PDF-1.4\n1 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream\nendobj\n%%%%EOF", z.Len(), z.String())
+
+	text, err := pdfToText([]byte(pdf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Hello (PDF)\nWorld again"; text != want {
+		t.Errorf("text = %q, want %q", text, want)
+	}
+}
+
+func TestPDFToTextPerFontCMaps(t *testing.T) {
+	cmap := func(dst string) string {
+		return "/CIDInit /ProcSet findresource begin begincmap\n1 beginbfchar\n<0001> <" + dst + ">\nendbfchar\nendcmap"
+	}
+	objs := []string{
+		"<< /Type /Page /Parent 8 0 R /Contents [6 0 R] >>",
+		"<< /Type /Font /Subtype /Type0 /ToUnicode 4 0 R >>",
+		"<< /Type /Font /Subtype /Type0 /ToUnicode 5 0 R >>",
+		"<< /Length 0 >>\nstream\n" + cmap("0041") + "\nendstream",
+		"<< /Length 0 >>\nstream\n" + cmap("0042") + "\nendstream",
+		"<< /Length 0 >>\nstream\nBT /F1 12 Tf <0001> Tj /F2 12 Tf <0001> Tj /F1 12 Tf <0001> Tj ET\nendstream",
+		"<< /Font << /F1 2 0 R /F2 3 0 R >> /ProcSet [/PDF /Text /ImageB] >>",
+		"<< /Type /Pages /Kids [1 0 R] /Resources 7 0 R >>",
+	}
+	var pdf strings.Builder
+	pdf.WriteString("%PDF-1.4\n")
+	for i, o := range objs {
+		fmt.Fprintf(&pdf, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	pdf.WriteString("%%EOF")
+
+	text, err := pdfToText([]byte(pdf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "ABA" {
+		t.Errorf("text = %q, want ABA", text)
+	}
+}
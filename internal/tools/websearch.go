@@ -4,26 +4,45 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
-	"net/url"
+	"log"
 	"strings"
-	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/yifanes/miniclawd/internal/core"
+	"github.com/yifanes/miniclawd/internal/netguard"
 )
 
-type WebSearchTool struct{}
+type WebSearchTool struct {
+	primary  SearchBackend
+	fallback SearchBackend // DuckDuckGo, used when primary fails; nil if primary is DDG
+	cache    *searchCache
+}
 
-func NewWebSearchTool() *WebSearchTool { return &WebSearchTool{} }
+// NewWebSearchTool creates the web_search tool for the configured backend.
+// An invalid configuration is logged and falls back to DuckDuckGo.
+func NewWebSearchTool(cfg SearchConfig, guard *netguard.Policy) *WebSearchTool {
+	ddg, _ := NewSearchBackend(SearchConfig{}, guard)
+	primary, err := NewSearchBackend(cfg, guard)
+	if err != nil {
+		log.Printf("[tools] web_search: %v; using duckduckgo", err)
+		primary = ddg
+	}
+	t := &WebSearchTool{primary: primary, cache: newSearchCache(cfg.CacheTTL)}
+	if primary.Name() != ddg.Name() {
+		t.fallback = ddg
+	}
+	return t
+}
 
 func (t *WebSearchTool) Name() string { return "web_search" }
 
 func (t *WebSearchTool) Definition() core.ToolDefinition {
 	return MakeDef("web_search",
-		"Search the web using DuckDuckGo. Returns titles, URLs, and snippets.",
+		"Search the web. Returns titles, URLs, and snippets.",
 		map[string]any{
-			"query": StringProp("The search query"),
+			"query":     StringProp("The search query"),
+			"count":     IntProp("Number of results (default 8, max 20)"),
+			"freshness": EnumProp("Only return results from this recent period", []string{"day", "week", "month", "year"}),
+			"site":      StringProp("Restrict results to a domain, e.g. 'go.dev' (comma-separated for several)"),
 		},
 		[]string{"query"},
 	)
@@ -31,7 +50,10 @@ func (t *WebSearchTool) Definition() core.ToolDefinition {
 
 func (t *WebSearchTool) Execute(ctx context.Context, input json.RawMessage) ToolResult {
 	var params struct {
-		Query string `json:"query"`
+		Query     string `json:"query"`
+		Count     int    `json:"count"`
+		Freshness string `json:"freshness"`
+		Site      string `json:"site"`
 	}
 	if err := json.Unmarshal(input, &params); err != nil {
 		return Error("invalid input: " + err.Error())
@@ -40,69 +62,54 @@ func (t *WebSearchTool) Execute(ctx context.Context, input json.RawMessage) Tool
 		return Error("query is required")
 	}
 
-	results, err := searchDDG(ctx, params.Query)
-	if err != nil {
-		return Error(fmt.Sprintf("search error: %v", err))
+	q := SearchQuery{Query: params.Query, Count: params.Count, Freshness: params.Freshness}
+	if q.Count <= 0 {
+		q.Count = 8
 	}
-
-	if len(results) == 0 {
-		return Success("No results found")
+	if q.Count > 20 {
+		q.Count = 20
 	}
-
-	var sb strings.Builder
-	for i, r := range results {
-		if i > 0 {
-			sb.WriteString("\n\n")
+	for _, s := range strings.Split(params.Site, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			q.Sites = append(q.Sites, s)
 		}
-		sb.WriteString(fmt.Sprintf("[%d] %s\n%s\n%s", i+1, r.title, r.url, r.snippet))
 	}
-	return Success(sb.String())
-}
-
-type searchResult struct {
-	title   string
-	url     string
-	snippet string
-}
-
-func searchDDG(ctx context.Context, query string) ([]searchResult, error) {
-	u := "https://html.duckduckgo.com/html/?q=" + url.QueryEscape(query)
 
-	client := &http.Client{Timeout: 15 * time.Second}
-	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; MiniClawd/1.0)")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	var note string
+	entry, ok := t.cache.get(q)
+	if !ok {
+		results, err := t.primary.Search(ctx, q)
+		backend := t.primary.Name()
+		if err != nil && t.fallback != nil {
+			log.Printf("[tools] web_search: %s failed: %v; falling back to %s", backend, err, t.fallback.Name())
+			note = fmt.Sprintf("(%s failed: %v; results from %s)\n\n", backend, err, t.fallback.Name())
+			backend = t.fallback.Name()
+			results, err = t.fallback.Search(ctx, q)
+		}
+		if err != nil {
+			return Error(fmt.Sprintf("search error: %v", err))
+		}
+		entry = searchCacheEntry{results: results, backend: backend}
+		t.cache.put(q, backend, results)
 	}
-	defer resp.Body.Close()
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		return nil, err
+	if len(entry.results) == 0 {
+		return Success(note + "No results found")
 	}
 
-	var results []searchResult
-	doc.Find(".result").Each(func(i int, s *goquery.Selection) {
-		if i >= 10 {
-			return
+	var sb strings.Builder
+	sb.WriteString(note)
+	for i, r := range entry.results {
+		if i > 0 {
+			sb.WriteString("\n\n")
 		}
-		title := strings.TrimSpace(s.Find(".result__a").Text())
-		link, _ := s.Find(".result__a").Attr("href")
-		snippet := strings.TrimSpace(s.Find(".result__snippet").Text())
-
-		if title != "" && link != "" {
-			results = append(results, searchResult{
-				title:   title,
-				url:     link,
-				snippet: snippet,
-			})
+		fmt.Fprintf(&sb, "[%d] %s\n%s", i+1, r.Title, r.URL)
+		if r.Published != "" {
+			fmt.Fprintf(&sb, "\nPublished: %s", r.Published)
 		}
-	})
-
-	return results, nil
+		if r.Snippet != "" {
+			sb.WriteString("\n" + strings.TrimSpace(r.Snippet))
+		}
+	}
+	return Success(sb.String())
 }
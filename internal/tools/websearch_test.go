@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebSearchBackends(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		q := r.URL.Query()
+		switch r.URL.Path {
+		case "/search": // SearXNG
+			if q.Get("format") != "json" || q.Get("time_range") != "week" {
+				t.Errorf("unexpected searxng query: %s", r.URL.RawQuery)
+			}
+			json.NewEncoder(w).Encode(map[string]any{"results": []map[string]any{
+				{"title": "Go", "url": "https://go.dev", "content": "The Go language", "publishedDate": "2026-01-02"},
+			}})
+		case "/custom": // generic JSON
+			if q.Get("query") != "golang (site:go.dev)" {
+				t.Errorf("unexpected json query: %s", r.URL.RawQuery)
+			}
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"items": []map[string]any{
+				{"name": "Go", "link": "https://go.dev", "summary": "Docs"},
+			}}})
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name  string
+		cfg   SearchConfig
+		input string
+		want  []string
+	}{
+		{
+			name:  "searxng",
+			cfg:   SearchConfig{Backend: "searxng", URL: srv.URL, CacheTTL: time.Minute},
+			input: `{"query":"golang","freshness":"week"}`,
+			want:  []string{"[1] Go\nhttps://go.dev", "Published: 2026-01-02", "The Go language"},
+		},
+		{
+			name: "generic json",
+			cfg: SearchConfig{Backend: "json", URL: srv.URL + "/custom", QueryParam: "query",
+				ResultsPath: "data.items", TitleField: "name", URLField: "link", SnippetField: "summary"},
+			input: `{"query":"golang","site":"go.dev"}`,
+			want:  []string{"[1] Go\nhttps://go.dev\nDocs"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tool := NewWebSearchTool(tc.cfg, nil)
+			res := tool.Execute(context.Background(), json.RawMessage(tc.input))
+			if res.IsError {
+				t.Fatalf("unexpected error: %s", res.Content)
+			}
+			for _, w := range tc.want {
+				if !strings.Contains(res.Content, w) {
+					t.Errorf("result missing %q:\n%s", w, res.Content)
+				}
+			}
+		})
+	}
+
+	// A repeated query is served from the cache.
+	tool := NewWebSearchTool(SearchConfig{Backend: "searxng", URL: srv.URL, CacheTTL: time.Minute}, nil)
+	hits = 0
+	for i := 0; i < 2; i++ {
+		tool.Execute(context.Background(), json.RawMessage(`{"query":"golang","freshness":"week"}`))
+	}
+	if hits != 1 {
+		t.Errorf("backend hit %d times, want 1", hits)
+	}
+}
@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Workspace resolves the working directory that file tools operate in.
+// In "chat" isolation mode every caller gets its own chat-<id> subdirectory
+// of the root; in "shared" mode all callers use the root directly.
+type Workspace struct {
+	root    string
+	perChat bool
+}
+
+// NewWorkspace creates a Workspace rooted at root. Any isolation value other
+// than "shared" isolates callers per chat.
+func NewWorkspace(root, isolation string) *Workspace {
+	return &Workspace{root: root, perChat: isolation != "shared"}
+}
+
+// Root returns the shared workspace root.
+func (w *Workspace) Root() string {
+	return w.root
+}
+
+// DirFor returns (and creates) the working directory for the caller.
+// Callers without an auth context fall back to the root.
+func (w *Workspace) DirFor(auth *ToolAuthContext) (string, error) {
+	if !w.perChat || auth == nil {
+		return w.root, nil
+	}
+	dir := w.ChatDir(auth.CallerChatID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("cannot create working directory: %w", err)
+	}
+	return dir, nil
+}
+
+// ChatDir returns the working directory of a chat without creating it.
+func (w *Workspace) ChatDir(chatID int64) string {
+	if !w.perChat {
+		return w.root
+	}
+	return filepath.Join(w.root, fmt.Sprintf("chat-%d", chatID))
+}
+
+// Resolve resolves path against the caller's working directory. An empty path
+// resolves to the directory itself. Non-control chats may not reach into
+// another chat's workspace; control chats can inspect any of them.
+func (w *Workspace) Resolve(auth *ToolAuthContext, path string) (string, error) {
+	dir, err := w.DirFor(auth)
+	if err != nil {
+		return "", err
+	}
+	if path == "" {
+		return dir, nil
+	}
+	resolved := resolvePath(dir, path)
+
+	if w.perChat && auth != nil && !auth.IsControlChat() {
+		if isWithin(w.root, resolved) && !isWithin(dir, resolved) {
+			return "", fmt.Errorf("access to %s is denied: it belongs to another chat's workspace", path)
+		}
+	}
+	return resolved, nil
+}
+
+// isWithin reports whether path is dir itself or located beneath it, after
+// resolving symlinks in the existing part of both paths.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(realPath(dir), realPath(path))
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorkspaceResolve(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	for _, d := range []string{"chat-5/sub", "chat-6"} {
+		os.MkdirAll(filepath.Join(root, d), 0o755)
+	}
+	os.Symlink(filepath.Join(root, "chat-6"), filepath.Join(root, "chat-5", "peek"))
+	os.Symlink(filepath.Join(root, "chat-5", "sub"), filepath.Join(root, "chat-5", "alias"))
+
+	user := &ToolAuthContext{CallerChannel: "telegram", CallerChatID: 5}
+	control := &ToolAuthContext{CallerChannel: "telegram", CallerChatID: 1, ControlChatIDs: []int64{1}}
+
+	tests := []struct {
+		name      string
+		isolation string
+		auth      *ToolAuthContext
+		path      string
+		want      string // relative to root; empty with wantErr
+		wantErr   bool
+	}{
+		{"chat dir", "chat", user, "", "chat-5", false},
+		{"relative file", "chat", user, "sub/a.txt", "chat-5/sub/a.txt", false},
+		{"dot dot inside own dir", "chat", user, "sub/../b.txt", "chat-5/b.txt", false},
+		{"dot dot into other chat", "chat", user, "../chat-6/x", "", true},
+		{"nested dot dot into other chat", "chat", user, "sub/../../chat-6", "", true},
+		{"absolute other chat", "chat", user, filepath.Join(root, "chat-6", "x"), "", true},
+		{"workspace root", "chat", user, "..", "", true},
+		{"symlink into other chat", "chat", user, "peek/x", "", true},
+		{"symlink to new file in other chat", "chat", user, "peek/new/deeper.txt", "", true},
+		{"symlink within own dir", "chat", user, "alias/x", "chat-5/alias/x", false},
+		{"control chat reaches other chat", "chat", control, "../chat-6/x", "chat-6/x", false},
+		{"no auth uses root", "chat", nil, "chat-6/x", "chat-6/x", false},
+		{"shared root", "shared", user, "", ".", false},
+		{"shared any chat dir", "shared", user, "chat-6/x", "chat-6/x", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := NewWorkspace(root, tc.isolation)
+			got, err := w.Resolve(tc.auth, tc.path)
+			if tc.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "another chat's workspace") {
+					t.Fatalf("Resolve(%q) = %q, %v; want denial", tc.path, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q): %v", tc.path, err)
+			}
+			if want := filepath.Join(root, tc.want); got != want {
+				t.Errorf("Resolve(%q) = %q, want %q", tc.path, got, want)
+			}
+		})
+	}
+
+	// Paths outside the workspace are left to the path policy.
+	if got, err := NewWorkspace(root, "chat").Resolve(user, outside); err != nil || got != outside {
+		t.Errorf("Resolve(outside) = %q, %v", got, err)
+	}
+}
+
+func TestWorkspaceDirForError(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(root, nil, 0o644)
+	w := NewWorkspace(root, "chat")
+	if _, err := w.DirFor(&ToolAuthContext{CallerChatID: 5}); err == nil {
+		t.Fatal("DirFor succeeded under a regular file")
+	}
+	if _, err := w.Resolve(&ToolAuthContext{CallerChatID: 5}, "x"); err == nil {
+		t.Fatal("Resolve succeeded without a working directory")
+	}
+}
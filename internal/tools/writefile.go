@@ -11,11 +11,12 @@ import (
 )
 
 type WriteFileTool struct {
-	workingDir string
+	workspace *Workspace
+	policy    *PathPolicy
 }
 
-func NewWriteFileTool(workingDir string) *WriteFileTool {
-	return &WriteFileTool{workingDir: workingDir}
+func NewWriteFileTool(workspace *Workspace, policy *PathPolicy) *WriteFileTool {
+	return &WriteFileTool{workspace: workspace, policy: policy}
 }
 
 func (t *WriteFileTool) Name() string { return "write_file" }
@@ -43,8 +44,12 @@ func (t *WriteFileTool) Execute(_ context.Context, input json.RawMessage) ToolRe
 		return Error("path is required")
 	}
 
-	path := resolvePath(t.workingDir, params.Path)
-	if err := CheckPath(path); err != nil {
+	auth := ExtractAuthContext(input)
+	path, err := t.workspace.Resolve(auth, params.Path)
+	if err != nil {
+		return Error(err.Error())
+	}
+	if err := t.policy.Enforce(auth, t.Name(), path, true); err != nil {
 		return Error(err.Error())
 	}
 
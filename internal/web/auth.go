@@ -4,11 +4,15 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"log"
+	"net"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/yifanes/miniclawd/internal/storage"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -30,6 +34,7 @@ func (s *WebState) handleSetPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if len(body.Password) < 8 {
+		s.auditAuth(r, "set_password", "failed", "password too short")
 		jsonError(w, "password must be at least 8 characters", http.StatusBadRequest)
 		return
 	}
@@ -44,6 +49,7 @@ func (s *WebState) handleSetPassword(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, "database error", http.StatusInternalServerError)
 		return
 	}
+	s.auditAuth(r, "set_password", "ok", "")
 
 	jsonOK(w, map[string]string{"status": "ok"})
 }
@@ -59,11 +65,13 @@ func (s *WebState) handleLogin(w http.ResponseWriter, r *http.Request) {
 
 	hash, found, _ := s.DB.GetAuthPasswordHash()
 	if !found {
+		s.auditAuth(r, "login", "failed", "no password set")
 		jsonError(w, "no password set", http.StatusBadRequest)
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(body.Password)); err != nil {
+		s.auditAuth(r, "login", "failed", "invalid password")
 		jsonError(w, "invalid password", http.StatusUnauthorized)
 		return
 	}
@@ -72,6 +80,7 @@ func (s *WebState) handleLogin(w http.ResponseWriter, r *http.Request) {
 	sessionID := generateSessionID()
 	expiresAt := time.Now().UTC().Add(24 * time.Hour).Format(time.RFC3339)
 	s.DB.CreateAuthSession(sessionID, "web_login", expiresAt)
+	s.auditAuth(r, "login", "ok", "")
 
 	http.SetCookie(w, &http.Cookie{
 		Name:     "mc_session",
@@ -90,6 +99,7 @@ func (s *WebState) handleLogout(w http.ResponseWriter, r *http.Request) {
 	if err == nil {
 		s.DB.RevokeAuthSession(cookie.Value)
 	}
+	s.auditAuth(r, "logout", "ok", "")
 
 	http.SetCookie(w, &http.Cookie{
 		Name:   "mc_session",
@@ -121,7 +131,7 @@ func (s *WebState) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rawKey := generateSessionID() + generateSessionID()
-	keyHash := hashAPIKey(rawKey)
+	keyHash := storage.HashAPIKey(rawKey)
 	prefix := rawKey[:8]
 
 	id, err := s.DB.CreateAPIKey(body.Label, keyHash, prefix, body.Scopes, nil, nil)
@@ -129,6 +139,7 @@ func (s *WebState) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, "database error", http.StatusInternalServerError)
 		return
 	}
+	s.auditAuth(r, "create_api_key", "ok", fmt.Sprintf("id=%d label=%q prefix=%s scopes=%v", id, body.Label, prefix, body.Scopes))
 
 	jsonOK(w, map[string]any{
 		"id":     id,
@@ -149,10 +160,26 @@ func (s *WebState) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, "database error", http.StatusInternalServerError)
 		return
 	}
+	s.auditAuth(r, "revoke_api_key", "ok", fmt.Sprintf("id=%d", id))
 
 	jsonOK(w, map[string]string{"status": "ok"})
 }
 
+// auditAuth records a web authentication event in audit_logs.
+func (s *WebState) auditAuth(r *http.Request, action, status, detail string) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	var d *string
+	if detail != "" {
+		d = &detail
+	}
+	if err := s.DB.LogAuditEvent("auth", "web:"+host, action, nil, status, d); err != nil {
+		log.Printf("[web] audit log write failed: %v", err)
+	}
+}
+
 func generateSessionID() string {
 	b := make([]byte, 32)
 	for i := range b {
@@ -160,8 +187,3 @@ func generateSessionID() string {
 	}
 	return hex.EncodeToString(sha256.New().Sum(b))[:32]
 }
-
-func hashAPIKey(key string) string {
-	h := sha256.Sum256([]byte(key))
-	return hex.EncodeToString(h[:])
-}
@@ -81,6 +81,9 @@ func StartWebServer(ctx context.Context, cfg *config.Config, db *storage.Databas
 	r.Get("/api/metrics", state.handleMetrics)
 	r.Get("/api/metrics/history", state.handleMetricsHistory)
 
+	// Audit log.
+	r.Get("/api/audit", state.handleAudit)
+
 	addr := fmt.Sprintf("%s:%d", cfg.WebHost, cfg.WebPort)
 	server := &http.Server{
 		Addr:    addr,
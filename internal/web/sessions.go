@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"strconv"
 	"time"
+
+	"github.com/yifanes/miniclawd/internal/storage"
 )
 
 func (s *WebState) handleListSessions(w http.ResponseWriter, r *http.Request) {
@@ -38,6 +40,7 @@ func (s *WebState) handleResetSession(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, "database error", http.StatusInternalServerError)
 		return
 	}
+	s.Deps.Processes.KillChat(chatID)
 
 	jsonOK(w, map[string]string{"status": "ok"})
 }
@@ -93,3 +96,36 @@ func (s *WebState) handleMetricsHistory(w http.ResponseWriter, r *http.Request)
 
 	jsonOK(w, points)
 }
+
+func (s *WebState) handleAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := storage.AuditFilter{Kind: q.Get("kind"), Action: q.Get("tool"), Status: q.Get("status")}
+	if v := q.Get("chat_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			jsonError(w, "invalid chat_id", http.StatusBadRequest)
+			return
+		}
+		f.ChatID = &id
+	}
+	var err error
+	if f.Since, err = storage.ParseTimeBound(q.Get("since")); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if f.Until, err = storage.ParseTimeBound(q.Get("until")); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.Limit = 100
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 {
+		f.Limit = min(l, 1000)
+	}
+
+	logs, err := s.DB.QueryAuditLogs(f)
+	if err != nil {
+		jsonError(w, "database error", http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, logs)
+}
@@ -113,6 +113,12 @@ func (s *WebState) handleSendStream(w http.ResponseWriter, r *http.Request) {
 			data["is_error"] = event.IsError
 			data["preview"] = event.Preview
 			data["duration_ms"] = event.DurationMs
+		case "file_diff":
+			data["name"] = event.Name
+			data["path"] = event.Path
+			data["diff"] = event.Diff
+			data["added"] = event.Added
+			data["removed"] = event.Removed
 		case "text_delta":
 			data["delta"] = event.Delta
 		case "final_response":